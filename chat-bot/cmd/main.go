@@ -2,10 +2,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/Mahaveer86619/ImaginAI/internal/catalog"
+	"github.com/Mahaveer86619/ImaginAI/internal/clients"
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/Mahaveer86619/ImaginAI/internal/database"
+	"github.com/Mahaveer86619/ImaginAI/internal/generation"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/openaicompat"
+	"github.com/Mahaveer86619/ImaginAI/internal/middleware"
+	"github.com/Mahaveer86619/ImaginAI/internal/secrets"
 	"github.com/Mahaveer86619/ImaginAI/internal/server"
+	"github.com/Mahaveer86619/ImaginAI/internal/users"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,8 +31,60 @@ func main() {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.SetLevel(logrus.InfoLevel)
 
+	if config.GetEnv("JWT_SECRET", "") == "" {
+		logrus.Fatal("JWT_SECRET must be set to the same value as the server's")
+	}
+	if _, err := secrets.GetKeyring(); err != nil {
+		logrus.WithError(err).Fatal("Error configuring encryption keys")
+	}
+	if err := middleware.LoadCORSPolicy().Validate(); err != nil {
+		logrus.WithError(err).Fatal("Error configuring CORS")
+	}
+
+	// The users table is shared with the server, which owns it
+	db, err := database.Connect()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error connecting to database")
+	}
+	defer db.Close()
+
+	// Conversations reference the users table, so the server must have created it
+	if err := database.WaitForUsers(context.Background(), db); err != nil {
+		logrus.WithError(err).Fatal("Error waiting for the server's tables")
+	}
+	if err := database.CreateTables(db); err != nil {
+		logrus.WithError(err).Fatal("Error creating/verifying tables")
+	}
+
 	ctx := context.Background()
-	srv := server.New(ctx) // This instance must be reused for all requests
+	srv := server.New(ctx, db) // This instance must be reused for all requests
+
+	generationDefaults, err := generation.DefaultsFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error configuring generation defaults")
+	}
+	srv.GenerationDefaults = generationDefaults
+
+	modelCatalog, err := catalog.Load()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error loading model catalog")
+	}
+	srv.Catalog = modelCatalog
+
+	if err := configureProvider(ctx, srv); err != nil {
+		logrus.WithError(err).Fatal("Error configuring the model provider")
+	}
+
+	// Evict idle clients, the clients of users who changed their key on the
+	// server, and the generations no client may resume anymore
+	go srv.Clients.Run(ctx)
+	go srv.Generations.Run(ctx)
+	go func() {
+		err := users.ListenKeyChanges(ctx, database.URL(), srv.Clients.InvalidateUser, srv.Clients.Reset)
+		if err != nil {
+			logrus.WithError(err).Error("Error listening for Gemini API key changes")
+		}
+	}()
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -35,6 +100,75 @@ func main() {
 	}
 }
 
+// configureProvider sets the default provider of the chat-bot from
+// LLM_PROVIDER: gemini (the default) with the optional GEMINI_API_KEY, openai
+// with OPENAI_BASE_URL and OPENAI_API_KEY for any server speaking the OpenAI
+// API, ollama with OLLAMA_BASE_URL, or fake with the optional script of
+// FAKE_PROVIDER_SCRIPT. Users' own Gemini API keys are only used with gemini.
+func configureProvider(ctx context.Context, srv *server.GenAIServer) error {
+	kind := config.GetEnv("LLM_PROVIDER", "gemini")
+	srv.UserKeys = kind == "gemini"
+
+	var provider llm.Provider
+	switch kind {
+	case "gemini":
+		// Optional default key for users who have not stored one
+		apiKey := config.GetEnv("GEMINI_API_KEY", "")
+		if apiKey == "" {
+			return nil
+		}
+		gemini, err := clients.NewGeminiClient(ctx, apiKey)
+		if err != nil {
+			return fmt.Errorf("error creating default Gemini client: %w", err)
+		}
+		provider = gemini
+	case "openai":
+		provider = openaicompat.New(config.GetEnv("OPENAI_BASE_URL", openaicompat.OpenAIBaseURL), config.GetEnv("OPENAI_API_KEY", ""))
+	case "ollama":
+		provider = openaicompat.New(config.GetEnv("OLLAMA_BASE_URL", openaicompat.OllamaBaseURL), "")
+	case "fake":
+		script := fake.New()
+		if path := config.GetEnv("FAKE_PROVIDER_SCRIPT", ""); path != "" {
+			var err error
+			if script, err = fake.Load(path); err != nil {
+				return err
+			}
+		}
+		// The fake provider replies with any model, there is nothing to check
+		srv.SetDefaultProvider(script)
+		return nil
+	default:
+		return fmt.Errorf("unknown LLM_PROVIDER %q, expected gemini, openai, ollama or fake", kind)
+	}
+
+	srv.SetDefaultProvider(provider)
+	go warnMissingModels(ctx, provider, srv.Catalog)
+	return nil
+}
+
+// warnMissingModels logs the enabled models of the catalog that provider does
+// not serve, chats with them would fail
+func warnMissingModels(ctx context.Context, provider llm.Provider, modelCatalog *catalog.Catalog) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	served, err := provider.ListModels(ctx)
+	if err != nil {
+		logrus.WithError(err).Warn("Error listing the models of the provider")
+		return
+	}
+
+	ids := map[string]bool{}
+	for _, model := range served {
+		ids[model.ID] = true
+	}
+	for _, model := range modelCatalog.Enabled() {
+		if !ids[model.ID] {
+			logrus.WithField("model", model.ID).Warn("Model of the catalog is not served by the provider")
+		}
+	}
+}
+
 // func initGeminiClient(apiKey string) {
 // 	ctx := context.Background()
 // 	fmt.Print("Creating Gemini client...", apiKey)
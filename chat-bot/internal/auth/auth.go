@@ -0,0 +1,152 @@
+// Package auth validates the access tokens issued by the server, so that the
+// chat-bot knows which user is calling without a login of its own.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/Mahaveer86619/ImaginAI/internal/users"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Roles granted by the server
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// TokenTypeAccess is the type of the server's access tokens. Its refresh
+// tokens are signed with the same key and must not be accepted in their place.
+const TokenTypeAccess = "access"
+
+// Claims mirrors the claims of the server's tokens
+type Claims struct {
+	UserID       string `json:"user_id"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"token_version"`
+	TokenType    string `json:"token_type"`
+	jwt.RegisteredClaims
+}
+
+type contextKey string
+
+const claimsContextKey = contextKey("claims")
+
+// TokenStates looks up whether a user's tokens are still accepted, see users.Store
+type TokenStates interface {
+	TokenState(ctx context.Context, userID string) (*users.TokenState, error)
+}
+
+// Authenticator checks bearer tokens signed with the JWT_SECRET shared with the server
+type Authenticator struct {
+	secret []byte
+	users  TokenStates
+}
+
+func NewAuthenticator(store TokenStates) *Authenticator {
+	return &Authenticator{
+		secret: []byte(config.GetEnv("JWT_SECRET", "")),
+		users:  store,
+	}
+}
+
+// Middleware rejects requests without a valid, unrevoked access token and
+// stores the token claims in the request context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := a.parse(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		// Tokens of disabled or deleted users and revoked tokens are rejected before they expire
+		state, err := a.users.TokenState(r.Context(), claims.UserID)
+		if err != nil {
+			if errors.Is(err, users.ErrNotFound) {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			logrus.WithError(err).Error("Error checking token")
+			http.Error(w, "Failed to check token", http.StatusInternalServerError)
+			return
+		}
+		if state.Disabled || state.TokenVersion != claims.TokenVersion {
+			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a *Authenticator) parse(tokenString string) (*Claims, error) {
+	if len(a.secret) == 0 {
+		return nil, errors.New("JWT_SECRET is not configured")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if claims.TokenType != TokenTypeAccess {
+		return nil, errors.New("not an access token")
+	}
+
+	if _, err := uuid.Parse(claims.UserID); err != nil {
+		return nil, errors.New("token has no user")
+	}
+	return claims, nil
+}
+
+// TokenFromQuery lets clients that cannot set headers, such as browsers
+// opening a WebSocket, pass their access token as the access_token query
+// parameter. It must run before Middleware.
+func TokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole only lets through requests whose token carries one of roles.
+// It must run after Middleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok || !slices.Contains(roles, claims.Role) {
+				http.Error(w, "You are not allowed to access this resource", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClaimsFromContext returns the token claims stored by Middleware
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok
+}
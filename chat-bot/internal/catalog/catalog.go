@@ -0,0 +1,173 @@
+// Package catalog lists the models users may chat with and the plans each
+// model is available on.
+package catalog
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+)
+
+//go:embed models.json
+var defaultCatalog []byte
+
+var (
+	// ErrUnknownModel is returned for models missing from the catalog or disabled
+	ErrUnknownModel = errors.New("unknown model")
+	// ErrModelNotOnPlan is returned for models the user's plan does not include
+	ErrModelNotOnPlan = errors.New("model is not available on your plan")
+)
+
+// Pricing is in US dollars per million tokens
+type Pricing struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+type Model struct {
+	ID               string   `json:"id"`
+	DisplayName      string   `json:"display_name"`
+	ContextWindow    int      `json:"context_window"`
+	OutputTokenLimit int32    `json:"output_token_limit"`
+	Capabilities     []string `json:"capabilities"`
+	Pricing          Pricing  `json:"pricing"`
+	// Plans lists the plans the model is available on, every plan when empty
+	Plans   []string `json:"plans,omitempty"`
+	Enabled bool     `json:"enabled"`
+}
+
+// Supports reports whether m lists capability, such as "vision"
+func (m *Model) Supports(capability string) bool {
+	return slices.Contains(m.Capabilities, capability)
+}
+
+// AvailableOn reports whether users on plan may chat with m
+func (m *Model) AvailableOn(plan string) bool {
+	return len(m.Plans) == 0 || slices.Contains(m.Plans, plan)
+}
+
+type Catalog struct {
+	DefaultModel string  `json:"default_model"`
+	Models       []Model `json:"models"`
+}
+
+// Load returns the catalog of MODEL_CATALOG_PATH, or the embedded catalog when
+// it is unset. DEFAULT_MODEL overrides the catalog's default model.
+func Load() (*Catalog, error) {
+	data := defaultCatalog
+	if path := config.GetEnv("MODEL_CATALOG_PATH", ""); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("error reading model catalog: %w", err)
+		}
+	}
+
+	c, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if model := config.GetEnv("DEFAULT_MODEL", ""); model != "" {
+		c.DefaultModel = model
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Default returns the embedded catalog
+func Default() *Catalog {
+	c, err := Parse(defaultCatalog)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Parse decodes and checks a catalog
+func Parse(data []byte) (*Catalog, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	c := &Catalog{}
+	if err := decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("error decoding model catalog: %w", err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Catalog) validate() error {
+	seen := map[string]bool{}
+	for _, m := range c.Models {
+		if m.ID == "" {
+			return errors.New("model catalog has a model without id")
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("model %q is listed more than once in the catalog", m.ID)
+		}
+		seen[m.ID] = true
+	}
+
+	if m := c.Get(c.DefaultModel); m == nil {
+		return fmt.Errorf("default model %q is not an enabled model of the catalog", c.DefaultModel)
+	}
+	return nil
+}
+
+// Get returns the enabled model id, or nil
+func (c *Catalog) Get(id string) *Model {
+	for i := range c.Models {
+		if c.Models[i].ID == id && c.Models[i].Enabled {
+			return &c.Models[i]
+		}
+	}
+	return nil
+}
+
+// Enabled returns every model users may be offered
+func (c *Catalog) Enabled() []Model {
+	var models []Model
+	for _, m := range c.Models {
+		if m.Enabled {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// DefaultFor returns the model used when a chat names none: the user's default
+// model while it is still available to them, the catalog's default otherwise.
+// The catalog's default is used on every plan.
+func (c *Catalog) DefaultFor(plan string, userDefault string) *Model {
+	if m := c.Get(userDefault); m != nil && m.AvailableOn(plan) {
+		return m
+	}
+	return c.Get(c.DefaultModel)
+}
+
+// Resolve returns the model a chat requesting id runs on for a user on plan,
+// falling back to DefaultFor when id is empty
+func (c *Catalog) Resolve(id string, plan string, userDefault string) (*Model, error) {
+	if id == "" {
+		return c.DefaultFor(plan, userDefault), nil
+	}
+
+	m := c.Get(id)
+	if m == nil {
+		return nil, ErrUnknownModel
+	}
+	if !m.AvailableOn(plan) {
+		return nil, ErrModelNotOnPlan
+	}
+	return m, nil
+}
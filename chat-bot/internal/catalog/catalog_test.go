@@ -0,0 +1,114 @@
+package catalog
+
+import (
+	"errors"
+	"testing"
+)
+
+// testCatalog has a model on every plan, one on the free plan, one on the pro
+// plan and a disabled one
+const testCatalog = `{
+	"default_model": "flash",
+	"models": [
+		{"id": "flash", "enabled": true},
+		{"id": "lite", "plans": ["free"], "enabled": true},
+		{"id": "pro", "plans": ["pro"], "enabled": true},
+		{"id": "retired", "enabled": false}
+	]
+}`
+
+func TestResolve(t *testing.T) {
+	c, err := Parse([]byte(testCatalog))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		id          string
+		plan        string
+		userDefault string
+		want        string
+		err         error
+	}{
+		{name: "model on every plan", id: "flash", plan: "free", want: "flash"},
+		{name: "model on the plan", id: "pro", plan: "pro", want: "pro"},
+		{name: "model on another plan", id: "pro", plan: "free", err: ErrModelNotOnPlan},
+		{name: "model without a plan", id: "lite", plan: "", err: ErrModelNotOnPlan},
+		{name: "unknown model", id: "gpt-4o", plan: "pro", err: ErrUnknownModel},
+		{name: "disabled model", id: "retired", plan: "pro", err: ErrUnknownModel},
+		{name: "no model", plan: "free", want: "flash"},
+		{name: "no model with a user default", plan: "pro", userDefault: "pro", want: "pro"},
+		{name: "no model with a user default no longer on the plan", plan: "free", userDefault: "pro", want: "flash"},
+		{name: "no model with a disabled user default", plan: "pro", userDefault: "retired", want: "flash"},
+		{name: "no model with an unknown user default", plan: "pro", userDefault: "gpt-4o", want: "flash"},
+		{name: "user default ignored when a model is named", id: "lite", plan: "free", userDefault: "flash", want: "lite"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := c.Resolve(tt.id, tt.plan, tt.userDefault)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if m != nil {
+					t.Errorf("Resolve() = %s along with an error", m.ID)
+				}
+				return
+			}
+			if m == nil || m.ID != tt.want {
+				t.Errorf("Resolve() = %v, want %s", m, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvailableOn(t *testing.T) {
+	tests := []struct {
+		plans []string
+		plan  string
+		want  bool
+	}{
+		{plans: nil, plan: "free", want: true},
+		{plans: nil, plan: "", want: true},
+		{plans: []string{"free", "pro"}, plan: "pro", want: true},
+		{plans: []string{"pro"}, plan: "free", want: false},
+		{plans: []string{"pro"}, plan: "Pro", want: false},
+	}
+
+	for _, tt := range tests {
+		m := &Model{ID: "m", Plans: tt.plans}
+		if got := m.AvailableOn(tt.plan); got != tt.want {
+			t.Errorf("AvailableOn(%q) of a model on %v = %t, want %t", tt.plan, tt.plans, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog string
+		wantErr bool
+	}{
+		{name: "valid", catalog: testCatalog},
+		{name: "disabled default", catalog: `{"default_model": "retired", "models": [{"id": "retired"}]}`, wantErr: true},
+		{name: "unknown default", catalog: `{"default_model": "pro", "models": [{"id": "flash", "enabled": true}]}`, wantErr: true},
+		{name: "model without id", catalog: `{"default_model": "flash", "models": [{"id": "flash", "enabled": true}, {"enabled": true}]}`, wantErr: true},
+		{name: "duplicate model", catalog: `{"default_model": "flash", "models": [{"id": "flash", "enabled": true}, {"id": "flash"}]}`, wantErr: true},
+		{name: "unknown field", catalog: `{"default_model": "flash", "models": [{"id": "flash", "enabled": true, "price": 1}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.catalog)); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Parse() error = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDefaultCatalog(t *testing.T) {
+	c := Default()
+	if m := c.Get(c.DefaultModel); m == nil || !m.AvailableOn("free") {
+		t.Errorf("default model %q is not available on the free plan", c.DefaultModel)
+	}
+}
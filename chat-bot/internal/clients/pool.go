@@ -0,0 +1,264 @@
+// Package clients caches the providers created for users' Gemini API keys, so
+// that chats under the same key reuse one client instead of creating one per
+// request.
+package clients
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/gemini"
+	"golang.org/x/sync/singleflight"
+)
+
+// Fingerprint identifies an API key without keeping the key itself as a map key
+func Fingerprint(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewClientFunc creates the client for an API key
+type NewClientFunc func(ctx context.Context, apiKey string) (llm.Provider, error)
+
+type entry struct {
+	fingerprint string
+	client      llm.Provider
+	lastUsed    time.Time
+	// users holds the users whose current key has this fingerprint
+	users map[string]struct{}
+}
+
+// Pool is a bounded cache of Gemini clients keyed by API key fingerprint.
+// The least recently used client is evicted when the pool is full, and
+// clients unused for IdleTTL are evicted by Run.
+type Pool struct {
+	MaxSize   int
+	IdleTTL   time.Duration
+	NewClient NewClientFunc
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru is ordered from most to least recently used
+	lru *list.List
+	// byUser maps a user id to the fingerprint of the key they last used
+	byUser map[string]string
+	// generations counts the invalidations of each user since the last Reset,
+	// and epoch the resets, so that clients created meanwhile are not cached
+	generations map[string]uint64
+	epoch       uint64
+	group       singleflight.Group
+}
+
+// NewPool returns a pool creating clients with newClient
+func NewPool(maxSize int, idleTTL time.Duration, newClient NewClientFunc) *Pool {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	return &Pool{
+		MaxSize:     maxSize,
+		IdleTTL:     idleTTL,
+		NewClient:   newClient,
+		entries:     map[string]*list.Element{},
+		lru:         list.New(),
+		byUser:      map[string]string{},
+		generations: map[string]uint64{},
+	}
+}
+
+// NewPoolFromEnv builds a pool from CLIENT_POOL_SIZE (default 100) and
+// CLIENT_POOL_IDLE_TTL (default 30m)
+func NewPoolFromEnv(newClient NewClientFunc) *Pool {
+	return NewPool(
+		config.GetEnvInt("CLIENT_POOL_SIZE", 100),
+		config.GetEnvDuration("CLIENT_POOL_IDLE_TTL", 30*time.Minute),
+		newClient,
+	)
+}
+
+// NewGeminiClient creates a provider for the Gemini API
+func NewGeminiClient(ctx context.Context, apiKey string) (llm.Provider, error) {
+	return gemini.New(ctx, apiKey)
+}
+
+// Get returns the client for apiKey on behalf of userID, creating it when it
+// is not cached. Concurrent calls for the same key create a single client.
+func (p *Pool) Get(ctx context.Context, userID string, apiKey string) (llm.Provider, error) {
+	fingerprint := Fingerprint(apiKey)
+
+	p.mu.Lock()
+	if client, ok := p.lookup(fingerprint, userID, time.Now()); ok {
+		p.mu.Unlock()
+		return client, nil
+	}
+	epoch, generation := p.epoch, p.generations[userID]
+	p.mu.Unlock()
+
+	created, err, _ := p.group.Do(fingerprint, func() (interface{}, error) {
+		// The client outlives the request that created it
+		return p.NewClient(context.WithoutCancel(ctx), apiKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := created.(llm.Provider)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The key was read before the user changed it, so the client serves this
+	// request but must not come back once the invalidation has been handled
+	if p.epoch != epoch || p.generations[userID] != generation {
+		return client, nil
+	}
+
+	// Another caller may have stored the client while this one was waiting
+	if cached, ok := p.lookup(fingerprint, userID, time.Now()); ok {
+		return cached, nil
+	}
+
+	e := &entry{
+		fingerprint: fingerprint,
+		client:      client,
+		lastUsed:    time.Now(),
+		users:       map[string]struct{}{},
+	}
+	p.entries[fingerprint] = p.lru.PushFront(e)
+	p.bind(userID, e)
+
+	for p.lru.Len() > p.MaxSize {
+		p.remove(p.lru.Back())
+	}
+
+	return client, nil
+}
+
+// InvalidateUser forgets the key userID last used, evicting its client unless
+// another user's key has the same fingerprint. It is called when the user
+// changes or removes their key.
+func (p *Pool) InvalidateUser(userID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.generations[userID]++
+
+	fingerprint, ok := p.byUser[userID]
+	if !ok {
+		return
+	}
+	delete(p.byUser, userID)
+
+	if element, ok := p.entries[fingerprint]; ok {
+		e := element.Value.(*entry)
+		delete(e.users, userID)
+		if len(e.users) == 0 {
+			p.remove(element)
+		}
+	}
+}
+
+// Reset evicts every client, for when invalidations may have been missed
+func (p *Pool) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.entries = map[string]*list.Element{}
+	p.lru.Init()
+	p.byUser = map[string]string{}
+	p.generations = map[string]uint64{}
+	p.epoch++
+}
+
+// Len returns the number of cached clients
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lru.Len()
+}
+
+// EvictIdle evicts the clients unused for longer than IdleTTL and returns how many were evicted
+func (p *Pool) EvictIdle(now time.Time) int {
+	if p.IdleTTL <= 0 {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	evicted := 0
+	for element := p.lru.Back(); element != nil; element = p.lru.Back() {
+		if now.Sub(element.Value.(*entry).lastUsed) <= p.IdleTTL {
+			break
+		}
+		p.remove(element)
+		evicted++
+	}
+	return evicted
+}
+
+// Run evicts idle clients until ctx is done
+func (p *Pool) Run(ctx context.Context) {
+	if p.IdleTTL <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.IdleTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.EvictIdle(now)
+		}
+	}
+}
+
+// lookup returns the cached client for fingerprint, marking it as used by userID.
+// p.mu must be held.
+func (p *Pool) lookup(fingerprint string, userID string, now time.Time) (llm.Provider, bool) {
+	element, ok := p.entries[fingerprint]
+	if !ok {
+		return nil, false
+	}
+
+	e := element.Value.(*entry)
+	if p.IdleTTL > 0 && now.Sub(e.lastUsed) > p.IdleTTL {
+		p.remove(element)
+		return nil, false
+	}
+
+	e.lastUsed = now
+	p.lru.MoveToFront(element)
+	p.bind(userID, e)
+	return e.client, true
+}
+
+// bind records that userID now uses the key of e, releasing the key they used before.
+// p.mu must be held.
+func (p *Pool) bind(userID string, e *entry) {
+	if previous, ok := p.byUser[userID]; ok && previous != e.fingerprint {
+		if element, ok := p.entries[previous]; ok {
+			delete(element.Value.(*entry).users, userID)
+		}
+	}
+	p.byUser[userID] = e.fingerprint
+	e.users[userID] = struct{}{}
+}
+
+// remove evicts the client of element. p.mu must be held.
+func (p *Pool) remove(element *list.Element) {
+	e := p.lru.Remove(element).(*entry)
+	delete(p.entries, e.fingerprint)
+	for userID := range e.users {
+		if p.byUser[userID] == e.fingerprint {
+			delete(p.byUser, userID)
+		}
+	}
+}
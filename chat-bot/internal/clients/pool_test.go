@@ -0,0 +1,240 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+)
+
+// counter creates fake clients and counts how many it created per key
+type counter struct {
+	mu      sync.Mutex
+	created map[string]int
+}
+
+func newCounter() *counter {
+	return &counter{created: map[string]int{}}
+}
+
+func (c *counter) newClient(ctx context.Context, apiKey string) (llm.Provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.created[apiKey]++
+	return fake.New(), nil
+}
+
+func (c *counter) count(apiKey string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.created[apiKey]
+}
+
+func get(t *testing.T, p *Pool, userID string, apiKey string) llm.Provider {
+	t.Helper()
+	client, err := p.Get(context.Background(), userID, apiKey)
+	if err != nil {
+		t.Fatalf("Get(%s, %s) error = %v", userID, apiKey, err)
+	}
+	return client
+}
+
+func TestPoolReusesClients(t *testing.T) {
+	c := newCounter()
+	p := NewPool(10, time.Hour, c.newClient)
+
+	first := get(t, p, "ada", "key-a")
+	if second := get(t, p, "ada", "key-a"); second != first {
+		t.Error("second Get returned another client")
+	}
+	if c.count("key-a") != 1 || p.Len() != 1 {
+		t.Errorf("created %d clients, pool holds %d, want 1 and 1", c.count("key-a"), p.Len())
+	}
+}
+
+func TestPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCounter()
+	p := NewPool(2, time.Hour, c.newClient)
+
+	get(t, p, "ada", "key-a")
+	get(t, p, "bob", "key-b")
+	get(t, p, "ada", "key-a")
+	get(t, p, "cy", "key-c")
+
+	if p.Len() != 2 {
+		t.Errorf("pool holds %d clients, want 2", p.Len())
+	}
+	get(t, p, "ada", "key-a")
+	if c.count("key-a") != 1 {
+		t.Errorf("key-a was created %d times, want it kept as recently used", c.count("key-a"))
+	}
+	get(t, p, "bob", "key-b")
+	if c.count("key-b") != 2 {
+		t.Errorf("key-b was created %d times, want it evicted as least recently used", c.count("key-b"))
+	}
+}
+
+func TestPoolEvictsIdleClients(t *testing.T) {
+	c := newCounter()
+	p := NewPool(10, time.Minute, c.newClient)
+
+	get(t, p, "ada", "key-a")
+	get(t, p, "bob", "key-b")
+
+	if evicted := p.EvictIdle(time.Now().Add(30 * time.Second)); evicted != 0 {
+		t.Errorf("EvictIdle() before the TTL evicted %d clients", evicted)
+	}
+	if evicted := p.EvictIdle(time.Now().Add(2 * time.Minute)); evicted != 2 || p.Len() != 0 {
+		t.Errorf("EvictIdle() after the TTL evicted %d clients, %d left, want 2 and 0", evicted, p.Len())
+	}
+
+	get(t, p, "ada", "key-a")
+	if c.count("key-a") != 2 {
+		t.Errorf("key-a was created %d times, want it created again after eviction", c.count("key-a"))
+	}
+}
+
+func TestPoolSharesFingerprints(t *testing.T) {
+	c := newCounter()
+	p := NewPool(10, time.Hour, c.newClient)
+
+	ada := get(t, p, "ada", "shared-key")
+	if bob := get(t, p, "bob", "shared-key"); bob != ada {
+		t.Error("users with the same key got different clients")
+	}
+
+	// The client stays cached as long as one of its users still has the key
+	p.InvalidateUser("ada")
+	if p.Len() != 1 {
+		t.Fatalf("pool holds %d clients after one user changed their key, want 1", p.Len())
+	}
+	p.InvalidateUser("bob")
+	if p.Len() != 0 {
+		t.Errorf("pool holds %d clients after every user changed their key, want 0", p.Len())
+	}
+}
+
+func TestPoolReleasesPreviousKey(t *testing.T) {
+	c := newCounter()
+	p := NewPool(10, time.Hour, c.newClient)
+
+	get(t, p, "ada", "old-key")
+	get(t, p, "bob", "old-key")
+	get(t, p, "ada", "new-key")
+
+	// bob still uses the old key, ada's invalidation only evicts her new one
+	p.InvalidateUser("ada")
+	if p.Len() != 1 {
+		t.Errorf("pool holds %d clients, want the old key kept for bob", p.Len())
+	}
+	get(t, p, "bob", "old-key")
+	if c.count("old-key") != 1 {
+		t.Errorf("old-key was created %d times, want 1", c.count("old-key"))
+	}
+}
+
+func TestPoolCreatesOneClientPerKey(t *testing.T) {
+	c := newCounter()
+	release := make(chan struct{})
+	p := NewPool(10, time.Hour, func(ctx context.Context, apiKey string) (llm.Provider, error) {
+		<-release
+		return c.newClient(ctx, apiKey)
+	})
+
+	var wg sync.WaitGroup
+	var clients sync.Map
+	for _, userID := range []string{"ada", "bob", "cy", "dee"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := p.Get(context.Background(), userID, "shared-key")
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+			clients.Store(client, true)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	distinct := 0
+	clients.Range(func(any, any) bool { distinct++; return true })
+	if c.count("shared-key") != 1 || distinct != 1 {
+		t.Errorf("created %d clients and handed out %d, want 1 and 1", c.count("shared-key"), distinct)
+	}
+}
+
+func TestPoolInvalidationDuringCreation(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		invalidate func(p *Pool)
+	}{
+		{"user invalidated", func(p *Pool) { p.InvalidateUser("ada") }},
+		{"pool reset", func(p *Pool) { p.Reset() }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCounter()
+			creating := make(chan struct{})
+			release := make(chan struct{})
+			var blocked atomic.Bool
+			p := NewPool(10, time.Hour, func(ctx context.Context, apiKey string) (llm.Provider, error) {
+				if blocked.CompareAndSwap(false, true) {
+					close(creating)
+					<-release
+				}
+				return c.newClient(ctx, apiKey)
+			})
+
+			done := make(chan llm.Provider)
+			go func() {
+				client, _ := p.Get(context.Background(), "ada", "old-key")
+				done <- client
+			}()
+
+			<-creating
+			tt.invalidate(p)
+			close(release)
+
+			if client := <-done; client == nil {
+				t.Fatal("Get() returned no client to the request that asked for it")
+			}
+			if p.Len() != 0 {
+				t.Errorf("pool holds %d clients, want the client created before the invalidation dropped", p.Len())
+			}
+
+			// Later requests create their own client and cache it again
+			get(t, p, "ada", "old-key")
+			if c.count("old-key") != 2 || p.Len() != 1 {
+				t.Errorf("created %d clients, pool holds %d, want 2 and 1", c.count("old-key"), p.Len())
+			}
+		})
+	}
+}
+
+func TestPoolDoesNotCacheErrors(t *testing.T) {
+	calls := 0
+	p := NewPool(10, time.Hour, func(ctx context.Context, apiKey string) (llm.Provider, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("invalid API key")
+		}
+		return fake.New(), nil
+	})
+
+	if _, err := p.Get(context.Background(), "ada", "key-a"); err == nil {
+		t.Fatal("Get() error = nil, want the creation error")
+	}
+	if p.Len() != 0 {
+		t.Errorf("pool holds %d clients after a failed creation, want 0", p.Len())
+	}
+	get(t, p, "ada", "key-a")
+	if calls != 2 {
+		t.Errorf("NewClient was called %d times, want a retry after the failure", calls)
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnv returns the value of the environment variable key, or fallback when it is unset or empty.
+func GetEnv(key string, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvInt returns the integer value of key, or fallback when it is unset or not a number.
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvInt64 returns the int64 value of key, or fallback when it is unset or not a number.
+func GetEnvInt64(key string, fallback int64) int64 {
+	value, err := strconv.ParseInt(GetEnv(key, ""), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvBool returns the boolean value of key, or fallback when it is unset or not a boolean.
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvDuration returns the duration value of key (e.g. "30s", "5m"), or fallback when it is unset or invalid.
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvList splits a comma separated environment variable, dropping empty entries.
+func GetEnvList(key string, fallback []string) []string {
+	raw := GetEnv(key, "")
+	if raw == "" {
+		return fallback
+	}
+
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
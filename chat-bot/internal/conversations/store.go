@@ -0,0 +1,548 @@
+// Package conversations stores users' conversations with the model, so that
+// the history of a chat is loaded server-side instead of being sent by clients.
+package conversations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when the conversation does not exist or belongs to another user
+	ErrNotFound = errors.New("conversation not found")
+	// ErrConflict is returned when the exchange a regenerated reply replaces is no longer the last one
+	ErrConflict = errors.New("conversation changed")
+	// ErrAttachmentNotFound is returned when the attachment is not a file of the conversation
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+// Roles of the stored messages, matching the Gemini roles
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+// MaxTitleLength is the maximum length of a title, in characters
+const MaxTitleLength = 200
+
+// generatedTitleLength is the length of titles derived from the first message
+const generatedTitleLength = 60
+
+// conversationColumns are scanned by scanConversation
+const conversationColumns = `id, title, created_at, updated_at, COALESCE(persona_id::TEXT, ''), COALESCE(persona_version, 0), system`
+
+// SystemSettings are the system instructions a conversation runs with
+type SystemSettings struct {
+	PersonaID      string
+	PersonaVersion int
+	System         string
+}
+
+// Exchange is a user message and the model's reply. FinishReason tells why
+// the reply ended, partial replies of cancelled generations are saved too.
+type Exchange struct {
+	// ConversationID is empty to start a new conversation
+	ConversationID string
+	// Settings are the system settings the reply was generated with
+	Settings    SystemSettings
+	UserMessage string
+	// UserParts are the text and files of a user message with files. The
+	// data of the files is stored as attachments, the parts without it.
+	UserParts    []models.MessagePart
+	ModelMessage string
+	FinishReason string
+	// ReplaceLast replaces the last exchange of the conversation, which must
+	// have the same user message, with a regenerated reply
+	ReplaceLast bool
+}
+
+// Attachment is a file sent with a message
+type Attachment struct {
+	ID       string
+	MIMEType string
+	Name     string
+	Data     []byte
+}
+
+type Store struct {
+	DB *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+// Create starts an empty conversation for userID
+func (s *Store) Create(ctx context.Context, userID string, title string) (*models.Conversation, error) {
+	query := `
+		INSERT INTO conversations (id, user_id, title, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING ` + conversationColumns + `
+	`
+
+	conversation := &models.Conversation{}
+	err := scanConversation(s.DB.QueryRowContext(ctx, query, uuid.NewString(), userID, title), conversation)
+	if err != nil {
+		return nil, fmt.Errorf("error creating conversation: %w", err)
+	}
+
+	return conversation, nil
+}
+
+// List returns a page of the conversations of userID, most recently active
+// first, along with the total number of conversations
+func (s *Store) List(ctx context.Context, userID string, limit int, offset int) ([]*models.Conversation, int, error) {
+	query := `
+		SELECT ` + conversationColumns + `, COUNT(*) OVER()
+		FROM conversations
+		WHERE user_id = $1
+		ORDER BY updated_at DESC, id
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := s.DB.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying conversations: %w", err)
+	}
+	defer rows.Close()
+
+	conversations := []*models.Conversation{}
+	total := 0
+	for rows.Next() {
+		conversation := &models.Conversation{}
+		err := rows.Scan(
+			&conversation.ID, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt,
+			&conversation.PersonaID, &conversation.PersonaVersion, &conversation.System, &total,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning conversation: %w", err)
+		}
+		conversations = append(conversations, conversation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error querying conversations: %w", err)
+	}
+
+	// The window count is missing when the page is past the end
+	if len(conversations) == 0 && offset > 0 {
+		countQuery := `SELECT COUNT(*) FROM conversations WHERE user_id = $1`
+		if err := s.DB.QueryRowContext(ctx, countQuery, userID).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("error counting conversations: %w", err)
+		}
+	}
+
+	return conversations, total, nil
+}
+
+// Get returns a conversation of userID with its messages in order
+func (s *Store) Get(ctx context.Context, userID string, conversationID string) (*models.Conversation, error) {
+	query := `SELECT ` + conversationColumns + ` FROM conversations WHERE id = $1 AND user_id = $2`
+
+	conversation := &models.Conversation{}
+	err := scanConversation(s.DB.QueryRowContext(ctx, query, conversationID, userID), conversation)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error querying conversation: %w", err)
+	}
+
+	conversation.Messages, err = s.messages(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// Rename changes the title of a conversation of userID
+func (s *Store) Rename(ctx context.Context, userID string, conversationID string, title string) (*models.Conversation, error) {
+	query := `
+		UPDATE conversations SET title = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+		RETURNING ` + conversationColumns + `
+	`
+
+	conversation := &models.Conversation{}
+	err := scanConversation(s.DB.QueryRowContext(ctx, query, title, conversationID, userID), conversation)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error renaming conversation: %w", err)
+	}
+
+	return conversation, nil
+}
+
+// Delete removes a conversation of userID and its messages
+func (s *Store) Delete(ctx context.Context, userID string, conversationID string) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM conversations WHERE id = $1 AND user_id = $2`, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting conversation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveExchange appends an exchange to a conversation of userID in one
+// transaction, so that a conversation never ends on an unanswered message,
+// and records the system settings the reply was generated with. A new
+// conversation is titled after the message. It returns the id of the
+// conversation.
+func (s *Store) SaveExchange(ctx context.Context, userID string, exchange Exchange) (string, error) {
+	personaID, personaVersion := nullablePersona(exchange.Settings)
+	conversationID := exchange.ConversationID
+	title := exchangeTitle(exchange)
+
+	var parts interface{}
+	if models.HasFiles(exchange.UserParts) {
+		data, err := json.Marshal(models.WithoutData(exchange.UserParts))
+		if err != nil {
+			return "", fmt.Errorf("error encoding message parts: %w", err)
+		}
+		parts = string(data)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if conversationID == "" {
+		conversationID = uuid.NewString()
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO conversations (id, user_id, title, persona_id, persona_version, system, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		`, conversationID, userID, title, personaID, personaVersion, exchange.Settings.System)
+		if err != nil {
+			return "", fmt.Errorf("error creating conversation: %w", err)
+		}
+	} else {
+		// Locking the conversation serializes concurrent exchanges
+		var locked int
+		err = tx.QueryRowContext(ctx, `SELECT 1 FROM conversations WHERE id = $1 AND user_id = $2 FOR UPDATE`, conversationID, userID).Scan(&locked)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return "", ErrNotFound
+			}
+			return "", fmt.Errorf("error querying conversation: %w", err)
+		}
+
+		// Conversations created empty are titled after their first message
+		_, err = tx.ExecContext(ctx, `
+			UPDATE conversations SET updated_at = NOW(), title = CASE WHEN title = '' THEN $1 ELSE title END,
+				persona_id = $2, persona_version = $3, system = $4
+			WHERE id = $5
+		`, title, personaID, personaVersion, exchange.Settings.System, conversationID)
+		if err != nil {
+			return "", fmt.Errorf("error updating conversation: %w", err)
+		}
+	}
+
+	if exchange.ReplaceLast {
+		if err := deleteLastExchange(ctx, tx, conversationID, exchange.UserMessage); err != nil {
+			return "", err
+		}
+	}
+
+	var position int
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM messages WHERE conversation_id = $1`, conversationID).Scan(&position)
+	if err != nil {
+		return "", fmt.Errorf("error querying messages: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO messages (id, conversation_id, position, role, content, finish_reason, parts, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+	`
+	userMessageID := uuid.NewString()
+	turns := []struct {
+		id, role, content, finishReason string
+		parts                           interface{}
+	}{
+		{userMessageID, RoleUser, exchange.UserMessage, "", parts},
+		{uuid.NewString(), RoleModel, exchange.ModelMessage, exchange.FinishReason, nil},
+	}
+	for i, turn := range turns {
+		if _, err := tx.ExecContext(ctx, insertQuery, turn.id, conversationID, position+i, turn.role, turn.content, turn.finishReason, turn.parts); err != nil {
+			return "", fmt.Errorf("error saving message: %w", err)
+		}
+	}
+
+	attachmentQuery := `
+		INSERT INTO attachments (id, message_id, mime_type, name, size, data, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`
+	for _, part := range exchange.UserParts {
+		if part.Type != models.PartFile {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, attachmentQuery, part.AttachmentID, userMessageID, part.MIMEType, part.Name, len(part.Data), part.Data); err != nil {
+			return "", fmt.Errorf("error saving attachment: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing messages: %w", err)
+	}
+
+	return conversationID, nil
+}
+
+// deleteLastExchange removes the last two messages of a locked conversation,
+// checking that they are userMessage and its reply
+func deleteLastExchange(ctx context.Context, tx *sql.Tx, conversationID string, userMessage string) error {
+	query := `
+		SELECT position, role, content FROM messages
+		WHERE conversation_id = $1
+		ORDER BY position DESC
+		LIMIT 2
+	`
+
+	rows, err := tx.QueryContext(ctx, query, conversationID)
+	if err != nil {
+		return fmt.Errorf("error querying messages: %w", err)
+	}
+	defer rows.Close()
+
+	var last []models.Message
+	var position int
+	for rows.Next() {
+		var message models.Message
+		if err := rows.Scan(&position, &message.Role, &message.Content); err != nil {
+			return fmt.Errorf("error scanning message: %w", err)
+		}
+		last = append(last, message)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error querying messages: %w", err)
+	}
+
+	if len(last) != 2 || last[0].Role != RoleModel || last[1].Role != RoleUser || last[1].Content != userMessage {
+		return ErrConflict
+	}
+
+	// position is the one of the user message, read last
+	if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE conversation_id = $1 AND position >= $2`, conversationID, position); err != nil {
+		return fmt.Errorf("error deleting messages: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) messages(ctx context.Context, conversationID string) ([]models.Message, error) {
+	query := `
+		SELECT id, role, content, finish_reason, parts, created_at FROM messages
+		WHERE conversation_id = $1
+		ORDER BY position
+	`
+
+	rows, err := s.DB.QueryContext(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var message models.Message
+		var parts []byte
+		if err := rows.Scan(&message.ID, &message.Role, &message.Content, &message.FinishReason, &parts, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning message: %w", err)
+		}
+		if parts != nil {
+			if err := json.Unmarshal(parts, &message.Parts); err != nil {
+				return nil, fmt.Errorf("error decoding parts of message %s: %w", message.ID, err)
+			}
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// AttachmentData returns the data of the attachments of a conversation by id,
+// to send the files of its history to the model again
+func (s *Store) AttachmentData(ctx context.Context, conversationID string) (map[string][]byte, error) {
+	query := `
+		SELECT a.id, a.data FROM attachments a
+		JOIN messages m ON m.id = a.message_id
+		WHERE m.conversation_id = $1
+	`
+
+	rows, err := s.DB.QueryContext(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying attachments: %w", err)
+	}
+	defer rows.Close()
+
+	data := map[string][]byte{}
+	for rows.Next() {
+		var id string
+		var content []byte
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, fmt.Errorf("error scanning attachment: %w", err)
+		}
+		data[id] = content
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying attachments: %w", err)
+	}
+
+	return data, nil
+}
+
+// Attachment returns a file sent in a conversation of userID
+func (s *Store) Attachment(ctx context.Context, userID string, conversationID string, attachmentID string) (*Attachment, error) {
+	query := `
+		SELECT a.id, a.mime_type, a.name, a.data FROM attachments a
+		JOIN messages m ON m.id = a.message_id
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE a.id = $1 AND c.id = $2 AND c.user_id = $3
+	`
+
+	attachment := &Attachment{}
+	err := s.DB.QueryRowContext(ctx, query, attachmentID, conversationID, userID).Scan(&attachment.ID, &attachment.MIMEType, &attachment.Name, &attachment.Data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, fmt.Errorf("error querying attachment: %w", err)
+	}
+
+	return attachment, nil
+}
+
+// Match is a message of a conversation matching a search
+type Match struct {
+	ConversationID string    `json:"conversation_id"`
+	Title          string    `json:"title"`
+	Role           string    `json:"role"`
+	Snippet        string    `json:"snippet"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Search returns the messages of the conversations of userID containing
+// query, case-insensitively, most recent first
+func (s *Store) Search(ctx context.Context, userID string, query string, limit int) ([]Match, error) {
+	statement := `
+		SELECT c.id, c.title, m.role, m.content, m.created_at FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE c.user_id = $1 AND m.content ILIKE $2
+		ORDER BY m.created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := s.DB.QueryContext(ctx, statement, userID, "%"+likeEscaper.Replace(query)+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching messages: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []Match{}
+	for rows.Next() {
+		var match Match
+		var content string
+		if err := rows.Scan(&match.ConversationID, &match.Title, &match.Role, &content, &match.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning message: %w", err)
+		}
+		match.Snippet = snippet(content, query)
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error searching messages: %w", err)
+	}
+
+	return matches, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// snippetLength is the length of the snippets of matches, in characters
+const snippetLength = 200
+
+// snippet returns the part of content around the first occurrence of query
+func snippet(content string, query string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	runes := []rune(content)
+	if len(runes) <= snippetLength {
+		return content
+	}
+
+	start := 0
+	lower := strings.ToLower(content)
+	if i := strings.Index(lower, strings.ToLower(query)); i >= 0 {
+		start = max(utf8.RuneCountInString(lower[:i])-snippetLength/4, 0)
+	}
+	end := min(start+snippetLength, len(runes))
+	start = max(end-snippetLength, 0)
+
+	text := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		text = "…" + text
+	}
+	if end < len(runes) {
+		text += "…"
+	}
+	return text
+}
+
+// exchangeTitle is the title of a conversation started by exchange, derived
+// from its message, or from the name of its first file when it has no text
+func exchangeTitle(exchange Exchange) string {
+	if title := GenerateTitle(exchange.UserMessage); title != "" {
+		return title
+	}
+	for _, part := range exchange.UserParts {
+		if part.Type == models.PartFile {
+			return GenerateTitle(part.Name)
+		}
+	}
+	return ""
+}
+
+// GenerateTitle derives a title from the first line of a message
+func GenerateTitle(message string) string {
+	title := strings.TrimSpace(message)
+	if line, _, found := strings.Cut(title, "\n"); found {
+		title = strings.TrimSpace(line)
+	}
+	title = strings.Join(strings.Fields(title), " ")
+
+	if utf8.RuneCountInString(title) > generatedTitleLength {
+		runes := []rune(title)
+		title = strings.TrimSpace(string(runes[:generatedTitleLength])) + "…"
+	}
+	return title
+}
+
+func scanConversation(row *sql.Row, conversation *models.Conversation) error {
+	return row.Scan(
+		&conversation.ID, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt,
+		&conversation.PersonaID, &conversation.PersonaVersion, &conversation.System,
+	)
+}
+
+// nullablePersona returns NULL for both persona columns when settings have no persona
+func nullablePersona(settings SystemSettings) (interface{}, interface{}) {
+	if settings.PersonaID == "" {
+		return nil, nil
+	}
+	return settings.PersonaID, settings.PersonaVersion
+}
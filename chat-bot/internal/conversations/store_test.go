@@ -0,0 +1,211 @@
+package conversations
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingConnector is a database holding no rows, which records every
+// statement run on it
+type recordingConnector struct {
+	mu         sync.Mutex
+	statements []statement
+}
+
+type statement struct {
+	query string
+	args  []interface{}
+}
+
+func (c *recordingConnector) record(query string, args []driver.NamedValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := statement{query: strings.Join(strings.Fields(query), " ")}
+	for _, arg := range args {
+		s.args = append(s.args, arg.Value)
+	}
+	c.statements = append(c.statements, s)
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &recordingConn{c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver {
+	return nil
+}
+
+type recordingConn struct {
+	connector *recordingConnector
+}
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.connector.record(query, args)
+	return emptyRows{}, nil
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.connector.record(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return recordingTx{}, nil
+}
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error   { return nil }
+func (recordingTx) Rollback() error { return nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return []string{"id"} }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
+
+const (
+	ownerID        = "6f1c3b52-0d7e-4f43-9a57-4d1a4c9f1e11"
+	conversationID = "0b6f6a8e-6d55-4c1e-9b7a-2f6f0c3f8d21"
+)
+
+// Every statement reading or changing a conversation by id must be limited to
+// the conversations of the user, so that another user's id reads as missing
+func TestStoreFiltersByOwner(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(s *Store) error
+		// owner is the predicate binding the user of the first statement
+		owner      string
+		err        error
+		statements int
+	}{
+		{
+			name:       "Get",
+			call:       func(s *Store) error { _, err := s.Get(context.Background(), ownerID, conversationID); return err },
+			owner:      "WHERE id = $1 AND user_id = $2",
+			err:        ErrNotFound,
+			statements: 1,
+		},
+		{
+			name: "Rename",
+			call: func(s *Store) error {
+				_, err := s.Rename(context.Background(), ownerID, conversationID, "Renamed")
+				return err
+			},
+			owner:      "WHERE id = $2 AND user_id = $3",
+			err:        ErrNotFound,
+			statements: 1,
+		},
+		{
+			name:       "Delete",
+			call:       func(s *Store) error { return s.Delete(context.Background(), ownerID, conversationID) },
+			owner:      "WHERE id = $1 AND user_id = $2",
+			err:        ErrNotFound,
+			statements: 1,
+		},
+		{
+			name:       "List",
+			call:       func(s *Store) error { _, _, err := s.List(context.Background(), ownerID, 20, 0); return err },
+			owner:      "WHERE user_id = $1",
+			statements: 1,
+		},
+		{
+			name:       "List past the end",
+			call:       func(s *Store) error { _, _, err := s.List(context.Background(), ownerID, 20, 40); return err },
+			owner:      "WHERE user_id = $1",
+			err:        sql.ErrNoRows,
+			statements: 2,
+		},
+		{
+			name: "Attachment",
+			call: func(s *Store) error {
+				_, err := s.Attachment(context.Background(), ownerID, conversationID, "a1")
+				return err
+			},
+			owner:      "WHERE a.id = $1 AND c.id = $2 AND c.user_id = $3",
+			err:        ErrAttachmentNotFound,
+			statements: 1,
+		},
+		{
+			name: "Search",
+			call: func(s *Store) error {
+				_, err := s.Search(context.Background(), ownerID, "recipe", 10)
+				return err
+			},
+			owner:      "WHERE c.user_id = $1",
+			statements: 1,
+		},
+		{
+			name: "SaveExchange to an existing conversation",
+			call: func(s *Store) error {
+				_, err := s.SaveExchange(context.Background(), ownerID, Exchange{ConversationID: conversationID, UserMessage: "Hi", ModelMessage: "Hello"})
+				return err
+			},
+			owner:      "WHERE id = $1 AND user_id = $2 FOR UPDATE",
+			err:        ErrNotFound,
+			statements: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := &recordingConnector{}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+
+			err := tt.call(NewStore(db))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			if len(connector.statements) != tt.statements {
+				t.Fatalf("ran %d statements, want %d: %v", len(connector.statements), tt.statements, connector.statements)
+			}
+			first := connector.statements[0]
+			if !strings.Contains(first.query, tt.owner) {
+				t.Errorf("query = %s, want it filtered with %q", first.query, tt.owner)
+			}
+			// The user is bound to the last placeholder of the predicate
+			placeholder := tt.owner[strings.LastIndex(tt.owner, "$")+1:]
+			var position int
+			fmt.Sscanf(placeholder, "%d", &position)
+			if position == 0 || first.args[position-1] != ownerID {
+				t.Errorf("$%d = %v, want the user %s", position, first.args[position-1], ownerID)
+			}
+		})
+	}
+}
+
+func TestSaveExchangeCreatesConversationsOfTheUser(t *testing.T) {
+	connector := &recordingConnector{}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	// The empty database answers the position query with no row
+	_, err := NewStore(db).SaveExchange(context.Background(), ownerID, Exchange{UserMessage: "Hi", ModelMessage: "Hello"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("error = %v, want the position query to find no row", err)
+	}
+
+	insert := connector.statements[0]
+	if !strings.HasPrefix(insert.query, "INSERT INTO conversations (id, user_id,") || insert.args[1] != ownerID {
+		t.Errorf("first statement = %s %v, want the conversation inserted for %s", insert.query, insert.args, ownerID)
+	}
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+
+	_ "github.com/lib/pq"
+)
+
+// URL returns DATABASE_URL, or builds it from the DB_HOST, DB_PORT, DB_USER,
+// DB_PASSWORD and DB_NAME variables used by docker-compose.
+func URL() string {
+	if dsn := config.GetEnv("DATABASE_URL", ""); dsn != "" {
+		return dsn
+	}
+
+	host := config.GetEnv("DB_HOST", "")
+	if host == "" {
+		return ""
+	}
+
+	u := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.GetEnv("DB_USER", ""), config.GetEnv("DB_PASSWORD", "")),
+		Host:     host + ":" + config.GetEnv("DB_PORT", "5432"),
+		Path:     "/" + config.GetEnv("DB_NAME", ""),
+		RawQuery: "sslmode=" + config.GetEnv("DB_SSLMODE", "disable"),
+	}
+	return u.String()
+}
+
+// Connect opens and verifies a connection to the database shared with the server
+func Connect() (*sql.DB, error) {
+	dsn := URL()
+	if dsn == "" {
+		return nil, fmt.Errorf("DATABASE_URL or DB_HOST must be set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ping failed: %w", err)
+	}
+
+	return conn, nil
+}
+
+// WaitForUsers waits until the server has created the users table, which the
+// chat-bot's tables reference, for at most DB_WAIT_TIMEOUT (default 1m).
+func WaitForUsers(ctx context.Context, conn *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, config.GetEnvDuration("DB_WAIT_TIMEOUT", time.Minute))
+	defer cancel()
+
+	for {
+		var exists bool
+		if err := conn.QueryRowContext(ctx, `SELECT to_regclass('users') IS NOT NULL`).Scan(&exists); err != nil {
+			return fmt.Errorf("error checking users table: %w", err)
+		}
+		if exists {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("users table was not created by the server: %w", ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// CreateTables creates the tables owned by the chat-bot
+func CreateTables(conn *sql.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS conversations (
+			id UUID PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS conversations_user_idx ON conversations (user_id, updated_at DESC);`,
+		// position orders the messages of a conversation, both turns of an
+		// exchange are written in one transaction and share created_at
+		`CREATE TABLE IF NOT EXISTS messages (
+			id UUID PRIMARY KEY,
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			role TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			UNIQUE (conversation_id, position)
+		);`,
+		// Personas without user_id are global and managed by admins. Editing
+		// the instruction of a persona adds a version instead of replacing it.
+		`CREATE TABLE IF NOT EXISTS personas (
+			id UUID PRIMARY KEY,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			current_version INT NOT NULL DEFAULT 1,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS personas_name_idx
+			ON personas (COALESCE(user_id, '00000000-0000-0000-0000-000000000000'), LOWER(name));`,
+		`CREATE TABLE IF NOT EXISTS persona_versions (
+			persona_id UUID NOT NULL REFERENCES personas(id) ON DELETE CASCADE,
+			version INT NOT NULL,
+			instruction TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (persona_id, version)
+		);`,
+		// Conversations keep the persona version they were started with, and
+		// forget it when the persona is deleted
+		`ALTER TABLE conversations ADD COLUMN IF NOT EXISTS persona_id UUID;`,
+		`ALTER TABLE conversations ADD COLUMN IF NOT EXISTS persona_version INT;`,
+		`ALTER TABLE conversations ADD COLUMN IF NOT EXISTS system TEXT NOT NULL DEFAULT '';`,
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'conversations_persona_fkey') THEN
+				ALTER TABLE conversations ADD CONSTRAINT conversations_persona_fkey
+				FOREIGN KEY (persona_id, persona_version) REFERENCES persona_versions(persona_id, version) ON DELETE SET NULL;
+			END IF;
+		END;
+		$$;`,
+		// Why the model stopped writing a reply, cancelled replies are kept as far as they got
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS finish_reason TEXT NOT NULL DEFAULT '';`,
+		// Messages with files list their text and files in order, content
+		// keeping their text. The files themselves are stored as attachments.
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS parts JSONB;`,
+		`CREATE TABLE IF NOT EXISTS attachments (
+			id UUID PRIMARY KEY,
+			message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+			mime_type TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			size INT NOT NULL,
+			data BYTEA NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS attachments_message_idx ON attachments (message_id);`,
+		// Generated images, whose files are kept in the image storage under storage_key
+		`CREATE TABLE IF NOT EXISTS images (
+			id UUID PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			prompt TEXT NOT NULL,
+			negative_prompt TEXT NOT NULL DEFAULT '',
+			aspect_ratio TEXT NOT NULL,
+			style TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL,
+			mime_type TEXT NOT NULL,
+			size INT NOT NULL,
+			storage_key TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS images_user_idx ON images (user_id, created_at DESC);`,
+	}
+
+	for _, query := range queries {
+		if _, err := conn.Exec(query); err != nil {
+			return fmt.Errorf("error creating table: %w", err)
+		}
+	}
+
+	return nil
+}
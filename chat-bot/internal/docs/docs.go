@@ -0,0 +1,47 @@
+package docs
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// uiHTML renders Swagger UI against the spec served at /openapi.json.
+const uiHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>ImaginAI Chat Bot API Docs</title>
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+    <script>
+        window.onload = function () {
+            window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+        };
+    </script>
+</body>
+</html>
+`
+
+// Spec returns the raw OpenAPI document.
+func Spec() []byte {
+	return spec
+}
+
+// SpecHandler serves the OpenAPI document as JSON.
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// UIHandler serves the interactive documentation page.
+func UIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(uiHTML))
+}
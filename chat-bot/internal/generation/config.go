@@ -0,0 +1,179 @@
+// Package generation validates the generation settings of chat requests and
+// merges them with the chat-bot's defaults.
+package generation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// Limits of the accepted settings, as documented by the Gemini API
+const (
+	MaxTemperature     = 2
+	MaxTopK            = 1000
+	MaxOutputTokens    = 65536
+	MaxStopSequences   = 5
+	MaxCandidateCount  = 8
+	MinPenalty         = -2
+	MaxPenaltyExcluded = 2
+)
+
+// Defaults returns the settings applied to chats that do not override them:
+// the historical temperature 0.9, top_p 0.5 and top_k 20 of the chat-bot
+func Defaults() *models.GenerationConfig {
+	temperature := float32(0.9)
+	topP := float32(0.5)
+	topK := int32(20)
+	return &models.GenerationConfig{
+		Temperature: &temperature,
+		TopP:        &topP,
+		TopK:        &topK,
+	}
+}
+
+// DefaultsFromEnv returns Defaults overridden by the GENERATION_TEMPERATURE,
+// GENERATION_TOP_P, GENERATION_TOP_K, GENERATION_MAX_OUTPUT_TOKENS,
+// GENERATION_STOP_SEQUENCES, GENERATION_SEED, GENERATION_CANDIDATE_COUNT,
+// GENERATION_PRESENCE_PENALTY and GENERATION_FREQUENCY_PENALTY variables.
+// Unlike other settings, malformed values are reported rather than ignored.
+func DefaultsFromEnv() (*models.GenerationConfig, error) {
+	env := &models.GenerationConfig{
+		StopSequences: config.GetEnvList("GENERATION_STOP_SEQUENCES", nil),
+	}
+
+	var errs []error
+	floatVar := func(key string) *float32 {
+		raw := config.GetEnv(key, "")
+		if raw == "" {
+			return nil
+		}
+		value, err := strconv.ParseFloat(raw, 32)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s must be a number", key))
+			return nil
+		}
+		v := float32(value)
+		return &v
+	}
+	intVar := func(key string) *int32 {
+		raw := config.GetEnv(key, "")
+		if raw == "" {
+			return nil
+		}
+		value, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s must be an integer", key))
+			return nil
+		}
+		v := int32(value)
+		return &v
+	}
+
+	env.Temperature = floatVar("GENERATION_TEMPERATURE")
+	env.TopP = floatVar("GENERATION_TOP_P")
+	env.TopK = intVar("GENERATION_TOP_K")
+	env.MaxOutputTokens = intVar("GENERATION_MAX_OUTPUT_TOKENS")
+	env.Seed = intVar("GENERATION_SEED")
+	env.CandidateCount = intVar("GENERATION_CANDIDATE_COUNT")
+	env.PresencePenalty = floatVar("GENERATION_PRESENCE_PENALTY")
+	env.FrequencyPenalty = floatVar("GENERATION_FREQUENCY_PENALTY")
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := Validate(env); err != nil {
+		return nil, fmt.Errorf("invalid generation defaults: %w", err)
+	}
+
+	return Merge(Defaults(), env), nil
+}
+
+// Validate checks that every setting of c is within the range accepted by the model
+func Validate(c *models.GenerationConfig) error {
+	if c == nil {
+		return nil
+	}
+
+	var problems []string
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > MaxTemperature) {
+		problems = append(problems, fmt.Sprintf("temperature must be between 0 and %d", MaxTemperature))
+	}
+	if c.TopP != nil && (*c.TopP < 0 || *c.TopP > 1) {
+		problems = append(problems, "top_p must be between 0 and 1")
+	}
+	if c.TopK != nil && (*c.TopK < 1 || *c.TopK > MaxTopK) {
+		problems = append(problems, fmt.Sprintf("top_k must be between 1 and %d", MaxTopK))
+	}
+	if c.MaxOutputTokens != nil && (*c.MaxOutputTokens < 1 || *c.MaxOutputTokens > MaxOutputTokens) {
+		problems = append(problems, fmt.Sprintf("max_output_tokens must be between 1 and %d", MaxOutputTokens))
+	}
+	if len(c.StopSequences) > MaxStopSequences {
+		problems = append(problems, fmt.Sprintf("stop_sequences accepts at most %d sequences", MaxStopSequences))
+	}
+	for _, sequence := range c.StopSequences {
+		if sequence == "" {
+			problems = append(problems, "stop_sequences must not contain empty sequences")
+			break
+		}
+	}
+	if c.CandidateCount != nil && (*c.CandidateCount < 1 || *c.CandidateCount > MaxCandidateCount) {
+		problems = append(problems, fmt.Sprintf("candidate_count must be between 1 and %d", MaxCandidateCount))
+	}
+	if c.PresencePenalty != nil && (*c.PresencePenalty < MinPenalty || *c.PresencePenalty >= MaxPenaltyExcluded) {
+		problems = append(problems, fmt.Sprintf("presence_penalty must be at least %d and less than %d", MinPenalty, MaxPenaltyExcluded))
+	}
+	if c.FrequencyPenalty != nil && (*c.FrequencyPenalty < MinPenalty || *c.FrequencyPenalty >= MaxPenaltyExcluded) {
+		problems = append(problems, fmt.Sprintf("frequency_penalty must be at least %d and less than %d", MinPenalty, MaxPenaltyExcluded))
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Merge returns the settings of overrides, falling back to defaults for the omitted ones
+func Merge(defaults *models.GenerationConfig, overrides *models.GenerationConfig) *models.GenerationConfig {
+	merged := &models.GenerationConfig{}
+	if defaults != nil {
+		*merged = *defaults
+	}
+	if overrides == nil {
+		return merged
+	}
+
+	if overrides.Temperature != nil {
+		merged.Temperature = overrides.Temperature
+	}
+	if overrides.TopP != nil {
+		merged.TopP = overrides.TopP
+	}
+	if overrides.TopK != nil {
+		merged.TopK = overrides.TopK
+	}
+	if overrides.MaxOutputTokens != nil {
+		merged.MaxOutputTokens = overrides.MaxOutputTokens
+	}
+	if overrides.StopSequences != nil {
+		merged.StopSequences = overrides.StopSequences
+	}
+	if overrides.Seed != nil {
+		merged.Seed = overrides.Seed
+	}
+	if overrides.CandidateCount != nil {
+		merged.CandidateCount = overrides.CandidateCount
+	}
+	if overrides.PresencePenalty != nil {
+		merged.PresencePenalty = overrides.PresencePenalty
+	}
+	if overrides.FrequencyPenalty != nil {
+		merged.FrequencyPenalty = overrides.FrequencyPenalty
+	}
+
+	return merged
+}
@@ -0,0 +1,200 @@
+package generation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+func float(v float32) *float32 { return &v }
+func integer(v int32) *int32   { return &v }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *models.GenerationConfig
+		// problems are the settings reported, in order
+		problems []string
+	}{
+		{name: "no settings", config: nil},
+		{name: "empty settings", config: &models.GenerationConfig{}},
+		{
+			name: "every setting at its bounds",
+			config: &models.GenerationConfig{
+				Temperature: float(2), TopP: float(1), TopK: integer(1000), MaxOutputTokens: integer(65536),
+				StopSequences: []string{"a", "b", "c", "d", "e"}, Seed: integer(-7), CandidateCount: integer(8),
+				PresencePenalty: float(-2), FrequencyPenalty: float(1.99),
+			},
+		},
+		{
+			name:   "every setting at its lower bound",
+			config: &models.GenerationConfig{Temperature: float(0), TopP: float(0), TopK: integer(1), MaxOutputTokens: integer(1), CandidateCount: integer(1)},
+		},
+		{name: "temperature below 0", config: &models.GenerationConfig{Temperature: float(-0.1)}, problems: []string{"temperature"}},
+		{name: "temperature above 2", config: &models.GenerationConfig{Temperature: float(2.01)}, problems: []string{"temperature"}},
+		{name: "top_p above 1", config: &models.GenerationConfig{TopP: float(1.5)}, problems: []string{"top_p"}},
+		{name: "top_p below 0", config: &models.GenerationConfig{TopP: float(-1)}, problems: []string{"top_p"}},
+		{name: "top_k of 0", config: &models.GenerationConfig{TopK: integer(0)}, problems: []string{"top_k"}},
+		{name: "top_k above the limit", config: &models.GenerationConfig{TopK: integer(1001)}, problems: []string{"top_k"}},
+		{name: "max_output_tokens of 0", config: &models.GenerationConfig{MaxOutputTokens: integer(0)}, problems: []string{"max_output_tokens"}},
+		{name: "max_output_tokens above the limit", config: &models.GenerationConfig{MaxOutputTokens: integer(65537)}, problems: []string{"max_output_tokens"}},
+		{name: "too many stop sequences", config: &models.GenerationConfig{StopSequences: []string{"a", "b", "c", "d", "e", "f"}}, problems: []string{"stop_sequences"}},
+		{name: "empty stop sequences", config: &models.GenerationConfig{StopSequences: []string{"end", "", ""}}, problems: []string{"stop_sequences"}},
+		{name: "candidate_count of 0", config: &models.GenerationConfig{CandidateCount: integer(0)}, problems: []string{"candidate_count"}},
+		{name: "candidate_count above the limit", config: &models.GenerationConfig{CandidateCount: integer(9)}, problems: []string{"candidate_count"}},
+		{name: "presence_penalty below -2", config: &models.GenerationConfig{PresencePenalty: float(-2.5)}, problems: []string{"presence_penalty"}},
+		{name: "presence_penalty of 2", config: &models.GenerationConfig{PresencePenalty: float(2)}, problems: []string{"presence_penalty"}},
+		{name: "frequency_penalty of 2", config: &models.GenerationConfig{FrequencyPenalty: float(2)}, problems: []string{"frequency_penalty"}},
+		{
+			name:     "several problems",
+			config:   &models.GenerationConfig{Temperature: float(3), TopK: integer(0), StopSequences: []string{"a", "b", "c", "d", "e", ""}, FrequencyPenalty: float(-3)},
+			problems: []string{"temperature", "top_k", "stop_sequences", "stop_sequences", "frequency_penalty"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.config)
+			if tt.problems == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want problems with %v", tt.problems)
+			}
+
+			var settings []string
+			for _, problem := range strings.Split(err.Error(), "; ") {
+				setting, _, _ := strings.Cut(problem, " ")
+				settings = append(settings, setting)
+			}
+			if !reflect.DeepEqual(settings, tt.problems) {
+				t.Errorf("Validate() = %q, want problems with %v", err, tt.problems)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	defaults := &models.GenerationConfig{
+		Temperature:     float(0.9),
+		TopP:            float(0.5),
+		TopK:            integer(20),
+		MaxOutputTokens: integer(1024),
+		StopSequences:   []string{"END"},
+	}
+
+	tests := []struct {
+		name      string
+		defaults  *models.GenerationConfig
+		overrides *models.GenerationConfig
+		want      *models.GenerationConfig
+	}{
+		{name: "no overrides", defaults: defaults, want: defaults},
+		{name: "empty overrides", defaults: defaults, overrides: &models.GenerationConfig{}, want: defaults},
+		{name: "no defaults", overrides: &models.GenerationConfig{Seed: integer(7)}, want: &models.GenerationConfig{Seed: integer(7)}},
+		{name: "neither", want: &models.GenerationConfig{}},
+		{
+			name:     "overrides take precedence",
+			defaults: defaults,
+			overrides: &models.GenerationConfig{
+				Temperature: float(0.2), TopK: integer(40), StopSequences: []string{"STOP", "HALT"},
+				Seed: integer(7), CandidateCount: integer(2), PresencePenalty: float(0.5), FrequencyPenalty: float(-0.5),
+			},
+			want: &models.GenerationConfig{
+				Temperature: float(0.2), TopP: float(0.5), TopK: integer(40), MaxOutputTokens: integer(1024),
+				StopSequences: []string{"STOP", "HALT"}, Seed: integer(7), CandidateCount: integer(2),
+				PresencePenalty: float(0.5), FrequencyPenalty: float(-0.5),
+			},
+		},
+		{
+			name:      "zero values are overrides",
+			defaults:  defaults,
+			overrides: &models.GenerationConfig{Temperature: float(0), StopSequences: []string{}},
+			want:      &models.GenerationConfig{Temperature: float(0), TopP: float(0.5), TopK: integer(20), MaxOutputTokens: integer(1024), StopSequences: []string{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Merge(tt.defaults, tt.overrides)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %s, want %s", describe(got), describe(tt.want))
+			}
+		})
+	}
+
+	// The defaults are shared by every chat and must never change
+	Merge(defaults, &models.GenerationConfig{Temperature: float(0.1)})
+	if *defaults.Temperature != 0.9 {
+		t.Errorf("Merge changed the defaults: temperature = %v", *defaults.Temperature)
+	}
+}
+
+func TestDefaultsFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want *models.GenerationConfig
+		err  string
+	}{
+		{name: "unset", want: Defaults()},
+		{
+			name: "overridden",
+			env:  map[string]string{"GENERATION_TEMPERATURE": "0.4", "GENERATION_MAX_OUTPUT_TOKENS": "2048", "GENERATION_STOP_SEQUENCES": "END,STOP"},
+			want: &models.GenerationConfig{Temperature: float(0.4), TopP: float(0.5), TopK: integer(20), MaxOutputTokens: integer(2048), StopSequences: []string{"END", "STOP"}},
+		},
+		{name: "not a number", env: map[string]string{"GENERATION_TOP_P": "half", "GENERATION_SEED": "1.5"}, err: "GENERATION_TOP_P must be a number\nGENERATION_SEED must be an integer"},
+		{name: "out of range", env: map[string]string{"GENERATION_CANDIDATE_COUNT": "12"}, err: "invalid generation defaults: candidate_count"},
+	}
+
+	keys := []string{
+		"GENERATION_TEMPERATURE", "GENERATION_TOP_P", "GENERATION_TOP_K", "GENERATION_MAX_OUTPUT_TOKENS", "GENERATION_STOP_SEQUENCES",
+		"GENERATION_SEED", "GENERATION_CANDIDATE_COUNT", "GENERATION_PRESENCE_PENALTY", "GENERATION_FREQUENCY_PENALTY",
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range keys {
+				t.Setenv(key, tt.env[key])
+			}
+
+			got, err := DefaultsFromEnv()
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("DefaultsFromEnv() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DefaultsFromEnv() = %s, %v, want %s", describe(got), err, describe(tt.want))
+			}
+		})
+	}
+}
+
+// describe prints the values of the settings of c rather than their addresses
+func describe(c *models.GenerationConfig) string {
+	if c == nil {
+		return "<nil>"
+	}
+	var fields []string
+	v := reflect.ValueOf(*c)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		} else if field.IsNil() {
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s=%v", v.Type().Field(i).Name, field.Interface()))
+	}
+	return "{" + strings.Join(fields, " ") + "}"
+}
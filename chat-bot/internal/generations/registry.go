@@ -0,0 +1,284 @@
+// Package generations keeps the events of streamed replies, so that a client
+// whose connection dropped can resume a generation where it left off, and
+// lets clients cancel generations from another connection.
+package generations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned for generations that do not exist, expired or
+	// belong to another user
+	ErrNotFound = errors.New("generation not found")
+	// ErrCancelled is the cause of generations cancelled by their user
+	ErrCancelled = errors.New("generation cancelled")
+	// ErrAbandoned is the cause of generations cancelled because no client
+	// streamed them for the disconnect grace period
+	ErrAbandoned = errors.New("generation abandoned by its clients")
+)
+
+// Event is a server-sent event of a generation. IDs start at 1 and increase
+// by one, so the ID of the last event received is enough to resume.
+type Event struct {
+	ID   int
+	Name string
+	Data []byte
+	// Value is the value Data encodes, for consumers other than event streams
+	Value interface{}
+}
+
+// Generation is the event log of one reply. Events are published by the
+// goroutine generating the reply and read by any number of streams.
+type Generation struct {
+	ID     string
+	UserID string
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	// stop releases the deadline of ctx
+	stop context.CancelFunc
+	// grace is how long the generation runs without clients before it is abandoned
+	grace time.Duration
+
+	mu     sync.Mutex
+	events []Event
+	done   bool
+	// changed is closed and replaced whenever an event is published or the generation ends
+	changed    chan struct{}
+	finishedAt time.Time
+	// clients counts the streams following the generation, abandon fires
+	// once the last one went away
+	clients int
+	abandon *time.Timer
+}
+
+// Context is done when the generation is cancelled, abandoned or times out.
+// context.Cause tells which.
+func (g *Generation) Context() context.Context {
+	return g.ctx
+}
+
+// Cancel stops the generation with cause, returning false when it already ended
+func (g *Generation) Cancel(cause error) bool {
+	g.mu.Lock()
+	done := g.done
+	g.mu.Unlock()
+	if done {
+		return false
+	}
+
+	g.cancel(cause)
+	return true
+}
+
+// Attach records a stream following the generation
+func (g *Generation) Attach() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.clients++
+	if g.abandon != nil {
+		g.abandon.Stop()
+		g.abandon = nil
+	}
+}
+
+// Detach records a stream going away. The generation is abandoned when no
+// stream attaches during the grace period that follows the last one leaving.
+func (g *Generation) Detach() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.clients--
+	if g.clients > 0 || g.done {
+		return
+	}
+	g.abandon = time.AfterFunc(g.grace, func() { g.cancel(ErrAbandoned) })
+}
+
+// Publish appends an event whose data is v encoded as JSON
+func (g *Generation) Publish(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding %s event: %w", name, err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return fmt.Errorf("generation %s already ended", g.ID)
+	}
+
+	g.events = append(g.events, Event{ID: len(g.events) + 1, Name: name, Data: data, Value: v})
+	g.notify()
+	return nil
+}
+
+// Close ends the generation, waking up the streams waiting for events
+func (g *Generation) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return
+	}
+
+	g.done = true
+	g.finishedAt = time.Now()
+	if g.abandon != nil {
+		g.abandon.Stop()
+		g.abandon = nil
+	}
+	g.stop()
+	g.cancel(nil)
+	g.notify()
+}
+
+// Since returns the events published after lastID, whether the generation
+// ended, and a channel closed when either changes
+func (g *Generation) Since(lastID int) ([]Event, bool, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var events []Event
+	if lastID >= 0 && lastID < len(g.events) {
+		events = g.events[lastID:]
+	}
+	return events, g.done, g.changed
+}
+
+// notify wakes up the streams waiting on g.changed. g.mu must be held.
+func (g *Generation) notify() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// expired reports whether g ended more than retention before now
+func (g *Generation) expired(now time.Time, retention time.Duration) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.done && now.Sub(g.finishedAt) > retention
+}
+
+// Registry holds the running generations, and the ended ones for Retention
+// so that clients may still fetch the events they missed
+type Registry struct {
+	Retention time.Duration
+	// Timeout bounds every generation, streamed or not
+	Timeout time.Duration
+	// DisconnectGrace is how long a generation continues once its last
+	// client went away, giving it the time to reconnect
+	DisconnectGrace time.Duration
+
+	mu          sync.Mutex
+	generations map[string]*Generation
+}
+
+func NewRegistry(retention time.Duration, timeout time.Duration, disconnectGrace time.Duration) *Registry {
+	return &Registry{
+		Retention:       retention,
+		Timeout:         timeout,
+		DisconnectGrace: disconnectGrace,
+		generations:     map[string]*Generation{},
+	}
+}
+
+// NewRegistryFromEnv builds a registry from GENERATION_RETENTION (default
+// 5m), GENERATION_TIMEOUT (default 5m) and GENERATION_DISCONNECT_GRACE
+// (default 30s)
+func NewRegistryFromEnv() *Registry {
+	return NewRegistry(
+		config.GetEnvDuration("GENERATION_RETENTION", 5*time.Minute),
+		config.GetEnvDuration("GENERATION_TIMEOUT", 5*time.Minute),
+		config.GetEnvDuration("GENERATION_DISCONNECT_GRACE", 30*time.Second),
+	)
+}
+
+// Start registers a new generation of userID. Its context carries the values
+// of parent but outlives it, the generation ending on its own terms.
+func (r *Registry) Start(parent context.Context, userID string) *Generation {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(parent))
+	ctx, stop := r.WithTimeout(ctx)
+
+	g := &Generation{
+		ID:      uuid.NewString(),
+		UserID:  userID,
+		ctx:     ctx,
+		cancel:  cancel,
+		stop:    stop,
+		grace:   r.DisconnectGrace,
+		changed: make(chan struct{}),
+	}
+
+	r.mu.Lock()
+	r.generations[g.ID] = g
+	r.mu.Unlock()
+	return g
+}
+
+// WithTimeout bounds parent by Timeout, unless Timeout is not positive
+func (r *Registry) WithTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	if r.Timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, r.Timeout)
+}
+
+// Get returns the generation id of userID
+func (r *Registry) Get(userID string, id string) (*Generation, error) {
+	r.mu.Lock()
+	g, ok := r.generations[id]
+	r.mu.Unlock()
+
+	if !ok || g.UserID != userID || g.expired(time.Now(), r.Retention) {
+		return nil, ErrNotFound
+	}
+	return g, nil
+}
+
+// Len returns the number of generations held, running or not
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.generations)
+}
+
+// EvictExpired removes the generations that ended more than Retention before now
+func (r *Registry) EvictExpired(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, g := range r.generations {
+		if g.expired(now, r.Retention) {
+			delete(r.generations, id)
+		}
+	}
+}
+
+// Run evicts expired generations until ctx is done
+func (r *Registry) Run(ctx context.Context) {
+	interval := r.Retention / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.EvictExpired(now)
+		}
+	}
+}
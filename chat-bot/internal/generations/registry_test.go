@@ -0,0 +1,234 @@
+package generations
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+const userID = "6f1c3b52-0d7e-4f43-9a57-4d1a4c9f1e11"
+
+// closed reports whether ch is closed
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestGetChecksTheOwner(t *testing.T) {
+	r := NewRegistry(time.Minute, 0, time.Minute)
+	g := r.Start(context.Background(), userID)
+
+	if got, err := r.Get(userID, g.ID); err != nil || got != g {
+		t.Errorf("Get(owner) = %v, %v, want the generation", got, err)
+	}
+	if _, err := r.Get("another user", g.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(another user) error = %v, want ErrNotFound", err)
+	}
+	if _, err := r.Get(userID, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(unknown id) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSince(t *testing.T) {
+	g := NewRegistry(time.Minute, 0, time.Minute).Start(context.Background(), userID)
+
+	events, done, changed := g.Since(0)
+	if len(events) != 0 || done {
+		t.Fatalf("Since(0) = %v, %t, want no events of a running generation", events, done)
+	}
+
+	for _, text := range []string{"Hello", " there", "!"} {
+		if err := g.Publish("delta", map[string]string{"text": text}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if !closed(changed) {
+		t.Error("changed is not closed by Publish")
+	}
+
+	tests := []struct {
+		lastID int
+		ids    []int
+	}{
+		{lastID: 0, ids: []int{1, 2, 3}},
+		{lastID: 1, ids: []int{2, 3}},
+		{lastID: 3, ids: nil},
+		{lastID: 7, ids: nil},
+		{lastID: -1, ids: nil},
+	}
+	for _, tt := range tests {
+		events, _, _ := g.Since(tt.lastID)
+		var ids []int
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		if !slices.Equal(ids, tt.ids) {
+			t.Errorf("Since(%d) ids = %v, want %v", tt.lastID, ids, tt.ids)
+		}
+	}
+
+	events, _, _ = g.Since(1)
+	if events[0].Name != "delta" || string(events[0].Data) != `{"text":" there"}` {
+		t.Errorf("event 2 = %s %s, want the second delta", events[0].Name, events[0].Data)
+	}
+
+	_, _, changed = g.Since(3)
+	g.Close()
+	if !closed(changed) {
+		t.Error("changed is not closed by Close")
+	}
+	if _, done, _ := g.Since(3); !done {
+		t.Error("Since reports a closed generation as running")
+	}
+	if err := g.Publish("delta", map[string]string{"text": "late"}); err == nil {
+		t.Error("Publish after Close succeeded")
+	}
+	if events, _, _ := g.Since(0); len(events) != 3 {
+		t.Errorf("closed generation holds %d events, want 3", len(events))
+	}
+}
+
+func TestRetention(t *testing.T) {
+	r := NewRegistry(time.Minute, 0, time.Minute)
+	running := r.Start(context.Background(), userID)
+	ended := r.Start(context.Background(), userID)
+	ended.Close()
+
+	r.EvictExpired(time.Now())
+	if r.Len() != 2 {
+		t.Fatalf("registry holds %d generations within retention, want 2", r.Len())
+	}
+
+	r.EvictExpired(time.Now().Add(2 * time.Minute))
+	if r.Len() != 1 {
+		t.Fatalf("registry holds %d generations after retention, want 1", r.Len())
+	}
+	if _, err := r.Get(userID, running.ID); err != nil {
+		t.Errorf("running generation was evicted: %v", err)
+	}
+	if _, err := r.Get(userID, ended.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(expired) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGenerationOutlivesItsRequest(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "request"))
+	g := NewRegistry(time.Minute, 0, time.Minute).Start(parent, userID)
+
+	cancel()
+	if err := g.Context().Err(); err != nil {
+		t.Errorf("generation context ended with its parent: %v", err)
+	}
+	if got := g.Context().Value(key{}); got != "request" {
+		t.Errorf("generation context value = %v, want the value of its parent", got)
+	}
+}
+
+// cause waits for the context of g to end and returns its cause
+func cause(t *testing.T, g *Generation) error {
+	t.Helper()
+
+	select {
+	case <-g.Context().Done():
+		return context.Cause(g.Context())
+	case <-time.After(time.Second):
+		t.Fatal("generation is still running")
+		return nil
+	}
+}
+
+func TestCancel(t *testing.T) {
+	g := NewRegistry(time.Minute, 0, time.Minute).Start(context.Background(), userID)
+
+	if !g.Cancel(ErrCancelled) {
+		t.Fatal("Cancel of a running generation returned false")
+	}
+	if err := cause(t, g); !errors.Is(err, ErrCancelled) {
+		t.Errorf("cause = %v, want ErrCancelled", err)
+	}
+
+	g.Close()
+	if g.Cancel(ErrCancelled) {
+		t.Error("Cancel of a closed generation returned true")
+	}
+}
+
+func TestDisconnectGrace(t *testing.T) {
+	const grace = 20 * time.Millisecond
+
+	t.Run("abandoned without clients", func(t *testing.T) {
+		g := NewRegistry(time.Minute, 0, grace).Start(context.Background(), userID)
+		g.Attach()
+		g.Detach()
+
+		if err := cause(t, g); !errors.Is(err, ErrAbandoned) {
+			t.Errorf("cause = %v, want ErrAbandoned", err)
+		}
+	})
+
+	t.Run("client reconnecting within the grace period", func(t *testing.T) {
+		g := NewRegistry(time.Minute, 0, grace).Start(context.Background(), userID)
+		g.Attach()
+		g.Detach()
+		g.Attach()
+
+		time.Sleep(3 * grace)
+		if err := g.Context().Err(); err != nil {
+			t.Errorf("generation ended with a client attached: %v", context.Cause(g.Context()))
+		}
+		g.Close()
+	})
+
+	t.Run("other clients still following", func(t *testing.T) {
+		g := NewRegistry(time.Minute, 0, grace).Start(context.Background(), userID)
+		g.Attach()
+		g.Attach()
+		g.Detach()
+
+		time.Sleep(3 * grace)
+		if err := g.Context().Err(); err != nil {
+			t.Errorf("generation ended with a client attached: %v", context.Cause(g.Context()))
+		}
+		g.Close()
+	})
+
+	t.Run("closed within the grace period", func(t *testing.T) {
+		g := NewRegistry(time.Minute, 0, grace).Start(context.Background(), userID)
+		g.Attach()
+		g.Detach()
+		g.Close()
+
+		time.Sleep(3 * grace)
+		if err := context.Cause(g.Context()); errors.Is(err, ErrAbandoned) {
+			t.Error("closed generation was abandoned")
+		}
+	})
+
+	t.Run("client leaving an ended generation", func(t *testing.T) {
+		g := NewRegistry(time.Minute, 0, grace).Start(context.Background(), userID)
+		g.Attach()
+		g.Close()
+		g.Detach()
+
+		time.Sleep(3 * grace)
+		if err := context.Cause(g.Context()); errors.Is(err, ErrAbandoned) {
+			t.Error("ended generation was abandoned")
+		}
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	g := NewRegistry(time.Minute, 20*time.Millisecond, time.Minute).Start(context.Background(), userID)
+
+	if err := cause(t, g); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("cause = %v, want DeadlineExceeded", err)
+	}
+	g.Close()
+}
@@ -0,0 +1,97 @@
+// Package images stores the images users generate: their files on the local
+// filesystem and their metadata in the database, which the gallery lists.
+package images
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// Limits of the image requests
+const (
+	// MaxPromptLength is the length of prompts and negative prompts, in characters
+	MaxPromptLength = 2000
+	// MaxCount is the number of images of a request
+	MaxCount = 4
+)
+
+// DefaultAspectRatio is used when requests name none
+const DefaultAspectRatio = "1:1"
+
+// AspectRatios are the aspect ratios images may be generated in
+var AspectRatios = []string{"1:1", "3:4", "4:3", "9:16", "16:9"}
+
+// Styles maps the styles of requests to the text appended to their prompt
+var Styles = map[string]string{
+	"photographic": "A high-resolution photograph with natural lighting.",
+	"cinematic":    "A cinematic film still with dramatic lighting and shallow depth of field.",
+	"digital-art":  "Digital art with vibrant colors and crisp details.",
+	"anime":        "Anime illustration with clean line art and cel shading.",
+	"watercolor":   "A watercolor painting with soft washes of color on textured paper.",
+	"oil-painting": "An oil painting with visible brush strokes.",
+	"sketch":       "A pencil sketch with fine hatching on white paper.",
+	"3d-render":    "A 3D render with realistic materials and soft global illumination.",
+	"pixel-art":    "Pixel art in a retro video game style.",
+}
+
+// Validate checks req, filling in the defaults of the fields left empty
+func Validate(req *models.ImageRequest) error {
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	req.NegativePrompt = strings.TrimSpace(req.NegativePrompt)
+
+	switch {
+	case req.Prompt == "":
+		return errors.New("prompt is required")
+	case utf8.RuneCountInString(req.Prompt) > MaxPromptLength:
+		return fmt.Errorf("prompt must be at most %d characters", MaxPromptLength)
+	case utf8.RuneCountInString(req.NegativePrompt) > MaxPromptLength:
+		return fmt.Errorf("negative_prompt must be at most %d characters", MaxPromptLength)
+	}
+
+	if req.AspectRatio == "" {
+		req.AspectRatio = DefaultAspectRatio
+	}
+	if !slices.Contains(AspectRatios, req.AspectRatio) {
+		return fmt.Errorf("aspect_ratio must be one of %s", strings.Join(AspectRatios, ", "))
+	}
+
+	if req.Count == 0 {
+		req.Count = 1
+	}
+	if req.Count < 1 || req.Count > MaxCount {
+		return fmt.Errorf("count must be between 1 and %d", MaxCount)
+	}
+
+	if _, ok := Styles[req.Style]; req.Style != "" && !ok {
+		return fmt.Errorf("style must be one of %s", strings.Join(StyleNames(), ", "))
+	}
+	return nil
+}
+
+// StyleNames returns the names of the styles in order
+func StyleNames() []string {
+	names := make([]string, 0, len(Styles))
+	for name := range Styles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Prompt returns the prompt sent to the model for req, describing its style
+func Prompt(req *models.ImageRequest) string {
+	if req.Style == "" {
+		return req.Prompt
+	}
+	return req.Prompt + "\n\n" + Styles[req.Style]
+}
+
+// ContentURL is the path the file of image id is downloaded from
+func ContentURL(id string) string {
+	return "/images/" + id + "/content"
+}
@@ -0,0 +1,74 @@
+package images
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.ImageRequest
+		// want is the request once its defaults are filled in
+		want models.ImageRequest
+		err  string
+	}{
+		{
+			name: "defaults",
+			req:  models.ImageRequest{Prompt: "  A red fox "},
+			want: models.ImageRequest{Prompt: "A red fox", AspectRatio: "1:1", Count: 1},
+		},
+		{
+			name: "every field",
+			req:  models.ImageRequest{Prompt: "A red fox", NegativePrompt: " snow ", AspectRatio: "9:16", Count: 4, Style: "pixel-art"},
+			want: models.ImageRequest{Prompt: "A red fox", NegativePrompt: "snow", AspectRatio: "9:16", Count: 4, Style: "pixel-art"},
+		},
+		{
+			name: "prompt at the limit",
+			req:  models.ImageRequest{Prompt: strings.Repeat("é", MaxPromptLength)},
+			want: models.ImageRequest{Prompt: strings.Repeat("é", MaxPromptLength), AspectRatio: "1:1", Count: 1},
+		},
+		{name: "blank prompt", req: models.ImageRequest{Prompt: " \n "}, err: "prompt is required"},
+		{name: "prompt too long", req: models.ImageRequest{Prompt: strings.Repeat("a", MaxPromptLength+1)}, err: "prompt must be at most 2000 characters"},
+		{
+			name: "negative prompt too long",
+			req:  models.ImageRequest{Prompt: "A fox", NegativePrompt: strings.Repeat("a", MaxPromptLength+1)},
+			err:  "negative_prompt must be at most 2000 characters",
+		},
+		{name: "unknown aspect ratio", req: models.ImageRequest{Prompt: "A fox", AspectRatio: "2:1"}, err: "aspect_ratio must be one of 1:1, 3:4, 4:3, 9:16, 16:9"},
+		{name: "negative count", req: models.ImageRequest{Prompt: "A fox", Count: -1}, err: "count must be between 1 and 4"},
+		{name: "too many images", req: models.ImageRequest{Prompt: "A fox", Count: MaxCount + 1}, err: "count must be between 1 and 4"},
+		{name: "unknown style", req: models.ImageRequest{Prompt: "A fox", Style: "mosaic"}, err: "style must be one of 3d-render, anime, cinematic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := Validate(&req)
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("Validate() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if req != tt.want {
+				t.Errorf("request = %+v, want %+v", req, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	if got := Prompt(&models.ImageRequest{Prompt: "A red fox"}); got != "A red fox" {
+		t.Errorf("Prompt() without a style = %q, want the prompt", got)
+	}
+	want := "A red fox\n\n" + Styles["watercolor"]
+	if got := Prompt(&models.ImageRequest{Prompt: "A red fox", Style: "watercolor"}); got != want {
+		t.Errorf("Prompt() with a style = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+)
+
+// extensions maps the MIME types of the images to the extensions of their files
+var extensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+}
+
+// Storage keeps the files of the images under Dir, in a directory per user.
+// Files are identified by keys relative to Dir.
+type Storage struct {
+	Dir string
+}
+
+// NewStorageFromEnv stores the files under IMAGE_STORAGE_DIR (default data/images)
+func NewStorageFromEnv() *Storage {
+	return &Storage{Dir: config.GetEnv("IMAGE_STORAGE_DIR", filepath.Join("data", "images"))}
+}
+
+// Save writes the file of image id of userID, returning its key
+func (s *Storage) Save(userID string, id string, mimeType string, data []byte) (string, error) {
+	extension, ok := extensions[mimeType]
+	if !ok {
+		extension = ".bin"
+	}
+	key := filepath.Join(userID, id+extension)
+
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("error creating image directory: %w", err)
+	}
+
+	// Files are written under a temporary name, so that a file is never seen half written
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("error creating image file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("error writing image file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("error writing image file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("error writing image file: %w", err)
+	}
+
+	return key, nil
+}
+
+// Open opens the file of key
+func (s *Storage) Open(key string) (*os.File, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete removes the file of key, which may already be gone
+func (s *Storage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error deleting image file: %w", err)
+	}
+	return nil
+}
+
+// path returns the path of key, which must stay within Dir
+func (s *Storage) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("invalid image key %q", key)
+	}
+	return filepath.Join(s.Dir, key), nil
+}
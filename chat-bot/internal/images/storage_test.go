@@ -0,0 +1,97 @@
+package images
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const ownerID = "6f1c3b52-0d7e-4f43-9a57-4d1a4c9f1e11"
+
+func TestStorage(t *testing.T) {
+	s := &Storage{Dir: t.TempDir()}
+
+	key, err := s.Save(ownerID, "img-1", "image/png", []byte("png data"))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if want := filepath.Join(ownerID, "img-1.png"); key != want {
+		t.Errorf("key = %q, want %q", key, want)
+	}
+
+	file, err := s.Open(key)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(data) != "png data" {
+		t.Errorf("file = %q, %v, want the saved data", data, err)
+	}
+
+	// Only the file is left in the directory of the user, the temporary one is gone
+	entries, err := os.ReadDir(filepath.Join(s.Dir, ownerID))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory of the user holds %v, %v, want the image only", entries, err)
+	}
+
+	if err := s.Delete(key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir, key)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file after Delete: %v, want it removed", err)
+	}
+	// A file already gone is not an error
+	if err := s.Delete(key); err != nil {
+		t.Errorf("second Delete() error = %v, want none", err)
+	}
+}
+
+func TestStorageExtensions(t *testing.T) {
+	s := &Storage{Dir: t.TempDir()}
+
+	tests := map[string]string{
+		"image/png":  ".png",
+		"image/jpeg": ".jpg",
+		"image/webp": ".webp",
+		"image/gif":  ".bin",
+	}
+	for mimeType, extension := range tests {
+		key, err := s.Save(ownerID, "img", mimeType, []byte{1})
+		if err != nil || filepath.Ext(key) != extension {
+			t.Errorf("Save() of %s = %q, %v, want a %s file", mimeType, key, err, extension)
+		}
+	}
+}
+
+// Keys come from the database, and must never reach outside of the directory
+func TestStorageRejectsKeysOutsideDir(t *testing.T) {
+	root := t.TempDir()
+	s := &Storage{Dir: filepath.Join(root, "images")}
+	outside := filepath.Join(root, "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	for _, key := range []string{"", "../secret", ownerID + "/../../secret", outside, "/etc/passwd"} {
+		if _, err := s.path(key); err == nil {
+			t.Errorf("path(%q) accepted the key", key)
+		}
+		if _, err := s.Open(key); err == nil {
+			t.Errorf("Open(%q) opened a file outside of the directory", key)
+		}
+		if err := s.Delete(key); err == nil {
+			t.Errorf("Delete(%q) accepted the key", key)
+		}
+	}
+	if _, err := s.Save("..", "secret", "image/png", []byte("overwritten")); err == nil {
+		t.Error("Save() of the user .. wrote outside of the directory")
+	}
+
+	if data, err := os.ReadFile(outside); err != nil || string(data) != "secret" {
+		t.Errorf("file outside of the directory = %q, %v, want it untouched", data, err)
+	}
+}
@@ -0,0 +1,133 @@
+package images
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// ErrNotFound is returned for images that do not exist or belong to another user
+var ErrNotFound = errors.New("image not found")
+
+// imageColumns are scanned by scanImage
+const imageColumns = `id, prompt, negative_prompt, aspect_ratio, style, model, mime_type, size, created_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Store keeps the metadata of the images, their files being kept by a Storage
+type Store struct {
+	DB *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+// Create records image of userID, whose file is stored under key. The
+// creation time of image is set.
+func (s *Store) Create(ctx context.Context, userID string, image *models.Image, key string) error {
+	query := `
+		INSERT INTO images (id, user_id, prompt, negative_prompt, aspect_ratio, style, model, mime_type, size, storage_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		RETURNING created_at
+	`
+
+	err := s.DB.QueryRowContext(ctx, query,
+		image.ID, userID, image.Prompt, image.NegativePrompt, image.AspectRatio, image.Style,
+		image.Model, image.MIMEType, image.Size, key,
+	).Scan(&image.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving image: %w", err)
+	}
+	image.URL = ContentURL(image.ID)
+	return nil
+}
+
+// List returns a page of the images of userID, newest first, along with the
+// total number of images
+func (s *Store) List(ctx context.Context, userID string, limit int, offset int) ([]*models.Image, int, error) {
+	query := `
+		SELECT ` + imageColumns + `, COUNT(*) OVER()
+		FROM images
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := s.DB.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying images: %w", err)
+	}
+	defer rows.Close()
+
+	images := []*models.Image{}
+	total := 0
+	for rows.Next() {
+		image := &models.Image{}
+		if err := scanImage(rows, image, &total); err != nil {
+			return nil, 0, fmt.Errorf("error scanning image: %w", err)
+		}
+		images = append(images, image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error querying images: %w", err)
+	}
+
+	// The window count is missing when the page is past the end
+	if len(images) == 0 && offset > 0 {
+		countQuery := `SELECT COUNT(*) FROM images WHERE user_id = $1`
+		if err := s.DB.QueryRowContext(ctx, countQuery, userID).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("error counting images: %w", err)
+		}
+	}
+
+	return images, total, nil
+}
+
+// Get returns an image of userID and the key of its file
+func (s *Store) Get(ctx context.Context, userID string, imageID string) (*models.Image, string, error) {
+	query := `SELECT ` + imageColumns + `, storage_key FROM images WHERE id = $1 AND user_id = $2`
+
+	image := &models.Image{}
+	var key string
+	if err := scanImage(s.DB.QueryRowContext(ctx, query, imageID, userID), image, &key); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("error querying image: %w", err)
+	}
+
+	return image, key, nil
+}
+
+// Delete removes an image of userID, returning the key of its file for the
+// caller to delete
+func (s *Store) Delete(ctx context.Context, userID string, imageID string) (string, error) {
+	var key string
+	err := s.DB.QueryRowContext(ctx, `DELETE FROM images WHERE id = $1 AND user_id = $2 RETURNING storage_key`, imageID, userID).Scan(&key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("error deleting image: %w", err)
+	}
+	return key, nil
+}
+
+// scanImage scans the imageColumns into image, followed by extra
+func scanImage(row scanner, image *models.Image, extra ...interface{}) error {
+	dest := []interface{}{
+		&image.ID, &image.Prompt, &image.NegativePrompt, &image.AspectRatio, &image.Style,
+		&image.Model, &image.MIMEType, &image.Size, &image.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	image.URL = ContentURL(image.ID)
+	return nil
+}
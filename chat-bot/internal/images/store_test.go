@@ -0,0 +1,245 @@
+package images
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// recordingConnector is a database answering queries with the rows of rows,
+// which records every statement run on it
+type recordingConnector struct {
+	mu         sync.Mutex
+	statements []statement
+	// rows returns the rows of a query, none when nil
+	rows func(query string) [][]driver.Value
+}
+
+type statement struct {
+	query string
+	args  []interface{}
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &recordingConn{c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver {
+	return nil
+}
+
+type recordingConn struct {
+	connector *recordingConnector
+}
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.connector.mu.Lock()
+	defer c.connector.mu.Unlock()
+
+	s := statement{query: strings.Join(strings.Fields(query), " ")}
+	for _, arg := range args {
+		s.args = append(s.args, arg.Value)
+	}
+	c.connector.statements = append(c.connector.statements, s)
+
+	if c.connector.rows == nil {
+		return &valueRows{}, nil
+	}
+	return &valueRows{values: c.connector.rows(s.query)}, nil
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type valueRows struct {
+	values [][]driver.Value
+}
+
+func (r *valueRows) Columns() []string {
+	if len(r.values) == 0 {
+		return []string{"id"}
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *valueRows) Close() error { return nil }
+
+func (r *valueRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// imageRow returns the imageColumns of image id followed by extra
+func imageRow(id string, extra ...driver.Value) []driver.Value {
+	row := []driver.Value{id, "A red fox", "", "1:1", "", "imagen", "image/png", int64(42), time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	return append(row, extra...)
+}
+
+const imageID = "0b6f6a8e-6d55-4c1e-9b7a-2f6f0c3f8d21"
+
+func TestStoreList(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int
+		rows   func(query string) [][]driver.Value
+		want   []string
+		total  int
+		// statements run, the list query then the count query
+		statements int
+	}{
+		{
+			name: "page",
+			rows: func(query string) [][]driver.Value {
+				return [][]driver.Value{imageRow("img-2", int64(7)), imageRow("img-1", int64(7))}
+			},
+			want:       []string{"img-2", "img-1"},
+			total:      7,
+			statements: 1,
+		},
+		{name: "no images", want: []string{}, statements: 1},
+		{
+			name:   "past the end",
+			offset: 40,
+			rows: func(query string) [][]driver.Value {
+				if strings.HasPrefix(query, "SELECT COUNT(*)") {
+					return [][]driver.Value{{int64(7)}}
+				}
+				return nil
+			},
+			want:       []string{},
+			total:      7,
+			statements: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := &recordingConnector{rows: tt.rows}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+
+			list, total, err := NewStore(db).List(context.Background(), ownerID, 2, tt.offset)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+
+			ids := []string{}
+			for _, image := range list {
+				ids = append(ids, image.ID)
+				if image.URL != ContentURL(image.ID) || image.Size != 42 {
+					t.Errorf("image = %+v, want its URL and size", image)
+				}
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") || total != tt.total {
+				t.Errorf("List() = %v of %d, want %v of %d", ids, total, tt.want, tt.total)
+			}
+
+			if len(connector.statements) != tt.statements {
+				t.Fatalf("ran %d statements, want %d: %v", len(connector.statements), tt.statements, connector.statements)
+			}
+			first := connector.statements[0]
+			if !strings.Contains(first.query, "WHERE user_id = $1 ORDER BY created_at DESC, id LIMIT $2 OFFSET $3") {
+				t.Errorf("query = %s, want a page of the user's images", first.query)
+			}
+			if first.args[0] != ownerID || first.args[1] != int64(2) || first.args[2] != int64(tt.offset) {
+				t.Errorf("args = %v, want the user, 2 and %d", first.args, tt.offset)
+			}
+			if tt.statements == 2 && connector.statements[1].args[0] != ownerID {
+				t.Errorf("count args = %v, want the user", connector.statements[1].args)
+			}
+		})
+	}
+}
+
+// An image of another user reads as missing
+func TestStoreFiltersByOwner(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(s *Store) error
+		want string
+	}{
+		{
+			name: "Get",
+			call: func(s *Store) error { _, _, err := s.Get(context.Background(), ownerID, imageID); return err },
+			want: "WHERE id = $1 AND user_id = $2",
+		},
+		{
+			name: "Delete",
+			call: func(s *Store) error { _, err := s.Delete(context.Background(), ownerID, imageID); return err },
+			want: "DELETE FROM images WHERE id = $1 AND user_id = $2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := &recordingConnector{}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+
+			if err := tt.call(NewStore(db)); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("error = %v, want ErrNotFound", err)
+			}
+			s := connector.statements[0]
+			if !strings.Contains(s.query, tt.want) || s.args[0] != imageID || s.args[1] != ownerID {
+				t.Errorf("statement = %s %v, want it filtered with %q", s.query, s.args, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreGet(t *testing.T) {
+	connector := &recordingConnector{rows: func(query string) [][]driver.Value {
+		return [][]driver.Value{imageRow(imageID, ownerID+"/"+imageID+".png")}
+	}}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	image, key, err := NewStore(db).Get(context.Background(), ownerID, imageID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if image.ID != imageID || image.URL != ContentURL(imageID) || key != ownerID+"/"+imageID+".png" {
+		t.Errorf("Get() = %+v, %q, want the image and the key of its file", image, key)
+	}
+}
+
+func TestStoreCreate(t *testing.T) {
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	connector := &recordingConnector{rows: func(query string) [][]driver.Value {
+		return [][]driver.Value{{created}}
+	}}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	image := &models.Image{ID: imageID, Prompt: "A red fox", AspectRatio: "1:1", Model: "imagen", MIMEType: "image/png", Size: 42}
+	if err := NewStore(db).Create(context.Background(), ownerID, image, "key.png"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !image.CreatedAt.Equal(created) || image.URL != ContentURL(imageID) {
+		t.Errorf("image = %+v, want its creation time and URL set", image)
+	}
+	if args := connector.statements[0].args; args[0] != imageID || args[1] != ownerID || args[9] != "key.png" {
+		t.Errorf("args = %v, want the image of the user stored under key.png", args)
+	}
+}
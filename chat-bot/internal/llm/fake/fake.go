@@ -0,0 +1,294 @@
+// Package fake is a scripted llm.Provider, so that the chat-bot can run and be
+// tested without a model. Its replies are deterministic: the scripted replies
+// in order, then echoes of the last message.
+package fake
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"iter"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// EmbeddingSize is the length of the embeddings of the fake provider
+const EmbeddingSize = 8
+
+// ImageWidth is the width of the images of the fake provider, their height
+// following the aspect ratio
+const ImageWidth = 64
+
+// Reply is a scripted reply
+type Reply struct {
+	Text      string         `json:"text,omitempty"`
+	ToolCalls []llm.ToolCall `json:"tool_calls,omitempty"`
+	// FinishReason defaults to llm.FinishStop
+	FinishReason string `json:"finish_reason,omitempty"`
+	// BlockReason answers the request as a blocked prompt
+	BlockReason string `json:"block_reason,omitempty"`
+	// Error fails the request with this message
+	Error string `json:"error,omitempty"`
+}
+
+// Script is the file format of Load
+type Script struct {
+	Models  []string `json:"models,omitempty"`
+	Replies []Reply  `json:"replies"`
+	// ChunkDelay is a duration such as "50ms"
+	ChunkDelay string `json:"chunk_delay,omitempty"`
+}
+
+// Provider replies with Replies in order, then echoes the last user message.
+// It is safe for concurrent use.
+type Provider struct {
+	// Models are the models listed, requests may name any model
+	Models []string
+	// ChunkDelay is waited before each chunk of a stream, to test clients
+	// interrupting generations
+	ChunkDelay time.Duration
+
+	mu       sync.Mutex
+	replies  []Reply
+	requests []*llm.Request
+}
+
+// New returns a provider replying with replies
+func New(replies ...Reply) *Provider {
+	return &Provider{Models: []string{"fake"}, replies: replies}
+}
+
+// Load returns a provider following the script at path
+func Load(path string) (*Provider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fake provider script: %w", err)
+	}
+
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("error decoding fake provider script: %w", err)
+	}
+
+	p := New(script.Replies...)
+	if len(script.Models) > 0 {
+		p.Models = script.Models
+	}
+	if script.ChunkDelay != "" {
+		if p.ChunkDelay, err = time.ParseDuration(script.ChunkDelay); err != nil {
+			return nil, fmt.Errorf("chunk_delay of fake provider script: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// Requests returns the requests received so far, as they were received
+func (p *Provider) Requests() []*llm.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*llm.Request(nil), p.requests...)
+}
+
+func (p *Provider) Chat(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	reply := p.next(req)
+	if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return response(req, reply, reply.Text, reply.ToolCalls, true), nil
+}
+
+// Stream yields the reply word by word, then its tool calls with the finish reason
+func (p *Provider) Stream(ctx context.Context, req *llm.Request) iter.Seq2[*llm.Response, error] {
+	return func(yield func(*llm.Response, error) bool) {
+		reply := p.next(req)
+		if reply.Error != "" {
+			yield(nil, errors.New(reply.Error))
+			return
+		}
+		if reply.BlockReason != "" {
+			yield(response(req, reply, "", nil, true), nil)
+			return
+		}
+
+		words := strings.SplitAfter(reply.Text, " ")
+		for i, word := range words {
+			if p.ChunkDelay > 0 {
+				select {
+				case <-time.After(p.ChunkDelay):
+				case <-ctx.Done():
+					yield(nil, ctx.Err())
+					return
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if i < len(words)-1 {
+				if !yield(response(req, reply, word, nil, false), nil) {
+					return
+				}
+				continue
+			}
+			yield(response(req, reply, word, reply.ToolCalls, true), nil)
+		}
+	}
+}
+
+// CountTokens counts the words of the prompt
+func (p *Provider) CountTokens(ctx context.Context, req *llm.Request) (int32, error) {
+	return promptTokens(req), nil
+}
+
+// Embed returns vectors derived from the hash of each text, equal texts
+// having equal embeddings
+func (p *Provider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		sum := sha256.Sum256([]byte(text))
+		embedding := make([]float32, EmbeddingSize)
+		for j := range embedding {
+			embedding[j] = float32(binary.BigEndian.Uint32(sum[j*4:])) / float32(1<<32)
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+// GenerateImages returns PNG images of a single color derived from the
+// prompt, equal prompts giving equal images
+func (p *Provider) GenerateImages(ctx context.Context, req *llm.ImageRequest) (*llm.ImageResponse, error) {
+	height := ImageWidth
+	if width, h, ok := strings.Cut(req.AspectRatio, ":"); ok {
+		w, errW := strconv.Atoi(width)
+		h, errH := strconv.Atoi(h)
+		if errW != nil || errH != nil || w <= 0 || h <= 0 {
+			return nil, fmt.Errorf("invalid aspect ratio %q", req.AspectRatio)
+		}
+		height = ImageWidth * h / w
+	}
+
+	response := &llm.ImageResponse{}
+	for i := range max(req.Count, 1) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256([]byte(req.Prompt + "\x00" + strconv.Itoa(int(i))))
+		img := image.NewRGBA(image.Rect(0, 0, ImageWidth, height))
+		fill := color.RGBA{R: sum[0], G: sum[1], B: sum[2], A: 255}
+		for y := range height {
+			for x := range ImageWidth {
+				img.SetRGBA(x, y, fill)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		response.Images = append(response.Images, llm.Blob{MIMEType: "image/png", Data: buf.Bytes()})
+	}
+	return response, nil
+}
+
+func (p *Provider) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	list := make([]llm.ModelInfo, len(p.Models))
+	for i, model := range p.Models {
+		list[i] = llm.ModelInfo{ID: model, DisplayName: model}
+	}
+	return list, nil
+}
+
+// next records req and returns the reply to it
+func (p *Provider) next(req *llm.Request) Reply {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Callers append to the messages of a request between the steps of a
+	// reply, so each step is recorded as it was sent
+	sent := *req
+	sent.Messages = slices.Clone(req.Messages)
+	p.requests = append(p.requests, &sent)
+	if len(p.replies) > 0 {
+		reply := p.replies[0]
+		p.replies = p.replies[1:]
+		return reply
+	}
+
+	var last string
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == llm.RoleUser {
+			last = req.Messages[i].Text()
+			var files []string
+			for _, part := range req.Messages[i].Parts {
+				if part.Data != nil {
+					files = append(files, part.Data.MIMEType)
+				}
+			}
+			if len(files) > 0 {
+				last += " [" + strings.Join(files, ", ") + "]"
+			}
+			break
+		}
+	}
+	return Reply{Text: "Echo: " + last}
+}
+
+// response returns a response of a single candidate made of text and calls.
+// The last response of a reply carries its finish reason and usage.
+func response(req *llm.Request, reply Reply, text string, calls []llm.ToolCall, last bool) *llm.Response {
+	if reply.BlockReason != "" {
+		return &llm.Response{BlockReason: reply.BlockReason}
+	}
+
+	candidate := llm.Candidate{Content: llm.Message{Role: llm.RoleModel}}
+	if text != "" {
+		candidate.Content.Parts = append(candidate.Content.Parts, llm.Part{Text: text})
+	}
+	for i := range calls {
+		candidate.Content.Parts = append(candidate.Content.Parts, llm.Part{ToolCall: &calls[i]})
+	}
+
+	res := &llm.Response{}
+	if last {
+		candidate.FinishReason = reply.FinishReason
+		if candidate.FinishReason == "" {
+			candidate.FinishReason = llm.FinishStop
+		}
+
+		prompt := promptTokens(req)
+		output := int32(len(strings.Fields(reply.Text)))
+		res.Usage = &models.Usage{PromptTokens: prompt, OutputTokens: output, TotalTokens: prompt + output}
+	}
+	res.Candidates = []llm.Candidate{candidate}
+	return res
+}
+
+// promptTokens counts the words of the system instruction and messages of req
+func promptTokens(req *llm.Request) int32 {
+	count := len(strings.Fields(req.System))
+	for _, message := range req.Messages {
+		count += len(strings.Fields(message.Text()))
+	}
+	return int32(count)
+}
@@ -0,0 +1,294 @@
+// Package gemini generates replies with the Gemini API.
+package gemini
+
+import (
+	"context"
+	"iter"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"google.golang.org/genai"
+)
+
+// Provider is a llm.Provider using a Gemini API key
+type Provider struct {
+	client *genai.Client
+}
+
+// New returns a provider using apiKey
+func New(ctx context.Context, apiKey string) (*Provider, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey: apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{client: client}, nil
+}
+
+func (p *Provider) Chat(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	contents, config := toGenai(req)
+	res, err := p.client.Models.GenerateContent(ctx, req.Model, contents, config)
+	if err != nil {
+		return nil, err
+	}
+	return fromGenai(res), nil
+}
+
+func (p *Provider) Stream(ctx context.Context, req *llm.Request) iter.Seq2[*llm.Response, error] {
+	contents, config := toGenai(req)
+	return func(yield func(*llm.Response, error) bool) {
+		for res, err := range p.client.Models.GenerateContentStream(ctx, req.Model, contents, config) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if res == nil {
+				continue
+			}
+			if !yield(fromGenai(res), nil) {
+				return
+			}
+		}
+	}
+}
+
+// CountTokens counts the system instruction as a message of the prompt, the
+// Gemini API not accepting instructions in token counts
+func (p *Provider) CountTokens(ctx context.Context, req *llm.Request) (int32, error) {
+	messages := req.Messages
+	if req.System != "" {
+		messages = append([]llm.Message{llm.NewTextMessage(llm.RoleUser, req.System)}, messages...)
+	}
+
+	res, err := p.client.Models.CountTokens(ctx, req.Model, toContents(messages), nil)
+	if err != nil {
+		return 0, err
+	}
+	return res.TotalTokens, nil
+}
+
+func (p *Provider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	contents := make([]*genai.Content, len(texts))
+	for i, text := range texts {
+		contents[i] = genai.NewContentFromText(text, genai.RoleUser)
+	}
+
+	res, err := p.client.Models.EmbedContent(ctx, model, contents, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(res.Embeddings))
+	for i, embedding := range res.Embeddings {
+		if embedding != nil {
+			embeddings[i] = embedding.Values
+		}
+	}
+	return embeddings, nil
+}
+
+func (p *Provider) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	var list []llm.ModelInfo
+	for model, err := range p.client.Models.All(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, llm.ModelInfo{
+			ID:               strings.TrimPrefix(model.Name, "models/"),
+			DisplayName:      model.DisplayName,
+			InputTokenLimit:  model.InputTokenLimit,
+			OutputTokenLimit: model.OutputTokenLimit,
+		})
+	}
+	return list, nil
+}
+
+// GenerateImages generates images with an Imagen model
+func (p *Provider) GenerateImages(ctx context.Context, req *llm.ImageRequest) (*llm.ImageResponse, error) {
+	config := &genai.GenerateImagesConfig{
+		NumberOfImages:   req.Count,
+		AspectRatio:      req.AspectRatio,
+		NegativePrompt:   req.NegativePrompt,
+		IncludeRAIReason: true,
+	}
+	res, err := p.client.Models.GenerateImages(ctx, req.Model, req.Prompt, config)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &llm.ImageResponse{}
+	for _, generated := range res.GeneratedImages {
+		if generated == nil {
+			continue
+		}
+		if generated.Image == nil || len(generated.Image.ImageBytes) == 0 {
+			if generated.RAIFilteredReason != "" {
+				response.Filtered = append(response.Filtered, generated.RAIFilteredReason)
+			}
+			continue
+		}
+
+		mimeType := generated.Image.MIMEType
+		if mimeType == "" {
+			mimeType = "image/png"
+		}
+		response.Images = append(response.Images, llm.Blob{MIMEType: mimeType, Data: generated.Image.ImageBytes})
+	}
+	return response, nil
+}
+
+// toGenai converts req to the contents and configuration of a Gemini request
+func toGenai(req *llm.Request) ([]*genai.Content, *genai.GenerateContentConfig) {
+	config := &genai.GenerateContentConfig{}
+	if req.Config != nil {
+		config = toConfig(req.Config)
+	}
+	if req.System != "" {
+		config.SystemInstruction = &genai.Content{Parts: []*genai.Part{genai.NewPartFromText(req.System)}}
+	}
+	config.Tools, config.ToolConfig = toTools(req.Tools, req.ToolChoice)
+
+	return toContents(req.Messages), config
+}
+
+func toConfig(c *models.GenerationConfig) *genai.GenerateContentConfig {
+	gc := &genai.GenerateContentConfig{
+		Temperature:      c.Temperature,
+		TopP:             c.TopP,
+		StopSequences:    c.StopSequences,
+		Seed:             c.Seed,
+		PresencePenalty:  c.PresencePenalty,
+		FrequencyPenalty: c.FrequencyPenalty,
+	}
+	if c.TopK != nil {
+		topK := float32(*c.TopK)
+		gc.TopK = &topK
+	}
+	if c.MaxOutputTokens != nil {
+		gc.MaxOutputTokens = *c.MaxOutputTokens
+	}
+	if c.CandidateCount != nil {
+		gc.CandidateCount = *c.CandidateCount
+	}
+	return gc
+}
+
+func toContents(messages []llm.Message) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(messages))
+	for _, message := range messages {
+		var role genai.Role = genai.RoleUser
+		if message.Role == llm.RoleModel {
+			role = genai.RoleModel
+		}
+
+		var parts []*genai.Part
+		for _, part := range message.Parts {
+			switch {
+			case part.Thought:
+				// Thoughts of earlier replies are not sent back
+			case part.Data != nil:
+				parts = append(parts, genai.NewPartFromBytes(part.Data.Data, part.Data.MIMEType))
+			case part.ToolCall != nil:
+				parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+					ID:   part.ToolCall.ID,
+					Name: part.ToolCall.Name,
+					Args: part.ToolCall.Args,
+				}})
+			case part.ToolResult != nil:
+				parts = append(parts, &genai.Part{FunctionResponse: &genai.FunctionResponse{
+					ID:       part.ToolResult.ID,
+					Name:     part.ToolResult.Name,
+					Response: part.ToolResult.Response,
+				}})
+			default:
+				parts = append(parts, genai.NewPartFromText(part.Text))
+			}
+		}
+		contents = append(contents, genai.NewContentFromParts(parts, role))
+	}
+	return contents
+}
+
+func toTools(tools []llm.Tool, choice *llm.ToolChoice) ([]*genai.Tool, *genai.ToolConfig) {
+	if len(tools) == 0 {
+		return nil, nil
+	}
+
+	tool := &genai.Tool{}
+	for _, t := range tools {
+		declaration := &genai.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+		}
+		if t.Parameters != nil {
+			declaration.ParametersJsonSchema = t.Parameters
+		}
+		tool.FunctionDeclarations = append(tool.FunctionDeclarations, declaration)
+	}
+
+	if choice == nil {
+		return []*genai.Tool{tool}, nil
+	}
+
+	calling := &genai.FunctionCallingConfig{}
+	switch choice.Mode {
+	case llm.ToolNone:
+		calling.Mode = genai.FunctionCallingConfigModeNone
+	case llm.ToolRequired:
+		calling.Mode = genai.FunctionCallingConfigModeAny
+		calling.AllowedFunctionNames = choice.Functions
+	default:
+		calling.Mode = genai.FunctionCallingConfigModeAuto
+	}
+	return []*genai.Tool{tool}, &genai.ToolConfig{FunctionCallingConfig: calling}
+}
+
+func fromGenai(res *genai.GenerateContentResponse) *llm.Response {
+	response := &llm.Response{}
+	for i, candidate := range res.Candidates {
+		if candidate == nil {
+			continue
+		}
+
+		c := llm.Candidate{Index: i, Content: llm.Message{Role: llm.RoleModel}}
+		if candidate.Index > 0 {
+			c.Index = int(candidate.Index)
+		}
+		if candidate.Content != nil {
+			for _, part := range candidate.Content.Parts {
+				if part == nil {
+					continue
+				}
+				switch {
+				case part.FunctionCall != nil:
+					c.Content.Parts = append(c.Content.Parts, llm.Part{ToolCall: &llm.ToolCall{
+						ID:   part.FunctionCall.ID,
+						Name: part.FunctionCall.Name,
+						Args: part.FunctionCall.Args,
+					}})
+				case part.Text != "":
+					c.Content.Parts = append(c.Content.Parts, llm.Part{Text: part.Text, Thought: part.Thought})
+				}
+			}
+		}
+		if candidate.FinishReason != "" && candidate.FinishReason != genai.FinishReasonUnspecified {
+			c.FinishReason = strings.ToLower(string(candidate.FinishReason))
+		}
+		response.Candidates = append(response.Candidates, c)
+	}
+
+	if metadata := res.UsageMetadata; metadata != nil {
+		response.Usage = &models.Usage{
+			PromptTokens:   metadata.PromptTokenCount,
+			OutputTokens:   metadata.CandidatesTokenCount,
+			ThoughtsTokens: metadata.ThoughtsTokenCount,
+			TotalTokens:    metadata.TotalTokenCount,
+		}
+	}
+	if res.PromptFeedback != nil && res.PromptFeedback.BlockReason != "" {
+		response.BlockReason = strings.ToLower(string(res.PromptFeedback.BlockReason))
+	}
+	return response
+}
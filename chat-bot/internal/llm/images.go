@@ -0,0 +1,30 @@
+package llm
+
+import "context"
+
+// ImageGenerator is implemented by the providers whose backend generates images
+type ImageGenerator interface {
+	// GenerateImages generates the images described by req
+	GenerateImages(ctx context.Context, req *ImageRequest) (*ImageResponse, error)
+}
+
+// ImageRequest asks a model for images
+type ImageRequest struct {
+	Model  string
+	Prompt string
+	// NegativePrompt describes what the images must not show
+	NegativePrompt string
+	// AspectRatio is width:height such as "16:9", the backend's default when empty
+	AspectRatio string
+	// Count is the number of images
+	Count int32
+}
+
+// ImageResponse holds the images generated. Backends filter out images that
+// break their safety policies, which may leave fewer images than requested.
+type ImageResponse struct {
+	Images []Blob
+	// Filtered holds the reasons the filtered images were removed for, when
+	// the backend reports them
+	Filtered []string
+}
@@ -0,0 +1,258 @@
+// Package openaicompat generates replies with servers speaking the OpenAI
+// chat completions API: OpenAI itself, or local servers such as Ollama and
+// llama.cpp.
+package openaicompat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/openai"
+)
+
+// Base URLs of well-known servers
+const (
+	OpenAIBaseURL = "https://api.openai.com/v1"
+	OllamaBaseURL = "http://localhost:11434/v1"
+)
+
+// maxLineSize bounds the lines of streamed responses
+const maxLineSize = 1 << 20
+
+// Provider is a llm.Provider sending requests to BaseURL
+type Provider struct {
+	// BaseURL is the URL the API paths such as /chat/completions are appended to
+	BaseURL string
+	// APIKey is sent as a bearer token, local servers usually need none
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// New returns a provider for the server of baseURL
+func New(baseURL string, apiKey string) *Provider {
+	return &Provider{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+func (p *Provider) Chat(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	body, err := openai.FromLLMRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var completion openai.ChatCompletion
+	if err := p.do(ctx, http.MethodPost, "/chat/completions", body, &completion); err != nil {
+		return nil, err
+	}
+	return openai.ToLLMResponse(&completion)
+}
+
+// Stream yields the text of the reply as it is written. Tool calls, whose
+// arguments are streamed in fragments, are yielded once complete.
+func (p *Provider) Stream(ctx context.Context, req *llm.Request) iter.Seq2[*llm.Response, error] {
+	return func(yield func(*llm.Response, error) bool) {
+		body, err := openai.FromLLMRequest(req)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		body.Stream = true
+		body.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+		res, err := p.send(ctx, http.MethodPost, "/chat/completions", body)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer res.Body.Close()
+
+		calls := toolCalls{}
+		scanner := bufio.NewScanner(res.Body)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				return
+			}
+
+			var chunk openai.ChatCompletionChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				yield(nil, fmt.Errorf("error decoding chunk: %w", err))
+				return
+			}
+
+			response, err := calls.add(&chunk)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(response, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// CountTokens is not part of the OpenAI API
+func (p *Provider) CountTokens(ctx context.Context, req *llm.Request) (int32, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func (p *Provider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	var res openai.EmbeddingResponse
+	if err := p.do(ctx, http.MethodPost, "/embeddings", openai.EmbeddingRequest{Model: model, Input: texts}, &res); err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, embedding := range res.Data {
+		if embedding.Index >= 0 && embedding.Index < len(embeddings) {
+			embeddings[embedding.Index] = embedding.Embedding
+		}
+	}
+	return embeddings, nil
+}
+
+func (p *Provider) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	var res openai.ModelList
+	if err := p.do(ctx, http.MethodGet, "/models", nil, &res); err != nil {
+		return nil, err
+	}
+
+	list := make([]llm.ModelInfo, len(res.Data))
+	for i, model := range res.Data {
+		list[i] = llm.ModelInfo{ID: model.ID, DisplayName: model.ID}
+	}
+	return list, nil
+}
+
+// do sends a request with body encoded as JSON, and decodes the response into v
+func (p *Provider) do(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	res, err := p.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding response of %s: %w", path, err)
+	}
+	return nil
+}
+
+// send sends a request with body encoded as JSON, turning error statuses into errors
+func (p *Provider) send(ctx context.Context, method string, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+
+		var failure openai.ErrorResponse
+		if json.Unmarshal(data, &failure) == nil && failure.Error.Message != "" {
+			return nil, fmt.Errorf("%s %s: %s (status %d)", method, path, failure.Error.Message, res.StatusCode)
+		}
+		return nil, fmt.Errorf("%s %s: status %d", method, path, res.StatusCode)
+	}
+	return res, nil
+}
+
+// toolCalls gathers the fragments of the tool calls of a stream, by choice
+// then by index within the choice
+type toolCalls map[int]map[int]*openai.ToolCall
+
+// add converts chunk, holding back its tool calls until their choice finishes
+func (c toolCalls) add(chunk *openai.ChatCompletionChunk) (*llm.Response, error) {
+	response := &llm.Response{Usage: openai.ToLLMUsage(chunk.Usage)}
+
+	for _, choice := range chunk.Choices {
+		candidate := llm.Candidate{Index: choice.Index, Content: llm.Message{Role: llm.RoleModel}}
+		if choice.Delta.Content != "" {
+			candidate.Content.Parts = append(candidate.Content.Parts, llm.Part{Text: choice.Delta.Content})
+		}
+
+		for position, call := range choice.Delta.ToolCalls {
+			index := position
+			if call.Index != nil {
+				index = *call.Index
+			}
+			if c[choice.Index] == nil {
+				c[choice.Index] = map[int]*openai.ToolCall{}
+			}
+			pending, ok := c[choice.Index][index]
+			if !ok {
+				pending = &openai.ToolCall{}
+				c[choice.Index][index] = pending
+			}
+			if call.ID != "" {
+				pending.ID = call.ID
+			}
+			pending.Function.Name += call.Function.Name
+			pending.Function.Arguments += call.Function.Arguments
+		}
+
+		if choice.FinishReason != nil {
+			for _, index := range slices.Sorted(maps.Keys(c[choice.Index])) {
+				part, err := openai.ToLLMToolCall(*c[choice.Index][index])
+				if err != nil {
+					return nil, err
+				}
+				candidate.Content.Parts = append(candidate.Content.Parts, part)
+			}
+			delete(c, choice.Index)
+
+			candidate.FinishReason = openai.ToLLMFinishReason(*choice.FinishReason)
+		}
+
+		response.Candidates = append(response.Candidates, candidate)
+	}
+	return response, nil
+}
@@ -0,0 +1,183 @@
+// Package llm defines the interface of the backends replies are generated
+// with, and the provider-neutral requests and responses they exchange. The
+// backends live in the subpackages: gemini, openaicompat for servers speaking
+// the OpenAI API such as Ollama or llama.cpp, and fake for offline tests.
+package llm
+
+import (
+	"context"
+	"iter"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// Provider generates replies with the models of a backend. Operations a
+// backend does not offer return errors.ErrUnsupported. Providers of backends
+// generating images also implement ImageGenerator.
+type Provider interface {
+	// Chat generates the reply to req
+	Chat(ctx context.Context, req *Request) (*Response, error)
+	// Stream generates the reply to req, yielding it in chunks as it is written
+	Stream(ctx context.Context, req *Request) iter.Seq2[*Response, error]
+	// CountTokens returns the number of tokens of the prompt of req
+	CountTokens(ctx context.Context, req *Request) (int32, error)
+	// Embed returns the embedding of each text, computed by model
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+	// ListModels returns the models the backend serves
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// Roles of the messages
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+// Finish reasons of the candidates. Backends report the reasons they know of
+// in lowercase, these are the ones the chat-bot handles specifically.
+const (
+	FinishStop              = "stop"
+	FinishMaxTokens         = "max_tokens"
+	FinishSafety            = "safety"
+	FinishRecitation        = "recitation"
+	FinishBlocklist         = "blocklist"
+	FinishProhibitedContent = "prohibited_content"
+	FinishSPII              = "spii"
+	FinishImageSafety       = "image_safety"
+)
+
+// Modes of ToolChoice
+const (
+	ToolAuto     = "auto"
+	ToolNone     = "none"
+	ToolRequired = "required"
+)
+
+// Request asks a model for the reply to a conversation
+type Request struct {
+	Model string
+	// System is the system instruction, none when empty
+	System   string
+	Messages []Message
+	// Config holds the generation settings, the backend's defaults when nil
+	Config *models.GenerationConfig
+
+	Tools      []Tool
+	ToolChoice *ToolChoice
+}
+
+// Message is a turn of the conversation
+type Message struct {
+	Role  string
+	Parts []Part
+}
+
+// Part is a piece of a message: text, a file, a tool call of the model, or
+// the result of a tool call sent back to the model
+type Part struct {
+	Text string
+	// Thought marks the text of the model's reasoning, which is not part of its reply
+	Thought    bool
+	Data       *Blob
+	ToolCall   *ToolCall
+	ToolResult *ToolResult
+}
+
+// Blob is a file sent inline, such as an image or a PDF
+type Blob struct {
+	MIMEType string
+	Data     []byte
+}
+
+type ToolCall struct {
+	// ID matches the call with its result, backends that do not identify
+	// calls leave it empty
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+type ToolResult struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// Tool is a function the model may call
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments
+	Parameters map[string]any
+}
+
+// ToolChoice restricts the tool calls of the model
+type ToolChoice struct {
+	// Mode is ToolAuto, ToolNone or ToolRequired
+	Mode string
+	// Functions limits the functions called in ToolRequired mode, any when empty
+	Functions []string
+}
+
+// Response is a reply, or a chunk of a streamed reply
+type Response struct {
+	Candidates []Candidate
+	// Usage holds the tokens counted for the reply, nil when the backend does
+	// not report them. Streamed replies report them on their last chunks.
+	Usage *models.Usage
+	// BlockReason is set in lowercase when the prompt was blocked
+	BlockReason string
+}
+
+type Candidate struct {
+	Index   int
+	Content Message
+	// FinishReason is set in lowercase once the candidate is complete
+	FinishReason string
+}
+
+// ModelInfo describes a model served by a backend
+type ModelInfo struct {
+	ID          string
+	DisplayName string
+	// Token limits are zero when the backend does not report them
+	InputTokenLimit  int32
+	OutputTokenLimit int32
+}
+
+// NewTextMessage returns a message of role made of text
+func NewTextMessage(role string, text string) Message {
+	return Message{Role: role, Parts: []Part{{Text: text}}}
+}
+
+// Text returns the text of m, leaving out thoughts
+func (m Message) Text() string {
+	var text strings.Builder
+	for _, part := range m.Parts {
+		if !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
+
+// ToolCalls returns the tool calls of m
+func (m Message) ToolCalls() []ToolCall {
+	var calls []ToolCall
+	for _, part := range m.Parts {
+		if part.ToolCall != nil {
+			calls = append(calls, *part.ToolCall)
+		}
+	}
+	return calls
+}
+
+// IsContentFilter reports whether reason means the reply was filtered
+func IsContentFilter(reason string) bool {
+	switch reason {
+	case FinishSafety, FinishRecitation, FinishBlocklist, FinishProhibitedContent, FinishSPII, FinishImageSafety:
+		return true
+	}
+	return false
+}
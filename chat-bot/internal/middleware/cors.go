@@ -0,0 +1,153 @@
+// Package middleware holds the HTTP middleware of the chat-bot.
+//
+// cors.go is a copy of server/src/middleware/cors.go, which is the source of
+// truth of the CORS policy of both services: change that file, then copy the
+// change here along with cors_test.go. Only the import of the environment
+// helpers and the exposed headers differ.
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+)
+
+// CORSPolicy decides which browser origins may call the API and what they may do.
+type CORSPolicy struct {
+	// AllowedOrigins holds exact origins ("https://app.example.com"), patterns with
+	// * wildcards ("https://*.example.com", "http://localhost:*") or "*" for any origin.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and HTTP authentication to
+	// the allowed origins. It cannot be combined with "*", see Validate.
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// errCredentialedWildcard is returned by Validate for policies letting any
+// site make credentialed requests
+var errCredentialedWildcard = errors.New(`CORS_ALLOW_CREDENTIALS cannot be enabled when CORS_ALLOWED_ORIGINS is "*", list the origins instead`)
+
+// defaultOrigins are used when CORS_ALLOWED_ORIGINS is not set. Staging and
+// production deliberately allow nothing until origins are configured.
+var defaultOrigins = map[string][]string{
+	"development": {"http://localhost:*", "http://127.0.0.1:*"},
+	"staging":     {},
+	"production":  {},
+}
+
+// LoadCORSPolicy builds the policy from APP_ENV and the CORS_* environment variables.
+func LoadCORSPolicy() CORSPolicy {
+	env := config.GetEnv("APP_ENV", "development")
+
+	return CORSPolicy{
+		AllowedOrigins: config.GetEnvList("CORS_ALLOWED_ORIGINS", defaultOrigins[env]),
+		AllowedMethods: config.GetEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		AllowedHeaders: config.GetEnvList("CORS_ALLOWED_HEADERS", []string{
+			"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "X-CSRF-Token", "Last-Event-ID",
+		}),
+		ExposedHeaders:   config.GetEnvList("CORS_EXPOSED_HEADERS", []string{"Retry-After", "Allow", "X-Generation-ID"}),
+		AllowCredentials: config.GetEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		MaxAge:           config.GetEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+	}
+}
+
+// Validate rejects policies that would let any origin make credentialed
+// requests, which exposes the responses of logged-in users to every site
+func (p CORSPolicy) Validate() error {
+	if p.AllowCredentials && p.allowsAnyOrigin() {
+		return errCredentialedWildcard
+	}
+	return nil
+}
+
+func (p CORSPolicy) allowsAnyOrigin() bool {
+	return slices.Contains(p.AllowedOrigins, "*")
+}
+
+// AllowsOrigin reports whether origin matches one of the allowed origins or patterns.
+func (p CORSPolicy) AllowsOrigin(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if strings.Contains(allowed, "*") {
+			if ok, _ := path.Match(strings.ToLower(allowed), strings.ToLower(origin)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowOriginValue returns the Access-Control-Allow-Origin value for an allowed origin.
+// Origins allowed by "*" get "*", which browsers never send credentials to.
+func (p CORSPolicy) allowOriginValue(origin string) string {
+	if p.allowsAnyOrigin() {
+		return "*"
+	}
+	return origin
+}
+
+// allowsCredentials reports whether credentialed requests are allowed. A
+// policy Validate rejects never allows them, in case it is used anyway.
+func (p CORSPolicy) allowsCredentials() bool {
+	return p.AllowCredentials && !p.allowsAnyOrigin()
+}
+
+// CORSMiddleware applies policy to every request and answers preflight requests itself.
+func CORSMiddleware(policy CORSPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// The response depends on the Origin header, so caches must key on it
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !policy.AllowsOrigin(origin) {
+				if preflight {
+					// Without CORS headers the browser rejects the actual request
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", policy.allowOriginValue(origin))
+			if policy.allowsCredentials() {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			// Handle preflight requests
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+				if policy.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if len(policy.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
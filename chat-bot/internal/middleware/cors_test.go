@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadCORSPolicyDisallowsCredentialsByDefault(t *testing.T) {
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "")
+
+	if LoadCORSPolicy().AllowCredentials {
+		t.Error("AllowCredentials = true, want false unless CORS_ALLOW_CREDENTIALS is set")
+	}
+}
+
+func TestCORSPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  CORSPolicy
+		wantErr bool
+	}{
+		{"wildcard without credentials", CORSPolicy{AllowedOrigins: []string{"*"}}, false},
+		{"wildcard with credentials", CORSPolicy{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}, true},
+		{"listed origins with credentials", CORSPolicy{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          CORSPolicy
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{
+			name:       "wildcard",
+			policy:     CORSPolicy{AllowedOrigins: []string{"*"}},
+			origin:     "https://evil.example",
+			wantOrigin: "*",
+		},
+		{
+			name:       "wildcard never allows credentials",
+			policy:     CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			origin:     "https://evil.example",
+			wantOrigin: "*",
+		},
+		{
+			name:            "listed origin with credentials",
+			policy:          CORSPolicy{AllowedOrigins: []string{"http://localhost:*"}, AllowCredentials: true},
+			origin:          "http://localhost:3000",
+			wantOrigin:      "http://localhost:3000",
+			wantCredentials: "true",
+		},
+		{
+			name:   "origin not allowed",
+			policy: CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			origin: "https://evil.example",
+		},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		CORSMiddleware(tt.policy)(next).ServeHTTP(w, r)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+			t.Errorf("%s: Access-Control-Allow-Credentials = %q, want %q", tt.name, got, tt.wantCredentials)
+		}
+	}
+}
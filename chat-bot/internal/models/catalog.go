@@ -0,0 +1,15 @@
+package models
+
+import "github.com/Mahaveer86619/ImaginAI/internal/catalog"
+
+type ModelInfo struct {
+	catalog.Model
+	// Available is false for models the user's plan does not include
+	Available bool `json:"available"`
+}
+
+type ModelListResponse struct {
+	Models []ModelInfo `json:"models"`
+	// DefaultModel is the model chats of the user run on when they name none
+	DefaultModel string `json:"default_model"`
+}
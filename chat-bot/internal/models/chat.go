@@ -1,18 +1,140 @@
 package models
 
+import "strings"
+
 type ChatMessage struct {
-	Role    string `json:"role"`
+	Role string `json:"role"`
+	// Message is the text of the message
 	Message string `json:"message"`
+	// Parts is set on messages with files, listing their text and files in order
+	Parts []MessagePart `json:"parts,omitempty"`
+}
+
+// Types of the message parts
+const (
+	PartText = "text"
+	// PartInlineData parts carry a file in Data, they are stored as PartFile parts
+	PartInlineData = "inline_data"
+	PartFile       = "file"
+)
+
+// MessagePart is a piece of a message: text or a file
+type MessagePart struct {
+	// Type is PartText, PartInlineData or PartFile
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	MIMEType string `json:"mime_type,omitempty"`
+	// Data is the content of files, base64 encoded in JSON. It is only sent by
+	// clients, stored files are downloaded by AttachmentID.
+	Data []byte `json:"data,omitempty"`
+	// File names the multipart field of the uploaded file a part of a request refers to
+	File string `json:"file,omitempty"`
+	// Name is the file name of the file
+	Name string `json:"name,omitempty"`
+	// Size is the size of the file in bytes
+	Size int `json:"size,omitempty"`
+	// AttachmentID identifies the file once stored
+	AttachmentID string `json:"attachment_id,omitempty"`
+}
+
+// PartsText returns the text of parts, one line per text part
+func PartsText(parts []MessagePart) string {
+	var lines []string
+	for _, part := range parts {
+		if part.Type == PartText {
+			lines = append(lines, part.Text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// HasFiles reports whether parts contain a file
+func HasFiles(parts []MessagePart) bool {
+	for _, part := range parts {
+		if part.Type != PartText {
+			return true
+		}
+	}
+	return false
 }
 
+// WithoutData returns parts without the content of their files
+func WithoutData(parts []MessagePart) []MessagePart {
+	if parts == nil {
+		return nil
+	}
+	stripped := make([]MessagePart, len(parts))
+	for i, part := range parts {
+		part.Data = nil
+		stripped[i] = part
+	}
+	return stripped
+}
+
+// ChatRequest continues the conversation ConversationID with Message. The
+// history is loaded from the database, a new conversation is started when
+// ConversationID is empty.
 type ChatRequest struct {
-	Message string        `json:"message"`
-	History []ChatMessage `json:"history"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	Message        string `json:"message"`
+	// Parts holds the text and files of the message in order. Message is a
+	// shorthand for a text part, placed first when both are set.
+	Parts []MessagePart `json:"parts,omitempty"`
+	// Tools names the tools the model may call while replying, see GET /tools
+	Tools []string `json:"tools,omitempty"`
+	// PersonaID selects the latest version of a persona for the conversation,
+	// which keeps using that version until another persona is selected
+	PersonaID string `json:"persona_id,omitempty"`
+	// System sets the inline system instruction of the conversation, an empty
+	// string removes it. It follows the persona's instruction when both are set.
+	System *string `json:"system,omitempty"`
+	// Model is the id of a model of the catalog, the user's default when empty
+	Model string `json:"model,omitempty"`
+	// Generation overrides the default generation settings for this message
+	Generation *GenerationConfig `json:"generation,omitempty"`
 }
 
 type ChatResponse struct {
-	Response string        `json:"response"`
-	History  []ChatMessage `json:"history"`
+	ConversationID string        `json:"conversation_id"`
+	Model          string        `json:"model"`
+	PersonaID      string        `json:"persona_id,omitempty"`
+	PersonaVersion int           `json:"persona_version,omitempty"`
+	Response       string        `json:"response"`
+	History        []ChatMessage `json:"history"`
+	// Generation holds the settings the reply was generated with
+	Generation *GenerationConfig `json:"generation"`
+	// FinishReason tells why the model stopped, see FinishStop
+	FinishReason string `json:"finish_reason"`
+	Usage        *Usage `json:"usage,omitempty"`
+	// ToolCalls lists the tools the model called while replying, in order
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall is a call of a tool by the model. Result or Error are set once it ran.
+type ToolCall struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	Result    map[string]any `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	// Step counts the replies of the model before the call, from 1
+	Step int `json:"step"`
 }
 
+// ToolInfo describes a tool models may call
+type ToolInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is the JSON schema of the arguments
+	Parameters map[string]any `json:"parameters"`
+}
+
+type ToolListResponse struct {
+	Tools []ToolInfo `json:"tools"`
+}
 
+// APIKeyRequest is the body of POST /setup
+type APIKeyRequest struct {
+	APIKey string `json:"api_key"`
+}
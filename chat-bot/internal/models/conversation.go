@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+type Conversation struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// PersonaID and PersonaVersion are the persona the conversation runs with
+	PersonaID      string `json:"persona_id,omitempty"`
+	PersonaVersion int    `json:"persona_version,omitempty"`
+	// System is the inline system instruction of the conversation
+	System string `json:"system,omitempty"`
+	// Messages is only set when a single conversation is requested
+	Messages []Message `json:"messages,omitempty"`
+}
+
+type Message struct {
+	ID      string `json:"id"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Parts is set on messages with files, listing their text and files in order
+	Parts     []MessagePart `json:"parts,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	// FinishReason is set on model messages, see FinishStop
+	FinishReason string `json:"finish_reason,omitempty"`
+}
+
+type ConversationRequest struct {
+	Title string `json:"title"`
+}
+
+type ConversationListResponse struct {
+	Conversations []*Conversation `json:"conversations"`
+	Total         int             `json:"total"`
+	Limit         int             `json:"limit"`
+	Offset        int             `json:"offset"`
+}
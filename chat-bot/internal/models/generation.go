@@ -0,0 +1,15 @@
+package models
+
+// GenerationConfig tunes how the model generates a reply. Omitted fields take
+// the chat-bot's defaults.
+type GenerationConfig struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	TopK             *int32   `json:"top_k,omitempty"`
+	MaxOutputTokens  *int32   `json:"max_output_tokens,omitempty"`
+	StopSequences    []string `json:"stop_sequences,omitempty"`
+	Seed             *int32   `json:"seed,omitempty"`
+	CandidateCount   *int32   `json:"candidate_count,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+}
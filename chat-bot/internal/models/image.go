@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// ImageRequest asks for images generated from a prompt
+type ImageRequest struct {
+	Prompt string `json:"prompt"`
+	// NegativePrompt describes what the images must not show
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	// AspectRatio is one of 1:1, 3:4, 4:3, 9:16 and 16:9, 1:1 when empty
+	AspectRatio string `json:"aspect_ratio,omitempty"`
+	// Count is the number of images, 1 when zero
+	Count int `json:"count,omitempty"`
+	// Style is one of the styles of the images package, none when empty
+	Style string `json:"style,omitempty"`
+}
+
+// Image is a generated image of the gallery of a user
+type Image struct {
+	ID             string `json:"id"`
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	AspectRatio    string `json:"aspect_ratio"`
+	Style          string `json:"style,omitempty"`
+	Model          string `json:"model"`
+	MIMEType       string `json:"mime_type"`
+	// Size is the size of the file in bytes
+	Size int `json:"size"`
+	// URL is the path the file is downloaded from
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ImageGenerateResponse struct {
+	Images []*Image `json:"images"`
+	// Filtered counts the images the model's safety filters removed
+	Filtered int `json:"filtered,omitempty"`
+}
+
+type ImageListResponse struct {
+	Images []*Image `json:"images"`
+	Total  int      `json:"total"`
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
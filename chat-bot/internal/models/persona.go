@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Persona is a named system instruction. Global personas are managed by admins
+// and offered to every user, the others belong to the user who created them.
+type Persona struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Global      bool      `json:"global"`
+	Version     int       `json:"version"`
+	Instruction string    `json:"instruction"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type PersonaVersion struct {
+	Version     int       `json:"version"`
+	Instruction string    `json:"instruction"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PersonaRequest creates or updates a persona. Global can only be set by
+// admins when creating a persona.
+type PersonaRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Instruction string `json:"instruction"`
+	Global      bool   `json:"global"`
+}
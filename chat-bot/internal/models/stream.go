@@ -0,0 +1,47 @@
+package models
+
+// Names of the events sent by /stream
+const (
+	EventDelta   = "delta"
+	EventUsage   = "usage"
+	EventFinish  = "finish"
+	EventError   = "error"
+	EventHistory = "history"
+	// EventToolCall and EventToolResult carry a ToolCall, before and after it runs
+	EventToolCall   = "tool_call"
+	EventToolResult = "tool_result"
+)
+
+// FinishStop is the finish reason of replies the model completed. Other
+// reasons are the model's, lowercased: max_tokens, safety, recitation...
+const FinishStop = "stop"
+
+// Finish reasons of replies interrupted by the chat-bot, saved as far as they got
+const (
+	// FinishCancelled replies were cancelled, or abandoned by their clients
+	FinishCancelled = "cancelled"
+	// FinishTimeout replies took longer than the generation timeout
+	FinishTimeout = "timeout"
+)
+
+// DeltaEvent carries the text appended to the reply since the previous delta
+type DeltaEvent struct {
+	Text string `json:"text"`
+}
+
+// Usage counts the tokens of a reply
+type Usage struct {
+	PromptTokens int32 `json:"prompt_tokens"`
+	OutputTokens int32 `json:"output_tokens"`
+	// ThoughtsTokens are spent by thinking models before they answer
+	ThoughtsTokens int32 `json:"thoughts_tokens,omitempty"`
+	TotalTokens    int32 `json:"total_tokens"`
+}
+
+type FinishEvent struct {
+	Reason string `json:"reason"`
+}
+
+type ErrorEvent struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,54 @@
+package models
+
+// Types of the messages of the /ws protocol
+const (
+	// Sent by clients
+	WSSend       = "send"
+	WSCancel     = "cancel"
+	WSRegenerate = "regenerate"
+	// Sent by clients and relayed to the other connections of the same user
+	WSTyping = "typing"
+	// Sent by the chat-bot
+	WSDelta      = "delta"
+	WSToolCall   = "tool_call"
+	WSToolResult = "tool_result"
+	WSDone       = "done"
+	WSError      = "error"
+)
+
+// WSClientMessage is a message sent by clients over /ws
+type WSClientMessage struct {
+	Type string `json:"type"`
+	// RequestID is chosen by the client for send and regenerate, and echoed
+	// by the messages about the generation. cancel names the generation to
+	// stop by its request id.
+	RequestID string `json:"request_id,omitempty"`
+	// Chat is the message to send for send. For regenerate, it names the
+	// conversation whose last reply is generated again, and may change the
+	// model and settings.
+	Chat *ChatRequest `json:"chat,omitempty"`
+	// ConversationID and Typing are set on typing messages
+	ConversationID string `json:"conversation_id,omitempty"`
+	Typing         bool   `json:"typing,omitempty"`
+}
+
+// WSServerMessage is a message sent by the chat-bot over /ws
+type WSServerMessage struct {
+	Type         string `json:"type"`
+	RequestID    string `json:"request_id,omitempty"`
+	GenerationID string `json:"generation_id,omitempty"`
+	// Text is the text appended to the reply, for delta
+	Text string `json:"text,omitempty"`
+	// ToolCall is the call of a tool, for tool_call and tool_result
+	ToolCall *ToolCall `json:"tool_call,omitempty"`
+	// FinishReason, Usage and Response end a generation, for done. Response
+	// is omitted when nothing was saved.
+	FinishReason string        `json:"finish_reason,omitempty"`
+	Usage        *Usage        `json:"usage,omitempty"`
+	Response     *ChatResponse `json:"response,omitempty"`
+	// Message describes the error, for error
+	Message string `json:"message,omitempty"`
+	// ConversationID and Typing are relayed from another connection, for typing
+	ConversationID string `json:"conversation_id,omitempty"`
+	Typing         bool   `json:"typing,omitempty"`
+}
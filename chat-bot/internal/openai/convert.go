@@ -0,0 +1,477 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/google/uuid"
+)
+
+// Generation returns the generation settings of req
+func (req *ChatCompletionRequest) Generation() *models.GenerationConfig {
+	maxTokens := req.MaxCompletionTokens
+	if maxTokens == nil {
+		maxTokens = req.MaxTokens
+	}
+
+	return &models.GenerationConfig{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		MaxOutputTokens:  maxTokens,
+		StopSequences:    req.Stop,
+		Seed:             req.Seed,
+		CandidateCount:   req.N,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+}
+
+// ToLLMMessages converts messages to the messages of a llm.Request. System
+// and developer messages are combined into the returned system instruction.
+// Consecutive messages of the same role are merged, as tool results must
+// answer the calls of the previous turn together.
+func ToLLMMessages(messages []Message) ([]llm.Message, string, error) {
+	if len(messages) == 0 {
+		return nil, "", errors.New("messages must not be empty")
+	}
+
+	var converted []llm.Message
+	var system []string
+	// toolNames maps the ids of the tool calls to their function, which
+	// backends such as Gemini need along with the results
+	toolNames := map[string]string{}
+
+	appendParts := func(role string, parts ...llm.Part) {
+		if len(parts) == 0 {
+			return
+		}
+		if last := len(converted) - 1; last >= 0 && converted[last].Role == role {
+			converted[last].Parts = append(converted[last].Parts, parts...)
+			return
+		}
+		converted = append(converted, llm.Message{Role: role, Parts: parts})
+	}
+
+	for i, message := range messages {
+		if message.Content != nil && len(message.Content.Unsupported) > 0 {
+			return nil, "", fmt.Errorf("messages[%d]: content parts of type %s are not supported", i, strings.Join(message.Content.Unsupported, ", "))
+		}
+		var text string
+		if message.Content != nil {
+			text = message.Content.Text
+		}
+
+		switch message.Role {
+		case RoleSystem, RoleDeveloper:
+			system = append(system, text)
+		case RoleUser:
+			parts, err := userParts(message.Content)
+			if err != nil {
+				return nil, "", fmt.Errorf("messages[%d]: %w", i, err)
+			}
+			appendParts(llm.RoleUser, parts...)
+		case RoleAssistant:
+			var parts []llm.Part
+			if text != "" {
+				parts = append(parts, llm.Part{Text: text})
+			}
+			for _, call := range message.ToolCalls {
+				var args map[string]any
+				if call.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+						return nil, "", fmt.Errorf("messages[%d]: arguments of tool call %s must be a JSON object", i, call.ID)
+					}
+				}
+				toolNames[call.ID] = call.Function.Name
+				parts = append(parts, llm.Part{ToolCall: &llm.ToolCall{ID: call.ID, Name: call.Function.Name, Args: args}})
+			}
+			appendParts(llm.RoleModel, parts...)
+		case RoleTool:
+			name, ok := toolNames[message.ToolCallID]
+			if !ok {
+				return nil, "", fmt.Errorf("messages[%d]: tool_call_id %q does not match a previous tool call", i, message.ToolCallID)
+			}
+			appendParts(llm.RoleUser, llm.Part{ToolResult: &llm.ToolResult{ID: message.ToolCallID, Name: name, Response: toolResult(text)}})
+		default:
+			return nil, "", fmt.Errorf("messages[%d]: unknown role %q", i, message.Role)
+		}
+	}
+
+	if len(converted) == 0 {
+		return nil, "", errors.New("messages must contain a user message")
+	}
+	return converted, strings.Join(system, "\n\n"), nil
+}
+
+// userParts converts the content of a user message. Files must be sent
+// inline, as data: URLs or base64 audio.
+func userParts(content *Content) ([]llm.Part, error) {
+	if content == nil {
+		return []llm.Part{{}}, nil
+	}
+	if len(content.Parts) == 0 {
+		return []llm.Part{{Text: content.Text}}, nil
+	}
+
+	parts := make([]llm.Part, 0, len(content.Parts))
+	for _, part := range content.Parts {
+		switch part.Type {
+		case PartText:
+			parts = append(parts, llm.Part{Text: part.Text})
+		case PartImageURL:
+			blob, err := fromDataURL(part.ImageURL.URL)
+			if err != nil {
+				return nil, fmt.Errorf("image_url: %w", err)
+			}
+			parts = append(parts, llm.Part{Data: blob})
+		case PartFile:
+			blob, err := fromDataURL(part.File.FileData)
+			if err != nil {
+				return nil, fmt.Errorf("file: %w", err)
+			}
+			parts = append(parts, llm.Part{Data: blob})
+		case PartInputAudio:
+			data, err := base64.StdEncoding.DecodeString(part.InputAudio.Data)
+			if err != nil {
+				return nil, errors.New("input_audio: data must be base64 encoded")
+			}
+			mimeType, ok := audioTypes[part.InputAudio.Format]
+			if !ok {
+				return nil, fmt.Errorf("input_audio: unknown format %q", part.InputAudio.Format)
+			}
+			parts = append(parts, llm.Part{Data: &llm.Blob{MIMEType: mimeType, Data: data}})
+		}
+	}
+	return parts, nil
+}
+
+// audioTypes maps the formats of input_audio parts to MIME types
+var audioTypes = map[string]string{
+	"wav": "audio/wav",
+	"mp3": "audio/mpeg",
+}
+
+// fromDataURL decodes a base64 data: URL
+func fromDataURL(url string) (*llm.Blob, error) {
+	header, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	mimeType, isBase64 := strings.CutSuffix(header, ";base64")
+	if !strings.HasPrefix(url, "data:") || !ok || !isBase64 || mimeType == "" {
+		return nil, errors.New("files must be sent as base64 data: URLs")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, errors.New("data: URL is not base64 encoded")
+	}
+	return &llm.Blob{MIMEType: mimeType, Data: decoded}, nil
+}
+
+// toDataURL encodes blob as a data: URL
+func toDataURL(blob *llm.Blob) string {
+	return "data:" + blob.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(blob.Data)
+}
+
+// fromLLMBlob converts a file of a user message to a content part
+func fromLLMBlob(blob *llm.Blob) (ContentPart, error) {
+	switch {
+	case strings.HasPrefix(blob.MIMEType, "image/"):
+		return ContentPart{Type: PartImageURL, ImageURL: &ImageURL{URL: toDataURL(blob)}}, nil
+	case blob.MIMEType == "application/pdf":
+		return ContentPart{Type: PartFile, File: &File{FileData: toDataURL(blob)}}, nil
+	}
+	for format, mimeType := range audioTypes {
+		if blob.MIMEType == mimeType {
+			audio := &InputAudio{Data: base64.StdEncoding.EncodeToString(blob.Data), Format: format}
+			return ContentPart{Type: PartInputAudio, InputAudio: audio}, nil
+		}
+	}
+	return ContentPart{}, fmt.Errorf("files of type %s cannot be sent to the model", blob.MIMEType)
+}
+
+// toolResult is the response backends expect for a tool's output: the output
+// itself when it is a JSON object, wrapped in {"output": ...} otherwise
+func toolResult(output string) map[string]any {
+	var result map[string]any
+	if err := json.Unmarshal([]byte(output), &result); err == nil && result != nil {
+		return result
+	}
+	return map[string]any{"output": output}
+}
+
+// ToLLMTools converts the tools of req and its tool choice
+func ToLLMTools(req *ChatCompletionRequest) ([]llm.Tool, *llm.ToolChoice, error) {
+	if len(req.Tools) == 0 {
+		if req.ToolChoice != nil && req.ToolChoice.Mode != "none" && req.ToolChoice.Mode != "auto" {
+			return nil, nil, errors.New("tool_choice requires tools")
+		}
+		return nil, nil, nil
+	}
+
+	var tools []llm.Tool
+	for i, t := range req.Tools {
+		if t.Type != "function" {
+			return nil, nil, fmt.Errorf("tools[%d]: only function tools are supported", i)
+		}
+		if t.Function.Name == "" {
+			return nil, nil, fmt.Errorf("tools[%d]: function name is required", i)
+		}
+
+		tool := llm.Tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+		}
+		if len(t.Function.Parameters) > 0 {
+			if err := json.Unmarshal(t.Function.Parameters, &tool.Parameters); err != nil {
+				return nil, nil, fmt.Errorf("tools[%d]: parameters must be a JSON schema object", i)
+			}
+		}
+		tools = append(tools, tool)
+	}
+
+	if req.ToolChoice == nil {
+		return tools, nil, nil
+	}
+
+	choice := &llm.ToolChoice{}
+	switch req.ToolChoice.Mode {
+	case "none":
+		choice.Mode = llm.ToolNone
+	case "auto":
+		choice.Mode = llm.ToolAuto
+	case "required":
+		choice.Mode = llm.ToolRequired
+	case "function":
+		choice.Mode = llm.ToolRequired
+		choice.Functions = []string{req.ToolChoice.Function}
+	default:
+		return nil, nil, fmt.Errorf(`tool_choice must be "none", "auto", "required" or name a function`)
+	}
+	return tools, choice, nil
+}
+
+// FromLLMCandidate returns the text and tool calls of a candidate
+func FromLLMCandidate(candidate llm.Candidate) (string, []ToolCall) {
+	var calls []ToolCall
+	for _, call := range candidate.Content.ToolCalls() {
+		calls = append(calls, toolCall(call))
+	}
+	return candidate.Content.Text(), calls
+}
+
+func toolCall(call llm.ToolCall) ToolCall {
+	id := call.ID
+	if id == "" {
+		id = "call_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	}
+	arguments, err := json.Marshal(call.Args)
+	if err != nil || call.Args == nil {
+		arguments = []byte("{}")
+	}
+	return ToolCall{
+		ID:       id,
+		Type:     "function",
+		Function: FunctionCall{Name: call.Name, Arguments: string(arguments)},
+	}
+}
+
+// FinishReason converts the finish reason of a candidate
+func FinishReason(reason string, calledTools bool) string {
+	switch {
+	case calledTools:
+		return FinishToolCalls
+	case reason == llm.FinishMaxTokens:
+		return FinishLength
+	case llm.IsContentFilter(reason):
+		return FinishContentFilter
+	default:
+		return FinishStop
+	}
+}
+
+// FromLLMUsage converts the token counts of a reply, nil when there are none
+func FromLLMUsage(usage *models.Usage) *Usage {
+	if usage == nil {
+		return nil
+	}
+	// Thoughts are billed as completion tokens by OpenAI
+	return &Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.OutputTokens + usage.ThoughtsTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// FromLLMRequest converts req to a request of the OpenAI API, for backends
+// speaking it
+func FromLLMRequest(req *llm.Request) (*ChatCompletionRequest, error) {
+	completion := &ChatCompletionRequest{Model: req.Model}
+
+	if req.System != "" {
+		completion.Messages = append(completion.Messages, Message{Role: RoleSystem, Content: &Content{Text: req.System}})
+	}
+	for _, message := range req.Messages {
+		var text strings.Builder
+		var calls []ToolCall
+		// files holds the content parts of user messages with files, in order
+		var files []ContentPart
+		for _, part := range message.Parts {
+			switch {
+			case part.Thought:
+			case part.Data != nil:
+				file, err := fromLLMBlob(part.Data)
+				if err != nil {
+					return nil, err
+				}
+				if text.Len() > 0 {
+					files = append(files, ContentPart{Type: PartText, Text: text.String()})
+					text.Reset()
+				}
+				files = append(files, file)
+			case part.ToolCall != nil:
+				calls = append(calls, toolCall(*part.ToolCall))
+			case part.ToolResult != nil:
+				// Each result is a message of its own
+				output, err := json.Marshal(part.ToolResult.Response)
+				if err != nil {
+					return nil, fmt.Errorf("error encoding result of tool %s: %w", part.ToolResult.Name, err)
+				}
+				completion.Messages = append(completion.Messages, Message{
+					Role:       RoleTool,
+					Content:    &Content{Text: string(output)},
+					ToolCallID: part.ToolResult.ID,
+				})
+			default:
+				text.WriteString(part.Text)
+			}
+		}
+
+		switch {
+		case message.Role == llm.RoleModel:
+			m := Message{Role: RoleAssistant, ToolCalls: calls}
+			if text.Len() > 0 || len(calls) == 0 {
+				m.Content = &Content{Text: text.String()}
+			}
+			completion.Messages = append(completion.Messages, m)
+		case len(files) > 0:
+			if text.Len() > 0 {
+				files = append(files, ContentPart{Type: PartText, Text: text.String()})
+			}
+			completion.Messages = append(completion.Messages, Message{Role: RoleUser, Content: &Content{Parts: files}})
+		case text.Len() > 0:
+			completion.Messages = append(completion.Messages, Message{Role: RoleUser, Content: &Content{Text: text.String()}})
+		}
+	}
+
+	if c := req.Config; c != nil {
+		completion.Temperature = c.Temperature
+		completion.TopP = c.TopP
+		completion.MaxTokens = c.MaxOutputTokens
+		completion.Stop = c.StopSequences
+		completion.Seed = c.Seed
+		completion.N = c.CandidateCount
+		completion.PresencePenalty = c.PresencePenalty
+		completion.FrequencyPenalty = c.FrequencyPenalty
+	}
+
+	for _, tool := range req.Tools {
+		definition := FunctionDefinition{Name: tool.Name, Description: tool.Description}
+		if tool.Parameters != nil {
+			parameters, err := json.Marshal(tool.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding parameters of tool %s: %w", tool.Name, err)
+			}
+			definition.Parameters = parameters
+		}
+		completion.Tools = append(completion.Tools, Tool{Type: "function", Function: definition})
+	}
+	if choice := req.ToolChoice; choice != nil && len(req.Tools) > 0 {
+		completion.ToolChoice = &ToolChoice{Mode: choice.Mode}
+		// The OpenAI API can only restrict the calls to a single function
+		if choice.Mode == llm.ToolRequired && len(choice.Functions) == 1 {
+			completion.ToolChoice = &ToolChoice{Mode: "function", Function: choice.Functions[0]}
+		}
+	}
+
+	return completion, nil
+}
+
+// ToLLMResponse converts a completion of a backend speaking the OpenAI API
+func ToLLMResponse(completion *ChatCompletion) (*llm.Response, error) {
+	response := &llm.Response{Usage: ToLLMUsage(completion.Usage)}
+	for _, choice := range completion.Choices {
+		candidate := llm.Candidate{
+			Index:        choice.Index,
+			Content:      llm.Message{Role: llm.RoleModel},
+			FinishReason: ToLLMFinishReason(choice.FinishReason),
+		}
+		if choice.Message.Content != nil && choice.Message.Content.Text != "" {
+			candidate.Content.Parts = append(candidate.Content.Parts, llm.Part{Text: choice.Message.Content.Text})
+		}
+		for _, call := range choice.Message.ToolCalls {
+			part, err := ToLLMToolCall(call)
+			if err != nil {
+				return nil, err
+			}
+			candidate.Content.Parts = append(candidate.Content.Parts, part)
+		}
+		response.Candidates = append(response.Candidates, candidate)
+	}
+	return response, nil
+}
+
+// ToLLMToolCall converts a complete tool call of a backend
+func ToLLMToolCall(call ToolCall) (llm.Part, error) {
+	var args map[string]any
+	if call.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+			return llm.Part{}, fmt.Errorf("arguments of tool call %s are not a JSON object", call.ID)
+		}
+	}
+	return llm.Part{ToolCall: &llm.ToolCall{ID: call.ID, Name: call.Function.Name, Args: args}}, nil
+}
+
+// ToLLMFinishReason converts the finish reason of a choice of a backend
+func ToLLMFinishReason(reason string) string {
+	switch reason {
+	case FinishLength:
+		return llm.FinishMaxTokens
+	case FinishContentFilter:
+		return llm.FinishSafety
+	case FinishToolCalls, "function_call":
+		// The calls tell on their own that the model called tools
+		return llm.FinishStop
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// ToLLMUsage converts the token counts of a backend, nil when there are none
+func ToLLMUsage(usage *Usage) *models.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &models.Usage{
+		PromptTokens: usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
+// NewID returns the id of a completion
+func NewID() string {
+	return "chatcmpl-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// NewError returns the body of a failed request. code may be empty.
+func NewError(message string, errorType string, code string) ErrorResponse {
+	e := Error{Message: message, Type: errorType}
+	if code != "" {
+		e.Code = &code
+	}
+	return ErrorResponse{Error: e}
+}
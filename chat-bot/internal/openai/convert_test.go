@@ -0,0 +1,252 @@
+package openai
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+)
+
+func TestToLLMMessages(t *testing.T) {
+	weather := &llm.ToolCall{ID: "call_1", Name: "weather", Args: map[string]any{"city": "Paris"}}
+	clock := &llm.ToolCall{ID: "call_2", Name: "time"}
+
+	tests := []struct {
+		name string
+		// messages is the JSON array sent by the client
+		messages string
+		want     []llm.Message
+		system   string
+		err      string
+	}{
+		{
+			name:     "system and developer messages",
+			messages: `[{"role":"system","content":"Be brief."},{"role":"user","content":"Hi"},{"role":"developer","content":"Answer in French."}]`,
+			want:     []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Text: "Hi"}}}},
+			system:   "Be brief.\n\nAnswer in French.",
+		},
+		{
+			name: "consecutive messages of a role",
+			messages: `[
+				{"role":"user","content":"Hi"},
+				{"role":"user","content":"Are you there?"},
+				{"role":"assistant","content":"Yes."},
+				{"role":"assistant","content":"How can I help?"},
+				{"role":"user","content":"Thanks"}
+			]`,
+			want: []llm.Message{
+				{Role: llm.RoleUser, Parts: []llm.Part{{Text: "Hi"}, {Text: "Are you there?"}}},
+				{Role: llm.RoleModel, Parts: []llm.Part{{Text: "Yes."}, {Text: "How can I help?"}}},
+				{Role: llm.RoleUser, Parts: []llm.Part{{Text: "Thanks"}}},
+			},
+		},
+		{
+			name: "tool results answer the calls together",
+			messages: `[
+				{"role":"user","content":"Weather and time in Paris?"},
+				{"role":"assistant","content":null,"tool_calls":[
+					{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"call_2","type":"function","function":{"name":"time","arguments":""}}
+				]},
+				{"role":"tool","tool_call_id":"call_1","content":"{\"celsius\":21}"},
+				{"role":"tool","tool_call_id":"call_2","content":"14:00"},
+				{"role":"user","content":"Thanks"}
+			]`,
+			want: []llm.Message{
+				{Role: llm.RoleUser, Parts: []llm.Part{{Text: "Weather and time in Paris?"}}},
+				{Role: llm.RoleModel, Parts: []llm.Part{{ToolCall: weather}, {ToolCall: clock}}},
+				{Role: llm.RoleUser, Parts: []llm.Part{
+					{ToolResult: &llm.ToolResult{ID: "call_1", Name: "weather", Response: map[string]any{"celsius": float64(21)}}},
+					{ToolResult: &llm.ToolResult{ID: "call_2", Name: "time", Response: map[string]any{"output": "14:00"}}},
+					{Text: "Thanks"},
+				}},
+			},
+		},
+		{
+			name: "files",
+			messages: `[{"role":"user","content":[
+				{"type":"text","text":"What is this?"},
+				{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw=="}},
+				{"type":"input_audio","input_audio":{"data":"UklGRg==","format":"wav"}}
+			]}]`,
+			want: []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{
+				{Text: "What is this?"},
+				{Data: &llm.Blob{MIMEType: "image/png", Data: []byte{0x89, 'P', 'N', 'G'}}},
+				{Data: &llm.Blob{MIMEType: "audio/wav", Data: []byte("RIFF")}},
+			}}},
+		},
+		{name: "no messages", messages: `[]`, err: "messages must not be empty"},
+		{name: "only a system message", messages: `[{"role":"system","content":"Be brief."}]`, err: "messages must contain a user message"},
+		{name: "unknown role", messages: `[{"role":"function","content":"{}"}]`, err: `messages[0]: unknown role "function"`},
+		{
+			name:     "tool_call_id without a call",
+			messages: `[{"role":"user","content":"Hi"},{"role":"tool","tool_call_id":"call_9","content":"42"}]`,
+			err:      `messages[1]: tool_call_id "call_9" does not match a previous tool call`,
+		},
+		{
+			name:     "tool_call_id of a later call",
+			messages: `[{"role":"tool","tool_call_id":"call_1","content":"42"},{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"answer"}}]}]`,
+			err:      `messages[0]: tool_call_id "call_1" does not match a previous tool call`,
+		},
+		{
+			name:     "arguments that are not an object",
+			messages: `[{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"Paris"}}]}]`,
+			err:      "messages[0]: arguments of tool call call_1 must be a JSON object",
+		},
+		{
+			name:     "image by URL",
+			messages: `[{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}]`,
+			err:      "messages[0]: image_url: files must be sent as base64 data: URLs",
+		},
+		{
+			name:     "data URL without base64",
+			messages: `[{"role":"user","content":[{"type":"file","file":{"file_data":"data:application/pdf,%25PDF"}}]}]`,
+			err:      "messages[0]: file: files must be sent as base64 data: URLs",
+		},
+		{
+			name:     "data URL without a type",
+			messages: `[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:;base64,iVBORw=="}}]}]`,
+			err:      "messages[0]: image_url: files must be sent as base64 data: URLs",
+		},
+		{
+			name:     "data URL badly encoded",
+			messages: `[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,not base64!"}}]}]`,
+			err:      "messages[0]: image_url: data: URL is not base64 encoded",
+		},
+		{
+			name:     "unknown audio format",
+			messages: `[{"role":"user","content":[{"type":"input_audio","input_audio":{"data":"UklGRg==","format":"flac"}}]}]`,
+			err:      `messages[0]: input_audio: unknown format "flac"`,
+		},
+		{
+			name:     "audio badly encoded",
+			messages: `[{"role":"user","content":[{"type":"input_audio","input_audio":{"data":"RIFF!","format":"wav"}}]}]`,
+			err:      "messages[0]: input_audio: data must be base64 encoded",
+		},
+		{
+			name:     "unsupported content parts",
+			messages: `[{"role":"user","content":[{"type":"text","text":"Hi"},{"type":"refusal"},{"type":"video"}]}]`,
+			err:      "messages[0]: content parts of type refusal, video are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var messages []Message
+			if err := json.Unmarshal([]byte(tt.messages), &messages); err != nil {
+				t.Fatalf("decoding messages: %v", err)
+			}
+
+			got, system, err := ToLLMMessages(messages)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("ToLLMMessages() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ToLLMMessages() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToLLMMessages() = %s, want %s", describe(got), describe(tt.want))
+			}
+			if system != tt.system {
+				t.Errorf("system = %q, want %q", system, tt.system)
+			}
+		})
+	}
+}
+
+func TestFromLLMRequestRoundTrip(t *testing.T) {
+	messages := `[
+		{"role":"system","content":"Be brief."},
+		{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw=="}}]},
+		{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"describe","arguments":"{\"detail\":\"high\"}"}}]},
+		{"role":"tool","tool_call_id":"call_1","content":"{\"label\":\"cat\"}"}
+	]`
+	var sent []Message
+	if err := json.Unmarshal([]byte(messages), &sent); err != nil {
+		t.Fatalf("decoding messages: %v", err)
+	}
+
+	converted, system, err := ToLLMMessages(sent)
+	if err != nil {
+		t.Fatalf("ToLLMMessages() error = %v", err)
+	}
+	completion, err := FromLLMRequest(&llm.Request{Model: "m", System: system, Messages: converted})
+	if err != nil {
+		t.Fatalf("FromLLMRequest() error = %v", err)
+	}
+
+	got, err := json.Marshal(completion.Messages)
+	if err != nil {
+		t.Fatalf("encoding messages: %v", err)
+	}
+	var want, have any
+	_ = json.Unmarshal([]byte(messages), &want)
+	_ = json.Unmarshal(got, &have)
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("messages = %s, want %s", got, strings.Join(strings.Fields(messages), ""))
+	}
+}
+
+func TestFinishReasons(t *testing.T) {
+	tests := []struct {
+		reason      string
+		calledTools bool
+		want        string
+	}{
+		{reason: llm.FinishStop, want: FinishStop},
+		{reason: llm.FinishStop, calledTools: true, want: FinishToolCalls},
+		{reason: llm.FinishMaxTokens, want: FinishLength},
+		{reason: llm.FinishSafety, want: FinishContentFilter},
+		{reason: "", want: FinishStop},
+	}
+	for _, tt := range tests {
+		if got := FinishReason(tt.reason, tt.calledTools); got != tt.want {
+			t.Errorf("FinishReason(%q, %t) = %q, want %q", tt.reason, tt.calledTools, got, tt.want)
+		}
+	}
+
+	backend := map[string]string{
+		FinishStop:          llm.FinishStop,
+		FinishLength:        llm.FinishMaxTokens,
+		FinishContentFilter: llm.FinishSafety,
+		FinishToolCalls:     llm.FinishStop,
+		"function_call":     llm.FinishStop,
+	}
+	for reason, want := range backend {
+		if got := ToLLMFinishReason(reason); got != want {
+			t.Errorf("ToLLMFinishReason(%q) = %q, want %q", reason, got, want)
+		}
+	}
+}
+
+// describe prints messages with the values their parts point to
+func describe(messages []llm.Message) string {
+	var b strings.Builder
+	for _, message := range messages {
+		b.WriteString(message.Role + "[")
+		for i, part := range message.Parts {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			switch {
+			case part.Data != nil:
+				b.WriteString(part.Data.MIMEType + ":" + string(part.Data.Data))
+			case part.ToolCall != nil:
+				args, _ := json.Marshal(part.ToolCall.Args)
+				b.WriteString("call " + part.ToolCall.ID + " " + part.ToolCall.Name + string(args))
+			case part.ToolResult != nil:
+				response, _ := json.Marshal(part.ToolResult.Response)
+				b.WriteString("result " + part.ToolResult.ID + " " + part.ToolResult.Name + string(response))
+			default:
+				b.WriteString(strings.TrimSpace(part.Text))
+			}
+		}
+		b.WriteString("] ")
+	}
+	return b.String()
+}
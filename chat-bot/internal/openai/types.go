@@ -0,0 +1,321 @@
+// Package openai translates between the OpenAI chat completions API and the
+// requests of the llm package, so that tools speaking the OpenAI API can use
+// the chat-bot, and the chat-bot can use backends speaking it.
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// Roles of the OpenAI messages
+const (
+	RoleSystem    = "system"
+	RoleDeveloper = "developer"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Finish reasons of the OpenAI choices
+const (
+	FinishStop          = "stop"
+	FinishLength        = "length"
+	FinishToolCalls     = "tool_calls"
+	FinishContentFilter = "content_filter"
+)
+
+// ChatCompletionRequest is the body of POST /v1/chat/completions. Fields of
+// the OpenAI API missing here are ignored.
+type ChatCompletionRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	N                *int32   `json:"n,omitempty"`
+	Seed             *int32   `json:"seed,omitempty"`
+	Stop             Stop     `json:"stop,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+	// MaxCompletionTokens replaces the deprecated MaxTokens, which is still
+	// sent by many clients
+	MaxTokens           *int32 `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int32 `json:"max_completion_tokens,omitempty"`
+
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+type StreamOptions struct {
+	// IncludeUsage adds a last chunk carrying the usage of the completion
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Message is a message of the conversation. Content is null on assistant
+// messages that only call tools.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    *Content   `json:"content"`
+	Name       string     `json:"name,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Content is the content of a message, sent by clients either as a string or
+// as an array of content parts
+type Content struct {
+	// Text joins the text of the parts
+	Text string
+	// Parts is set when the content was sent as an array, or holds files
+	Parts []ContentPart
+	// Unsupported holds the types of the parts that cannot be converted
+	Unsupported []string
+}
+
+// Types of the content parts
+const (
+	PartText       = "text"
+	PartImageURL   = "image_url"
+	PartInputAudio = "input_audio"
+	PartFile       = "file"
+)
+
+// ContentPart is text, an image, an audio clip or a file of a message
+type ContentPart struct {
+	Type       string      `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	ImageURL   *ImageURL   `json:"image_url,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+	File       *File       `json:"file,omitempty"`
+}
+
+type ImageURL struct {
+	// URL is a data: URL, images are not downloaded
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type InputAudio struct {
+	// Data is base64 encoded
+	Data string `json:"data"`
+	// Format is wav or mp3
+	Format string `json:"format"`
+}
+
+type File struct {
+	Filename string `json:"filename,omitempty"`
+	// FileData is a data: URL
+	FileData string `json:"file_data,omitempty"`
+}
+
+func (c *Content) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		return json.Unmarshal(data, &c.Text)
+	}
+
+	var parts []ContentPart
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+
+	for _, part := range parts {
+		switch {
+		case part.Type == PartText:
+			c.Text += part.Text
+		case part.Type == PartImageURL && part.ImageURL != nil,
+			part.Type == PartInputAudio && part.InputAudio != nil,
+			part.Type == PartFile && part.File != nil:
+		default:
+			c.Unsupported = append(c.Unsupported, part.Type)
+			continue
+		}
+		c.Parts = append(c.Parts, part)
+	}
+	return nil
+}
+
+// MarshalJSON sends content with files as an array of parts, and other content as a string
+func (c *Content) MarshalJSON() ([]byte, error) {
+	for _, part := range c.Parts {
+		if part.Type != PartText {
+			return json.Marshal(c.Parts)
+		}
+	}
+	return json.Marshal(c.Text)
+}
+
+// Stop holds the stop sequences, sent by clients either as a string or as an array
+type Stop []string
+
+func (s *Stop) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var sequence string
+		if err := json.Unmarshal(data, &sequence); err != nil {
+			return err
+		}
+		*s = Stop{sequence}
+		return nil
+	}
+
+	var sequences []string
+	if err := json.Unmarshal(data, &sequences); err != nil {
+		return errors.New("stop must be a string or an array of strings")
+	}
+	*s = sequences
+	return nil
+}
+
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is the JSON schema of the arguments
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolChoice is "none", "auto", "required", or names the function to call
+type ToolChoice struct {
+	Mode     string
+	Function string
+}
+
+func (t *ToolChoice) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		return json.Unmarshal(data, &t.Mode)
+	}
+
+	var choice struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(data, &choice); err != nil || choice.Type != "function" || choice.Function.Name == "" {
+		return errors.New(`tool_choice must be "none", "auto", "required" or name a function`)
+	}
+	t.Mode = "function"
+	t.Function = choice.Function.Name
+	return nil
+}
+
+func (t *ToolChoice) MarshalJSON() ([]byte, error) {
+	if t.Mode != "function" {
+		return json.Marshal(t.Mode)
+	}
+
+	choice := map[string]any{
+		"type":     "function",
+		"function": map[string]string{"name": t.Function},
+	}
+	return json.Marshal(choice)
+}
+
+type ToolCall struct {
+	// Index is only set on the tool calls of stream chunks
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name string `json:"name"`
+	// Arguments are encoded as a JSON object
+	Arguments string `json:"arguments"`
+}
+
+// ChatCompletion is the response of POST /v1/chat/completions
+type ChatCompletion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
+}
+
+type Choice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// ChatCompletionChunk is an event of a streamed completion
+type ChatCompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	// Usage is only set on the last chunk, when stream_options.include_usage is set
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+type ChunkChoice struct {
+	Index int   `json:"index"`
+	Delta Delta `json:"delta"`
+	// FinishReason is null until the last chunk of the choice
+	FinishReason *string `json:"finish_reason"`
+}
+
+type Delta struct {
+	Role      string     `json:"role,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+type Usage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CompletionTokens int32 `json:"completion_tokens"`
+	TotalTokens      int32 `json:"total_tokens"`
+}
+
+// Model is an entry of GET /v1/models
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type ModelList struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+}
+
+// EmbeddingRequest is the body of POST /embeddings
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingResponse struct {
+	Data  []Embedding `json:"data"`
+	Model string      `json:"model"`
+}
+
+type Embedding struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// ErrorResponse is the body of failed requests
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+type Error struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
@@ -0,0 +1,283 @@
+// Package personas stores named, versioned system instructions. Global
+// personas are offered to every user, the others only to their owner.
+package personas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned for personas that do not exist or are not visible to the user
+	ErrNotFound = errors.New("persona not found")
+	// ErrForbidden is returned when a user who is not an admin changes a global persona
+	ErrForbidden = errors.New("only admins may change global personas")
+	// ErrNameTaken is returned when the owner already has a persona with the same name
+	ErrNameTaken = errors.New("a persona with this name already exists")
+)
+
+// Limits of the persona fields, in characters
+const (
+	MaxNameLength        = 100
+	MaxDescriptionLength = 500
+	MaxInstructionLength = 32000
+)
+
+// personaColumns are scanned by scanPersona, joined with the version v
+const personaColumns = `p.id, p.name, p.description, p.user_id IS NULL, v.version, v.instruction, p.created_at, p.updated_at`
+
+type Store struct {
+	DB *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+// Create adds a persona owned by userID, or a global persona when global is set
+func (s *Store) Create(ctx context.Context, userID string, req *models.PersonaRequest) (*models.Persona, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var owner interface{} = userID
+	if req.Global {
+		owner = nil
+	}
+
+	id := uuid.NewString()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO personas (id, user_id, name, description, current_version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 1, NOW(), NOW())
+	`, id, owner, req.Name, req.Description)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrNameTaken
+		}
+		return nil, fmt.Errorf("error creating persona: %w", err)
+	}
+
+	if err := addVersion(ctx, tx, id, 1, req.Instruction); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing persona: %w", err)
+	}
+
+	return s.Get(ctx, userID, id, 0)
+}
+
+// List returns the global personas followed by those of userID, at their current version
+func (s *Store) List(ctx context.Context, userID string) ([]*models.Persona, error) {
+	query := `
+		SELECT ` + personaColumns + `
+		FROM personas p
+		JOIN persona_versions v ON v.persona_id = p.id AND v.version = p.current_version
+		WHERE p.user_id IS NULL OR p.user_id = $1
+		ORDER BY p.user_id IS NOT NULL, LOWER(p.name)
+	`
+
+	rows, err := s.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying personas: %w", err)
+	}
+	defer rows.Close()
+
+	personas := []*models.Persona{}
+	for rows.Next() {
+		persona, err := scanPersona(rows)
+		if err != nil {
+			return nil, err
+		}
+		personas = append(personas, persona)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying personas: %w", err)
+	}
+
+	return personas, nil
+}
+
+// Get returns a persona visible to userID at version, or at its current version when version is 0
+func (s *Store) Get(ctx context.Context, userID string, personaID string, version int) (*models.Persona, error) {
+	query := `
+		SELECT ` + personaColumns + `
+		FROM personas p
+		JOIN persona_versions v ON v.persona_id = p.id AND v.version = COALESCE(NULLIF($3, 0), p.current_version)
+		WHERE p.id = $1 AND (p.user_id IS NULL OR p.user_id = $2)
+	`
+
+	persona, err := scanPersona(s.DB.QueryRowContext(ctx, query, personaID, userID, version))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return persona, nil
+}
+
+// Versions returns every version of a persona visible to userID, latest first
+func (s *Store) Versions(ctx context.Context, userID string, personaID string) ([]models.PersonaVersion, error) {
+	if _, err := s.Get(ctx, userID, personaID, 0); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT version, instruction, created_at FROM persona_versions
+		WHERE persona_id = $1
+		ORDER BY version DESC
+	`
+
+	rows, err := s.DB.QueryContext(ctx, query, personaID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying persona versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.PersonaVersion{}
+	for rows.Next() {
+		var version models.PersonaVersion
+		if err := rows.Scan(&version.Version, &version.Instruction, &version.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning persona version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying persona versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// Update renames and describes a persona, adding a version when its
+// instruction changed. Conversations keep the version they use.
+func (s *Store) Update(ctx context.Context, userID string, isAdmin bool, personaID string, req *models.PersonaRequest) (*models.Persona, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkWritable(ctx, tx, userID, isAdmin, personaID); err != nil {
+		return nil, err
+	}
+
+	var version int
+	var instruction string
+	err = tx.QueryRowContext(ctx, `
+		SELECT v.version, v.instruction FROM personas p
+		JOIN persona_versions v ON v.persona_id = p.id AND v.version = p.current_version
+		WHERE p.id = $1
+	`, personaID).Scan(&version, &instruction)
+	if err != nil {
+		return nil, fmt.Errorf("error querying persona: %w", err)
+	}
+
+	if instruction != req.Instruction {
+		version++
+		if err := addVersion(ctx, tx, personaID, version, req.Instruction); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE personas SET name = $1, description = $2, current_version = $3, updated_at = NOW()
+		WHERE id = $4
+	`, req.Name, req.Description, version, personaID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrNameTaken
+		}
+		return nil, fmt.Errorf("error updating persona: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing persona: %w", err)
+	}
+
+	return s.Get(ctx, userID, personaID, 0)
+}
+
+// Delete removes a persona and its versions. Conversations using it continue
+// with their inline system instruction only.
+func (s *Store) Delete(ctx context.Context, userID string, isAdmin bool, personaID string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkWritable(ctx, tx, userID, isAdmin, personaID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM personas WHERE id = $1`, personaID); err != nil {
+		return fmt.Errorf("error deleting persona: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing persona deletion: %w", err)
+	}
+	return nil
+}
+
+// checkWritable locks a persona that userID may change: their own, or a global one for admins
+func checkWritable(ctx context.Context, tx *sql.Tx, userID string, isAdmin bool, personaID string) error {
+	var owner sql.NullString
+	err := tx.QueryRowContext(ctx, `SELECT user_id FROM personas WHERE id = $1 FOR UPDATE`, personaID).Scan(&owner)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("error querying persona: %w", err)
+	}
+
+	switch {
+	case !owner.Valid && !isAdmin:
+		return ErrForbidden
+	case owner.Valid && owner.String != userID:
+		return ErrNotFound
+	}
+	return nil
+}
+
+func addVersion(ctx context.Context, tx *sql.Tx, personaID string, version int, instruction string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO persona_versions (persona_id, version, instruction, created_at)
+		VALUES ($1, $2, $3, NOW())
+	`, personaID, version, instruction)
+	if err != nil {
+		return fmt.Errorf("error saving persona version: %w", err)
+	}
+	return nil
+}
+
+func scanPersona(row interface{ Scan(...interface{}) error }) (*models.Persona, error) {
+	persona := &models.Persona{}
+	err := row.Scan(
+		&persona.ID, &persona.Name, &persona.Description, &persona.Global,
+		&persona.Version, &persona.Instruction, &persona.CreatedAt, &persona.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error scanning persona: %w", err)
+	}
+	return persona, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,14 @@
+// Package router routes the requests of the chat-bot.
+//
+// router.go is a copy of server/src/router/router.go, which is the source of
+// truth of the router of both services: change that file, then copy it here
+// along with router_test.go. Only writeRouteError, in this file, differs.
+package router
+
+import "net/http"
+
+// writeRouteError answers a request no route matches in plain text, like the
+// other errors of the chat-bot
+func writeRouteError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+	http.Error(w, message, statusCode)
+}
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteErrors(t *testing.T) {
+	tests := []struct {
+		method  string
+		path    string
+		message string
+	}{
+		{method: http.MethodGet, path: "/missing", message: "Route not found"},
+		{method: http.MethodPut, path: "/health", message: "Method not allowed"},
+	}
+
+	rt := testRouter()
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if got := strings.TrimSpace(w.Body.String()); got != tt.message || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+			t.Errorf("%s %s = %q, want %q in plain text", tt.method, tt.path, got, tt.message)
+		}
+	}
+}
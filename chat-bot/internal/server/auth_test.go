@@ -0,0 +1,167 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+	"github.com/Mahaveer86619/ImaginAI/internal/users"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claimsOf returns the claims of an access token of testUserID valid for an
+// hour, changed by change
+func claimsOf(change func(c *auth.Claims)) auth.Claims {
+	claims := auth.Claims{
+		UserID:       testUserID,
+		Role:         auth.RoleUser,
+		TokenVersion: 2,
+		TokenType:    auth.TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	change(&claims)
+	return claims
+}
+
+func TestAuthentication(t *testing.T) {
+	gs, handler, _ := newTestServer(t, fake.New())
+	gs.auth = auth.NewAuthenticator(testUsers{state: users.TokenState{TokenVersion: 2}})
+	handler = gs.SetupRoutes()
+
+	valid := signToken(t, claimsOf(func(c *auth.Claims) {}))
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsOf(func(c *auth.Claims) {})).SignedString([]byte("another-secret"))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		body          string
+	}{
+		{name: "valid token", authorization: "Bearer " + valid, status: http.StatusOK},
+		{name: "missing token", status: http.StatusUnauthorized, body: "Authorization header is required"},
+		{name: "malformed token", authorization: "Bearer not-a-token", status: http.StatusUnauthorized, body: "Invalid token"},
+		{name: "token signed with another secret", authorization: "Bearer " + forged, status: http.StatusUnauthorized, body: "Invalid token"},
+		{
+			name:          "expired token",
+			authorization: "Bearer " + signToken(t, claimsOf(func(c *auth.Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) })),
+			status:        http.StatusUnauthorized,
+			body:          "Invalid token",
+		},
+		{
+			name:          "token without expiry",
+			authorization: "Bearer " + signToken(t, claimsOf(func(c *auth.Claims) { c.ExpiresAt = nil })),
+			status:        http.StatusUnauthorized,
+			body:          "Invalid token",
+		},
+		{
+			name:          "token without a user",
+			authorization: "Bearer " + signToken(t, claimsOf(func(c *auth.Claims) { c.UserID = "ada" })),
+			status:        http.StatusUnauthorized,
+			body:          "Invalid token",
+		},
+		{
+			name:          "refresh token",
+			authorization: "Bearer " + signToken(t, claimsOf(func(c *auth.Claims) { c.TokenType = "refresh" })),
+			status:        http.StatusUnauthorized,
+			body:          "Invalid token",
+		},
+		{
+			name:          "token without a type",
+			authorization: "Bearer " + signToken(t, claimsOf(func(c *auth.Claims) { c.TokenType = "" })),
+			status:        http.StatusUnauthorized,
+			body:          "Invalid token",
+		},
+		{
+			name:          "revoked token",
+			authorization: "Bearer " + signToken(t, claimsOf(func(c *auth.Claims) { c.TokenVersion = 1 })),
+			status:        http.StatusUnauthorized,
+			body:          "Token has been revoked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/models", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body {
+				t.Errorf("body = %q, want %q", w.Body, tt.body)
+			}
+		})
+	}
+}
+
+func TestDisabledUserIsRejected(t *testing.T) {
+	gs, _, _ := newTestServer(t, fake.New())
+	gs.auth = auth.NewAuthenticator(testUsers{state: users.TokenState{Disabled: true}})
+
+	w := send(t, gs.SetupRoutes(), http.MethodGet, "/models", "")
+	if w.Code != http.StatusUnauthorized || strings.TrimSpace(w.Body.String()) != "Token has been revoked" {
+		t.Errorf("response = %d %q, want 401 Token has been revoked", w.Code, w.Body)
+	}
+}
+
+func TestSetupRequiresAdmin(t *testing.T) {
+	_, handler, _ := newTestServer(t, fake.New())
+
+	tests := []struct {
+		role   string
+		status int
+	}{
+		{role: auth.RoleUser, status: http.StatusForbidden},
+		// Admins reach the handler, which refuses to set a Gemini key on the fake provider
+		{role: auth.RoleAdmin, status: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		token := signToken(t, claimsOf(func(c *auth.Claims) { c.Role = tt.role; c.TokenVersion = 0 }))
+		r := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(`{"api_key":"AIza-test"}`))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("POST /setup as %s: status = %d, want %d", tt.role, w.Code, tt.status)
+		}
+	}
+}
+
+func TestTokenFromQueryOnlyOnWebSocket(t *testing.T) {
+	_, handler, _ := newTestServer(t, fake.New())
+	token := testToken(t)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		// Without upgrade headers the token is accepted and the upgrade refused
+		{path: "/ws?access_token=" + token, status: http.StatusBadRequest},
+		{path: "/ws", status: http.StatusUnauthorized},
+		{path: "/ws?access_token=not-a-token", status: http.StatusUnauthorized},
+		{path: "/models?access_token=" + token, status: http.StatusUnauthorized},
+		{path: "/conversations?access_token=" + token, status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", strings.SplitN(tt.path, "=", 2)[0], w.Code, tt.status)
+		}
+	}
+}
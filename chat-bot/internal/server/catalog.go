@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ListModelsHandler lists the enabled models of the catalog, flagging those
+// the caller's plan does not include
+func (gs *GenAIServer) ListModelsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	settings, err := gs.users.ChatSettings(r.Context(), claims.UserID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", claims.UserID).Error("Error loading chat settings")
+		http.Error(w, "Failed to load chat settings", http.StatusInternalServerError)
+		return
+	}
+
+	resp := models.ModelListResponse{
+		Models:       []models.ModelInfo{},
+		DefaultModel: gs.Catalog.DefaultFor(settings.Plan, settings.DefaultModel).ID,
+	}
+	for _, m := range gs.Catalog.Enabled() {
+		resp.Models = append(resp.Models, models.ModelInfo{
+			Model:     m,
+			Available: m.AvailableOn(settings.Plan),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
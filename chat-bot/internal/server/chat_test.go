@@ -0,0 +1,534 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/catalog"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/Mahaveer86619/ImaginAI/internal/openai"
+)
+
+func TestChatHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []fake.Reply
+		message string
+		reply   string
+		finish  string
+		usage   models.Usage
+	}{
+		{
+			name:    "scripted reply",
+			replies: []fake.Reply{{Text: "Hello Ada, how can I help?"}},
+			message: "Hello there",
+			reply:   "Hello Ada, how can I help?",
+			finish:  models.FinishStop,
+			usage:   models.Usage{PromptTokens: 2, OutputTokens: 6, TotalTokens: 8},
+		},
+		{
+			name:    "truncated reply",
+			replies: []fake.Reply{{Text: "Once upon a", FinishReason: llm.FinishMaxTokens}},
+			message: "Tell me a story",
+			reply:   "Once upon a",
+			finish:  llm.FinishMaxTokens,
+			usage:   models.Usage{PromptTokens: 4, OutputTokens: 3, TotalTokens: 7},
+		},
+		{
+			name:    "echo once the script ran out",
+			message: "Are you there?",
+			reply:   "Echo: Are you there?",
+			finish:  models.FinishStop,
+			usage:   models.Usage{PromptTokens: 3, OutputTokens: 4, TotalTokens: 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler, store := newTestServer(t, fake.New(tt.replies...))
+
+			w := send(t, handler, http.MethodPost, "/chat", `{"message":"`+tt.message+`"}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+			}
+
+			var resp models.ChatResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Response != tt.reply {
+				t.Errorf("response = %q, want %q", resp.Response, tt.reply)
+			}
+			if resp.FinishReason != tt.finish {
+				t.Errorf("finish_reason = %q, want %q", resp.FinishReason, tt.finish)
+			}
+			if resp.Usage == nil || *resp.Usage != tt.usage {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, tt.usage)
+			}
+			if resp.Model != "gemini-2.5-flash" {
+				t.Errorf("model = %q, want the default model", resp.Model)
+			}
+
+			saved := store.messages(resp.ConversationID)
+			if len(saved) != 2 || saved[0].Content != tt.message || saved[1].Content != tt.reply || saved[1].FinishReason != tt.finish {
+				t.Errorf("saved messages = %+v, want the exchange", saved)
+			}
+		})
+	}
+}
+
+func TestChatHandlerContinuesConversation(t *testing.T) {
+	provider := fake.New(fake.Reply{Text: "Nice to meet you, Ada."})
+	_, handler, store := newTestServer(t, provider)
+	conversationID := store.add(testUserID,
+		models.Message{Role: RoleUser, Content: "Hi"},
+		models.Message{Role: RoleModel, Content: "Hello! What is your name?"},
+	)
+
+	w := send(t, handler, http.MethodPost, "/chat", `{"conversation_id":"`+conversationID+`","message":"I am Ada"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.ChatResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	// The history is sent along: 1 + 5 words stored, 3 words sent
+	if want := (models.Usage{PromptTokens: 9, OutputTokens: 5, TotalTokens: 14}); resp.Usage == nil || *resp.Usage != want {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+	if len(resp.History) != 4 || resp.ConversationID != conversationID {
+		t.Errorf("response = %s with %d messages, want the conversation with 4", resp.ConversationID, len(resp.History))
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 1 || len(requests[0].Messages) != 3 {
+		t.Fatalf("the model received %d requests, want one with 3 messages", len(requests))
+	}
+	if got := requests[0].Messages[1].Text(); got != "Hello! What is your name?" {
+		t.Errorf("second message = %q, want the stored reply", got)
+	}
+	if got := len(store.messages(conversationID)); got != 4 {
+		t.Errorf("conversation holds %d messages, want 4", got)
+	}
+}
+
+func TestChatHandlerFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []fake.Reply
+		body    func(store *testConversations) string
+		status  int
+		message string
+	}{
+		{
+			name:    "missing message",
+			body:    func(*testConversations) string { return `{"message":"  "}` },
+			status:  http.StatusBadRequest,
+			message: "Message is required",
+		},
+		{
+			name: "unknown conversation",
+			body: func(*testConversations) string {
+				return `{"conversation_id":"0b6f6a8e-6d55-4c1e-9b7a-2f6f0c3f8d21","message":"Hi"}`
+			},
+			status:  http.StatusNotFound,
+			message: "Conversation not found",
+		},
+		{
+			name: "stored message with an unknown role",
+			body: func(store *testConversations) string {
+				id := store.add(testUserID, models.Message{Role: "tool", Content: `{"result":4}`})
+				return `{"conversation_id":"` + id + `","message":"Hi"}`
+			},
+			status:  http.StatusInternalServerError,
+			message: "Failed to load conversation",
+		},
+		{
+			name:    "model error",
+			replies: []fake.Reply{{Error: "quota exceeded"}},
+			body:    func(*testConversations) string { return `{"message":"Hi"}` },
+			status:  http.StatusInternalServerError,
+			message: "Failed to send message to the model",
+		},
+		{
+			name:    "blocked message",
+			replies: []fake.Reply{{BlockReason: "SAFETY"}},
+			body:    func(*testConversations) string { return `{"message":"Hi"}` },
+			status:  http.StatusBadGateway,
+			message: "The message was blocked by the model: SAFETY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler, store := newTestServer(t, fake.New(tt.replies...))
+
+			w := send(t, handler, http.MethodPost, "/chat", tt.body(store))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.message {
+				t.Errorf("body = %q, want %q", got, tt.message)
+			}
+		})
+	}
+}
+
+func TestStreamChatHandler(t *testing.T) {
+	_, handler, store := newTestServer(t, fake.New(fake.Reply{Text: "Hello Ada, how can I help?"}))
+
+	w := send(t, handler, http.MethodPost, "/stream", `{"message":"Hello there"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	if w.Header().Get("X-Generation-ID") == "" {
+		t.Error("X-Generation-ID is not set")
+	}
+
+	var reply strings.Builder
+	var names []string
+	var usage models.Usage
+	var finish models.FinishEvent
+	var history models.ChatResponse
+	for _, event := range parseEvents(t, w.Body.String()) {
+		names = append(names, event.Name)
+		var err error
+		switch event.Name {
+		case models.EventDelta:
+			var delta models.DeltaEvent
+			err = json.Unmarshal([]byte(event.Data), &delta)
+			reply.WriteString(delta.Text)
+		case models.EventUsage:
+			err = json.Unmarshal([]byte(event.Data), &usage)
+		case models.EventFinish:
+			err = json.Unmarshal([]byte(event.Data), &finish)
+		case models.EventHistory:
+			err = json.Unmarshal([]byte(event.Data), &history)
+		}
+		if err != nil {
+			t.Fatalf("decoding %s event: %v", event.Name, err)
+		}
+	}
+
+	want := []string{"delta", "delta", "delta", "delta", "delta", "delta", "usage", "finish", "history"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", names, want)
+	}
+	if reply.String() != "Hello Ada, how can I help?" {
+		t.Errorf("deltas = %q, want the reply", reply.String())
+	}
+	if want := (models.Usage{PromptTokens: 2, OutputTokens: 6, TotalTokens: 8}); usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+	if finish.Reason != models.FinishStop {
+		t.Errorf("finish reason = %q, want %q", finish.Reason, models.FinishStop)
+	}
+	if history.Response != reply.String() || history.FinishReason != models.FinishStop || history.Usage == nil {
+		t.Errorf("history = %+v, want the saved reply", history)
+	}
+	if saved := store.messages(history.ConversationID); len(saved) != 2 || saved[1].Content != reply.String() {
+		t.Errorf("saved messages = %+v, want the exchange", saved)
+	}
+}
+
+func TestChatCompletionsHandler(t *testing.T) {
+	t.Run("completion", func(t *testing.T) {
+		_, handler, _ := newTestServer(t, fake.New(fake.Reply{Text: "Paris is the capital of France."}))
+
+		w := send(t, handler, http.MethodPost, "/v1/chat/completions", `{
+			"model": "gemini-2.5-flash",
+			"messages": [
+				{"role": "system", "content": "Answer in one sentence"},
+				{"role": "user", "content": "What is the capital of France?"}
+			]
+		}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+		}
+
+		var completion openai.ChatCompletion
+		if err := json.NewDecoder(w.Body).Decode(&completion); err != nil {
+			t.Fatalf("decoding completion: %v", err)
+		}
+		if completion.Object != "chat.completion" || completion.Model != "gemini-2.5-flash" {
+			t.Errorf("completion = %s of %s, want a chat.completion of gemini-2.5-flash", completion.Object, completion.Model)
+		}
+		if len(completion.Choices) != 1 {
+			t.Fatalf("choices = %+v, want one", completion.Choices)
+		}
+		choice := completion.Choices[0]
+		if choice.Message.Content == nil || choice.Message.Content.Text != "Paris is the capital of France." {
+			t.Errorf("content = %+v, want the reply", choice.Message.Content)
+		}
+		if choice.Message.Role != openai.RoleAssistant || choice.FinishReason != openai.FinishStop {
+			t.Errorf("choice = %s finishing with %s, want assistant and stop", choice.Message.Role, choice.FinishReason)
+		}
+		// The system instruction counts as prompt: 4 + 6 words
+		if want := (openai.Usage{PromptTokens: 10, CompletionTokens: 6, TotalTokens: 16}); completion.Usage == nil || *completion.Usage != want {
+			t.Errorf("usage = %+v, want %+v", completion.Usage, want)
+		}
+	})
+
+	t.Run("length", func(t *testing.T) {
+		_, handler, _ := newTestServer(t, fake.New(fake.Reply{Text: "Paris is", FinishReason: llm.FinishMaxTokens}))
+
+		w := send(t, handler, http.MethodPost, "/v1/chat/completions", `{"model":"gemini-2.5-flash","messages":[{"role":"user","content":"Capital of France?"}]}`)
+		var completion openai.ChatCompletion
+		if err := json.NewDecoder(w.Body).Decode(&completion); err != nil {
+			t.Fatalf("decoding completion: %v", err)
+		}
+		if len(completion.Choices) != 1 || completion.Choices[0].FinishReason != openai.FinishLength {
+			t.Errorf("choices = %+v, want one finishing with length", completion.Choices)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		_, handler, _ := newTestServer(t, fake.New(fake.Reply{Text: "Paris is the capital of France."}))
+
+		w := send(t, handler, http.MethodPost, "/v1/chat/completions", `{
+			"model": "gemini-2.5-flash",
+			"stream": true,
+			"stream_options": {"include_usage": true},
+			"messages": [{"role": "user", "content": "What is the capital of France?"}]
+		}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+		}
+
+		events := parseEvents(t, w.Body.String())
+		if len(events) == 0 || events[len(events)-1].Data != "[DONE]" {
+			t.Fatalf("stream does not end with [DONE]: %s", w.Body.String())
+		}
+
+		var text strings.Builder
+		var finish string
+		var usage *openai.Usage
+		for i, event := range events[:len(events)-1] {
+			var chunk openai.ChatCompletionChunk
+			if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+				t.Fatalf("decoding chunk %d: %v", i, err)
+			}
+			if chunk.Object != "chat.completion.chunk" {
+				t.Errorf("chunk %d object = %q", i, chunk.Object)
+			}
+			for _, choice := range chunk.Choices {
+				if (i == 0) != (choice.Delta.Role == openai.RoleAssistant) {
+					t.Errorf("chunk %d role = %q, want it on the first chunk only", i, choice.Delta.Role)
+				}
+				text.WriteString(choice.Delta.Content)
+				if choice.FinishReason != nil {
+					finish = *choice.FinishReason
+				}
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+		}
+
+		if text.String() != "Paris is the capital of France." {
+			t.Errorf("content = %q, want the reply", text.String())
+		}
+		if finish != openai.FinishStop {
+			t.Errorf("finish_reason = %q, want stop", finish)
+		}
+		if want := (openai.Usage{PromptTokens: 6, CompletionTokens: 6, TotalTokens: 12}); usage == nil || *usage != want {
+			t.Errorf("usage = %+v, want %+v", usage, want)
+		}
+	})
+
+	t.Run("stream with tool calls", func(t *testing.T) {
+		_, handler, _ := newTestServer(t, fake.New(fake.Reply{
+			Text: "Let me check.",
+			ToolCalls: []llm.ToolCall{
+				{ID: "call_1", Name: "weather", Args: map[string]any{"city": "Paris"}},
+				{Name: "time"},
+			},
+		}))
+
+		w := send(t, handler, http.MethodPost, "/v1/chat/completions", `{
+			"model": "gemini-2.5-flash",
+			"stream": true,
+			"stream_options": {"include_usage": true},
+			"messages": [{"role": "user", "content": "Weather and time in Paris?"}],
+			"tools": [
+				{"type": "function", "function": {"name": "weather", "parameters": {"type": "object"}}},
+				{"type": "function", "function": {"name": "time"}}
+			]
+		}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+		}
+
+		events := parseEvents(t, w.Body.String())
+		if len(events) < 2 || events[len(events)-1].Data != "[DONE]" {
+			t.Fatalf("stream does not end with [DONE]: %s", w.Body.String())
+		}
+		chunks := make([]openai.ChatCompletionChunk, len(events)-1)
+		for i, event := range events[:len(events)-1] {
+			if err := json.Unmarshal([]byte(event.Data), &chunks[i]); err != nil {
+				t.Fatalf("decoding chunk %d: %v", i, err)
+			}
+		}
+
+		// The usage comes alone in the last chunk, with no choices
+		last := chunks[len(chunks)-1]
+		if last.Usage == nil || last.Choices == nil || len(last.Choices) != 0 {
+			t.Errorf("last chunk = %+v, want the usage without choices", last)
+		}
+		for i, chunk := range chunks[:len(chunks)-1] {
+			if chunk.Usage != nil {
+				t.Errorf("chunk %d carries the usage, want it on the last chunk only", i)
+			}
+		}
+
+		var calls []openai.ToolCall
+		var finish string
+		for _, chunk := range chunks {
+			for _, choice := range chunk.Choices {
+				calls = append(calls, choice.Delta.ToolCalls...)
+				if choice.FinishReason != nil {
+					finish = *choice.FinishReason
+				}
+			}
+		}
+		if len(calls) != 2 {
+			t.Fatalf("tool calls = %+v, want 2", calls)
+		}
+		for i, call := range calls {
+			if call.Index == nil || *call.Index != i {
+				t.Errorf("tool call %d index = %v, want %d", i, call.Index, i)
+			}
+			if call.Type != "function" || call.ID == "" {
+				t.Errorf("tool call %d = %+v, want a function call with an id", i, call)
+			}
+		}
+		if calls[0].ID != "call_1" || calls[0].Function.Name != "weather" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+			t.Errorf("first tool call = %+v, want weather in Paris", calls[0])
+		}
+		// Calls of backends without ids get one, and arguments are always an object
+		if !strings.HasPrefix(calls[1].ID, "call_") || calls[1].Function.Name != "time" || calls[1].Function.Arguments != "{}" {
+			t.Errorf("second tool call = %+v, want time without arguments", calls[1])
+		}
+		if finish != openai.FinishToolCalls {
+			t.Errorf("finish_reason = %q, want tool_calls", finish)
+		}
+	})
+
+	t.Run("stream without usage", func(t *testing.T) {
+		_, handler, _ := newTestServer(t, fake.New(fake.Reply{Text: "Paris."}))
+
+		w := send(t, handler, http.MethodPost, "/v1/chat/completions", `{"model":"gemini-2.5-flash","stream":true,"messages":[{"role":"user","content":"Capital of France?"}]}`)
+		for _, event := range parseEvents(t, w.Body.String()) {
+			if strings.Contains(event.Data, `"usage"`) {
+				t.Errorf("chunk %s carries the usage, which was not asked for", event.Data)
+			}
+		}
+	})
+}
+
+func TestOutputTokenLimit(t *testing.T) {
+	small, err := catalog.Parse([]byte(`{
+		"default_model": "small",
+		"models": [{"id": "small", "output_token_limit": 1024, "capabilities": ["text"], "enabled": true}]
+	}`))
+	if err != nil {
+		t.Fatalf("parsing catalog: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		body      string
+		status    int
+		message   string
+		maxTokens int32
+	}{
+		{
+			name:      "chat at the limit",
+			path:      "/chat",
+			body:      `{"message":"Hi","generation":{"max_output_tokens":1024}}`,
+			status:    http.StatusOK,
+			maxTokens: 1024,
+		},
+		{
+			name:    "chat over the limit of the model",
+			path:    "/chat",
+			body:    `{"message":"Hi","generation":{"max_output_tokens":1025}}`,
+			status:  http.StatusBadRequest,
+			message: "Invalid generation settings: max_output_tokens must be at most 1024 for small",
+		},
+		{
+			name:    "chat over the limit of every model",
+			path:    "/chat",
+			body:    `{"message":"Hi","generation":{"max_output_tokens":70000}}`,
+			status:  http.StatusBadRequest,
+			message: "Invalid generation settings: max_output_tokens must be between 1 and 65536",
+		},
+		{
+			name:      "completion at the limit",
+			path:      "/v1/chat/completions",
+			body:      `{"model":"small","max_tokens":1024,"messages":[{"role":"user","content":"Hi"}]}`,
+			status:    http.StatusOK,
+			maxTokens: 1024,
+		},
+		{
+			name:    "completion over the limit of the model",
+			path:    "/v1/chat/completions",
+			body:    `{"model":"small","max_tokens":4096,"messages":[{"role":"user","content":"Hi"}]}`,
+			status:  http.StatusBadRequest,
+			message: "max_tokens must be at most 1024 for small",
+		},
+		{
+			name:      "max_completion_tokens replacing max_tokens",
+			path:      "/v1/chat/completions",
+			body:      `{"model":"small","max_tokens":4096,"max_completion_tokens":512,"messages":[{"role":"user","content":"Hi"}]}`,
+			status:    http.StatusOK,
+			maxTokens: 512,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := fake.New(fake.Reply{Text: "Hello"})
+			gs, handler, _ := newTestServer(t, provider)
+			gs.Catalog = small
+			temperature := float32(0.3)
+			gs.GenerationDefaults = &models.GenerationConfig{Temperature: &temperature}
+
+			w := send(t, handler, http.MethodPost, tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				if !strings.Contains(w.Body.String(), tt.message) {
+					t.Errorf("body = %s, want %q", w.Body.String(), tt.message)
+				}
+				if len(provider.Requests()) != 0 {
+					t.Error("the refused request was sent to the model")
+				}
+				return
+			}
+
+			requests := provider.Requests()
+			if len(requests) != 1 || requests[0].Config == nil {
+				t.Fatalf("model received %d requests, want 1 with generation settings", len(requests))
+			}
+			config := requests[0].Config
+			if config.MaxOutputTokens == nil || *config.MaxOutputTokens != tt.maxTokens {
+				t.Errorf("max_output_tokens = %v, want %d", config.MaxOutputTokens, tt.maxTokens)
+			}
+			if config.Temperature == nil || *config.Temperature != temperature {
+				t.Errorf("temperature = %v, want the default %v", config.Temperature, temperature)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/conversations"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func (gs *GenAIServer) CreateConversationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	// The body is optional
+	var req models.ConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	title, ok := validTitle(w, req.Title, false)
+	if !ok {
+		return
+	}
+
+	conversation, err := gs.conversations.Create(r.Context(), claims.UserID, title)
+	if err != nil {
+		logrus.WithError(err).Error("Error creating conversation")
+		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, conversation)
+}
+
+func (gs *GenAIServer) ListConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, total, err := gs.conversations.List(r.Context(), claims.UserID, limit, offset)
+	if err != nil {
+		logrus.WithError(err).Error("Error listing conversations")
+		http.Error(w, "Failed to list conversations", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ConversationListResponse{
+		Conversations: list,
+		Total:         total,
+		Limit:         limit,
+		Offset:        offset,
+	})
+}
+
+func (gs *GenAIServer) GetConversationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	conversation, err := gs.conversations.Get(r.Context(), claims.UserID, id)
+	if err != nil {
+		writeConversationError(w, err, "Failed to load conversation")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, conversation)
+}
+
+func (gs *GenAIServer) RenameConversationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	title, ok := validTitle(w, req.Title, true)
+	if !ok {
+		return
+	}
+
+	conversation, err := gs.conversations.Rename(r.Context(), claims.UserID, id, title)
+	if err != nil {
+		writeConversationError(w, err, "Failed to rename conversation")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, conversation)
+}
+
+func (gs *GenAIServer) DeleteConversationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := gs.conversations.Delete(r.Context(), claims.UserID, id); err != nil {
+		writeConversationError(w, err, "Failed to delete conversation")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAttachmentHandler downloads a file sent in a conversation of the caller
+func (gs *GenAIServer) GetAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := conversationID(w, r)
+	if !ok {
+		return
+	}
+	attachmentID := r.PathValue("attachmentId")
+	if err := uuid.Validate(attachmentID); err != nil {
+		http.Error(w, "Invalid attachment id", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := gs.conversations.Attachment(r.Context(), claims.UserID, id, attachmentID)
+	if err != nil {
+		writeConversationError(w, err, "Failed to load attachment")
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.MIMEType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(attachment.Data)))
+	// Files are served as they were uploaded, browsers must not guess another type
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if attachment.Name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.Name}))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(attachment.Data)
+}
+
+// conversationID returns the {id} path value, answering 400 when it is not a UUID
+func conversationID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if err := uuid.Validate(id); err != nil {
+		http.Error(w, "Invalid conversation id", http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
+
+func validTitle(w http.ResponseWriter, title string, required bool) (string, bool) {
+	title = strings.TrimSpace(title)
+	if required && title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(title) > conversations.MaxTitleLength {
+		http.Error(w, "Title must be at most "+strconv.Itoa(conversations.MaxTitleLength)+" characters", http.StatusBadRequest)
+		return "", false
+	}
+	return title, true
+}
+
+// writeConversationError answers 404 for conversations and attachments that
+// do not exist or belong to another user, 409 for conflicting changes, and logs any other error
+func writeConversationError(w http.ResponseWriter, err error, message string) {
+	writeRequestError(w, conversationRequestError(err, message), message)
+}
+
+// conversationRequestError converts the errors of the conversation store,
+// logging unexpected errors
+func conversationRequestError(err error, message string) error {
+	switch {
+	case errors.Is(err, conversations.ErrNotFound):
+		return &requestError{status: http.StatusNotFound, message: "Conversation not found"}
+	case errors.Is(err, conversations.ErrAttachmentNotFound):
+		return &requestError{status: http.StatusNotFound, message: "Attachment not found"}
+	case errors.Is(err, conversations.ErrConflict):
+		return &requestError{status: http.StatusConflict, message: "The conversation changed meanwhile, try again"}
+	}
+	logrus.WithError(err).Error(message)
+	return &requestError{status: http.StatusInternalServerError, message: message}
+}
+
+// pagination reads the limit and offset query parameters
+func pagination(r *http.Request) (int, int, error) {
+	limit, offset := defaultPageSize, 0
+	query := r.URL.Query()
+
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		limit = n
+	}
+	if value := query.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be a positive number")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -1,191 +1,583 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/catalog"
+	"github.com/Mahaveer86619/ImaginAI/internal/conversations"
+	"github.com/Mahaveer86619/ImaginAI/internal/generation"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
 	"github.com/Mahaveer86619/ImaginAI/internal/models"
-	"google.golang.org/genai"
+	"github.com/Mahaveer86619/ImaginAI/internal/personas"
+	"github.com/Mahaveer86619/ImaginAI/internal/uploads"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
 const (
-	RoleUser  string = genai.RoleUser
-	RoleModel string = genai.RoleModel
+	RoleUser  string = llm.RoleUser
+	RoleModel string = llm.RoleModel
+	// RoleSystem messages of a history are folded into the system instruction
+	RoleSystem string = "system"
 )
 
 func (gs *GenAIServer) ChatHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	turn, ok := gs.startTurn(w, r)
+	if !ok {
 		return
 	}
 
-	var req models.ChatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	// The message was validated by startTurn, so failures come from the stored history
+	req, err := turn.request()
+	if err != nil {
+		writeRequestError(w, err, "Failed to load conversation")
 		return
 	}
 
-	gs.mu.Lock()
-	client := gs.client
-	gs.mu.Unlock()
+	// The model stops writing when the client goes away or the reply takes too long
+	ctx, cancel := gs.Generations.WithTimeout(r.Context())
+	defer cancel()
+
+	// Each step is a reply of the model, which goes on with the results of
+	// the tools it called until it answers without calling any
+	var reply strings.Builder
+	var finishReason string
+	var usage *models.Usage
+	var toolCalls []models.ToolCall
+	for step := 1; ; step++ {
+		res, err := turn.provider.Chat(ctx, req)
+		if err != nil || res == nil {
+			switch {
+			case r.Context().Err() != nil:
+				logrus.WithField("user_id", turn.userID).Info("Client went away before the reply")
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				http.Error(w, "The model did not reply in time", http.StatusGatewayTimeout)
+			default:
+				logrus.WithError(err).WithField("user_id", turn.userID).Error("Error generating reply")
+				http.Error(w, "Failed to send message to the model", http.StatusInternalServerError)
+			}
+			return
+		}
+		usage = addUsage(usage, res.Usage)
 
-	if client == nil {
-		http.Error(w, "Gemini client not initialized. Please POST /setup first.", http.StatusServiceUnavailable)
+		text, reason := responseText(res)
+		finishReason = reason
+		if text != "" && reply.Len() > 0 {
+			reply.WriteString("\n\n")
+		}
+		reply.WriteString(text)
+
+		calls := responseCalls(res)
+		if !gs.callsTools(turn, calls, step) {
+			if reply.Len() == 0 {
+				http.Error(w, emptyReplyMessage(res.BlockReason, finishReason), http.StatusBadGateway)
+				return
+			}
+			break
+		}
+		toolCalls = append(toolCalls, gs.runTools(ctx, turn, req, text, calls, step, func(string, interface{}) {})...)
+	}
+	if finishReason == "" {
+		finishReason = models.FinishStop
+	}
+
+	resp, err := gs.finishTurn(r.Context(), turn, reply.String(), finishReason)
+	if err != nil {
+		writeConversationError(w, err, "Failed to save conversation")
 		return
 	}
+	resp.Usage = usage
+	resp.ToolCalls = toolCalls
 
-	temp := float32(0.9)
-	topP := float32(0.5)
-	topK := float32(20.0)
+	writeJSON(w, http.StatusOK, resp)
+}
 
-	config := &genai.GenerateContentConfig{
-		Temperature: &temp,
-		TopP:        &topP,
-		TopK:        &topK,
+// chatTurn is a message being sent to the model in a conversation of userID
+type chatTurn struct {
+	userID         string
+	conversationID string
+	// message is the text of the message, parts its text and files in order
+	message  string
+	parts    []models.MessagePart
+	provider llm.Provider
+	// history holds the stored messages of the conversation, with the data of their files
+	history []models.ChatMessage
+	model   *catalog.Model
+	// generation holds the request's settings merged with the defaults
+	generation *models.GenerationConfig
+	// tools are declared to the model, which may call them while replying
+	tools []llm.Tool
+	// system is saved with the exchange, instruction is the text it resolves to
+	system      conversations.SystemSettings
+	instruction string
+	// replaceLast is set when the reply to the last message of the
+	// conversation is regenerated, the new exchange replacing the last one
+	replaceLast bool
+}
+
+// request returns the request sending the message of t to the model
+func (t *chatTurn) request() (*llm.Request, error) {
+	messages, system, err := toMessages(t.history)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history of conversation %s: %w", t.conversationID, err)
+	}
+	if t.instruction != "" {
+		system = append(system, t.instruction)
+	}
+
+	return &llm.Request{
+		Model:    t.model.ID,
+		System:   strings.Join(system, "\n\n"),
+		Messages: append(messages, toMessage(RoleUser, t.message, t.parts)),
+		Config:   t.generation,
+		Tools:    t.tools,
+	}, nil
+}
+
+// requestError is a chat request that cannot proceed, with the status it is answered with
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// writeRequestError answers the request with err, a *requestError or an
+// unexpected error logged as message
+func writeRequestError(w http.ResponseWriter, err error, message string) {
+	var reqErr *requestError
+	if !errors.As(err, &reqErr) {
+		logrus.WithError(err).Error(message)
+		reqErr = &requestError{status: http.StatusInternalServerError, message: message}
 	}
+	http.Error(w, reqErr.message, reqErr.status)
+}
 
-	history := req.History
-	history = append(history, models.ChatMessage{Role: RoleUser, Message: req.Message})
+// startTurn decodes a chat request, sent as JSON or as multipart/form-data
+// with files, and loads the history of its conversation, answering the
+// request itself when it cannot proceed
+func (gs *GenAIServer) startTurn(w http.ResponseWriter, r *http.Request) (*chatTurn, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, gs.Uploads.MaxRequestSize)
 
-	chat, err := client.Chats.Create(gs.Ctx, "gemini-2.5-flash", config, ToGenaiContent(history))
+	var req models.ChatRequest
+	var files []uploads.File
+	if uploads.IsMultipart(r) {
+		var err error
+		if files, err = uploads.ReadMultipart(r, gs.Uploads, &req); err != nil {
+			writeRequestError(w, uploadRequestError(err), "Failed to read upload")
+			return nil, false
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			http.Error(w, "Requests must be at most "+strconv.FormatInt(maxBytes.Limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	turn, err := gs.newTurn(r.Context(), claims.UserID, &req, files, false)
 	if err != nil {
-		http.Error(w, "Failed to create chat", http.StatusInternalServerError)
-		return
+		writeRequestError(w, err, "Failed to start chat")
+		return nil, false
 	}
+	return turn, true
+}
 
-	res, err := chat.SendMessage(gs.Ctx, genai.Part{Text: req.Message})
-	if err != nil || res == nil {
-		http.Error(w, "Failed to send message to Gemini. err: "+err.Error(), http.StatusInternalServerError)
-		return
+// newTurn validates a chat request of userID, with the files uploaded along,
+// and loads the history of its conversation. When regenerate is set, the
+// request's message is replaced by the last message of the conversation,
+// whose reply is generated again. Errors the client should see are
+// *requestError.
+func (gs *GenAIServer) newTurn(ctx context.Context, userID string, req *models.ChatRequest, files []uploads.File, regenerate bool) (*chatTurn, error) {
+	badRequest := func(message string) error {
+		return &requestError{status: http.StatusBadRequest, message: message}
 	}
 
-	history = append(history, models.ChatMessage{Role: RoleModel, Message: res.Text()})
+	switch {
+	case regenerate && req.ConversationID == "":
+		return nil, badRequest("conversation_id is required to regenerate a reply")
+	case regenerate && (req.Message != "" || len(req.Parts) > 0 || len(files) > 0):
+		return nil, badRequest("Regenerated replies answer the last message of the conversation, message and parts must be empty")
+	}
 
-	resp := models.ChatResponse{
-		Response: res.Text(),
-		History:  history,
+	var parts []models.MessagePart
+	if !regenerate {
+		var err error
+		if parts, err = uploads.Resolve(req, files, gs.Uploads); err != nil {
+			return nil, uploadRequestError(err)
+		}
+		if strings.TrimSpace(models.PartsText(parts)) == "" && !models.HasFiles(parts) {
+			return nil, badRequest("Message is required")
+		}
+	}
+	if req.ConversationID != "" && uuid.Validate(req.ConversationID) != nil {
+		return nil, badRequest("Invalid conversation id")
+	}
+	if req.PersonaID != "" && uuid.Validate(req.PersonaID) != nil {
+		return nil, badRequest("Invalid persona id")
+	}
+	if req.System != nil && utf8.RuneCountInString(*req.System) > personas.MaxInstructionLength {
+		return nil, badRequest("system must be at most " + strconv.Itoa(personas.MaxInstructionLength) + " characters")
+	}
+	if err := generation.Validate(req.Generation); err != nil {
+		return nil, badRequest("Invalid generation settings: " + err.Error())
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-}
+	settings, err := gs.users.ChatSettings(ctx, userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Error loading chat settings")
+		return nil, &requestError{status: http.StatusInternalServerError, message: "Failed to load chat settings"}
+	}
 
-func (gs *GenAIServer) StreamChatHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	model, err := gs.Catalog.Resolve(req.Model, settings.Plan, settings.DefaultModel)
+	switch {
+	case errors.Is(err, catalog.ErrUnknownModel):
+		return nil, badRequest("Unknown model " + req.Model + ", see GET /models")
+	case errors.Is(err, catalog.ErrModelNotOnPlan):
+		return nil, &requestError{status: http.StatusForbidden, message: "Model " + req.Model + " is not available on your plan"}
 	}
 
-	var req models.ChatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	for i, name := range req.Tools {
+		switch {
+		case gs.Tools.Get(name) == nil:
+			return nil, badRequest("Unknown tool " + name + ", see GET /tools")
+		case slices.Contains(req.Tools[:i], name):
+			return nil, badRequest("Tool " + name + " is listed more than once")
+		}
+	}
+	if len(req.Tools) > 0 && !model.Supports("tools") {
+		return nil, badRequest("Model " + model.ID + " cannot call tools")
+	}
+	declarations, err := gs.Tools.Declarations(req.Tools)
+	if err != nil {
+		return nil, err
 	}
 
-	gs.mu.Lock()
-	client := gs.client
-	gs.mu.Unlock()
+	merged := generation.Merge(gs.GenerationDefaults, req.Generation)
+	if merged.MaxOutputTokens != nil && model.OutputTokenLimit > 0 && *merged.MaxOutputTokens > model.OutputTokenLimit {
+		return nil, badRequest("Invalid generation settings: max_output_tokens must be at most " + strconv.Itoa(int(model.OutputTokenLimit)) + " for " + model.ID)
+	}
 
-	if client == nil {
-		http.Error(w, "Gemini client not initialized. Please POST /setup first.", http.StatusServiceUnavailable)
-		return
+	turn := &chatTurn{
+		userID:         userID,
+		conversationID: req.ConversationID,
+		message:        models.PartsText(parts),
+		parts:          parts,
+		model:          model,
+		generation:     merged,
+		tools:          declarations,
+	}
+
+	if turn.conversationID != "" {
+		conversation, err := gs.conversations.Get(ctx, turn.userID, turn.conversationID)
+		if err != nil {
+			return nil, conversationRequestError(err, "Failed to load conversation")
+		}
+		messages := conversation.Messages
+		if regenerate {
+			last := len(messages) - 2
+			if last < 0 || messages[last].Role != RoleUser || messages[last+1].Role != RoleModel {
+				return nil, &requestError{status: http.StatusConflict, message: "The conversation does not end with a reply to regenerate"}
+			}
+			turn.message = messages[last].Content
+			turn.parts = messages[last].Parts
+			if turn.parts == nil {
+				turn.parts = []models.MessagePart{{Type: models.PartText, Text: turn.message}}
+			}
+			turn.replaceLast = true
+			messages = messages[:last]
+		}
+		for _, message := range messages {
+			turn.history = append(turn.history, models.ChatMessage{Role: message.Role, Message: message.Content, Parts: message.Parts})
+		}
+		if err := gs.loadAttachments(ctx, turn); err != nil {
+			logrus.WithError(err).WithField("conversation_id", turn.conversationID).Error("Error loading attachments")
+			return nil, &requestError{status: http.StatusInternalServerError, message: "Failed to load conversation"}
+		}
+		turn.system = conversations.SystemSettings{
+			PersonaID:      conversation.PersonaID,
+			PersonaVersion: conversation.PersonaVersion,
+			System:         conversation.System,
+		}
 	}
 
-	temp := float32(0.9)
-	topP := float32(0.5)
-	topK := float32(20.0)
+	if mimeType := unreadableFile(model, turn); mimeType != "" {
+		return nil, badRequest("Model " + model.ID + " cannot read " + mimeType + " files")
+	}
 
-	config := &genai.GenerateContentConfig{
-		Temperature: &temp,
-		TopP:        &topP,
-		TopK:        &topK,
+	if err := gs.resolveSystem(ctx, turn, req); err != nil {
+		return nil, personaRequestError(err, "Failed to load persona")
 	}
 
-	history := req.History
-	history = append(history, models.ChatMessage{Role: RoleUser, Message: req.Message})
+	provider, status, err := gs.providerFor(ctx, userID)
+	if err != nil {
+		return nil, &requestError{status: status, message: err.Error()}
+	}
+	turn.provider = provider
+
+	return turn, nil
+}
 
-	chat, err := client.Chats.Create(gs.Ctx, "gemini-2.5-flash", config, ToGenaiContent(history))
+// uploadRequestError converts the errors of the uploads package to the status they are answered with
+func uploadRequestError(err error) error {
+	switch {
+	case errors.Is(err, uploads.ErrTooLarge):
+		return &requestError{status: http.StatusRequestEntityTooLarge, message: err.Error()}
+	case errors.Is(err, uploads.ErrUnsupportedType):
+		return &requestError{status: http.StatusUnsupportedMediaType, message: err.Error()}
+	case errors.Is(err, uploads.ErrInvalid):
+		return &requestError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	return err
+}
+
+// loadAttachments loads the data of the files of the history and message of
+// turn, which only hold their attachment ids when loaded from the conversation
+func (gs *GenAIServer) loadAttachments(ctx context.Context, turn *chatTurn) error {
+	stored := func(parts []models.MessagePart) bool {
+		for _, part := range parts {
+			if part.Type == models.PartFile && part.Data == nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	needed := stored(turn.parts)
+	for _, message := range turn.history {
+		needed = needed || stored(message.Parts)
+	}
+	if !needed {
+		return nil
+	}
+
+	data, err := gs.conversations.AttachmentData(ctx, turn.conversationID)
 	if err != nil {
-		http.Error(w, "Failed to create chat", http.StatusInternalServerError)
-		return
+		return err
 	}
 
-	iter := chat.SendMessageStream(gs.Ctx, genai.Part{Text: req.Message})
+	fill := func(parts []models.MessagePart) []models.MessagePart {
+		if !stored(parts) {
+			return parts
+		}
+		filled := make([]models.MessagePart, len(parts))
+		for i, part := range parts {
+			if part.Type == models.PartFile && part.Data == nil {
+				part.Data = data[part.AttachmentID]
+			}
+			filled[i] = part
+		}
+		return filled
+	}
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+	turn.parts = fill(turn.parts)
+	for i := range turn.history {
+		turn.history[i].Parts = fill(turn.history[i].Parts)
+	}
+	return nil
+}
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
-		return
+// unreadableFile returns the type of the first file of turn model cannot
+// read, or "" when it can read them all
+func unreadableFile(model *catalog.Model, turn *chatTurn) string {
+	check := func(parts []models.MessagePart) string {
+		for _, part := range parts {
+			if part.Type == models.PartFile && !model.Supports(uploads.Capability(part.MIMEType)) {
+				return part.MIMEType
+			}
+		}
+		return ""
 	}
 
-	var responseText string
+	for _, message := range turn.history {
+		if mimeType := check(message.Parts); mimeType != "" {
+			return mimeType
+		}
+	}
+	return check(turn.parts)
+}
 
-	for chunk, _ := range iter {
-		if chunk == nil {
-			break
+// resolveSystem applies the persona and inline system instruction of the
+// request to the conversation's, and resolves them to the instruction text
+func (gs *GenAIServer) resolveSystem(ctx context.Context, turn *chatTurn, req *models.ChatRequest) error {
+	if req.PersonaID != "" {
+		turn.system.PersonaID = req.PersonaID
+		turn.system.PersonaVersion = 0
+	}
+	if req.System != nil {
+		turn.system.System = strings.TrimSpace(*req.System)
+	}
+
+	var parts []string
+	if turn.system.PersonaID != "" {
+		persona, err := gs.personas.Get(ctx, turn.userID, turn.system.PersonaID, turn.system.PersonaVersion)
+		switch {
+		case err == nil:
+			turn.system.PersonaVersion = persona.Version
+			parts = append(parts, persona.Instruction)
+		case errors.Is(err, personas.ErrNotFound) && req.PersonaID == "":
+			// The persona of the conversation was deleted in the meantime
+			turn.system.PersonaID, turn.system.PersonaVersion = "", 0
+		default:
+			return err
 		}
-		part := chunk.Candidates[0].Content.Parts[0]
-		responseText += part.Text
-		// Send each part as an SSE event
-		_, _ = w.Write([]byte("data: " + responseText + "\n\n"))
-		flusher.Flush()
 	}
+	if turn.system.System != "" {
+		parts = append(parts, turn.system.System)
+	}
+
+	turn.instruction = strings.Join(parts, "\n\n")
+	return nil
+}
 
-	// Save the model's response to history
-	history = append(history, models.ChatMessage{Role: RoleModel, Message: responseText})
+// finishTurn stores the message and the model's reply, returning the response sent to the client
+func (gs *GenAIServer) finishTurn(ctx context.Context, turn *chatTurn, reply string, finishReason string) (*models.ChatResponse, error) {
+	var userParts []models.MessagePart
+	if models.HasFiles(turn.parts) {
+		userParts = turn.parts
+	}
 
-	// Optionally, send the final history as a JSON event at the end
-	finalResp := models.ChatResponse{
-		Response: responseText,
-		History:  history,
+	conversationID, err := gs.conversations.SaveExchange(ctx, turn.userID, conversations.Exchange{
+		ConversationID: turn.conversationID,
+		Settings:       turn.system,
+		UserMessage:    turn.message,
+		UserParts:      userParts,
+		ModelMessage:   reply,
+		FinishReason:   finishReason,
+		ReplaceLast:    turn.replaceLast,
+	})
+	if err != nil {
+		return nil, err
 	}
-	finalJSON, _ := json.Marshal(finalResp)
-	_, _ = w.Write([]byte("event: history\ndata: " + string(finalJSON) + "\n\n"))
-	flusher.Flush()
+
+	return &models.ChatResponse{
+		ConversationID: conversationID,
+		Model:          turn.model.ID,
+		PersonaID:      turn.system.PersonaID,
+		PersonaVersion: turn.system.PersonaVersion,
+		Response:       reply,
+		History: responseHistory(append(turn.history,
+			models.ChatMessage{Role: RoleUser, Message: turn.message, Parts: userParts},
+			models.ChatMessage{Role: RoleModel, Message: reply},
+		)),
+		Generation:   turn.generation,
+		FinishReason: finishReason,
+	}, nil
 }
 
+// SetupHandler sets the default Gemini API key, used for users who have not
+// stored their own. Only admins may call it.
 func (s *GenAIServer) SetupHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !s.UserKeys {
+		http.Error(w, "The chat-bot runs on another provider than Gemini", http.StatusConflict)
 		return
 	}
+
 	var req models.APIKeyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.APIKey == "" {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	client, err := genai.NewClient(s.Ctx, &genai.ClientConfig{
-		APIKey: req.APIKey,
-	})
-	if err != nil {
+
+	if err := s.SetDefaultAPIKey(req.APIKey); err != nil {
 		http.Error(w, "Failed to initialize Gemini client", http.StatusInternalServerError)
 		return
 	}
 
-	s.mu.Lock()
-	s.client = client
-	s.mu.Unlock()
-
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"status":"ready"}`))
 }
 
-func ToGenaiContent(history []models.ChatMessage) []*genai.Content {
-	history_content := []*genai.Content{}
+// providerFor returns the provider for userID: Gemini with their own API key
+// when they stored one on the server, the default provider otherwise.
+func (gs *GenAIServer) providerFor(ctx context.Context, userID string) (llm.Provider, int, error) {
+	if gs.UserKeys {
+		apiKey, err := gs.users.GeminiAPIKey(ctx, userID)
+		if err != nil {
+			logrus.WithError(err).WithField("user_id", userID).Error("Error loading Gemini API key")
+			return nil, http.StatusInternalServerError, errors.New("Failed to load Gemini API key")
+		}
+
+		if apiKey != "" {
+			provider, err := gs.Clients.Get(ctx, userID, apiKey)
+			if err != nil {
+				return nil, http.StatusInternalServerError, errors.New("Failed to initialize Gemini client")
+			}
+			return provider, http.StatusOK, nil
+		}
+	}
+
+	gs.mu.Lock()
+	provider := gs.defaultProvider
+	gs.mu.Unlock()
+
+	if provider == nil {
+		return nil, http.StatusServiceUnavailable, errors.New("No Gemini API key configured. Add gemini_api_key to your profile.")
+	}
+	return provider, http.StatusOK, nil
+}
+
+// responseHistory returns history without the data of its files, which
+// clients download from the attachments of the conversation
+func responseHistory(history []models.ChatMessage) []models.ChatMessage {
+	stripped := make([]models.ChatMessage, len(history))
+	for i, message := range history {
+		message.Parts = models.WithoutData(message.Parts)
+		stripped[i] = message
+	}
+	return stripped
+}
+
+// toMessages converts a stored history to the messages of a request. System
+// messages are returned apart, to go ahead of the turn's system instruction.
+func toMessages(history []models.ChatMessage) ([]llm.Message, []string, error) {
+	messages := make([]llm.Message, 0, len(history)+1)
+	var system []string
 	for _, msg := range history {
-		var role genai.Role
-		if msg.Role == RoleUser {
-			role = genai.RoleUser
-		} else {
-			role = genai.RoleModel
+		switch msg.Role {
+		case RoleSystem:
+			if text := strings.TrimSpace(msg.Message); text != "" {
+				system = append(system, text)
+			}
+		case RoleUser, RoleModel:
+			messages = append(messages, toMessage(msg.Role, msg.Message, msg.Parts))
+		default:
+			return nil, nil, fmt.Errorf("unknown message role %q", msg.Role)
 		}
+	}
+	return messages, system, nil
+}
+
+// toMessage converts a message of role to a message of a request, its parts
+// when it has files and its text otherwise
+func toMessage(role string, text string, parts []models.MessagePart) llm.Message {
+	if !models.HasFiles(parts) {
+		return llm.NewTextMessage(role, text)
+	}
 
-		content := genai.NewContentFromText(msg.Message, role)
-		history_content = append(history_content, content)
+	message := llm.Message{Role: role}
+	for _, part := range parts {
+		switch {
+		case part.Type == models.PartText:
+			message.Parts = append(message.Parts, llm.Part{Text: part.Text})
+		case part.Data != nil:
+			message.Parts = append(message.Parts, llm.Part{Data: &llm.Blob{MIMEType: part.MIMEType, Data: part.Data}})
+		}
 	}
-	return history_content
+	return message
 }
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/catalog"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+func TestStoredHistoryErrorsAreServerFaults(t *testing.T) {
+	turn := &chatTurn{
+		userID:         "6f1c3b52-0d7e-4f43-9a57-4d1a4c9f1e11",
+		conversationID: "0b6f6a8e-6d55-4c1e-9b7a-2f6f0c3f8d21",
+		message:        "Hello",
+		model:          &catalog.Model{ID: "gemini-2.5-flash"},
+		history:        []models.ChatMessage{{Role: "tool", Message: `{"result":4}`}},
+	}
+
+	_, err := (&GenAIServer{}).startGeneration(context.Background(), turn)
+	if err == nil {
+		t.Fatal("startGeneration() error = nil, want the unknown role")
+	}
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		t.Errorf("startGeneration() error = %v with status %d, want an unexpected error", err, reqErr.status)
+	}
+
+	w := httptest.NewRecorder()
+	writeRequestError(w, err, "Failed to start generation")
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != "Failed to start generation" {
+		t.Errorf("body = %q, want the generic message without the cause", body)
+	}
+}
+
+func TestRequestErrorsKeepTheirStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeRequestError(w, &requestError{status: http.StatusBadRequest, message: "Message is required"}, "Failed to start generation")
+	if w.Code != http.StatusBadRequest || strings.TrimSpace(w.Body.String()) != "Message is required" {
+		t.Errorf("response = %d %q, want 400 Message is required", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,513 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/conversations"
+	"github.com/Mahaveer86619/ImaginAI/internal/images"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/Mahaveer86619/ImaginAI/internal/personas"
+	"github.com/Mahaveer86619/ImaginAI/internal/tools"
+	"github.com/Mahaveer86619/ImaginAI/internal/users"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	testUserID = "6f1c3b52-0d7e-4f43-9a57-4d1a4c9f1e11"
+	testSecret = "test-secret"
+)
+
+// testUsers stands in for users.Store: every user is on the free plan,
+// without a Gemini API key of their own, and their tokens are in state
+type testUsers struct {
+	state users.TokenState
+}
+
+func (u testUsers) TokenState(ctx context.Context, userID string) (*users.TokenState, error) {
+	state := u.state
+	return &state, nil
+}
+
+func (testUsers) ChatSettings(ctx context.Context, userID string) (*users.ChatSettings, error) {
+	return &users.ChatSettings{Plan: "free"}, nil
+}
+
+func (testUsers) GeminiAPIKey(ctx context.Context, userID string) (string, error) {
+	return "", nil
+}
+
+// testConversations keeps conversations in memory, in place of conversations.Store
+type testConversations struct {
+	mu            sync.Mutex
+	conversations map[string]*models.Conversation
+	owners        map[string]string
+}
+
+func newTestConversations() *testConversations {
+	return &testConversations{conversations: map[string]*models.Conversation{}, owners: map[string]string{}}
+}
+
+// add stores a conversation of userID with messages, returning its id
+func (s *testConversations) add(userID string, messages ...models.Message) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := uuid.NewString()
+	s.conversations[id] = &models.Conversation{ID: id, Title: "Test", Messages: messages}
+	s.owners[id] = userID
+	return id
+}
+
+// messages returns the messages of conversation id
+func (s *testConversations) messages(id string) []models.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.conversations[id]; ok {
+		return append([]models.Message(nil), c.Messages...)
+	}
+	return nil
+}
+
+func (s *testConversations) Create(ctx context.Context, userID string, title string) (*models.Conversation, error) {
+	id := s.add(userID)
+	return s.Rename(ctx, userID, id, title)
+}
+
+func (s *testConversations) List(ctx context.Context, userID string, limit int, offset int) ([]*models.Conversation, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []*models.Conversation{}
+	for id, c := range s.conversations {
+		if s.owners[id] == userID {
+			list = append(list, &models.Conversation{ID: c.ID, Title: c.Title})
+		}
+	}
+	total := len(list)
+	list = list[min(offset, total):min(offset+limit, total)]
+	return list, total, nil
+}
+
+func (s *testConversations) Get(ctx context.Context, userID string, conversationID string) (*models.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.conversations[conversationID]
+	if !ok || s.owners[conversationID] != userID {
+		return nil, conversations.ErrNotFound
+	}
+	copied := *c
+	copied.Messages = append([]models.Message(nil), c.Messages...)
+	return &copied, nil
+}
+
+func (s *testConversations) Rename(ctx context.Context, userID string, conversationID string, title string) (*models.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.conversations[conversationID]
+	if !ok || s.owners[conversationID] != userID {
+		return nil, conversations.ErrNotFound
+	}
+	c.Title = title
+	return &models.Conversation{ID: c.ID, Title: c.Title}, nil
+}
+
+func (s *testConversations) Delete(ctx context.Context, userID string, conversationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owners[conversationID] != userID {
+		return conversations.ErrNotFound
+	}
+	delete(s.conversations, conversationID)
+	delete(s.owners, conversationID)
+	return nil
+}
+
+func (s *testConversations) SaveExchange(ctx context.Context, userID string, exchange conversations.Exchange) (string, error) {
+	id := exchange.ConversationID
+	if id == "" {
+		id = s.add(userID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.conversations[id]
+	if !ok || s.owners[id] != userID {
+		return "", conversations.ErrNotFound
+	}
+	if exchange.ReplaceLast {
+		c.Messages = c.Messages[:len(c.Messages)-2]
+	}
+	c.Messages = append(c.Messages,
+		models.Message{ID: uuid.NewString(), Role: RoleUser, Content: exchange.UserMessage, Parts: models.WithoutData(exchange.UserParts)},
+		models.Message{ID: uuid.NewString(), Role: RoleModel, Content: exchange.ModelMessage, FinishReason: exchange.FinishReason},
+	)
+	return id, nil
+}
+
+func (s *testConversations) AttachmentData(ctx context.Context, conversationID string) (map[string][]byte, error) {
+	return map[string][]byte{}, nil
+}
+
+func (s *testConversations) Attachment(ctx context.Context, userID string, conversationID string, attachmentID string) (*conversations.Attachment, error) {
+	return nil, conversations.ErrAttachmentNotFound
+}
+
+func (s *testConversations) Search(ctx context.Context, userID string, query string, limit int) ([]conversations.Match, error) {
+	return nil, nil
+}
+
+// testPersona is a persona of testPersonas, owned by no one when global
+type testPersona struct {
+	owner       string
+	name        string
+	description string
+	// versions holds the instructions, version n at n-1
+	versions []models.PersonaVersion
+}
+
+// testPersonas keeps personas in memory, in place of personas.Store
+type testPersonas struct {
+	mu       sync.Mutex
+	personas map[string]*testPersona
+}
+
+func newTestPersonas() *testPersonas {
+	return &testPersonas{personas: map[string]*testPersona{}}
+}
+
+// persona returns persona id at version, the current one when version is 0
+func (s *testPersonas) persona(id string, version int) *models.Persona {
+	p := s.personas[id]
+	if version == 0 {
+		version = len(p.versions)
+	}
+	if version > len(p.versions) {
+		return nil
+	}
+	return &models.Persona{
+		ID: id, Name: p.name, Description: p.description, Global: p.owner == "",
+		Version: version, Instruction: p.versions[version-1].Instruction,
+	}
+}
+
+// nameTaken reports whether owner has another persona named name
+func (s *testPersonas) nameTaken(owner string, name string, except string) bool {
+	for id, p := range s.personas {
+		if id != except && p.owner == owner && strings.EqualFold(p.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// writable checks that userID may change persona id, as personas.Store does
+func (s *testPersonas) writable(userID string, isAdmin bool, id string) (*testPersona, error) {
+	p, ok := s.personas[id]
+	switch {
+	case !ok:
+		return nil, personas.ErrNotFound
+	case p.owner == "" && !isAdmin:
+		return nil, personas.ErrForbidden
+	case p.owner != "" && p.owner != userID:
+		return nil, personas.ErrNotFound
+	}
+	return p, nil
+}
+
+func (s *testPersonas) Create(ctx context.Context, userID string, req *models.PersonaRequest) (*models.Persona, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner := userID
+	if req.Global {
+		owner = ""
+	}
+	if s.nameTaken(owner, req.Name, "") {
+		return nil, personas.ErrNameTaken
+	}
+
+	id := uuid.NewString()
+	s.personas[id] = &testPersona{
+		owner: owner, name: req.Name, description: req.Description,
+		versions: []models.PersonaVersion{{Version: 1, Instruction: req.Instruction}},
+	}
+	return s.persona(id, 0), nil
+}
+
+func (s *testPersonas) List(ctx context.Context, userID string) ([]*models.Persona, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []*models.Persona{}
+	for id, p := range s.personas {
+		if p.owner == "" || p.owner == userID {
+			list = append(list, s.persona(id, 0))
+		}
+	}
+	slices.SortFunc(list, func(a, b *models.Persona) int {
+		if a.Global != b.Global {
+			if a.Global {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return list, nil
+}
+
+func (s *testPersonas) Get(ctx context.Context, userID string, personaID string, version int) (*models.Persona, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.personas[personaID]
+	if !ok || (p.owner != "" && p.owner != userID) {
+		return nil, personas.ErrNotFound
+	}
+	if persona := s.persona(personaID, version); persona != nil {
+		return persona, nil
+	}
+	return nil, personas.ErrNotFound
+}
+
+func (s *testPersonas) Versions(ctx context.Context, userID string, personaID string) ([]models.PersonaVersion, error) {
+	if _, err := s.Get(ctx, userID, personaID, 0); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := slices.Clone(s.personas[personaID].versions)
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+func (s *testPersonas) Update(ctx context.Context, userID string, isAdmin bool, personaID string, req *models.PersonaRequest) (*models.Persona, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.writable(userID, isAdmin, personaID)
+	if err != nil {
+		return nil, err
+	}
+	if s.nameTaken(p.owner, req.Name, personaID) {
+		return nil, personas.ErrNameTaken
+	}
+
+	p.name, p.description = req.Name, req.Description
+	if current := p.versions[len(p.versions)-1]; current.Instruction != req.Instruction {
+		p.versions = append(p.versions, models.PersonaVersion{Version: current.Version + 1, Instruction: req.Instruction})
+	}
+	return s.persona(personaID, 0), nil
+}
+
+func (s *testPersonas) Delete(ctx context.Context, userID string, isAdmin bool, personaID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.writable(userID, isAdmin, personaID); err != nil {
+		return err
+	}
+	delete(s.personas, personaID)
+	return nil
+}
+
+// testImage is an image of testImages with its owner and file
+type testImage struct {
+	owner string
+	key   string
+	image models.Image
+}
+
+// testImages keeps the metadata of images in memory, in place of images.Store
+type testImages struct {
+	mu sync.Mutex
+	// images are in the order they were created
+	images []testImage
+	// failCreate fails the Create call at this position, counting from 1
+	failCreate int
+	creates    int
+}
+
+// count returns the number of images of userID
+func (s *testImages) count(userID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, image := range s.images {
+		if image.owner == userID {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *testImages) Create(ctx context.Context, userID string, image *models.Image, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.creates++
+	if s.creates == s.failCreate {
+		return errors.New("database is down")
+	}
+	image.CreatedAt = time.Now().UTC()
+	image.URL = images.ContentURL(image.ID)
+	s.images = append(s.images, testImage{owner: userID, key: key, image: *image})
+	return nil
+}
+
+func (s *testImages) List(ctx context.Context, userID string, limit int, offset int) ([]*models.Image, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var owned []*models.Image
+	for i := len(s.images) - 1; i >= 0; i-- {
+		if s.images[i].owner == userID {
+			image := s.images[i].image
+			owned = append(owned, &image)
+		}
+	}
+	page := []*models.Image{}
+	if offset < len(owned) {
+		page = append(page, owned[offset:min(offset+limit, len(owned))]...)
+	}
+	return page, len(owned), nil
+}
+
+func (s *testImages) Get(ctx context.Context, userID string, imageID string) (*models.Image, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, image := range s.images {
+		if image.image.ID == imageID && image.owner == userID {
+			found := image.image
+			return &found, image.key, nil
+		}
+	}
+	return nil, "", images.ErrNotFound
+}
+
+func (s *testImages) Delete(ctx context.Context, userID string, imageID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, image := range s.images {
+		if image.image.ID == imageID && image.owner == userID {
+			s.images = slices.Delete(s.images, i, i+1)
+			return image.key, nil
+		}
+	}
+	return "", images.ErrNotFound
+}
+
+// newTestServer returns a chat-bot replying with provider, whose users and
+// conversations are kept in memory
+func newTestServer(t *testing.T, provider llm.Provider) (*GenAIServer, http.Handler, *testConversations) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", testSecret)
+
+	store := newTestConversations()
+	gs := New(context.Background(), nil)
+	gs.UserKeys = false
+	gs.users = testUsers{}
+	gs.conversations = store
+	gs.personas = newTestPersonas()
+	gs.images = &testImages{}
+	gs.ImageStorage = &images.Storage{Dir: t.TempDir()}
+	gs.auth = auth.NewAuthenticator(testUsers{})
+	gs.Tools = tools.Builtin(store)
+	gs.SetDefaultProvider(provider)
+
+	return gs, gs.SetupRoutes(), store
+}
+
+// testToken returns an access token of testUserID
+func testToken(t *testing.T) string {
+	t.Helper()
+
+	return signToken(t, auth.Claims{
+		UserID:    testUserID,
+		Role:      auth.RoleUser,
+		TokenType: auth.TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+}
+
+// signToken signs claims with the secret of the test server
+func signToken(t *testing.T, claims auth.Claims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+// send sends an authenticated request of testUserID to handler
+func send(t *testing.T, handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testToken(t))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// sseEvent is an event of a text/event-stream response
+type sseEvent struct {
+	ID   string
+	Name string
+	Data string
+}
+
+// parseEvents splits a text/event-stream body in its events, leaving out comments
+func parseEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+
+	var events []sseEvent
+	for _, block := range strings.Split(body, "\n\n") {
+		var event sseEvent
+		var data []string
+		for _, line := range strings.Split(block, "\n") {
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				event.ID = value
+			case "event":
+				event.Name = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+		if data != nil {
+			event.Data = strings.Join(data, "\n")
+			events = append(events, event)
+		}
+	}
+	return events
+}
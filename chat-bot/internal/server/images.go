@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/images"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// GenerateImagesHandler generates images from a prompt with the image model,
+// and adds them to the caller's gallery
+func (gs *GenAIServer) GenerateImagesHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	log := logrus.WithField("user_id", claims.UserID)
+
+	r.Body = http.MaxBytesReader(w, r.Body, gs.Uploads.MaxRequestSize)
+
+	var req models.ImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			http.Error(w, "Requests must be at most "+strconv.FormatInt(maxBytes.Limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := images.Validate(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	provider, status, err := gs.providerFor(r.Context(), claims.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	generator, ok := provider.(llm.ImageGenerator)
+	if !ok {
+		http.Error(w, "The chat-bot's provider does not generate images", http.StatusNotImplemented)
+		return
+	}
+
+	// The model stops when the client goes away or the images take too long
+	ctx, cancel := gs.Generations.WithTimeout(r.Context())
+	defer cancel()
+
+	res, err := generator.GenerateImages(ctx, &llm.ImageRequest{
+		Model:          gs.ImageModel,
+		Prompt:         images.Prompt(&req),
+		NegativePrompt: req.NegativePrompt,
+		AspectRatio:    req.AspectRatio,
+		Count:          int32(req.Count),
+	})
+	if err != nil {
+		switch {
+		case r.Context().Err() != nil:
+			log.Info("Client went away before the images")
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			http.Error(w, "The model did not generate the images in time", http.StatusGatewayTimeout)
+		default:
+			log.WithError(err).Error("Error generating images")
+			http.Error(w, "Failed to generate images", http.StatusBadGateway)
+		}
+		return
+	}
+	if len(res.Images) == 0 {
+		message := "The model did not generate any image"
+		if len(res.Filtered) > 0 {
+			message = "The images were blocked by the model: " + strings.Join(res.Filtered, "; ")
+		}
+		http.Error(w, message, http.StatusBadGateway)
+		return
+	}
+
+	saved, err := gs.saveImages(r.Context(), claims.UserID, &req, res.Images)
+	if err != nil {
+		log.WithError(err).Error("Error saving images")
+		http.Error(w, "Failed to save images", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.ImageGenerateResponse{
+		Images:   saved,
+		Filtered: max(req.Count-len(res.Images), 0),
+	})
+}
+
+// saveImages stores the files and metadata of generated images. The files of
+// a request are all saved or, on failure, all removed.
+func (gs *GenAIServer) saveImages(ctx context.Context, userID string, req *models.ImageRequest, generated []llm.Blob) ([]*models.Image, error) {
+	var saved []*models.Image
+	var keys []string
+	cleanup := func() {
+		for i, key := range keys {
+			if i < len(saved) {
+				gs.images.Delete(context.WithoutCancel(ctx), userID, saved[i].ID)
+			}
+			if err := gs.ImageStorage.Delete(key); err != nil {
+				logrus.WithError(err).WithField("key", key).Warn("Error removing image file")
+			}
+		}
+	}
+
+	for _, blob := range generated {
+		image := &models.Image{
+			ID:             uuid.NewString(),
+			Prompt:         req.Prompt,
+			NegativePrompt: req.NegativePrompt,
+			AspectRatio:    req.AspectRatio,
+			Style:          req.Style,
+			Model:          gs.ImageModel,
+			MIMEType:       blob.MIMEType,
+			Size:           len(blob.Data),
+		}
+
+		key, err := gs.ImageStorage.Save(userID, image.ID, image.MIMEType, blob.Data)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		keys = append(keys, key)
+
+		if err := gs.images.Create(ctx, userID, image, key); err != nil {
+			cleanup()
+			return nil, err
+		}
+		saved = append(saved, image)
+	}
+	return saved, nil
+}
+
+// ListImagesHandler lists the caller's gallery, newest images first
+func (gs *GenAIServer) ListImagesHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, total, err := gs.images.List(r.Context(), claims.UserID, limit, offset)
+	if err != nil {
+		logrus.WithError(err).Error("Error listing images")
+		http.Error(w, "Failed to list images", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ImageListResponse{
+		Images: list,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// GetImageHandler returns the metadata of an image of the caller
+func (gs *GenAIServer) GetImageHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := imageID(w, r)
+	if !ok {
+		return
+	}
+
+	image, _, err := gs.images.Get(r.Context(), claims.UserID, id)
+	if err != nil {
+		writeImageError(w, err, "Failed to load image")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, image)
+}
+
+// GetImageContentHandler downloads the file of an image of the caller
+func (gs *GenAIServer) GetImageContentHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := imageID(w, r)
+	if !ok {
+		return
+	}
+
+	image, key, err := gs.images.Get(r.Context(), claims.UserID, id)
+	if err != nil {
+		writeImageError(w, err, "Failed to load image")
+		return
+	}
+
+	file, err := gs.ImageStorage.Open(key)
+	if err != nil {
+		logrus.WithError(err).WithField("image_id", id).Error("Error opening image file")
+		http.Error(w, "Failed to load image", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", image.MIMEType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Images never change, their id names a single file
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", image.CreatedAt, file)
+}
+
+// DeleteImageHandler removes an image of the caller from the gallery and its file
+func (gs *GenAIServer) DeleteImageHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := imageID(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := gs.images.Delete(r.Context(), claims.UserID, id)
+	if err != nil {
+		writeImageError(w, err, "Failed to delete image")
+		return
+	}
+	// The image is gone from the gallery even when its file lingers
+	if err := gs.ImageStorage.Delete(key); err != nil {
+		logrus.WithError(err).WithField("image_id", id).Warn("Error removing image file")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// imageID returns the {id} path value, answering 400 when it is not a UUID
+func imageID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if err := uuid.Validate(id); err != nil {
+		http.Error(w, "Invalid image id", http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
+
+// writeImageError answers 404 for images that do not exist or belong to
+// another user, and logs any other error
+func writeImageError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, images.ErrNotFound) {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	logrus.WithError(err).Error(message)
+	http.Error(w, message, http.StatusInternalServerError)
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// generateImages generates count images of prompt with claims, failing the
+// test unless they are created
+func generateImages(t *testing.T, handler http.Handler, claims auth.Claims, prompt string, count int) []*models.Image {
+	t.Helper()
+
+	body, _ := json.Marshal(models.ImageRequest{Prompt: prompt, Count: count})
+	w := sendAs(t, handler, claims, http.MethodPost, "/images/generate", string(body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("generate: status = %d, want 201: %s", w.Code, w.Body)
+	}
+	var resp models.ImageGenerateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding images: %v", err)
+	}
+	return resp.Images
+}
+
+// storedFiles returns the files under dir, relative to it
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, rel)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("listing stored files: %v", err)
+	}
+	return files
+}
+
+func TestGenerateImages(t *testing.T) {
+	gs, handler, _ := newTestServer(t, fake.New())
+	gs.ImageModel = "imagen-test"
+
+	w := send(t, handler, http.MethodPost, "/images/generate", `{"prompt":"  A red fox  ","count":2,"aspect_ratio":"16:9","style":"watercolor"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+	var resp models.ImageGenerateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding images: %v", err)
+	}
+	if len(resp.Images) != 2 || resp.Filtered != 0 {
+		t.Fatalf("response = %+v, want 2 images", resp)
+	}
+
+	for _, image := range resp.Images {
+		if image.Prompt != "A red fox" || image.AspectRatio != "16:9" || image.Style != "watercolor" || image.Model != "imagen-test" {
+			t.Errorf("image = %+v, want the trimmed request generated with imagen-test", image)
+		}
+		if image.MIMEType != "image/png" || image.URL != "/images/"+image.ID+"/content" || image.CreatedAt.IsZero() {
+			t.Errorf("image = %+v, want a saved PNG", image)
+		}
+
+		// The file is stored in the directory of the user
+		data, err := os.ReadFile(filepath.Join(gs.ImageStorage.Dir, testUserID, image.ID+".png"))
+		if err != nil || len(data) != image.Size {
+			t.Errorf("stored file of %s = %d bytes, %v, want %d bytes", image.ID, len(data), err, image.Size)
+		}
+
+		w := send(t, handler, http.MethodGet, image.URL, "")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || w.Body.Len() != image.Size {
+			t.Errorf("GET %s = %d %s of %d bytes, want the PNG", image.URL, w.Code, w.Header().Get("Content-Type"), w.Body.Len())
+		}
+	}
+}
+
+func TestGenerateImagesRejectsBadRequests(t *testing.T) {
+	gs, _, _ := newTestServer(t, fake.New())
+	gs.Uploads.MaxRequestSize = 256
+	handler := gs.SetupRoutes()
+
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		message string
+	}{
+		{name: "invalid body", body: `{"prompt":`, status: http.StatusBadRequest, message: "Invalid request body"},
+		{name: "missing prompt", body: `{"prompt":"  "}`, status: http.StatusBadRequest, message: "prompt is required"},
+		{name: "too many images", body: `{"prompt":"A fox","count":5}`, status: http.StatusBadRequest, message: "count must be between 1 and 4"},
+		{name: "unknown style", body: `{"prompt":"A fox","style":"mosaic"}`, status: http.StatusBadRequest},
+		{
+			name:    "body too large",
+			body:    `{"prompt":"` + strings.Repeat("fox ", 100) + `"}`,
+			status:  http.StatusRequestEntityTooLarge,
+			message: "Requests must be at most 256 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(t, handler, http.MethodPost, "/images/generate", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.message != "" && strings.TrimSpace(w.Body.String()) != tt.message {
+				t.Errorf("body = %q, want %q", w.Body, tt.message)
+			}
+			if files := storedFiles(t, gs.ImageStorage.Dir); len(files) != 0 {
+				t.Errorf("stored files %v for a refused request", files)
+			}
+		})
+	}
+}
+
+// A request whose images cannot all be recorded leaves no image behind
+func TestSaveImagesRollsBack(t *testing.T) {
+	gs, handler, _ := newTestServer(t, fake.New())
+	store := &testImages{failCreate: 2}
+	gs.images = store
+
+	w := send(t, handler, http.MethodPost, "/images/generate", `{"prompt":"A red fox","count":3}`)
+	if w.Code != http.StatusInternalServerError || strings.TrimSpace(w.Body.String()) != "Failed to save images" {
+		t.Fatalf("response = %d %q, want 500 Failed to save images", w.Code, w.Body)
+	}
+	if n := store.count(testUserID); n != 0 {
+		t.Errorf("gallery holds %d images, want the first one removed", n)
+	}
+	if files := storedFiles(t, gs.ImageStorage.Dir); len(files) != 0 {
+		t.Errorf("stored files = %v, want them all removed", files)
+	}
+}
+
+func TestListImagesPagination(t *testing.T) {
+	_, handler, _ := newTestServer(t, fake.New())
+	user := claimsOf(func(c *auth.Claims) {})
+	generated := generateImages(t, handler, user, "A red fox", 3)
+	generateImages(t, handler, claimsOf(func(c *auth.Claims) { c.UserID = otherUserID }), "A grey wolf", 2)
+
+	tests := []struct {
+		query string
+		// want are the indexes of the generated images listed, newest first
+		want   []int
+		limit  int
+		offset int
+	}{
+		{query: "", want: []int{2, 1, 0}, limit: 20},
+		{query: "?limit=2", want: []int{2, 1}, limit: 2},
+		{query: "?limit=2&offset=2", want: []int{0}, limit: 2, offset: 2},
+		{query: "?offset=5", want: []int{}, limit: 20, offset: 5},
+	}
+	for _, tt := range tests {
+		w := send(t, handler, http.MethodGet, "/images"+tt.query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /images%s: status = %d, want 200: %s", tt.query, w.Code, w.Body)
+		}
+		var list models.ImageListResponse
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("decoding images: %v", err)
+		}
+
+		if list.Total != 3 || list.Limit != tt.limit || list.Offset != tt.offset || len(list.Images) != len(tt.want) {
+			t.Errorf("GET /images%s = %d of %d at %d/%d, want %d of 3 at %d/%d", tt.query,
+				len(list.Images), list.Total, list.Limit, list.Offset, len(tt.want), tt.limit, tt.offset)
+			continue
+		}
+		for i, index := range tt.want {
+			if list.Images[i].ID != generated[index].ID {
+				t.Errorf("GET /images%s: image %d = %s, want %s", tt.query, i, list.Images[i].ID, generated[index].ID)
+			}
+		}
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=101", "?offset=-1", "?limit=ten"} {
+		if w := send(t, handler, http.MethodGet, "/images"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /images%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestImagesOfAnotherUser(t *testing.T) {
+	gs, handler, _ := newTestServer(t, fake.New())
+	image := generateImages(t, handler, claimsOf(func(c *auth.Claims) { c.UserID = otherUserID }), "A grey wolf", 1)[0]
+
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/images/" + image.ID},
+		{http.MethodGet, "/images/" + image.ID + "/content"},
+		{http.MethodDelete, "/images/" + image.ID},
+	} {
+		w := send(t, handler, request.method, request.path, "")
+		if w.Code != http.StatusNotFound || strings.TrimSpace(w.Body.String()) != "Image not found" {
+			t.Errorf("%s %s = %d %q, want 404 Image not found", request.method, request.path, w.Code, w.Body)
+		}
+	}
+	if files := storedFiles(t, gs.ImageStorage.Dir); len(files) != 1 {
+		t.Errorf("stored files = %v, want the other user's image kept", files)
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	gs, handler, _ := newTestServer(t, fake.New())
+	user := claimsOf(func(c *auth.Claims) {})
+	generated := generateImages(t, handler, user, "A red fox", 2)
+
+	if w := send(t, handler, http.MethodDelete, "/images/"+generated[0].ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d, want 204: %s", w.Code, w.Body)
+	}
+	if w := send(t, handler, http.MethodGet, "/images/"+generated[0].ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("get of a deleted image: status = %d, want 404", w.Code)
+	}
+
+	want := filepath.Join(testUserID, generated[1].ID+".png")
+	if files := storedFiles(t, gs.ImageStorage.Dir); len(files) != 1 || files[0] != want {
+		t.Errorf("stored files = %v, want only %s", files, want)
+	}
+
+	if w := send(t, handler, http.MethodDelete, "/images/not-an-id", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("delete of an invalid id: status = %d, want 400", w.Code)
+	}
+}
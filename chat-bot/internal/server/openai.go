@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/catalog"
+	"github.com/Mahaveer86619/ImaginAI/internal/generation"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/openai"
+	"github.com/Mahaveer86619/ImaginAI/internal/uploads"
+	"github.com/sirupsen/logrus"
+)
+
+// ChatCompletionsHandler implements POST /v1/chat/completions of the OpenAI
+// API on top of the chat-bot's provider. Completions are stateless: the messages carry the
+// whole conversation and nothing is stored.
+func (gs *GenAIServer) ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, gs.Uploads.MaxRequestSize)
+
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			writeOpenAIError(w, http.StatusRequestEntityTooLarge, "Requests must be at most "+strconv.FormatInt(maxBytes.Limit, 10)+" bytes", "invalid_request_error", "")
+			return
+		}
+		writeOpenAIError(w, http.StatusBadRequest, "Invalid request body: "+err.Error(), "invalid_request_error", "")
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	settings, err := gs.users.ChatSettings(r.Context(), claims.UserID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", claims.UserID).Error("Error loading chat settings")
+		writeOpenAIError(w, http.StatusInternalServerError, "Failed to load chat settings", "server_error", "")
+		return
+	}
+
+	model, err := gs.Catalog.Resolve(req.Model, settings.Plan, settings.DefaultModel)
+	switch {
+	case errors.Is(err, catalog.ErrUnknownModel):
+		writeOpenAIError(w, http.StatusNotFound, "The model "+strconv.Quote(req.Model)+" does not exist, see GET /v1/models", "invalid_request_error", "model_not_found")
+		return
+	case errors.Is(err, catalog.ErrModelNotOnPlan):
+		writeOpenAIError(w, http.StatusForbidden, "The model "+strconv.Quote(req.Model)+" is not available on your plan", "permission_error", "model_not_available")
+		return
+	}
+
+	overrides := req.Generation()
+	if err := generation.Validate(overrides); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "")
+		return
+	}
+	merged := generation.Merge(gs.GenerationDefaults, overrides)
+	if merged.MaxOutputTokens != nil && model.OutputTokenLimit > 0 && *merged.MaxOutputTokens > model.OutputTokenLimit {
+		writeOpenAIError(w, http.StatusBadRequest, "max_tokens must be at most "+strconv.Itoa(int(model.OutputTokenLimit))+" for "+model.ID, "invalid_request_error", "")
+		return
+	}
+
+	messages, system, err := openai.ToLLMMessages(req.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "")
+		return
+	}
+	if status, err := gs.checkFiles(model, messages); err != nil {
+		writeOpenAIError(w, status, err.Error(), "invalid_request_error", "")
+		return
+	}
+	tools, toolChoice, err := openai.ToLLMTools(&req)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "")
+		return
+	}
+
+	request := &llm.Request{
+		Model:      model.ID,
+		System:     system,
+		Messages:   messages,
+		Config:     merged,
+		Tools:      tools,
+		ToolChoice: toolChoice,
+	}
+
+	provider, status, err := gs.providerFor(r.Context(), claims.UserID)
+	if err != nil {
+		writeOpenAIError(w, status, err.Error(), "server_error", "")
+		return
+	}
+
+	// The model stops writing when the client goes away or the completion takes too long
+	ctx, cancel := gs.Generations.WithTimeout(r.Context())
+	defer cancel()
+
+	completion := openai.ChatCompletion{
+		ID:      openai.NewID(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model.ID,
+	}
+
+	if req.Stream {
+		gs.streamCompletion(ctx, w, r, provider, &completion, request, req.StreamOptions)
+		return
+	}
+
+	res, err := provider.Chat(ctx, request)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		logrus.WithError(err).WithField("user_id", claims.UserID).Error("Error generating completion")
+		writeOpenAIError(w, http.StatusBadGateway, "Failed to receive a completion from the model", "server_error", "")
+		return
+	}
+
+	completion.Choices = []openai.Choice{}
+	for _, candidate := range res.Candidates {
+		text, calls := openai.FromLLMCandidate(candidate)
+		choice := openai.Choice{
+			Index:        candidate.Index,
+			Message:      openai.Message{Role: openai.RoleAssistant, ToolCalls: calls},
+			FinishReason: openai.FinishReason(candidate.FinishReason, len(calls) > 0),
+		}
+		if text != "" || len(calls) == 0 {
+			choice.Message.Content = &openai.Content{Text: text}
+		}
+		completion.Choices = append(completion.Choices, choice)
+	}
+	if len(completion.Choices) == 0 {
+		writeOpenAIError(w, http.StatusBadRequest, emptyReplyMessage(res.BlockReason, ""), "invalid_request_error", "content_filter")
+		return
+	}
+	completion.Usage = openai.FromLLMUsage(res.Usage)
+
+	writeJSON(w, http.StatusOK, completion)
+}
+
+// streamCompletion streams the completion as chat.completion.chunk events,
+// ended by [DONE]
+func (gs *GenAIServer) streamCompletion(ctx context.Context, w http.ResponseWriter, r *http.Request, provider llm.Provider, completion *openai.ChatCompletion, request *llm.Request, options *openai.StreamOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "Streaming unsupported!", "server_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	newChunk := func(choices ...openai.ChunkChoice) openai.ChatCompletionChunk {
+		return openai.ChatCompletionChunk{
+			ID:      completion.ID,
+			Object:  "chat.completion.chunk",
+			Created: completion.Created,
+			Model:   completion.Model,
+			Choices: choices,
+		}
+	}
+
+	// started and toolCalls are tracked per choice: the first delta of a
+	// choice carries the role, and tool calls are numbered within their choice
+	started := map[int]bool{}
+	toolCalls := map[int]int{}
+	var usage *openai.Usage
+
+	for res, err := range provider.Stream(ctx, request) {
+		if err != nil {
+			if r.Context().Err() == nil {
+				logrus.WithError(err).Error("Error streaming completion")
+				_ = writeData(w, openai.NewError("Failed to receive a completion from the model", "server_error", ""))
+				flusher.Flush()
+			}
+			return
+		}
+
+		var choices []openai.ChunkChoice
+		for _, candidate := range res.Candidates {
+			index := candidate.Index
+
+			text, calls := openai.FromLLMCandidate(candidate)
+			choice := openai.ChunkChoice{Index: index, Delta: openai.Delta{Content: text}}
+			if !started[index] {
+				started[index] = true
+				choice.Delta.Role = openai.RoleAssistant
+			}
+			for _, call := range calls {
+				position := toolCalls[index]
+				toolCalls[index]++
+				call.Index = &position
+				choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, call)
+			}
+			if candidate.FinishReason != "" {
+				reason := openai.FinishReason(candidate.FinishReason, toolCalls[index] > 0)
+				choice.FinishReason = &reason
+			}
+			choices = append(choices, choice)
+		}
+		if res.Usage != nil {
+			usage = openai.FromLLMUsage(res.Usage)
+		}
+		if len(choices) == 0 {
+			continue
+		}
+
+		if err := writeData(w, newChunk(choices...)); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+
+	if options != nil && options.IncludeUsage && usage != nil {
+		chunk := newChunk()
+		chunk.Choices = []openai.ChunkChoice{}
+		chunk.Usage = usage
+		if err := writeData(w, chunk); err != nil {
+			return
+		}
+	}
+	_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// ListOpenAIModelsHandler implements GET /v1/models of the OpenAI API,
+// listing the models available on the caller's plan
+func (gs *GenAIServer) ListOpenAIModelsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	settings, err := gs.users.ChatSettings(r.Context(), claims.UserID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", claims.UserID).Error("Error loading chat settings")
+		writeOpenAIError(w, http.StatusInternalServerError, "Failed to load chat settings", "server_error", "")
+		return
+	}
+
+	list := openai.ModelList{Object: "list", Data: []openai.Model{}}
+	for _, m := range gs.Catalog.Enabled() {
+		if m.AvailableOn(settings.Plan) {
+			list.Data = append(list.Data, openai.Model{ID: m.ID, Object: "model", OwnedBy: "google"})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// writeData writes v as the data of an unnamed server-sent event
+func writeData(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, message string, errorType string, code string) {
+	writeJSON(w, status, openai.NewError(message, errorType, code))
+}
+
+// checkFiles checks the files of messages against the upload limits and the
+// capabilities of model, returning the status of the first file refused
+func (gs *GenAIServer) checkFiles(model *catalog.Model, messages []llm.Message) (int, error) {
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			if part.Data == nil {
+				continue
+			}
+			mimeType := uploads.MediaType(part.Data.MIMEType)
+			switch {
+			case !gs.Uploads.Allows(mimeType):
+				return http.StatusUnsupportedMediaType, fmt.Errorf("files of type %s are not supported", part.Data.MIMEType)
+			case int64(len(part.Data.Data)) > gs.Uploads.MaxFileSize:
+				return http.StatusRequestEntityTooLarge, fmt.Errorf("files must be at most %d bytes", gs.Uploads.MaxFileSize)
+			case !model.Supports(uploads.Capability(mimeType)):
+				return http.StatusBadRequest, fmt.Errorf("the model %s cannot read %s files", model.ID, mimeType)
+			}
+		}
+	}
+	return http.StatusOK, nil
+}
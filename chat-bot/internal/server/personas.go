@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/Mahaveer86619/ImaginAI/internal/personas"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func (gs *GenAIServer) CreatePersonaHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	req, ok := decodePersonaRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Global && claims.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins may create global personas", http.StatusForbidden)
+		return
+	}
+
+	persona, err := gs.personas.Create(r.Context(), claims.UserID, req)
+	if err != nil {
+		writePersonaError(w, err, "Failed to create persona")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, persona)
+}
+
+func (gs *GenAIServer) ListPersonasHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	list, err := gs.personas.List(r.Context(), claims.UserID)
+	if err != nil {
+		writePersonaError(w, err, "Failed to list personas")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (gs *GenAIServer) GetPersonaHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := personaID(w, r)
+	if !ok {
+		return
+	}
+
+	version := 0
+	if value := r.URL.Query().Get("version"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "version must be a positive number", http.StatusBadRequest)
+			return
+		}
+		version = n
+	}
+
+	persona, err := gs.personas.Get(r.Context(), claims.UserID, id, version)
+	if err != nil {
+		writePersonaError(w, err, "Failed to load persona")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, persona)
+}
+
+func (gs *GenAIServer) ListPersonaVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := personaID(w, r)
+	if !ok {
+		return
+	}
+
+	versions, err := gs.personas.Versions(r.Context(), claims.UserID, id)
+	if err != nil {
+		writePersonaError(w, err, "Failed to list persona versions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, versions)
+}
+
+func (gs *GenAIServer) UpdatePersonaHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := personaID(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := decodePersonaRequest(w, r)
+	if !ok {
+		return
+	}
+
+	persona, err := gs.personas.Update(r.Context(), claims.UserID, claims.Role == auth.RoleAdmin, id, req)
+	if err != nil {
+		writePersonaError(w, err, "Failed to update persona")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, persona)
+}
+
+func (gs *GenAIServer) DeletePersonaHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id, ok := personaID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := gs.personas.Delete(r.Context(), claims.UserID, claims.Role == auth.RoleAdmin, id); err != nil {
+		writePersonaError(w, err, "Failed to delete persona")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// personaID returns the {id} path value, answering 400 when it is not a UUID
+func personaID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if err := uuid.Validate(id); err != nil {
+		http.Error(w, "Invalid persona id", http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
+
+func decodePersonaRequest(w http.ResponseWriter, r *http.Request) (*models.PersonaRequest, bool) {
+	var req models.PersonaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	req.Instruction = strings.TrimSpace(req.Instruction)
+
+	var problems []string
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > personas.MaxNameLength {
+		problems = append(problems, "name must be between 1 and "+strconv.Itoa(personas.MaxNameLength)+" characters")
+	}
+	if utf8.RuneCountInString(req.Description) > personas.MaxDescriptionLength {
+		problems = append(problems, "description must be at most "+strconv.Itoa(personas.MaxDescriptionLength)+" characters")
+	}
+	if req.Instruction == "" || utf8.RuneCountInString(req.Instruction) > personas.MaxInstructionLength {
+		problems = append(problems, "instruction must be between 1 and "+strconv.Itoa(personas.MaxInstructionLength)+" characters")
+	}
+	if len(problems) > 0 {
+		http.Error(w, strings.Join(problems, "; "), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func writePersonaError(w http.ResponseWriter, err error, message string) {
+	writeRequestError(w, personaRequestError(err, message), message)
+}
+
+// personaRequestError converts the errors of the persona store, logging
+// unexpected errors
+func personaRequestError(err error, message string) error {
+	switch {
+	case errors.Is(err, personas.ErrNotFound):
+		return &requestError{status: http.StatusNotFound, message: "Persona not found"}
+	case errors.Is(err, personas.ErrForbidden):
+		return &requestError{status: http.StatusForbidden, message: "Only admins may change global personas"}
+	case errors.Is(err, personas.ErrNameTaken):
+		return &requestError{status: http.StatusConflict, message: "A persona with this name already exists"}
+	}
+	logrus.WithError(err).Error(message)
+	return &requestError{status: http.StatusInternalServerError, message: message}
+}
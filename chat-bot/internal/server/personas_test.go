@@ -0,0 +1,270 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+const otherUserID = "9a1b2c3d-4e5f-4a6b-8c7d-0e1f2a3b4c5d"
+
+// sendAs sends a request to handler with an access token of claims
+func sendAs(t *testing.T, handler http.Handler, claims auth.Claims, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	claims.TokenVersion = 0
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+signToken(t, claims))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// decodePersona decodes the persona answered in w
+func decodePersona(t *testing.T, w *httptest.ResponseRecorder) *models.Persona {
+	t.Helper()
+
+	var persona models.Persona
+	if err := json.NewDecoder(w.Body).Decode(&persona); err != nil {
+		t.Fatalf("decoding persona: %v", err)
+	}
+	return &persona
+}
+
+func TestPersonaCRUD(t *testing.T) {
+	_, handler, _ := newTestServer(t, fake.New())
+	admin := claimsOf(func(c *auth.Claims) { c.Role = auth.RoleAdmin })
+	other := claimsOf(func(c *auth.Claims) { c.UserID = otherUserID })
+
+	w := send(t, handler, http.MethodPost, "/personas", `{"name":"Pirate","description":"Talks like a pirate","instruction":"Answer like a pirate."}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want 201: %s", w.Code, w.Body)
+	}
+	pirate := decodePersona(t, w)
+	if pirate.Version != 1 || pirate.Global || pirate.Instruction != "Answer like a pirate." {
+		t.Errorf("created persona = %+v, want version 1 of a persona of the user", pirate)
+	}
+
+	w = sendAs(t, handler, admin, http.MethodPost, "/personas", `{"name":"Tutor","instruction":"Explain step by step.","global":true}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create global: status = %d, want 201: %s", w.Code, w.Body)
+	}
+	tutor := decodePersona(t, w)
+
+	tests := []struct {
+		name   string
+		claims auth.Claims
+		method string
+		path   string
+		body   string
+		status int
+		// message is the error answered, if any
+		message string
+	}{
+		{
+			name: "global persona created by a user", claims: claimsOf(func(c *auth.Claims) {}),
+			method: http.MethodPost, path: "/personas", body: `{"name":"Global","instruction":"Hi","global":true}`,
+			status: http.StatusForbidden, message: "Only admins may create global personas",
+		},
+		{
+			name: "duplicate name", claims: claimsOf(func(c *auth.Claims) {}),
+			method: http.MethodPost, path: "/personas", body: `{"name":"pirate","instruction":"Arr."}`,
+			status: http.StatusConflict, message: "A persona with this name already exists",
+		},
+		{
+			name: "same name for another user", claims: other,
+			method: http.MethodPost, path: "/personas", body: `{"name":"Pirate","instruction":"Arr."}`,
+			status: http.StatusCreated,
+		},
+		{
+			name: "missing instruction", claims: claimsOf(func(c *auth.Claims) {}),
+			method: http.MethodPost, path: "/personas", body: `{"name":"Empty"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "invalid id", claims: claimsOf(func(c *auth.Claims) {}),
+			method: http.MethodGet, path: "/personas/pirate",
+			status: http.StatusBadRequest, message: "Invalid persona id",
+		},
+		{
+			name: "global persona read by a user", claims: claimsOf(func(c *auth.Claims) {}),
+			method: http.MethodGet, path: "/personas/" + tutor.ID,
+			status: http.StatusOK,
+		},
+		{
+			name: "global persona changed by a user", claims: claimsOf(func(c *auth.Claims) {}),
+			method: http.MethodPut, path: "/personas/" + tutor.ID, body: `{"name":"Tutor","instruction":"Be brief."}`,
+			status: http.StatusForbidden, message: "Only admins may change global personas",
+		},
+		{
+			name: "global persona deleted by a user", claims: claimsOf(func(c *auth.Claims) {}),
+			method: http.MethodDelete, path: "/personas/" + tutor.ID,
+			status: http.StatusForbidden, message: "Only admins may change global personas",
+		},
+		{
+			name: "persona of another user read", claims: other,
+			method: http.MethodGet, path: "/personas/" + pirate.ID,
+			status: http.StatusNotFound, message: "Persona not found",
+		},
+		{
+			name: "persona of another user changed", claims: other,
+			method: http.MethodPut, path: "/personas/" + pirate.ID, body: `{"name":"Pirate","instruction":"Arr."}`,
+			status: http.StatusNotFound, message: "Persona not found",
+		},
+		{
+			name: "persona of another user deleted", claims: other,
+			method: http.MethodDelete, path: "/personas/" + pirate.ID,
+			status: http.StatusNotFound, message: "Persona not found",
+		},
+		{
+			name: "versions of another user's persona", claims: other,
+			method: http.MethodGet, path: "/personas/" + pirate.ID + "/versions",
+			status: http.StatusNotFound, message: "Persona not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendAs(t, handler, tt.claims, tt.method, tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.message != "" && strings.TrimSpace(w.Body.String()) != tt.message {
+				t.Errorf("body = %q, want %q", w.Body, tt.message)
+			}
+		})
+	}
+
+	// The user sees the global personas first, and none of the other user's
+	w = send(t, handler, http.MethodGet, "/personas", "")
+	var list []*models.Persona
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decoding personas: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != tutor.ID || list[1].ID != pirate.ID {
+		t.Errorf("personas = %+v, want Tutor then the user's Pirate", list)
+	}
+
+	w = send(t, handler, http.MethodPut, "/personas/"+pirate.ID, `{"name":"Captain","description":"Commands a ship","instruction":"Answer like a pirate."}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("rename: status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if renamed := decodePersona(t, w); renamed.Name != "Captain" || renamed.Description != "Commands a ship" {
+		t.Errorf("renamed persona = %+v, want Captain", renamed)
+	}
+
+	if w := send(t, handler, http.MethodDelete, "/personas/"+pirate.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d, want 204: %s", w.Code, w.Body)
+	}
+	if w := send(t, handler, http.MethodGet, "/personas/"+pirate.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("get of a deleted persona: status = %d, want 404", w.Code)
+	}
+}
+
+func TestPersonaVersions(t *testing.T) {
+	_, handler, _ := newTestServer(t, fake.New())
+
+	w := send(t, handler, http.MethodPost, "/personas", `{"name":"Pirate","instruction":"Answer like a pirate."}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want 201: %s", w.Code, w.Body)
+	}
+	id := decodePersona(t, w).ID
+
+	updates := []struct {
+		body    string
+		version int
+	}{
+		// Only a changed instruction makes a new version
+		{body: `{"name":"Pirate","description":"Arr","instruction":"Answer like a pirate."}`, version: 1},
+		{body: `{"name":"Pirate","instruction":"Answer like a pirate captain."}`, version: 2},
+		{body: `{"name":"Captain","instruction":"Answer like a pirate captain."}`, version: 2},
+	}
+	for _, u := range updates {
+		w := send(t, handler, http.MethodPut, "/personas/"+id, u.body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("update %s: status = %d, want 200: %s", u.body, w.Code, w.Body)
+		}
+		if got := decodePersona(t, w).Version; got != u.version {
+			t.Errorf("update %s: version = %d, want %d", u.body, got, u.version)
+		}
+	}
+
+	w = send(t, handler, http.MethodGet, "/personas/"+id+"/versions", "")
+	var versions []models.PersonaVersion
+	if err := json.NewDecoder(w.Body).Decode(&versions); err != nil {
+		t.Fatalf("decoding versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Fatalf("versions = %+v, want 2 then 1", versions)
+	}
+
+	tests := []struct {
+		query       string
+		status      int
+		instruction string
+	}{
+		{query: "", status: http.StatusOK, instruction: "Answer like a pirate captain."},
+		{query: "?version=1", status: http.StatusOK, instruction: "Answer like a pirate."},
+		{query: "?version=2", status: http.StatusOK, instruction: "Answer like a pirate captain."},
+		{query: "?version=3", status: http.StatusNotFound},
+		{query: "?version=0", status: http.StatusBadRequest},
+		{query: "?version=latest", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := send(t, handler, http.MethodGet, "/personas/"+id+tt.query, "")
+		if w.Code != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.query, w.Code, tt.status)
+			continue
+		}
+		if tt.instruction != "" {
+			if got := decodePersona(t, w).Instruction; got != tt.instruction {
+				t.Errorf("GET %s: instruction = %q, want %q", tt.query, got, tt.instruction)
+			}
+		}
+	}
+}
+
+// The model is instructed with the system messages of the history, then the
+// persona, then the inline instruction of the conversation
+func TestPersonaInstructsTheModel(t *testing.T) {
+	provider := fake.New(fake.Reply{Text: "Arr, ahoy Ada."})
+	_, handler, store := newTestServer(t, provider)
+
+	w := send(t, handler, http.MethodPost, "/personas", `{"name":"Pirate","instruction":"Answer like a pirate."}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want 201: %s", w.Code, w.Body)
+	}
+	id := decodePersona(t, w).ID
+
+	conversationID := store.add(testUserID,
+		models.Message{Role: RoleSystem, Content: "The user is called Ada."},
+		models.Message{Role: RoleUser, Content: "Hi"},
+		models.Message{Role: RoleModel, Content: "Hello!"},
+	)
+	w = send(t, handler, http.MethodPost, "/chat", `{"conversation_id":"`+conversationID+`","persona_id":"`+id+`","system":"Keep it short.","message":"Greet me"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("chat: status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("the model received %d requests, want 1", len(requests))
+	}
+	want := "The user is called Ada.\n\nAnswer like a pirate.\n\nKeep it short."
+	if requests[0].System != want {
+		t.Errorf("system = %q, want %q", requests[0].System, want)
+	}
+	// The system message is an instruction, not a turn of the conversation
+	if got := len(requests[0].Messages); got != 3 {
+		t.Errorf("the model received %d messages, want 3", got)
+	}
+}
@@ -2,49 +2,230 @@ package server
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
-	"github.com/rs/cors"
-	"google.golang.org/genai"
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/catalog"
+	"github.com/Mahaveer86619/ImaginAI/internal/clients"
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/Mahaveer86619/ImaginAI/internal/conversations"
+	"github.com/Mahaveer86619/ImaginAI/internal/docs"
+	"github.com/Mahaveer86619/ImaginAI/internal/generation"
+	"github.com/Mahaveer86619/ImaginAI/internal/generations"
+	"github.com/Mahaveer86619/ImaginAI/internal/images"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/middleware"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/Mahaveer86619/ImaginAI/internal/personas"
+	"github.com/Mahaveer86619/ImaginAI/internal/router"
+	"github.com/Mahaveer86619/ImaginAI/internal/tools"
+	"github.com/Mahaveer86619/ImaginAI/internal/uploads"
+	"github.com/Mahaveer86619/ImaginAI/internal/users"
+	"github.com/gorilla/websocket"
 )
 
 type GenAIServer struct {
-	Ctx    context.Context
-	client *genai.Client
-	mu     sync.Mutex
+	Ctx context.Context
+	// defaultProvider is configured at startup or through /setup and used
+	// for users who have not stored a Gemini API key of their own
+	defaultProvider llm.Provider
+	mu              sync.Mutex
+
+	// UserKeys enables the Gemini API keys users store on their profile. It is
+	// disabled when the chat-bot runs on another provider, which the keys do
+	// not work with.
+	UserKeys bool
+
+	// Catalog lists the models users may chat with
+	Catalog *catalog.Catalog
+
+	// GenerationDefaults are the generation settings of chats that do not override them
+	GenerationDefaults *models.GenerationConfig
+
+	// Clients caches the clients created for users' own API keys
+	Clients *clients.Pool
+
+	// Generations keeps the events of streamed replies for resumption
+	Generations *generations.Registry
+	// StreamHeartbeat is the interval of the comments sent on idle streams
+	StreamHeartbeat time.Duration
+
+	// WebSocket bounds the resources of /ws connections
+	WebSocket WebSocketSettings
+
+	// Uploads bounds the files sent with chat messages
+	Uploads uploads.Limits
+
+	// Tools are the tools chat requests may let the model call
+	Tools *tools.Registry
+	// ToolSettings bound the tool calls of a reply
+	ToolSettings tools.Settings
+
+	// ImageModel is the model images are generated with
+	ImageModel string
+	// ImageStorage keeps the files of the generated images
+	ImageStorage *images.Storage
+
+	users         userStore
+	conversations conversationStore
+	personas      personaStore
+	images        imageStore
+	auth          *auth.Authenticator
+
+	cors     middleware.CORSPolicy
+	upgrader *websocket.Upgrader
+	sockets  *socketHub
+
+	// router holds the routing table built by SetupRoutes
+	router *router.Router
+}
+
+// userStore is the part of users.Store the handlers read
+type userStore interface {
+	ChatSettings(ctx context.Context, userID string) (*users.ChatSettings, error)
+	GeminiAPIKey(ctx context.Context, userID string) (string, error)
+}
+
+// conversationStore is the part of conversations.Store the handlers use
+type conversationStore interface {
+	Create(ctx context.Context, userID string, title string) (*models.Conversation, error)
+	List(ctx context.Context, userID string, limit int, offset int) ([]*models.Conversation, int, error)
+	Get(ctx context.Context, userID string, conversationID string) (*models.Conversation, error)
+	Rename(ctx context.Context, userID string, conversationID string, title string) (*models.Conversation, error)
+	Delete(ctx context.Context, userID string, conversationID string) error
+	SaveExchange(ctx context.Context, userID string, exchange conversations.Exchange) (string, error)
+	AttachmentData(ctx context.Context, conversationID string) (map[string][]byte, error)
+	Attachment(ctx context.Context, userID string, conversationID string, attachmentID string) (*conversations.Attachment, error)
+}
+
+// personaStore is the part of personas.Store the handlers use
+type personaStore interface {
+	Create(ctx context.Context, userID string, req *models.PersonaRequest) (*models.Persona, error)
+	List(ctx context.Context, userID string) ([]*models.Persona, error)
+	Get(ctx context.Context, userID string, personaID string, version int) (*models.Persona, error)
+	Versions(ctx context.Context, userID string, personaID string) ([]models.PersonaVersion, error)
+	Update(ctx context.Context, userID string, isAdmin bool, personaID string, req *models.PersonaRequest) (*models.Persona, error)
+	Delete(ctx context.Context, userID string, isAdmin bool, personaID string) error
 }
 
-func New(ctx context.Context) *GenAIServer {
+// imageStore is the part of images.Store the handlers use
+type imageStore interface {
+	Create(ctx context.Context, userID string, image *models.Image, key string) error
+	List(ctx context.Context, userID string, limit int, offset int) ([]*models.Image, int, error)
+	Get(ctx context.Context, userID string, imageID string) (*models.Image, string, error)
+	Delete(ctx context.Context, userID string, imageID string) (string, error)
+}
+
+func New(ctx context.Context, db *sql.DB) *GenAIServer {
+	store := users.NewStore(db)
+	conversationStore := conversations.NewStore(db)
+	cors := middleware.LoadCORSPolicy()
 	return &GenAIServer{
-		Ctx: ctx,
-		mu:  sync.Mutex{},
+		Ctx:                ctx,
+		Catalog:            catalog.Default(),
+		GenerationDefaults: generation.Defaults(),
+		mu:                 sync.Mutex{},
+		UserKeys:           true,
+		Clients:            clients.NewPoolFromEnv(clients.NewGeminiClient),
+		Generations:        generations.NewRegistryFromEnv(),
+		StreamHeartbeat:    config.GetEnvDuration("STREAM_HEARTBEAT_INTERVAL", defaultHeartbeat),
+		WebSocket:          WebSocketSettingsFromEnv(),
+		Uploads:            uploads.LimitsFromEnv(),
+		Tools:              tools.Builtin(conversationStore),
+		ToolSettings:       tools.SettingsFromEnv(),
+		ImageModel:         config.GetEnv("IMAGE_MODEL", "imagen-4.0-generate-001"),
+		ImageStorage:       images.NewStorageFromEnv(),
+		users:              store,
+		conversations:      conversationStore,
+		personas:           personas.NewStore(db),
+		images:             images.NewStore(db),
+		auth:               auth.NewAuthenticator(store),
+		cors:               cors,
+		upgrader:           newUpgrader(cors),
+		sockets:            newSocketHub(),
 	}
 }
 
+// SetDefaultAPIKey replaces the provider used for users without a Gemini API
+// key by Gemini with apiKey
+func (s *GenAIServer) SetDefaultAPIKey(apiKey string) error {
+	provider, err := clients.NewGeminiClient(s.Ctx, apiKey)
+	if err != nil {
+		return err
+	}
+
+	s.SetDefaultProvider(provider)
+	return nil
+}
+
+// SetDefaultProvider replaces the provider used for users without a Gemini API key
+func (s *GenAIServer) SetDefaultProvider(provider llm.Provider) {
+	s.mu.Lock()
+	s.defaultProvider = provider
+	s.mu.Unlock()
+}
+
 func (s *GenAIServer) SetupRoutes() http.Handler {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet && r.Method != http.MethodOptions {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		if r.URL.Path == "/favicon.ico" {
-			return
-		}
+	rt := router.New()
+	s.router = rt
+
+	rt.Get("/test", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "ImaginAi chat bot is running!")
 	})
-	mux.HandleFunc("/chat", s.ChatHandler)
-	mux.HandleFunc("/stream", s.StreamChatHandler)
-	mux.HandleFunc("/setup", s.SetupHandler)
 
-	c := cors.New(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedHeaders: []string{"Access-Control-Allow-Origin", "Content-Type"},
-	})
+	//* Docs routes
+	rt.Get("/openapi.json", docs.SpecHandler)
+	rt.Get("/docs", docs.UIHandler)
+
+	//* Chat routes - the server's access token is required
+	authed := rt.Group("", s.auth.Middleware)
+	authed.Post("/chat", s.ChatHandler)
+	authed.Post("/stream", s.StreamChatHandler)
+	authed.Post("/setup", s.SetupHandler, auth.RequireRole(auth.RoleAdmin))
+	authed.Get("/models", s.ListModelsHandler)
+	authed.Get("/tools", s.ListToolsHandler)
+	authed.Get("/generations/{id}/events", s.GenerationEventsHandler)
+	authed.Post("/generations/{id}/cancel", s.CancelGenerationHandler)
+
+	//* OpenAI compatible API - SDKs send our access token as their API key
+	authed.Post("/v1/chat/completions", s.ChatCompletionsHandler)
+	authed.Get("/v1/models", s.ListOpenAIModelsHandler)
+
+	//* WebSocket chat - browsers cannot set headers on WebSockets, so the
+	// access token may also be passed as the access_token query parameter
+	ws := rt.Group("", auth.TokenFromQuery, s.auth.Middleware)
+	ws.Get("/ws", s.WebSocketHandler)
+
+	//* Conversation routes - users only see their own conversations
+	authed.Post("/conversations", s.CreateConversationHandler)
+	authed.Get("/conversations", s.ListConversationsHandler)
+	authed.Get("/conversations/{id}", s.GetConversationHandler)
+	authed.Patch("/conversations/{id}", s.RenameConversationHandler)
+	authed.Delete("/conversations/{id}", s.DeleteConversationHandler)
+	authed.Get("/conversations/{id}/attachments/{attachmentId}", s.GetAttachmentHandler)
+
+	//* Persona routes - global personas can only be changed by admins
+	authed.Post("/personas", s.CreatePersonaHandler)
+	authed.Get("/personas", s.ListPersonasHandler)
+	authed.Get("/personas/{id}", s.GetPersonaHandler)
+	authed.Get("/personas/{id}/versions", s.ListPersonaVersionsHandler)
+	authed.Put("/personas/{id}", s.UpdatePersonaHandler)
+	authed.Delete("/personas/{id}", s.DeletePersonaHandler)
+
+	//* Image routes - users only see the images they generated
+	authed.Post("/images/generate", s.GenerateImagesHandler)
+	authed.Get("/images", s.ListImagesHandler)
+	authed.Get("/images/{id}", s.GetImageHandler)
+	authed.Get("/images/{id}/content", s.GetImageContentHandler)
+	authed.Delete("/images/{id}", s.DeleteImageHandler)
+
+	cors := middleware.CORSMiddleware(s.cors)
 
-	return c.Handler(mux)
+	return cors(rt)
 }
 
 // // Wrapper for chatHandler to check Gemini initialization
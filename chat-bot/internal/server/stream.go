@@ -0,0 +1,331 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/generations"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultHeartbeat is the interval of the comments keeping idle streams open
+const defaultHeartbeat = 15 * time.Second
+
+// lineBreaks normalizes the line endings of event data
+var lineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// StreamChatHandler sends the reply as server-sent events: delta events while
+// the model writes, tool_call and tool_result events around the tools it
+// calls, then usage, finish and history once the exchange is saved.
+// The reply is generated independently of the request, so a client that lost
+// the stream can resume it from GET /generations/{id}/events. It is cancelled
+// by POST /generations/{id}/cancel, or once no client followed it for the
+// disconnect grace period.
+func (gs *GenAIServer) StreamChatHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	turn, ok := gs.startTurn(w, r)
+	if !ok {
+		return
+	}
+
+	generation, err := gs.startGeneration(r.Context(), turn)
+	if err != nil {
+		writeRequestError(w, err, "Failed to start generation")
+		return
+	}
+
+	gs.streamEvents(w, r, generation, 0)
+}
+
+// startGeneration generates the reply of turn in the background, publishing
+// it to a new generation. Errors the client should see are *requestError.
+func (gs *GenAIServer) startGeneration(ctx context.Context, turn *chatTurn) (*generations.Generation, error) {
+	// The message was validated by newTurn, so failures come from the stored history
+	req, err := turn.request()
+	if err != nil {
+		return nil, err
+	}
+
+	generation := gs.Generations.Start(ctx, turn.userID)
+
+	go gs.streamReply(generation, req, turn)
+	return generation, nil
+}
+
+// GenerationEventsHandler streams the events of a generation, starting after
+// the Last-Event-ID header or from the first event when it is absent
+func (gs *GenAIServer) GenerationEventsHandler(w http.ResponseWriter, r *http.Request) {
+	lastID := 0
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Last-Event-ID must be the id of an event of the generation", http.StatusBadRequest)
+			return
+		}
+		lastID = n
+	}
+
+	generation, ok := gs.generation(w, r)
+	if !ok {
+		return
+	}
+
+	gs.streamEvents(w, r, generation, lastID)
+}
+
+// CancelGenerationHandler stops a generation of the caller. Its streams
+// receive the reply as far as it got with the cancelled finish reason, and
+// the reply is saved that way.
+func (gs *GenAIServer) CancelGenerationHandler(w http.ResponseWriter, r *http.Request) {
+	generation, ok := gs.generation(w, r)
+	if !ok {
+		return
+	}
+
+	if !generation.Cancel(generations.ErrCancelled) {
+		http.Error(w, "Generation already ended", http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// generation returns the generation of the {id} path value, answering the
+// request itself when it is not one of the caller's
+func (gs *GenAIServer) generation(w http.ResponseWriter, r *http.Request) (*generations.Generation, bool) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	id := r.PathValue("id")
+	if err := uuid.Validate(id); err != nil {
+		http.Error(w, "Invalid generation id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	generation, err := gs.Generations.Get(claims.UserID, id)
+	if err != nil {
+		if errors.Is(err, generations.ErrNotFound) {
+			http.Error(w, "Generation not found", http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, "Failed to load generation", http.StatusInternalServerError)
+		return nil, false
+	}
+	return generation, true
+}
+
+// streamReply sends req, the message of turn, and publishes the reply to
+// generation as it is written, running the tools the model calls, then saves
+// the exchange. Interrupted replies are saved as far as they got.
+func (gs *GenAIServer) streamReply(generation *generations.Generation, req *llm.Request, turn *chatTurn) {
+	defer generation.Close()
+
+	log := logrus.WithFields(logrus.Fields{"generation_id": generation.ID, "user_id": turn.userID})
+	publish := func(name string, v interface{}) {
+		if err := generation.Publish(name, v); err != nil {
+			log.WithError(err).Error("Error publishing generation event")
+		}
+	}
+
+	var reply strings.Builder
+	var finishReason string
+	var tokens *models.Usage
+	var blockReason string
+	var toolCalls []models.ToolCall
+
+	ctx := generation.Context()
+	var streamErr error
+	// Each step is a reply of the model, which goes on with the results of
+	// the tools it called until it answers without calling any
+	for step := 1; ; step++ {
+		var text strings.Builder
+		var calls []llm.ToolCall
+		var stepTokens *models.Usage
+		for chunk, err := range turn.provider.Stream(ctx, req) {
+			if err != nil {
+				streamErr = err
+				break
+			}
+			if chunk == nil {
+				continue
+			}
+
+			delta, reason := responseText(chunk)
+			if delta != "" {
+				// The texts of successive steps are separated by a blank line
+				published := delta
+				if text.Len() == 0 && reply.Len() > 0 {
+					published = "\n\n" + delta
+				}
+				text.WriteString(delta)
+				reply.WriteString(published)
+				publish(models.EventDelta, models.DeltaEvent{Text: published})
+			}
+			calls = append(calls, responseCalls(chunk)...)
+			if reason != "" {
+				finishReason = reason
+			}
+			if chunk.Usage != nil {
+				stepTokens = chunk.Usage
+			}
+			if chunk.BlockReason != "" {
+				blockReason = chunk.BlockReason
+			}
+		}
+		tokens = addUsage(tokens, stepTokens)
+
+		if streamErr != nil || !gs.callsTools(turn, calls, step) {
+			break
+		}
+		toolCalls = append(toolCalls, gs.runTools(ctx, turn, req, text.String(), calls, step, publish)...)
+	}
+
+	interrupted := streamErr != nil && ctx.Err() != nil
+	switch {
+	case interrupted:
+		finishReason = models.FinishCancelled
+		if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+			finishReason = models.FinishTimeout
+		}
+		log.WithField("cause", context.Cause(ctx)).Info("Generation interrupted")
+	case streamErr != nil:
+		log.WithError(streamErr).Error("Error receiving reply from the model")
+		publish(models.EventError, models.ErrorEvent{Message: "Failed to receive a reply from the model"})
+		return
+	case finishReason == "":
+		finishReason = models.FinishStop
+	}
+
+	// An exchange without a reply is not worth keeping
+	if reply.Len() == 0 {
+		if interrupted {
+			publish(models.EventFinish, models.FinishEvent{Reason: finishReason})
+			return
+		}
+		publish(models.EventError, models.ErrorEvent{Message: emptyReplyMessage(blockReason, finishReason)})
+		return
+	}
+
+	if tokens != nil {
+		publish(models.EventUsage, tokens)
+	}
+	publish(models.EventFinish, models.FinishEvent{Reason: finishReason})
+
+	// The reply is saved even when the generation is cancelled meanwhile
+	resp, err := gs.finishTurn(context.WithoutCancel(ctx), turn, reply.String(), finishReason)
+	if err != nil {
+		log.WithError(err).Error("Error saving conversation")
+		publish(models.EventError, models.ErrorEvent{Message: "Failed to save conversation"})
+		return
+	}
+	resp.Usage = tokens
+	resp.ToolCalls = toolCalls
+	publish(models.EventHistory, resp)
+}
+
+// streamEvents writes the events of generation published after lastID until
+// it ends or the client goes away, with heartbeat comments while it is idle
+func (gs *GenAIServer) streamEvents(w http.ResponseWriter, r *http.Request, generation *generations.Generation, lastID int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Keeps reverse proxies such as nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set("X-Generation-ID", generation.ID)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	generation.Attach()
+	defer generation.Detach()
+
+	interval := gs.StreamHeartbeat
+	if interval <= 0 {
+		interval = defaultHeartbeat
+	}
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	for {
+		events, done, changed := generation.Since(lastID)
+		for _, event := range events {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			lastID = event.ID
+		}
+		flusher.Flush()
+
+		if done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent encodes event in the text/event-stream format. Each line of the
+// data gets its own data field, which clients join back with newlines.
+func writeEvent(w io.Writer, event generations.Event) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %d\nevent: %s\n", event.ID, event.Name)
+	for _, line := range strings.Split(lineBreaks.Replace(string(event.Data)), "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// responseText returns the text of the first candidate of res, leaving out
+// its thoughts, and the reason the candidate finished if it did
+func responseText(res *llm.Response) (string, string) {
+	for _, candidate := range res.Candidates {
+		if candidate.Index == 0 {
+			return candidate.Content.Text(), candidate.FinishReason
+		}
+	}
+	return "", ""
+}
+
+// emptyReplyMessage explains why the model did not reply
+func emptyReplyMessage(blockReason string, finishReason string) string {
+	switch {
+	case blockReason != "":
+		return "The message was blocked by the model: " + blockReason
+	case finishReason != "" && finishReason != models.FinishStop:
+		return "The model did not reply, finish reason: " + finishReason
+	default:
+		return "Failed to receive a reply from the model"
+	}
+}
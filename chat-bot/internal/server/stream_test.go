@@ -0,0 +1,368 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// followEvents opens the event stream of generation id on srv, resuming after lastID when it is not empty
+func followEvents(t *testing.T, srv *httptest.Server, id string, lastID string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/generations/"+id+"/events", nil)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken(t))
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("following generation: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		t.Fatalf("status = %d, want 200", res.StatusCode)
+	}
+	return res
+}
+
+// readEvents reads n events from an event stream, or every event until it
+// ends when n is negative, along with the number of heartbeats in between
+func readEvents(t *testing.T, scanner *bufio.Scanner, n int) ([]sseEvent, int) {
+	t.Helper()
+
+	var events []sseEvent
+	var block strings.Builder
+	heartbeats := 0
+	for n < 0 || len(events) < n {
+		if !scanner.Scan() {
+			if n >= 0 {
+				t.Fatalf("stream ended after %d events, want %d", len(events), n)
+			}
+			break
+		}
+		line := scanner.Text()
+		switch {
+		case line == ": heartbeat":
+			heartbeats++
+		case line == "":
+			events = append(events, parseEvents(t, block.String())...)
+			block.Reset()
+		default:
+			block.WriteString(line + "\n")
+		}
+	}
+	return events, heartbeats
+}
+
+// deltaText returns the text of a delta event
+func deltaText(t *testing.T, event sseEvent) string {
+	t.Helper()
+
+	var delta models.DeltaEvent
+	if err := json.Unmarshal([]byte(event.Data), &delta); err != nil {
+		t.Fatalf("decoding event %s: %v", event.ID, err)
+	}
+	return delta.Text
+}
+
+func TestResumeGeneration(t *testing.T) {
+	gs, handler, _ := newTestServer(t, fake.New())
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	generation := gs.Generations.Start(context.Background(), testUserID)
+	publish := func(text string) {
+		t.Helper()
+		if err := generation.Publish(models.EventDelta, models.DeltaEvent{Text: text}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	for _, text := range []string{"One ", "two ", "three "} {
+		publish(text)
+	}
+
+	// The first client reads two events, then its connection drops
+	res := followEvents(t, srv, generation.ID, "")
+	first, _ := readEvents(t, bufio.NewScanner(res.Body), 2)
+	res.Body.Close()
+
+	// More of the reply is written while no client follows it
+	for _, text := range []string{"four ", "five"} {
+		publish(text)
+	}
+
+	res = followEvents(t, srv, generation.ID, first[len(first)-1].ID)
+	defer res.Body.Close()
+	scanner := bufio.NewScanner(res.Body)
+	resumed, _ := readEvents(t, scanner, 3)
+
+	// Events published while the client is attached reach it too
+	publish(".")
+	generation.Close()
+	rest, _ := readEvents(t, scanner, -1)
+
+	var ids []string
+	var reply strings.Builder
+	for _, event := range append(append(first, resumed...), rest...) {
+		ids = append(ids, event.ID)
+		if event.Name != models.EventDelta {
+			t.Errorf("event %s = %s, want a delta", event.ID, event.Name)
+		}
+		reply.WriteString(deltaText(t, event))
+	}
+	if got := strings.Join(ids, ","); got != "1,2,3,4,5,6" {
+		t.Errorf("event ids = %s, want each event once in order", got)
+	}
+	if reply.String() != "One two three four five." {
+		t.Errorf("reply = %q, want every delta once", reply.String())
+	}
+}
+
+func TestResumeStreamedReply(t *testing.T) {
+	provider := fake.New(fake.Reply{Text: "one two three four five six seven eight"})
+	provider.ChunkDelay = 5 * time.Millisecond
+	_, handler, store := newTestServer(t, provider)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	res := startStream(t, srv, "Count to eight")
+	id := res.Header.Get("X-Generation-ID")
+	first, _ := readEvents(t, bufio.NewScanner(res.Body), 3)
+	res.Body.Close()
+
+	res = followEvents(t, srv, id, first[len(first)-1].ID)
+	defer res.Body.Close()
+	rest, _ := readEvents(t, bufio.NewScanner(res.Body), -1)
+
+	var reply strings.Builder
+	var names []string
+	for i, event := range append(first, rest...) {
+		if event.ID != strconv.Itoa(i+1) {
+			t.Fatalf("event %d has id %s, want %d", i+1, event.ID, i+1)
+		}
+		names = append(names, event.Name)
+		if event.Name == models.EventDelta {
+			reply.WriteString(deltaText(t, event))
+		}
+	}
+	if reply.String() != "one two three four five six seven eight" {
+		t.Errorf("deltas = %q, want the reply once", reply.String())
+	}
+	if got := strings.Join(names[len(names)-3:], ","); got != "usage,finish,history" {
+		t.Errorf("last events = %s, want usage, finish and history", got)
+	}
+
+	list, _, _ := store.List(context.Background(), testUserID, 1, 0)
+	if len(list) != 1 {
+		t.Fatalf("user has %d conversations, want 1", len(list))
+	}
+	saved := store.messages(list[0].ID)
+	if len(saved) != 2 || saved[1].Content != reply.String() || saved[1].FinishReason != models.FinishStop {
+		t.Errorf("saved messages = %+v, want the whole reply", saved)
+	}
+}
+
+func TestGenerationEventsHeartbeat(t *testing.T) {
+	gs, handler, _ := newTestServer(t, fake.New())
+	gs.StreamHeartbeat = 10 * time.Millisecond
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	generation := gs.Generations.Start(context.Background(), testUserID)
+	res := followEvents(t, srv, generation.ID, "")
+	defer res.Body.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	generation.Close()
+	events, heartbeats := readEvents(t, bufio.NewScanner(res.Body), -1)
+	if len(events) != 0 || heartbeats == 0 {
+		t.Errorf("idle stream sent %d events and %d heartbeats, want heartbeats only", len(events), heartbeats)
+	}
+}
+
+func TestGenerationEventsRequests(t *testing.T) {
+	gs, handler, _ := newTestServer(t, fake.New())
+	generation := gs.Generations.Start(context.Background(), testUserID)
+	generation.Close()
+	other := gs.Generations.Start(context.Background(), "another user")
+	other.Close()
+
+	tests := []struct {
+		name   string
+		id     string
+		lastID string
+		status int
+	}{
+		{name: "ended generation", id: generation.ID, status: http.StatusOK},
+		{name: "invalid last event id", id: generation.ID, lastID: "three", status: http.StatusBadRequest},
+		{name: "negative last event id", id: generation.ID, lastID: "-1", status: http.StatusBadRequest},
+		{name: "invalid generation id", id: "42", status: http.StatusBadRequest},
+		{name: "generation of another user", id: other.ID, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/generations/"+tt.id+"/events", nil)
+			r.Header.Set("Authorization", "Bearer "+testToken(t))
+			if tt.lastID != "" {
+				r.Header.Set("Last-Event-ID", tt.lastID)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+// startStream posts message to /stream on srv, returning the response once its headers arrived
+func startStream(t *testing.T, srv *httptest.Server, message string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/stream", strings.NewReader(`{"message":"`+message+`"}`))
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken(t))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("starting stream: %v", err)
+	}
+	return res
+}
+
+// savedReply waits for the reply of the only conversation of testUserID to be saved
+func savedReply(t *testing.T, store *testConversations) models.Message {
+	t.Helper()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		list, _, _ := store.List(context.Background(), testUserID, 1, 0)
+		if len(list) == 1 {
+			if saved := store.messages(list[0].ID); len(saved) == 2 {
+				return saved[1]
+			}
+		}
+	}
+	t.Fatal("reply was not saved")
+	return models.Message{}
+}
+
+// slowProvider replies with a long text, one word every few milliseconds
+func slowProvider() *fake.Provider {
+	provider := fake.New(fake.Reply{Text: strings.Repeat("word ", 200)})
+	provider.ChunkDelay = 5 * time.Millisecond
+	return provider
+}
+
+func TestCancelGeneration(t *testing.T) {
+	_, handler, store := newTestServer(t, slowProvider())
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	res := startStream(t, srv, "Talk for a while")
+	defer res.Body.Close()
+	id := res.Header.Get("X-Generation-ID")
+	scanner := bufio.NewScanner(res.Body)
+	readEvents(t, scanner, 2)
+
+	if w := send(t, handler, http.MethodPost, "/generations/"+id+"/cancel", ""); w.Code != http.StatusAccepted {
+		t.Fatalf("cancel status = %d, want 202", w.Code)
+	}
+
+	events, _ := readEvents(t, scanner, -1)
+	var finish models.FinishEvent
+	for _, event := range events {
+		if event.Name == models.EventFinish {
+			if err := json.Unmarshal([]byte(event.Data), &finish); err != nil {
+				t.Fatalf("decoding finish event: %v", err)
+			}
+		}
+	}
+	if finish.Reason != models.FinishCancelled {
+		t.Errorf("finish reason = %q, want %q", finish.Reason, models.FinishCancelled)
+	}
+
+	reply := savedReply(t, store)
+	if reply.FinishReason != models.FinishCancelled || reply.Content == "" || len(reply.Content) >= len(strings.Repeat("word ", 200)) {
+		t.Errorf("saved reply = %d bytes finishing with %q, want the partial reply, cancelled", len(reply.Content), reply.FinishReason)
+	}
+
+	if w := send(t, handler, http.MethodPost, "/generations/"+id+"/cancel", ""); w.Code != http.StatusConflict {
+		t.Errorf("second cancel status = %d, want 409", w.Code)
+	}
+}
+
+func TestGenerationInterrupted(t *testing.T) {
+	t.Run("abandoned by its client", func(t *testing.T) {
+		gs, handler, store := newTestServer(t, slowProvider())
+		gs.Generations.DisconnectGrace = 10 * time.Millisecond
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		res := startStream(t, srv, "Talk for a while")
+		readEvents(t, bufio.NewScanner(res.Body), 2)
+		res.Body.Close()
+
+		if reply := savedReply(t, store); reply.FinishReason != models.FinishCancelled {
+			t.Errorf("saved finish reason = %q, want %q", reply.FinishReason, models.FinishCancelled)
+		}
+	})
+
+	t.Run("client reconnecting within the grace period", func(t *testing.T) {
+		provider := fake.New(fake.Reply{Text: strings.Repeat("word ", 20)})
+		provider.ChunkDelay = 5 * time.Millisecond
+		gs, handler, store := newTestServer(t, provider)
+		gs.Generations.DisconnectGrace = time.Second
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		res := startStream(t, srv, "Talk for a while")
+		id := res.Header.Get("X-Generation-ID")
+		first, _ := readEvents(t, bufio.NewScanner(res.Body), 2)
+		res.Body.Close()
+
+		res = followEvents(t, srv, id, first[len(first)-1].ID)
+		defer res.Body.Close()
+		readEvents(t, bufio.NewScanner(res.Body), -1)
+
+		if reply := savedReply(t, store); reply.FinishReason != models.FinishStop {
+			t.Errorf("saved finish reason = %q, want %q", reply.FinishReason, models.FinishStop)
+		}
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		gs, handler, store := newTestServer(t, slowProvider())
+		gs.Generations.Timeout = 50 * time.Millisecond
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		res := startStream(t, srv, "Talk for a while")
+		defer res.Body.Close()
+		events, _ := readEvents(t, bufio.NewScanner(res.Body), -1)
+
+		var names []string
+		for _, event := range events {
+			names = append(names, event.Name)
+		}
+		if got := strings.Join(names[len(names)-2:], ","); got != "finish,history" {
+			t.Errorf("last events = %s, want finish and history", got)
+		}
+		if reply := savedReply(t, store); reply.FinishReason != models.FinishTimeout {
+			t.Errorf("saved finish reason = %q, want %q", reply.FinishReason, models.FinishTimeout)
+		}
+	})
+}
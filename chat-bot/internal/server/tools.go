@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/Mahaveer86619/ImaginAI/internal/tools"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ListToolsHandler lists the tools chat requests may let the model call
+func (gs *GenAIServer) ListToolsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, models.ToolListResponse{Tools: gs.Tools.List()})
+}
+
+// runTools runs the tool calls of the model's reply at step, publishing each
+// call with emit before and after it runs, and appends the reply and the
+// results to req for the next step. Failed calls send their error to the
+// model, which may recover from it. Once the last step allowed by
+// ToolSettings ran, the model is asked to answer without tools.
+func (gs *GenAIServer) runTools(ctx context.Context, turn *chatTurn, req *llm.Request, text string, calls []llm.ToolCall, step int, emit func(name string, v interface{})) []models.ToolCall {
+	reply := llm.Message{Role: RoleModel}
+	if text != "" {
+		reply.Parts = append(reply.Parts, llm.Part{Text: text})
+	}
+	results := llm.Message{Role: RoleUser}
+
+	var ran []models.ToolCall
+	for _, call := range calls {
+		// Calls the backend does not identify are matched with their results
+		// by name, the id only tells clients the events of a call apart
+		id := call.ID
+		if id == "" {
+			id = uuid.NewString()
+		}
+		reply.Parts = append(reply.Parts, llm.Part{ToolCall: &call})
+
+		toolCall := models.ToolCall{ID: id, Name: call.Name, Arguments: call.Args, Step: step}
+		if toolCall.Arguments == nil {
+			toolCall.Arguments = map[string]any{}
+		}
+		emit(models.EventToolCall, toolCall)
+
+		result, err := gs.callTool(ctx, turn, call)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"user_id": turn.userID, "tool": call.Name}).Info("Tool call failed")
+			toolCall.Error = err.Error()
+			result = map[string]any{"error": toolCall.Error}
+		}
+		toolCall.Result = result
+		emit(models.EventToolResult, toolCall)
+
+		results.Parts = append(results.Parts, llm.Part{ToolResult: &llm.ToolResult{ID: call.ID, Name: call.Name, Response: result}})
+		ran = append(ran, toolCall)
+	}
+
+	req.Messages = append(req.Messages, reply, results)
+	if step >= gs.ToolSettings.MaxSteps {
+		req.ToolChoice = &llm.ToolChoice{Mode: llm.ToolNone}
+	}
+	return ran
+}
+
+// callTool runs call, which must name a tool of turn
+func (gs *GenAIServer) callTool(ctx context.Context, turn *chatTurn, call llm.ToolCall) (map[string]any, error) {
+	if !slices.ContainsFunc(turn.tools, func(tool llm.Tool) bool { return tool.Name == call.Name }) {
+		return nil, fmt.Errorf("%w %s", tools.ErrUnknownTool, call.Name)
+	}
+	return gs.Tools.Call(ctx, gs.ToolSettings.Timeout, turn.userID, call)
+}
+
+// callsTools reports whether the model asked for calls at step which may still run
+func (gs *GenAIServer) callsTools(turn *chatTurn, calls []llm.ToolCall, step int) bool {
+	return len(calls) > 0 && len(turn.tools) > 0 && step <= gs.ToolSettings.MaxSteps
+}
+
+// responseCalls returns the tool calls of the first candidate of res
+func responseCalls(res *llm.Response) []llm.ToolCall {
+	for _, candidate := range res.Candidates {
+		if candidate.Index == 0 {
+			return candidate.Content.ToolCalls()
+		}
+	}
+	return nil
+}
+
+// addUsage adds the tokens of a step of a reply to the tokens of the previous steps
+func addUsage(total *models.Usage, step *models.Usage) *models.Usage {
+	switch {
+	case step == nil:
+		return total
+	case total == nil:
+		usage := *step
+		return &usage
+	}
+	return &models.Usage{
+		PromptTokens:   total.PromptTokens + step.PromptTokens,
+		OutputTokens:   total.OutputTokens + step.OutputTokens,
+		ThoughtsTokens: total.ThoughtsTokens + step.ThoughtsTokens,
+		TotalTokens:    total.TotalTokens + step.TotalTokens,
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/Mahaveer86619/ImaginAI/internal/tools"
+)
+
+// calculate is a call of the calculator tool
+func calculate(id string, expression string) []llm.ToolCall {
+	return []llm.ToolCall{{ID: id, Name: "calculator", Args: map[string]any{"expression": expression}}}
+}
+
+// toolResults returns the tool results of the last message of req
+func toolResults(req *llm.Request) []*llm.ToolResult {
+	var results []*llm.ToolResult
+	if len(req.Messages) == 0 {
+		return nil
+	}
+	for _, part := range req.Messages[len(req.Messages)-1].Parts {
+		if part.ToolResult != nil {
+			results = append(results, part.ToolResult)
+		}
+	}
+	return results
+}
+
+func TestToolLoop(t *testing.T) {
+	provider := fake.New(
+		fake.Reply{ToolCalls: calculate("call-1", "2^10")},
+		fake.Reply{Text: "Let me check the remainder.", ToolCalls: calculate("call-2", "5 % 0")},
+		fake.Reply{Text: "2^10 is 1024, and 5 % 0 is undefined."},
+	)
+	gs, handler, _ := newTestServer(t, provider)
+	gs.ToolSettings = tools.Settings{MaxSteps: 2, Timeout: time.Second}
+
+	w := send(t, handler, http.MethodPost, "/chat", `{"message":"What are 2^10 and 5 % 0?","tools":["calculator"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var resp models.ChatResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if resp.Response != "Let me check the remainder.\n\n2^10 is 1024, and 5 % 0 is undefined." {
+		t.Errorf("response = %q, want the texts of every step", resp.Response)
+	}
+	if resp.FinishReason != models.FinishStop {
+		t.Errorf("finish_reason = %q, want %q", resp.FinishReason, models.FinishStop)
+	}
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("tool calls = %+v, want 2", resp.ToolCalls)
+	}
+	if call := resp.ToolCalls[0]; call.ID != "call-1" || call.Step != 1 || call.Error != "" || call.Result["result"] != float64(1024) {
+		t.Errorf("first call = %+v, want 2^10 = 1024 at step 1", call)
+	}
+	if call := resp.ToolCalls[1]; call.ID != "call-2" || call.Step != 2 || call.Error != "division by zero" {
+		t.Errorf("second call = %+v, want the division by zero at step 2", call)
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("the model received %d requests, want 3", len(requests))
+	}
+	for i, req := range requests[:2] {
+		if req.ToolChoice != nil && req.ToolChoice.Mode == llm.ToolNone {
+			t.Errorf("request %d may not call tools before the step limit", i+1)
+		}
+		if len(req.Tools) != 1 || req.Tools[0].Name != "calculator" {
+			t.Errorf("request %d tools = %+v, want the calculator", i+1, req.Tools)
+		}
+	}
+	if choice := requests[2].ToolChoice; choice == nil || choice.Mode != llm.ToolNone {
+		t.Errorf("tool choice after %d steps = %+v, want none", gs.ToolSettings.MaxSteps, choice)
+	}
+
+	// Each step sends the calls of the model back with their results
+	if results := toolResults(requests[1]); len(results) != 1 || results[0].ID != "call-1" || results[0].Response["result"] != float64(1024) {
+		t.Errorf("results of step 1 = %+v, want 1024", results)
+	}
+	if results := toolResults(requests[2]); len(results) != 1 || results[0].ID != "call-2" || results[0].Response["error"] != "division by zero" {
+		t.Errorf("results of step 2 = %+v, want the error", results)
+	}
+	if got := len(requests[2].Messages); got != 5 {
+		t.Errorf("last request holds %d messages, want the message and 2 steps of call and results", got)
+	}
+}
+
+func TestToolLoopStopsAtTheStepLimit(t *testing.T) {
+	// The model keeps calling tools, even when asked not to
+	provider := fake.New(
+		fake.Reply{ToolCalls: calculate("call-1", "1 + 1")},
+		fake.Reply{Text: "Still counting.", ToolCalls: calculate("call-2", "2 + 2")},
+	)
+	gs, handler, _ := newTestServer(t, provider)
+	gs.ToolSettings = tools.Settings{MaxSteps: 1, Timeout: time.Second}
+
+	w := send(t, handler, http.MethodPost, "/chat", `{"message":"Count","tools":["calculator"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var resp models.ChatResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "call-1" {
+		t.Errorf("tool calls = %+v, want the call of the first step only", resp.ToolCalls)
+	}
+	if resp.Response != "Still counting." {
+		t.Errorf("response = %q, want the text of the last step", resp.Response)
+	}
+	if requests := provider.Requests(); len(requests) != 2 || requests[1].ToolChoice == nil || requests[1].ToolChoice.Mode != llm.ToolNone {
+		t.Errorf("the model received %d requests, want 2, the last without tools", len(requests))
+	}
+}
+
+func TestStreamToolEvents(t *testing.T) {
+	provider := fake.New(
+		fake.Reply{ToolCalls: calculate("call-1", "sqrt")},
+		fake.Reply{Text: "The expression is missing its argument."},
+	)
+	gs, handler, _ := newTestServer(t, provider)
+	gs.ToolSettings = tools.Settings{MaxSteps: 2, Timeout: time.Second}
+
+	w := send(t, handler, http.MethodPost, "/stream", `{"message":"What is sqrt?","tools":["calculator"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	var names []string
+	var result models.ToolCall
+	for _, event := range parseEvents(t, w.Body.String()) {
+		if event.Name != models.EventDelta {
+			names = append(names, event.Name)
+		}
+		if event.Name == models.EventToolResult {
+			if err := json.Unmarshal([]byte(event.Data), &result); err != nil {
+				t.Fatalf("decoding tool_result event: %v", err)
+			}
+		}
+	}
+
+	if got := strings.Join(names, ","); got != "tool_call,tool_result,usage,finish,history" {
+		t.Errorf("events = %s, want the call and its result before the reply", got)
+	}
+	if result.ID != "call-1" || result.Error != "sqrt must be followed by parentheses" || result.Result["error"] != result.Error {
+		t.Errorf("tool result = %+v, want the error of the calculator", result)
+	}
+	if results := toolResults(provider.Requests()[1]); len(results) != 1 || results[0].Response["error"] != result.Error {
+		t.Errorf("results sent back = %+v, want the error", results)
+	}
+}
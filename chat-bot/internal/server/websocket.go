@@ -0,0 +1,392 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/auth"
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/Mahaveer86619/ImaginAI/internal/generations"
+	"github.com/Mahaveer86619/ImaginAI/internal/middleware"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// WebSocketSettings bound the resources of /ws connections
+type WebSocketSettings struct {
+	// PongTimeout is how long a connection may stay silent, pings are sent
+	// often enough for clients to answer in time
+	PongTimeout  time.Duration
+	WriteTimeout time.Duration
+	// SendBuffer is the number of messages queued for a client, which is
+	// disconnected when it does not keep up
+	SendBuffer int
+	// MaxGenerations is the number of generations running at once on a connection
+	MaxGenerations int
+	// MaxMessageSize is the size of the largest message accepted, in bytes
+	MaxMessageSize int64
+}
+
+// WebSocketSettingsFromEnv reads WS_PONG_TIMEOUT (default 60s),
+// WS_WRITE_TIMEOUT (default 10s), WS_SEND_BUFFER (default 256),
+// WS_MAX_GENERATIONS (default 2) and WS_MAX_MESSAGE_SIZE (default 256 KiB)
+func WebSocketSettingsFromEnv() WebSocketSettings {
+	return WebSocketSettings{
+		PongTimeout:    config.GetEnvDuration("WS_PONG_TIMEOUT", 60*time.Second),
+		WriteTimeout:   config.GetEnvDuration("WS_WRITE_TIMEOUT", 10*time.Second),
+		SendBuffer:     config.GetEnvInt("WS_SEND_BUFFER", 256),
+		MaxGenerations: config.GetEnvInt("WS_MAX_GENERATIONS", 2),
+		MaxMessageSize: config.GetEnvInt64("WS_MAX_MESSAGE_SIZE", 256<<10),
+	}
+}
+
+// newUpgrader accepts WebSocket connections from the origins policy allows,
+// and from clients sending no origin, which are not browsers
+func newUpgrader(policy middleware.CORSPolicy) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || policy.AllowsOrigin(origin)
+		},
+	}
+}
+
+// WebSocketHandler upgrades the request to a WebSocket speaking the JSON
+// protocol of models.WSClientMessage and models.WSServerMessage. Replies are
+// generated like those of /stream, so a generation whose connection dropped
+// may be resumed from GET /generations/{id}/events.
+func (gs *GenAIServer) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+
+	// The upgrader answers failed upgrades itself
+	conn, err := gs.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &wsConn{
+		gs:          gs,
+		conn:        conn,
+		userID:      claims.UserID,
+		settings:    gs.WebSocket,
+		send:        make(chan models.WSServerMessage, max(gs.WebSocket.SendBuffer, 1)),
+		closed:      make(chan struct{}),
+		generations: map[string]*generations.Generation{},
+	}
+
+	gs.sockets.add(c)
+	defer gs.sockets.remove(c)
+
+	go c.writeLoop()
+	c.readLoop(r.Context())
+}
+
+// wsConn is a WebSocket connection of a user
+type wsConn struct {
+	gs       *GenAIServer
+	conn     *websocket.Conn
+	userID   string
+	settings WebSocketSettings
+
+	// send queues the messages written by writeLoop
+	send chan models.WSServerMessage
+	// closed is closed once the connection ends
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu sync.Mutex
+	// generations holds the generations of the connection by request id, nil
+	// while a generation is being started
+	generations map[string]*generations.Generation
+}
+
+// readLoop handles the client's messages until the connection ends
+func (c *wsConn) readLoop(ctx context.Context) {
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	c.conn.SetReadLimit(c.settings.MaxMessageSize)
+	extend := func() error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.settings.PongTimeout))
+	}
+	_ = extend()
+	c.conn.SetPongHandler(func(string) error { return extend() })
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				c.close(websocket.CloseMessageTooBig, "Message too big")
+			}
+			return
+		}
+		_ = extend()
+
+		var msg models.WSClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.queue(models.WSServerMessage{Type: models.WSError, Message: "Invalid message"})
+			continue
+		}
+
+		switch msg.Type {
+		case models.WSSend, models.WSRegenerate:
+			c.startGeneration(ctx, msg)
+		case models.WSCancel:
+			c.cancel(msg.RequestID)
+		case models.WSTyping:
+			if msg.ConversationID == "" {
+				c.queue(models.WSServerMessage{Type: models.WSError, Message: "conversation_id is required"})
+				continue
+			}
+			c.gs.sockets.relay(c, models.WSServerMessage{Type: models.WSTyping, ConversationID: msg.ConversationID, Typing: msg.Typing})
+		default:
+			c.queue(models.WSServerMessage{Type: models.WSError, RequestID: msg.RequestID, Message: "Unknown message type " + strconv.Quote(msg.Type)})
+		}
+	}
+}
+
+// writeLoop writes the queued messages and the pings keeping the connection
+// alive until it ends
+func (c *wsConn) writeLoop() {
+	ticker := time.NewTicker(c.settings.PongTimeout * 9 / 10)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.settings.WriteTimeout))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.settings.WriteTimeout)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// queue sends msg to the client unless the connection ended. A client too
+// slow to read its messages is disconnected rather than buffered without
+// bound, its generations continue for the disconnect grace period.
+func (c *wsConn) queue(msg models.WSServerMessage) bool {
+	select {
+	case <-c.closed:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		logrus.WithField("user_id", c.userID).Warn("Closing WebSocket of a client too slow to read its messages")
+		c.close(websocket.CloseTryAgainLater, "Client too slow")
+		return false
+	}
+}
+
+// close ends the connection, telling the client why with code and reason
+func (c *wsConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		if code != websocket.CloseAbnormalClosure {
+			message := websocket.FormatCloseMessage(code, reason)
+			_ = c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(c.settings.WriteTimeout))
+		}
+		_ = c.conn.Close()
+	})
+}
+
+// startGeneration takes a generation slot for a send or regenerate message
+// and starts the generation in the background, so that loading the
+// conversation does not hold up the other messages of the client
+func (c *wsConn) startGeneration(ctx context.Context, msg models.WSClientMessage) {
+	fail := func(message string) {
+		c.queue(models.WSServerMessage{Type: models.WSError, RequestID: msg.RequestID, Message: message})
+	}
+
+	switch {
+	case msg.RequestID == "":
+		fail("request_id is required")
+		return
+	case msg.Chat == nil:
+		fail("chat is required")
+		return
+	}
+
+	c.mu.Lock()
+	if _, ok := c.generations[msg.RequestID]; ok {
+		c.mu.Unlock()
+		fail("request_id is already used by a generation in progress")
+		return
+	}
+	if len(c.generations) >= c.settings.MaxGenerations {
+		c.mu.Unlock()
+		fail("At most " + strconv.Itoa(c.settings.MaxGenerations) + " generations may run at once on a connection")
+		return
+	}
+	c.generations[msg.RequestID] = nil
+	c.mu.Unlock()
+
+	go c.run(ctx, msg)
+}
+
+// run starts and forwards the generation of msg, whose slot is taken
+func (c *wsConn) run(ctx context.Context, msg models.WSClientMessage) {
+	turn, err := c.gs.newTurn(ctx, c.userID, msg.Chat, nil, msg.Type == models.WSRegenerate)
+	var generation *generations.Generation
+	if err == nil {
+		generation, err = c.gs.startGeneration(ctx, turn)
+	}
+	if err != nil {
+		c.release(msg.RequestID)
+
+		var reqErr *requestError
+		if !errors.As(err, &reqErr) {
+			logrus.WithError(err).Error("Error starting generation")
+			reqErr = &requestError{message: "Failed to start generation"}
+		}
+		c.queue(models.WSServerMessage{Type: models.WSError, RequestID: msg.RequestID, Message: reqErr.message})
+		return
+	}
+
+	c.mu.Lock()
+	c.generations[msg.RequestID] = generation
+	c.mu.Unlock()
+
+	c.forward(msg.RequestID, generation)
+}
+
+// forward sends the events of generation to the client as delta, tool_call
+// and tool_result messages, then done or error. It stops once a message
+// cannot be queued, the connection being closed.
+func (c *wsConn) forward(requestID string, generation *generations.Generation) {
+	generation.Attach()
+	defer generation.Detach()
+	defer c.release(requestID)
+
+	done := models.WSServerMessage{Type: models.WSDone, RequestID: requestID, GenerationID: generation.ID}
+
+	lastID := 0
+	for {
+		events, ended, changed := generation.Since(lastID)
+		for _, event := range events {
+			lastID = event.ID
+
+			switch value := event.Value.(type) {
+			case models.DeltaEvent:
+				if !c.queue(models.WSServerMessage{Type: models.WSDelta, RequestID: requestID, GenerationID: generation.ID, Text: value.Text}) {
+					return
+				}
+			case models.ToolCall:
+				msgType := models.WSToolCall
+				if event.Name == models.EventToolResult {
+					msgType = models.WSToolResult
+				}
+				if !c.queue(models.WSServerMessage{Type: msgType, RequestID: requestID, GenerationID: generation.ID, ToolCall: &value}) {
+					return
+				}
+			case *models.Usage:
+				done.Usage = value
+			case models.FinishEvent:
+				done.FinishReason = value.Reason
+			case *models.ChatResponse:
+				done.Response = value
+			case models.ErrorEvent:
+				// An error ends the generation in place of done
+				c.release(requestID)
+				c.queue(models.WSServerMessage{Type: models.WSError, RequestID: requestID, GenerationID: generation.ID, Message: value.Message})
+				return
+			}
+		}
+
+		if ended {
+			// The slot is free by the time the client learns the generation
+			// ended, so that it may start the next one at once
+			c.release(requestID)
+			c.queue(done)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// cancel stops the generation of requestID
+func (c *wsConn) cancel(requestID string) {
+	c.mu.Lock()
+	generation := c.generations[requestID]
+	c.mu.Unlock()
+
+	if generation == nil || !generation.Cancel(generations.ErrCancelled) {
+		c.queue(models.WSServerMessage{Type: models.WSError, RequestID: requestID, Message: "No generation in progress for this request_id"})
+	}
+}
+
+// release frees the generation slot of requestID
+func (c *wsConn) release(requestID string) {
+	c.mu.Lock()
+	delete(c.generations, requestID)
+	c.mu.Unlock()
+}
+
+// socketHub tracks the WebSocket connections of each user, to relay the
+// messages of one connection to the others
+type socketHub struct {
+	mu    sync.Mutex
+	users map[string]map[*wsConn]struct{}
+}
+
+func newSocketHub() *socketHub {
+	return &socketHub{users: map[string]map[*wsConn]struct{}{}}
+}
+
+func (h *socketHub) add(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.users[c.userID] == nil {
+		h.users[c.userID] = map[*wsConn]struct{}{}
+	}
+	h.users[c.userID][c] = struct{}{}
+}
+
+func (h *socketHub) remove(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.users[c.userID], c)
+	if len(h.users[c.userID]) == 0 {
+		delete(h.users, c.userID)
+	}
+}
+
+// relay sends msg to the other connections of the user of from
+func (h *socketHub) relay(from *wsConn, msg models.WSServerMessage) {
+	h.mu.Lock()
+	var targets []*wsConn
+	for c := range h.users[from.userID] {
+		if c != from {
+			targets = append(targets, c)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, c := range targets {
+		c.queue(msg)
+	}
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/llm/fake"
+	"github.com/Mahaveer86619/ImaginAI/internal/middleware"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+// dialWS connects to /ws of server with query and header, failing the test
+// unless the upgrade answers status
+func dialWS(t *testing.T, server *httptest.Server, query string, header http.Header, status int) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if resp == nil {
+		t.Fatalf("dialing /ws: %v", err)
+	}
+	if resp.StatusCode != status {
+		t.Fatalf("upgrade status = %d, want %d: %v", resp.StatusCode, status, err)
+	}
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn
+}
+
+// readUntil reads the messages of conn until stop returns true for one of
+// them, returning them all
+func readUntil(t *testing.T, conn *websocket.Conn, stop func(msg models.WSServerMessage) bool) []models.WSServerMessage {
+	t.Helper()
+
+	var messages []models.WSServerMessage
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg models.WSServerMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("reading message after %+v: %v", messages, err)
+		}
+		messages = append(messages, msg)
+		if stop(msg) {
+			return messages
+		}
+	}
+}
+
+// ends reports whether msg ends the generation of requestID
+func ends(requestID string) func(msg models.WSServerMessage) bool {
+	return func(msg models.WSServerMessage) bool {
+		return msg.RequestID == requestID && (msg.Type == models.WSDone || msg.Type == models.WSError)
+	}
+}
+
+func TestWebSocketAuthentication(t *testing.T) {
+	_, handler, _ := newTestServer(t, fake.New())
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	dialWS(t, server, "", nil, http.StatusUnauthorized)
+	dialWS(t, server, "?access_token=not-a-token", nil, http.StatusUnauthorized)
+	dialWS(t, server, "?access_token="+testToken(t), nil, http.StatusSwitchingProtocols)
+	dialWS(t, server, "", http.Header{"Authorization": {"Bearer " + testToken(t)}}, http.StatusSwitchingProtocols)
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	gs, _, _ := newTestServer(t, fake.New())
+	gs.upgrader = newUpgrader(middleware.CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}})
+	server := httptest.NewServer(gs.SetupRoutes())
+	defer server.Close()
+
+	query := "?access_token=" + testToken(t)
+	dialWS(t, server, query, http.Header{"Origin": {"https://evil.example.com"}}, http.StatusForbidden)
+	dialWS(t, server, query, http.Header{"Origin": {"https://app.example.com"}}, http.StatusSwitchingProtocols)
+	// Clients other than browsers send no origin
+	dialWS(t, server, query, nil, http.StatusSwitchingProtocols)
+}
+
+func TestWebSocketSend(t *testing.T) {
+	provider := fake.New(fake.Reply{Text: "Hello Ada, how can I help?"})
+	_, handler, store := newTestServer(t, provider)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn := dialWS(t, server, "?access_token="+testToken(t), nil, http.StatusSwitchingProtocols)
+	if err := conn.WriteJSON(models.WSClientMessage{Type: models.WSSend, RequestID: "r1", Chat: &models.ChatRequest{Message: "Hi, I am Ada"}}); err != nil {
+		t.Fatalf("sending message: %v", err)
+	}
+
+	messages := readUntil(t, conn, ends("r1"))
+	var reply strings.Builder
+	for _, msg := range messages[:len(messages)-1] {
+		if msg.Type != models.WSDelta || msg.RequestID != "r1" || msg.GenerationID == "" {
+			t.Fatalf("message = %+v, want a delta of r1", msg)
+		}
+		reply.WriteString(msg.Text)
+	}
+	if reply.String() != "Hello Ada, how can I help?" {
+		t.Errorf("deltas = %q, want the reply", reply.String())
+	}
+
+	done := messages[len(messages)-1]
+	if done.Type != models.WSDone || done.FinishReason != models.FinishStop || done.Response == nil || done.Usage == nil {
+		t.Fatalf("last message = %+v, want done with the response and usage", done)
+	}
+	if got := len(store.messages(done.Response.ConversationID)); got != 2 {
+		t.Errorf("conversation holds %d messages, want 2", got)
+	}
+}
+
+func TestWebSocketCancel(t *testing.T) {
+	provider := fake.New(fake.Reply{Text: strings.Repeat("word ", 100)})
+	provider.ChunkDelay = 10 * time.Millisecond
+	_, handler, _ := newTestServer(t, provider)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn := dialWS(t, server, "?access_token="+testToken(t), nil, http.StatusSwitchingProtocols)
+	if err := conn.WriteJSON(models.WSClientMessage{Type: models.WSSend, RequestID: "r1", Chat: &models.ChatRequest{Message: "Talk"}}); err != nil {
+		t.Fatalf("sending message: %v", err)
+	}
+	readUntil(t, conn, func(msg models.WSServerMessage) bool { return msg.Type == models.WSDelta })
+
+	if err := conn.WriteJSON(models.WSClientMessage{Type: models.WSCancel, RequestID: "r1"}); err != nil {
+		t.Fatalf("sending cancel: %v", err)
+	}
+	messages := readUntil(t, conn, ends("r1"))
+	if done := messages[len(messages)-1]; done.Type != models.WSDone || done.FinishReason != models.FinishCancelled {
+		t.Errorf("last message = %+v, want done cancelled", done)
+	}
+	if len(messages) > 50 {
+		t.Errorf("received %d messages after cancelling, want the generation stopped", len(messages))
+	}
+
+	// The generation is over, so there is nothing left to cancel
+	if err := conn.WriteJSON(models.WSClientMessage{Type: models.WSCancel, RequestID: "r1"}); err != nil {
+		t.Fatalf("sending cancel: %v", err)
+	}
+	if msg := readUntil(t, conn, ends("r1"))[0]; msg.Message != "No generation in progress for this request_id" {
+		t.Errorf("message = %+v, want no generation in progress", msg)
+	}
+}
+
+func TestWebSocketGenerationLimit(t *testing.T) {
+	provider := fake.New(fake.Reply{Text: "one two three four five"})
+	provider.ChunkDelay = 20 * time.Millisecond
+	gs, _, _ := newTestServer(t, provider)
+	gs.WebSocket.MaxGenerations = 1
+	server := httptest.NewServer(gs.SetupRoutes())
+	defer server.Close()
+
+	conn := dialWS(t, server, "?access_token="+testToken(t), nil, http.StatusSwitchingProtocols)
+	for _, requestID := range []string{"r1", "r2"} {
+		if err := conn.WriteJSON(models.WSClientMessage{Type: models.WSSend, RequestID: requestID, Chat: &models.ChatRequest{Message: "Count"}}); err != nil {
+			t.Fatalf("sending message: %v", err)
+		}
+	}
+
+	ended := map[string]models.WSServerMessage{}
+	readUntil(t, conn, func(msg models.WSServerMessage) bool {
+		if ends(msg.RequestID)(msg) {
+			ended[msg.RequestID] = msg
+		}
+		return len(ended) == 2
+	})
+	if msg := ended["r1"]; msg.Type != models.WSDone {
+		t.Errorf("r1 ended with %+v, want done", msg)
+	}
+	if msg := ended["r2"]; msg.Type != models.WSError || msg.Message != "At most 1 generations may run at once on a connection" {
+		t.Errorf("r2 ended with %+v, want the limit error", msg)
+	}
+
+	// The slot is free again once r1 is done
+	if err := conn.WriteJSON(models.WSClientMessage{Type: models.WSSend, RequestID: "r3", Chat: &models.ChatRequest{Message: "Again"}}); err != nil {
+		t.Fatalf("sending message: %v", err)
+	}
+	messages := readUntil(t, conn, ends("r3"))
+	if msg := messages[len(messages)-1]; msg.Type != models.WSDone {
+		t.Errorf("r3 ended with %+v, want done", msg)
+	}
+}
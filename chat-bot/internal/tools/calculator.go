@@ -0,0 +1,275 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxExpressionLength bounds the expressions of the calculator
+const maxExpressionLength = 1000
+
+// Calculator evaluates arithmetic expressions, which models get wrong
+func Calculator() *Tool {
+	return &Tool{
+		Name:        "calculator",
+		Description: "Evaluates an arithmetic expression exactly. Supports + - * / % ^, parentheses, the constants pi and e, and the functions sqrt, abs, exp, ln, log10, sin, cos, tan, floor, ceil and round.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"expression": map[string]any{
+					"type":        "string",
+					"description": "The expression, such as (3 + 4) * 2^10 / sqrt(2)",
+				},
+			},
+			"required": []string{"expression"},
+		},
+		Run: func(ctx context.Context, call Invocation) (map[string]any, error) {
+			expression, err := stringArg(call.Args, "expression")
+			if err != nil {
+				return nil, err
+			}
+			result, err := Evaluate(expression)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"expression": expression, "result": result}, nil
+		},
+	}
+}
+
+// Evaluate computes an arithmetic expression
+func Evaluate(expression string) (float64, error) {
+	if strings.TrimSpace(expression) == "" {
+		return 0, errors.New("expression is required")
+	}
+	if len(expression) > maxExpressionLength {
+		return 0, fmt.Errorf("expression must be at most %d characters", maxExpressionLength)
+	}
+
+	p := &parser{input: expression}
+	value, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("the result is not a finite number")
+	}
+	return value, nil
+}
+
+var functions = map[string]func(float64) float64{
+	"sqrt":  math.Sqrt,
+	"abs":   math.Abs,
+	"exp":   math.Exp,
+	"ln":    math.Log,
+	"log10": math.Log10,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+	"round": math.Round,
+}
+
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// parser is a recursive descent parser of expressions, evaluating them as it goes:
+//
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/" | "%") unary }
+//	unary      = ("+" | "-") unary | power
+//	power      = primary [ "^" unary ]
+//	primary    = number | constant | function "(" expression ")" | "(" expression ")"
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) expression() (float64, error) {
+	value, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+', '-':
+			op := p.next()
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			if op == '+' {
+				value += right
+			} else {
+				value -= right
+			}
+		default:
+			return value, nil
+		}
+	}
+}
+
+func (p *parser) term() (float64, error) {
+	value, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '*', '/', '%':
+			op := p.next()
+			right, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			switch {
+			case op == '*':
+				value *= right
+			case right == 0:
+				return 0, errors.New("division by zero")
+			case op == '/':
+				value /= right
+			default:
+				value = math.Mod(value, right)
+			}
+		default:
+			return value, nil
+		}
+	}
+}
+
+func (p *parser) unary() (float64, error) {
+	switch p.peek() {
+	case '+':
+		p.next()
+		return p.unary()
+	case '-':
+		p.next()
+		value, err := p.unary()
+		return -value, err
+	}
+	return p.power()
+}
+
+func (p *parser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.next()
+	// Powers are right associative: 2^3^2 is 2^9
+	exponent, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+func (p *parser) primary() (float64, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.next()
+		value, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, errors.New("missing closing parenthesis")
+		}
+		p.next()
+		return value, nil
+	case c == '.' || unicode.IsDigit(rune(c)):
+		return p.number()
+	case unicode.IsLetter(rune(c)):
+		name := p.identifier()
+		if value, ok := constants[name]; ok {
+			return value, nil
+		}
+		function, ok := functions[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown name %q", name)
+		}
+		if p.peek() != '(' {
+			return 0, fmt.Errorf("%s must be followed by parentheses", name)
+		}
+		argument, err := p.primary()
+		if err != nil {
+			return 0, err
+		}
+		return function(argument), nil
+	case c == 0:
+		return 0, errors.New("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
+
+func (p *parser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsDigit(rune(p.input[p.pos])) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	// Exponents such as 1.5e3
+	if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+		end := p.pos + 1
+		if end < len(p.input) && (p.input[end] == '+' || p.input[end] == '-') {
+			end++
+		}
+		if end < len(p.input) && unicode.IsDigit(rune(p.input[end])) {
+			p.pos = end
+			for p.pos < len(p.input) && unicode.IsDigit(rune(p.input[p.pos])) {
+				p.pos++
+			}
+		}
+	}
+
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+	}
+	return value, nil
+}
+
+func (p *parser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+		p.pos++
+	}
+	return strings.ToLower(p.input[start:p.pos])
+}
+
+// peek returns the next character that is not a space, 0 at the end
+func (p *parser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.peek()
+	p.pos++
+	return c
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
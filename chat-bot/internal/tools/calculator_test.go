@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       float64
+		err        string
+	}{
+		{expression: "1 + 2 * 3", want: 7},
+		{expression: "(1 + 2) * 3", want: 9},
+		{expression: "10 - 4 - 3", want: 3},
+		{expression: "2 ^ 10", want: 1024},
+		{expression: "2^3^2", want: 512},
+		{expression: "-2^2", want: -4},
+		{expression: "2^-1", want: 0.5},
+		{expression: "--3", want: 3},
+		{expression: "1.5e3", want: 1500},
+		{expression: "2.5E-2 * 4", want: 0.1},
+		{expression: ".5 + .25", want: 0.75},
+		{expression: "7 % 3", want: 1},
+		{expression: "sqrt(16) + abs(-2)", want: 6},
+		{expression: "round(PI * 100)", want: 314},
+		{expression: "ln(e)", want: 1},
+		{expression: "5 / 0", err: "division by zero"},
+		{expression: "5 % 0", err: "division by zero"},
+		{expression: "sqrt 4", err: "sqrt must be followed by parentheses"},
+		{expression: "sqrt", err: "sqrt must be followed by parentheses"},
+		{expression: "foo(1)", err: `unknown name "foo"`},
+		{expression: "(1 + 2", err: "missing closing parenthesis"},
+		{expression: "1 +", err: "unexpected end of expression"},
+		{expression: "1 2", err: `unexpected '2' at position 3`},
+		{expression: "2 $ 3", err: `unexpected '$' at position 3`},
+		{expression: "1..2", err: `invalid number "1..2"`},
+		{expression: "sqrt(-1)", err: "the result is not a finite number"},
+		{expression: "10^400", err: "the result is not a finite number"},
+		{expression: "   ", err: "expression is required"},
+		{expression: strings.Repeat("1+", 500) + "1", err: "expression must be at most 1000 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := Evaluate(tt.expression)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Evaluate(%q) error = %v, want %q", tt.expression, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate(%q): %v", tt.expression, err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package tools holds the Go functions models may call while replying, with
+// the JSON schema of their arguments they are declared to models with.
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/Mahaveer86619/ImaginAI/internal/llm"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// ErrUnknownTool is returned for calls of tools missing from the registry
+var ErrUnknownTool = errors.New("unknown tool")
+
+// Tool is a function models may call
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments
+	Parameters map[string]any
+	// Run returns the result of a call, which is sent back to the model
+	Run func(ctx context.Context, call Invocation) (map[string]any, error)
+}
+
+// Invocation is a call of a tool, made while replying to UserID
+type Invocation struct {
+	UserID string
+	Args   map[string]any
+}
+
+// Settings bound the tool calls of a reply
+type Settings struct {
+	// MaxSteps is the number of replies of the model that may call tools,
+	// the next one being asked to answer without them
+	MaxSteps int
+	// Timeout bounds each call
+	Timeout time.Duration
+}
+
+// SettingsFromEnv reads TOOLS_MAX_STEPS (default 5) and TOOLS_TIMEOUT (default 10s)
+func SettingsFromEnv() Settings {
+	return Settings{
+		MaxSteps: config.GetEnvInt("TOOLS_MAX_STEPS", 5),
+		Timeout:  config.GetEnvDuration("TOOLS_TIMEOUT", 10*time.Second),
+	}
+}
+
+// Registry holds the tools by name
+type Registry struct {
+	tools map[string]*Tool
+	names []string
+}
+
+// NewRegistry returns a registry of tools
+func NewRegistry(tools ...*Tool) *Registry {
+	r := &Registry{tools: map[string]*Tool{}}
+	for _, tool := range tools {
+		r.Register(tool)
+	}
+	return r
+}
+
+// Register adds tool, replacing the tool of the same name
+func (r *Registry) Register(tool *Tool) {
+	if _, ok := r.tools[tool.Name]; !ok {
+		r.names = append(r.names, tool.Name)
+		slices.Sort(r.names)
+	}
+	r.tools[tool.Name] = tool
+}
+
+// Get returns the tool name, or nil
+func (r *Registry) Get(name string) *Tool {
+	return r.tools[name]
+}
+
+// List describes the tools, by name
+func (r *Registry) List() []models.ToolInfo {
+	list := make([]models.ToolInfo, 0, len(r.names))
+	for _, name := range r.names {
+		tool := r.tools[name]
+		list = append(list, models.ToolInfo{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters})
+	}
+	return list
+}
+
+// Declarations returns the declarations of the tools names, which must be registered
+func (r *Registry) Declarations(names []string) ([]llm.Tool, error) {
+	declarations := make([]llm.Tool, 0, len(names))
+	for _, name := range names {
+		tool := r.tools[name]
+		if tool == nil {
+			return nil, fmt.Errorf("%w %s", ErrUnknownTool, name)
+		}
+		declarations = append(declarations, llm.Tool{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters})
+	}
+	return declarations, nil
+}
+
+// Call runs the tool called by call for userID, for at most timeout
+func (r *Registry) Call(ctx context.Context, timeout time.Duration, userID string, call llm.ToolCall) (map[string]any, error) {
+	tool := r.tools[call.Name]
+	if tool == nil {
+		return nil, fmt.Errorf("%w %s", ErrUnknownTool, call.Name)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	args := call.Args
+	if args == nil {
+		args = map[string]any{}
+	}
+	result, err := tool.Run(ctx, Invocation{UserID: userID, Args: args})
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s did not finish within %s", call.Name, timeout)
+	}
+	return result, err
+}
+
+// stringArg returns the string argument name, "" when it is missing
+func stringArg(args map[string]any, name string) (string, error) {
+	value, ok := args[name]
+	if !ok || value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+	return s, nil
+}
+
+// numberArg returns the number argument name, which is required
+func numberArg(args map[string]any, name string) (float64, error) {
+	switch value := args[name].(type) {
+	case float64:
+		return value, nil
+	case int:
+		return float64(value), nil
+	case nil:
+		return 0, fmt.Errorf("%s is required", name)
+	default:
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+}
+
+// Builtin returns a registry of the built-in tools, searching conversations with searcher
+func Builtin(searcher Searcher) *Registry {
+	return NewRegistry(CurrentTime(), Calculator(), UnitConverter(), ConversationSearch(searcher))
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/conversations"
+)
+
+const (
+	// defaultSearchLimit is the number of matches returned by default
+	defaultSearchLimit = 5
+	// maxSearchLimit is the largest number of matches returned
+	maxSearchLimit = 20
+)
+
+// Searcher searches the messages of a user's conversations
+type Searcher interface {
+	Search(ctx context.Context, userID string, query string, limit int) ([]conversations.Match, error)
+}
+
+// ConversationSearch searches the past conversations of the user the model
+// replies to
+func ConversationSearch(searcher Searcher) *Tool {
+	return &Tool{
+		Name:        "search_conversations",
+		Description: "Searches the user's past conversations for messages containing a text, most recent first. Use it when the user refers to something discussed before.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{"type": "string", "description": "Text the messages contain, matched case-insensitively"},
+				"limit": map[string]any{"type": "integer", "description": "Number of messages to return, 5 by default and at most 20"},
+			},
+			"required": []string{"query"},
+		},
+		Run: func(ctx context.Context, call Invocation) (map[string]any, error) {
+			query, err := stringArg(call.Args, "query")
+			if err != nil {
+				return nil, err
+			}
+			query = strings.TrimSpace(query)
+			if query == "" {
+				return nil, errors.New("query is required")
+			}
+
+			limit := defaultSearchLimit
+			if _, ok := call.Args["limit"]; ok {
+				n, err := numberArg(call.Args, "limit")
+				if err != nil {
+					return nil, err
+				}
+				limit = min(max(int(n), 1), maxSearchLimit)
+			}
+
+			matches, err := searcher.Search(ctx, call.UserID, query, limit)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"query": query, "matches": matches}, nil
+		},
+	}
+}
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	// Embeds the time zone database, which slim images lack
+	_ "time/tzdata"
+)
+
+// CurrentTime tells the date and time in a time zone
+func CurrentTime() *Tool {
+	return &Tool{
+		Name:        "current_time",
+		Description: "Returns the current date and time. Use it for questions about today, the time or dates relative to now.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"timezone": map[string]any{
+					"type":        "string",
+					"description": "IANA time zone such as Europe/Paris or America/New_York, UTC when omitted",
+				},
+			},
+		},
+		Run: func(ctx context.Context, call Invocation) (map[string]any, error) {
+			name, err := stringArg(call.Args, "timezone")
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				name = "UTC"
+			}
+			location, err := time.LoadLocation(name)
+			if err != nil {
+				return nil, fmt.Errorf("unknown time zone %q", name)
+			}
+
+			now := time.Now().In(location)
+			return map[string]any{
+				"time":     now.Format(time.RFC3339),
+				"timezone": location.String(),
+				"weekday":  now.Weekday().String(),
+				"unix":     now.Unix(),
+			}, nil
+		},
+	}
+}
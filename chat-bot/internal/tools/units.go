@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// unit is a unit of a quantity, with its size in the base unit of the quantity
+type unit struct {
+	quantity string
+	factor   float64
+}
+
+// units maps the names of the units, lowercase, to their size. Temperatures
+// are converted apart, their scales not sharing their zero.
+var units = map[string]unit{
+	// Length, in meters
+	"mm": {"length", 0.001}, "cm": {"length", 0.01}, "m": {"length", 1}, "km": {"length", 1000},
+	"in": {"length", 0.0254}, "ft": {"length", 0.3048}, "yd": {"length", 0.9144},
+	"mi": {"length", 1609.344}, "nmi": {"length", 1852},
+	// Mass, in kilograms
+	"mg": {"mass", 1e-6}, "g": {"mass", 0.001}, "kg": {"mass", 1}, "t": {"mass", 1000},
+	"oz": {"mass", 0.028349523125}, "lb": {"mass", 0.45359237}, "st": {"mass", 6.35029318},
+	// Volume, in liters, with US customary units
+	"ml": {"volume", 0.001}, "l": {"volume", 1}, "m3": {"volume", 1000},
+	"tsp": {"volume", 0.00492892159375}, "tbsp": {"volume", 0.01478676478125}, "floz": {"volume", 0.0295735295625},
+	"cup": {"volume", 0.2365882365}, "pt": {"volume", 0.473176473}, "qt": {"volume", 0.946352946}, "gal": {"volume", 3.785411784},
+	// Area, in square meters
+	"mm2": {"area", 1e-6}, "cm2": {"area", 1e-4}, "m2": {"area", 1}, "km2": {"area", 1e6}, "ha": {"area", 1e4},
+	"in2": {"area", 0.00064516}, "ft2": {"area", 0.09290304}, "acre": {"area", 4046.8564224}, "mi2": {"area", 2589988.110336},
+	// Time, in seconds
+	"ms": {"time", 0.001}, "s": {"time", 1}, "min": {"time", 60}, "h": {"time", 3600}, "d": {"time", 86400}, "wk": {"time", 604800},
+	// Speed, in meters per second
+	"m/s": {"speed", 1}, "km/h": {"speed", 1 / 3.6}, "mph": {"speed", 0.44704}, "kn": {"speed", 1852.0 / 3600},
+	// Data, in bytes
+	"bit": {"data", 0.125}, "byte": {"data", 1},
+	"kb": {"data", 1e3}, "mb": {"data", 1e6}, "gb": {"data", 1e9}, "tb": {"data", 1e12},
+	"kib": {"data", 1 << 10}, "mib": {"data", 1 << 20}, "gib": {"data", 1 << 30}, "tib": {"data", 1 << 40},
+}
+
+// temperatures converts the temperature scales to and from kelvins
+var temperatures = map[string]struct{ toKelvin, fromKelvin func(float64) float64 }{
+	"c": {func(v float64) float64 { return v + 273.15 }, func(k float64) float64 { return k - 273.15 }},
+	"f": {func(v float64) float64 { return (v-32)*5/9 + 273.15 }, func(k float64) float64 { return (k-273.15)*9/5 + 32 }},
+	"k": {func(v float64) float64 { return v }, func(k float64) float64 { return k }},
+}
+
+// UnitConverter converts values between units of the same quantity
+func UnitConverter() *Tool {
+	return &Tool{
+		Name: "convert_units",
+		Description: "Converts a value between units of length (mm, cm, m, km, in, ft, yd, mi, nmi), mass (mg, g, kg, t, oz, lb, st), " +
+			"volume (ml, l, m3, tsp, tbsp, floz, cup, pt, qt, gal in US units), area (mm2, cm2, m2, km2, ha, in2, ft2, acre, mi2), " +
+			"time (ms, s, min, h, d, wk), speed (m/s, km/h, mph, kn), data (bit, byte, kb, mb, gb, tb, kib, mib, gib, tib) " +
+			"or temperature (c, f, k).",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"value": map[string]any{"type": "number"},
+				"from":  map[string]any{"type": "string", "description": "Unit of the value"},
+				"to":    map[string]any{"type": "string", "description": "Unit to convert to"},
+			},
+			"required": []string{"value", "from", "to"},
+		},
+		Run: func(ctx context.Context, call Invocation) (map[string]any, error) {
+			value, err := numberArg(call.Args, "value")
+			if err != nil {
+				return nil, err
+			}
+			from, err := stringArg(call.Args, "from")
+			if err != nil {
+				return nil, err
+			}
+			to, err := stringArg(call.Args, "to")
+			if err != nil {
+				return nil, err
+			}
+
+			result, err := ConvertUnits(value, from, to)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"value": value, "from": from, "to": to, "result": result}, nil
+		},
+	}
+}
+
+// ConvertUnits converts value from a unit to another of the same quantity
+func ConvertUnits(value float64, from string, to string) (float64, error) {
+	from, to = normalizeUnit(from), normalizeUnit(to)
+
+	fromScale, fromTemperature := temperatures[from]
+	toScale, toTemperature := temperatures[to]
+	if fromTemperature || toTemperature {
+		if !fromTemperature || !toTemperature {
+			return 0, fmt.Errorf("cannot convert between %s and %s", from, to)
+		}
+		return toScale.fromKelvin(fromScale.toKelvin(value)), nil
+	}
+
+	fromUnit, ok := units[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	toUnit, ok := units[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if fromUnit.quantity != toUnit.quantity {
+		return 0, fmt.Errorf("cannot convert %s, a unit of %s, to %s, a unit of %s", from, fromUnit.quantity, to, toUnit.quantity)
+	}
+	return value * fromUnit.factor / toUnit.factor, nil
+}
+
+// normalizeUnit lowercases unit and strips the degree sign of temperatures
+func normalizeUnit(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	return strings.TrimPrefix(unit, "°")
+}
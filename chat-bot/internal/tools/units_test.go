@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+		err      string
+	}{
+		{value: 1, from: "km", to: "m", want: 1000},
+		{value: 1, from: "mi", to: "km", want: 1.609344},
+		{value: 12, from: "in", to: "ft", want: 1},
+		{value: 1, from: "lb", to: "g", want: 453.59237},
+		{value: 1, from: "gal", to: "l", want: 3.785411784},
+		{value: 1, from: "ha", to: "m2", want: 10000},
+		{value: 90, from: "min", to: "h", want: 1.5},
+		{value: 36, from: "km/h", to: "m/s", want: 10},
+		{value: 1, from: "gib", to: "mib", want: 1024},
+		{value: 8, from: "bit", to: "byte", want: 1},
+		{value: 100, from: "c", to: "f", want: 212},
+		{value: -40, from: "f", to: "c", want: -40},
+		{value: 0, from: "k", to: "c", want: -273.15},
+		// Units are case insensitive, and temperatures may carry a degree sign
+		{value: 2, from: "KM", to: "M", want: 2000},
+		{value: 1, from: "GB", to: "Mb", want: 1000},
+		{value: 72, from: "KM/H", to: "m/S", want: 20},
+		{value: 0, from: " °C ", to: "°F", want: 32},
+		{value: 1, from: "kg", to: "m", err: "cannot convert kg, a unit of mass, to m, a unit of length"},
+		{value: 1, from: "c", to: "m", err: "cannot convert between c and m"},
+		{value: 1, from: "kg", to: "k", err: "cannot convert between kg and k"},
+		{value: 1, from: "furlong", to: "m", err: `unknown unit "furlong"`},
+		{value: 1, from: "m", to: "parsec", err: `unknown unit "parsec"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			got, err := ConvertUnits(tt.value, tt.from, tt.to)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("ConvertUnits(%v, %q, %q) error = %v, want %q", tt.value, tt.from, tt.to, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertUnits(%v, %q, %q): %v", tt.value, tt.from, tt.to, err)
+			}
+			if math.Abs(got-tt.want) > 1e-9*math.Max(1, math.Abs(tt.want)) {
+				t.Errorf("ConvertUnits(%v, %q, %q) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Package uploads reads the files sent with chat messages, either inline in
+// the JSON request or as multipart/form-data uploads, and enforces the limits
+// on their size and type.
+package uploads
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/config"
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTooLarge is returned for files, requests or numbers of files over the limits
+	ErrTooLarge = errors.New("upload too large")
+	// ErrUnsupportedType is returned for files of a type that is not allowed
+	ErrUnsupportedType = errors.New("unsupported file type")
+	// ErrInvalid is returned for malformed uploads and parts
+	ErrInvalid = errors.New("invalid upload")
+)
+
+// RequestField is the multipart field holding the JSON chat request
+const RequestField = "request"
+
+// defaultAllowedTypes are the images, audio and documents Gemini accepts inline
+var defaultAllowedTypes = []string{
+	"image/png", "image/jpeg", "image/webp", "image/heic", "image/heif",
+	"audio/wav", "audio/mp3", "audio/mpeg", "audio/aiff", "audio/aac", "audio/ogg", "audio/flac",
+	"application/pdf", "text/plain",
+}
+
+// Limits bound the files of a chat message
+type Limits struct {
+	// MaxFileSize is the size of the largest file, in bytes
+	MaxFileSize int64
+	// MaxRequestSize is the size of the largest request body, files included
+	MaxRequestSize int64
+	// MaxFiles is the number of files of a message
+	MaxFiles int
+	// AllowedTypes are the MIME types files may have
+	AllowedTypes []string
+}
+
+// LimitsFromEnv reads UPLOAD_MAX_FILE_SIZE (default 10 MiB),
+// UPLOAD_MAX_REQUEST_SIZE (default 20 MiB), UPLOAD_MAX_FILES (default 10) and
+// UPLOAD_ALLOWED_TYPES (default images, audio, PDF and plain text)
+func LimitsFromEnv() Limits {
+	return Limits{
+		MaxFileSize:    config.GetEnvInt64("UPLOAD_MAX_FILE_SIZE", 10<<20),
+		MaxRequestSize: config.GetEnvInt64("UPLOAD_MAX_REQUEST_SIZE", 20<<20),
+		MaxFiles:       config.GetEnvInt("UPLOAD_MAX_FILES", 10),
+		AllowedTypes:   config.GetEnvList("UPLOAD_ALLOWED_TYPES", defaultAllowedTypes),
+	}
+}
+
+// Allows reports whether files of mimeType may be sent
+func (l Limits) Allows(mimeType string) bool {
+	return slices.Contains(l.AllowedTypes, mimeType)
+}
+
+// File is a file uploaded with a multipart request
+type File struct {
+	// Field is the name of the multipart field
+	Field    string
+	Name     string
+	MIMEType string
+	Data     []byte
+}
+
+// IsMultipart reports whether r is a multipart/form-data request
+func IsMultipart(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "multipart/form-data"
+}
+
+// ReadMultipart decodes the chat request of the request field of a
+// multipart/form-data body into req, and returns the files of the other
+// fields. The body should be limited by http.MaxBytesReader.
+func ReadMultipart(r *http.Request, limits Limits, req *models.ChatRequest) ([]File, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+
+	var files []File
+	var decoded bool
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, readError(err)
+		}
+
+		if part.FormName() == RequestField && part.FileName() == "" {
+			if decoded {
+				return nil, fmt.Errorf("%w: the %s field is sent more than once", ErrInvalid, RequestField)
+			}
+			if err := json.NewDecoder(part).Decode(req); err != nil {
+				if e := readError(err); errors.Is(e, ErrTooLarge) {
+					return nil, e
+				}
+				return nil, fmt.Errorf("%w: the %s field must be a JSON chat request", ErrInvalid, RequestField)
+			}
+			decoded = true
+			continue
+		}
+
+		if part.FormName() == "" {
+			return nil, fmt.Errorf("%w: every field must be named", ErrInvalid)
+		}
+		// Parts refer to files by field, so each field holds a single file
+		if slices.ContainsFunc(files, func(f File) bool { return f.Field == part.FormName() }) {
+			return nil, fmt.Errorf("%w: the %s field is sent more than once", ErrInvalid, part.FormName())
+		}
+		if len(files) == limits.MaxFiles {
+			return nil, fmt.Errorf("%w: at most %d files may be sent", ErrTooLarge, limits.MaxFiles)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, limits.MaxFileSize+1))
+		if err != nil {
+			return nil, readError(err)
+		}
+		name := part.FileName()
+		if name == "" {
+			name = part.FormName()
+		}
+		if int64(len(data)) > limits.MaxFileSize {
+			return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrTooLarge, name, limits.MaxFileSize)
+		}
+
+		files = append(files, File{
+			Field:    part.FormName(),
+			Name:     name,
+			MIMEType: part.Header.Get("Content-Type"),
+			Data:     data,
+		})
+	}
+
+	if !decoded {
+		return nil, fmt.Errorf("%w: the %s field is required", ErrInvalid, RequestField)
+	}
+	return files, nil
+}
+
+// readError reports bodies over http.MaxBytesReader's limit as ErrTooLarge
+func readError(err error) error {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return fmt.Errorf("%w: requests must be at most %d bytes", ErrTooLarge, maxBytes.Limit)
+	}
+	return fmt.Errorf("%w: %s", ErrInvalid, err)
+}
+
+// Resolve returns the parts of the message of req: its message as a first
+// text part, then its parts with the uploaded files they refer to. Uploaded
+// files no part refers to are appended. Files are returned as PartFile parts
+// holding their data, with a new AttachmentID.
+func Resolve(req *models.ChatRequest, files []File, limits Limits) ([]models.MessagePart, error) {
+	var parts []models.MessagePart
+	if req.Message != "" {
+		parts = append(parts, models.MessagePart{Type: models.PartText, Text: req.Message})
+	}
+
+	used := make([]bool, len(files))
+	for i, part := range req.Parts {
+		switch part.Type {
+		case models.PartText:
+			if part.Text == "" {
+				continue
+			}
+			parts = append(parts, models.MessagePart{Type: models.PartText, Text: part.Text})
+			continue
+		case models.PartInlineData:
+			if len(part.Data) == 0 {
+				return nil, fmt.Errorf("%w: parts[%d] has no data", ErrInvalid, i)
+			}
+		case models.PartFile:
+			j := slices.IndexFunc(files, func(f File) bool { return f.Field == part.File })
+			if part.File == "" || j < 0 {
+				return nil, fmt.Errorf("%w: parts[%d] does not name an uploaded file", ErrInvalid, i)
+			}
+			if used[j] {
+				return nil, fmt.Errorf("%w: file %s is referred to more than once", ErrInvalid, part.File)
+			}
+			used[j] = true
+			part.Data = files[j].Data
+			part.Name = cmp.Or(part.Name, files[j].Name)
+			part.MIMEType = cmp.Or(part.MIMEType, files[j].MIMEType)
+		default:
+			return nil, fmt.Errorf("%w: parts[%d] has unknown type %q", ErrInvalid, i, part.Type)
+		}
+
+		file, err := newFilePart(part.Name, part.MIMEType, part.Data, limits)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, file)
+	}
+
+	for j, f := range files {
+		if used[j] {
+			continue
+		}
+		file, err := newFilePart(f.Name, f.MIMEType, f.Data, limits)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, file)
+	}
+
+	if count := len(parts) - countText(parts); count > limits.MaxFiles {
+		return nil, fmt.Errorf("%w: at most %d files may be sent", ErrTooLarge, limits.MaxFiles)
+	}
+	return parts, nil
+}
+
+// newFilePart checks a file against limits and returns its part. Files sent
+// without a type, or as application/octet-stream, are typed by their content.
+func newFilePart(name string, mimeType string, data []byte, limits Limits) (models.MessagePart, error) {
+	if name == "" {
+		name = "file"
+	}
+	if int64(len(data)) > limits.MaxFileSize {
+		return models.MessagePart{}, fmt.Errorf("%w: %s is larger than %d bytes", ErrTooLarge, name, limits.MaxFileSize)
+	}
+
+	mimeType = MediaType(mimeType)
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = MediaType(http.DetectContentType(data))
+	}
+	if !limits.Allows(mimeType) {
+		return models.MessagePart{}, fmt.Errorf("%w: %s is %s, files must be one of %s", ErrUnsupportedType, name, mimeType, strings.Join(limits.AllowedTypes, ", "))
+	}
+
+	return models.MessagePart{
+		Type:         models.PartFile,
+		MIMEType:     mimeType,
+		Data:         data,
+		Name:         name,
+		Size:         len(data),
+		AttachmentID: uuid.NewString(),
+	}, nil
+}
+
+// MediaType returns the lowercase media type of a MIME type, without parameters
+func MediaType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// Capability returns the model capability needed to read files of mimeType:
+// "vision" for images, "audio", or "documents" for anything else
+func Capability(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "vision"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	default:
+		return "documents"
+	}
+}
+
+func countText(parts []models.MessagePart) int {
+	count := 0
+	for _, part := range parts {
+		if part.Type == models.PartText {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,323 @@
+package uploads
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/models"
+)
+
+// png is the start of a PNG image, enough for http.DetectContentType
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+var testLimits = Limits{
+	MaxFileSize:    64,
+	MaxRequestSize: 1 << 10,
+	MaxFiles:       2,
+	AllowedTypes:   []string{"image/png", "text/plain"},
+}
+
+// field is a part of a multipart body. Parts with a file name are sent as
+// files, with contentType or application/octet-stream.
+type field struct {
+	name        string
+	fileName    string
+	contentType string
+	data        []byte
+}
+
+// multipartRequest returns a multipart/form-data request of fields, whose
+// body is limited to limits.MaxRequestSize
+func multipartRequest(t *testing.T, fields ...field) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range fields {
+		var w io.Writer
+		var err error
+		switch {
+		case f.fileName != "" && f.contentType != "":
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", `form-data; name="`+f.name+`"; filename="`+f.fileName+`"`)
+			header.Set("Content-Type", f.contentType)
+			w, err = mw.CreatePart(header)
+		case f.fileName != "":
+			w, err = mw.CreateFormFile(f.name, f.fileName)
+		default:
+			w, err = mw.CreateFormField(f.name)
+		}
+		if err != nil {
+			t.Fatalf("writing %s field: %v", f.name, err)
+		}
+		w.Write(f.data)
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/chat", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, testLimits.MaxRequestSize)
+	return r
+}
+
+func request(json string) field {
+	return field{name: RequestField, data: []byte(json)}
+}
+
+func TestReadMultipart(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []field
+		files  int
+		err    error
+	}{
+		{
+			name:   "request and files",
+			fields: []field{request(`{"message":"What is this?"}`), {name: "photo", fileName: "cat.png", data: png}, {name: "notes", fileName: "notes.txt", contentType: "text/plain", data: []byte("hello")}},
+			files:  2,
+		},
+		{
+			name:   "file at the size limit",
+			fields: []field{request(`{"message":"Hi"}`), {name: "notes", fileName: "notes.txt", data: bytes.Repeat([]byte("a"), 64)}},
+			files:  1,
+		},
+		{
+			name:   "file one byte over the size limit",
+			fields: []field{request(`{"message":"Hi"}`), {name: "notes", fileName: "notes.txt", data: bytes.Repeat([]byte("a"), 65)}},
+			err:    ErrTooLarge,
+		},
+		{
+			name:   "request over the size limit",
+			fields: []field{request(`{"message":"` + strings.Repeat("a", 1<<10) + `"}`)},
+			err:    ErrTooLarge,
+		},
+		{
+			name:   "too many files",
+			fields: []field{request(`{"message":"Hi"}`), {name: "a", fileName: "a.png", data: png}, {name: "b", fileName: "b.png", data: png}, {name: "c", fileName: "c.png", data: png}},
+			err:    ErrTooLarge,
+		},
+		{
+			name:   "request field given twice",
+			fields: []field{request(`{"message":"Hi"}`), request(`{"message":"Hi again"}`)},
+			err:    ErrInvalid,
+		},
+		{
+			name:   "file field given twice",
+			fields: []field{request(`{"message":"Hi"}`), {name: "photo", fileName: "a.png", data: png}, {name: "photo", fileName: "b.png", data: png}},
+			err:    ErrInvalid,
+		},
+		{
+			name:   "missing request field",
+			fields: []field{{name: "photo", fileName: "cat.png", data: png}},
+			err:    ErrInvalid,
+		},
+		{
+			name:   "request field that is not JSON",
+			fields: []field{request(`message=Hi`)},
+			err:    ErrInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req models.ChatRequest
+			files, err := ReadMultipart(multipartRequest(t, tt.fields...), testLimits, &req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if len(files) != tt.files {
+				t.Errorf("read %d files, want %d", len(files), tt.files)
+			}
+		})
+	}
+}
+
+func TestReadMultipartFiles(t *testing.T) {
+	var req models.ChatRequest
+	files, err := ReadMultipart(multipartRequest(t,
+		request(`{"message":"What is this?","model":"gemini-2.5-flash"}`),
+		field{name: "photo", fileName: "cat.png", data: png},
+		field{name: "notes", data: []byte("hello")},
+	), testLimits, &req)
+	if err != nil {
+		t.Fatalf("ReadMultipart: %v", err)
+	}
+
+	if req.Message != "What is this?" || req.Model != "gemini-2.5-flash" {
+		t.Errorf("request = %+v, want the request field", req)
+	}
+	want := []File{
+		{Field: "photo", Name: "cat.png", MIMEType: "application/octet-stream", Data: png},
+		{Field: "notes", Name: "notes", Data: []byte("hello")},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("read %d files, want %d", len(files), len(want))
+	}
+	for i, f := range files {
+		if f.Field != want[i].Field || f.Name != want[i].Name || f.MIMEType != want[i].MIMEType || !bytes.Equal(f.Data, want[i].Data) {
+			t.Errorf("files[%d] = %s %s %s, want %s %s %s", i, f.Field, f.Name, f.MIMEType, want[i].Field, want[i].Name, want[i].MIMEType)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	photo := File{Field: "photo", Name: "cat.png", MIMEType: "application/octet-stream", Data: png}
+	notes := File{Field: "notes", Name: "notes.txt", MIMEType: "text/plain; charset=utf-8", Data: []byte("hello")}
+
+	tests := []struct {
+		name  string
+		req   models.ChatRequest
+		files []File
+		types []string
+		err   error
+	}{
+		{
+			name:  "message only",
+			req:   models.ChatRequest{Message: "Hi"},
+			types: []string{"text"},
+		},
+		{
+			name: "parts referring to files",
+			req: models.ChatRequest{Message: "Compare", Parts: []models.MessagePart{
+				{Type: models.PartFile, File: "notes"},
+				{Type: models.PartText, Text: "with"},
+				{Type: models.PartFile, File: "photo"},
+			}},
+			files: []File{photo, notes},
+			types: []string{"text", "text/plain", "text", "image/png"},
+		},
+		{
+			name:  "files no part refers to",
+			req:   models.ChatRequest{Message: "What is this?"},
+			files: []File{photo},
+			types: []string{"text", "image/png"},
+		},
+		{
+			name: "inline data",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: models.PartInlineData, MIMEType: "image/png", Data: png},
+			}},
+			types: []string{"image/png"},
+		},
+		{
+			name: "file referred to twice",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: models.PartFile, File: "photo"},
+				{Type: models.PartFile, File: "photo"},
+			}},
+			files: []File{photo},
+			err:   ErrInvalid,
+		},
+		{
+			name: "dangling file reference",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: models.PartFile, File: "missing"},
+			}},
+			files: []File{photo},
+			err:   ErrInvalid,
+		},
+		{
+			name: "file reference without a field",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: models.PartFile},
+			}},
+			err: ErrInvalid,
+		},
+		{
+			name: "inline data without data",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: models.PartInlineData, MIMEType: "image/png"},
+			}},
+			err: ErrInvalid,
+		},
+		{
+			name: "unknown part type",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: "video"},
+			}},
+			err: ErrInvalid,
+		},
+		{
+			name: "too many files with inline data",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: models.PartInlineData, MIMEType: "image/png", Data: png},
+			}},
+			files: []File{photo, notes},
+			err:   ErrTooLarge,
+		},
+		{
+			name: "inline data one byte over the size limit",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: models.PartInlineData, MIMEType: "text/plain", Data: bytes.Repeat([]byte("a"), 65)},
+			}},
+			err: ErrTooLarge,
+		},
+		{
+			name: "type that is not allowed",
+			req: models.ChatRequest{Parts: []models.MessagePart{
+				{Type: models.PartInlineData, MIMEType: "application/pdf", Data: []byte("%PDF-1.7")},
+			}},
+			err: ErrUnsupportedType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := Resolve(&tt.req, tt.files, testLimits)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			var types []string
+			for _, part := range parts {
+				if part.Type == models.PartText {
+					types = append(types, "text")
+					continue
+				}
+				types = append(types, part.MIMEType)
+				if part.Type != models.PartFile || part.AttachmentID == "" || part.Size != len(part.Data) {
+					t.Errorf("part %s = %s with attachment %q and size %d, want a file part", part.Name, part.Type, part.AttachmentID, part.Size)
+				}
+			}
+			if strings.Join(types, ",") != strings.Join(tt.types, ",") {
+				t.Errorf("parts = %v, want %v", types, tt.types)
+			}
+		})
+	}
+}
+
+func TestNewFilePartType(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+		want     string
+		err      error
+	}{
+		{name: "declared type", mimeType: "image/png", data: png, want: "image/png"},
+		{name: "type with parameters", mimeType: "Text/Plain; charset=utf-8", data: []byte("hello"), want: "text/plain"},
+		{name: "octet-stream detected by content", mimeType: "application/octet-stream", data: png, want: "image/png"},
+		{name: "missing type detected by content", data: []byte("hello"), want: "text/plain"},
+		{name: "octet-stream of unknown content", mimeType: "application/octet-stream", data: []byte{0, 1, 2, 3}, err: ErrUnsupportedType},
+		{name: "declared type that is not allowed", mimeType: "image/gif", data: png, err: ErrUnsupportedType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			part, err := newFilePart("upload", tt.mimeType, tt.data, testLimits)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if part.MIMEType != tt.want {
+				t.Errorf("type = %q, want %q", part.MIMEType, tt.want)
+			}
+		})
+	}
+}
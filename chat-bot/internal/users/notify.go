@@ -0,0 +1,46 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// KeyChangedChannel is notified by the server with a user id whenever that
+// user's Gemini API key changes or the user is deleted
+const KeyChangedChannel = "gemini_api_key_changed"
+
+// ListenKeyChanges calls onChange with the id of every user whose Gemini API
+// key changed, until ctx is done. Notifications sent while the connection was
+// down are lost, so onReset is called after every reconnection.
+func ListenKeyChanges(ctx context.Context, dsn string, onChange func(userID string), onReset func()) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logrus.WithError(err).Warn("Gemini API key change listener connection error")
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(KeyChangedChannel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification := <-listener.Notify:
+			// A nil notification means the connection was re-established
+			if notification == nil {
+				onReset()
+				continue
+			}
+			onChange(notification.Extra)
+		case <-time.After(90 * time.Second):
+			// Detect dead connections that would otherwise go unnoticed
+			go listener.Ping()
+		}
+	}
+}
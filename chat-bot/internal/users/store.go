@@ -0,0 +1,91 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Mahaveer86619/ImaginAI/internal/secrets"
+)
+
+// ErrNotFound is returned when the user does not exist
+var ErrNotFound = errors.New("user not found")
+
+// Store reads the users table owned by the server
+type Store struct {
+	DB *sql.DB
+}
+
+// TokenState is what decides whether a user's tokens are still accepted
+type TokenState struct {
+	TokenVersion int
+	Disabled     bool
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+// TokenState returns the token version and disabled flag of a user
+func (s *Store) TokenState(ctx context.Context, userID string) (*TokenState, error) {
+	query := `SELECT token_version, disabled_at IS NOT NULL FROM users WHERE id = $1`
+
+	var state TokenState
+	err := s.DB.QueryRowContext(ctx, query, userID).Scan(&state.TokenVersion, &state.Disabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error querying user: %w", err)
+	}
+
+	return &state, nil
+}
+
+// ChatSettings are the profile settings that decide which model a user chats with
+type ChatSettings struct {
+	Plan         string
+	DefaultModel string
+}
+
+// ChatSettings returns the plan and default model of a user
+func (s *Store) ChatSettings(ctx context.Context, userID string) (*ChatSettings, error) {
+	query := `SELECT plan, default_model FROM users WHERE id = $1`
+
+	var settings ChatSettings
+	err := s.DB.QueryRowContext(ctx, query, userID).Scan(&settings.Plan, &settings.DefaultModel)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error querying user: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// GeminiAPIKey returns the decrypted Gemini API key of a user, empty when they have none
+func (s *Store) GeminiAPIKey(ctx context.Context, userID string) (string, error) {
+	query := `SELECT COALESCE(gemini_api_key, '') FROM users WHERE id = $1`
+
+	var stored string
+	err := s.DB.QueryRowContext(ctx, query, userID).Scan(&stored)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("error querying user: %w", err)
+	}
+
+	keyring, err := secrets.GetKeyring()
+	if err != nil {
+		return "", err
+	}
+	key, err := keyring.DecryptSecret(stored)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting gemini api key: %w", err)
+	}
+
+	return key, nil
+}
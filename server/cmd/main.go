@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
+	cli "github.com/Mahaveer86619/ImaginAI/src/cli"
 	postgres "github.com/Mahaveer86619/ImaginAI/src/database"
+	docs "github.com/Mahaveer86619/ImaginAI/src/docs"
 	handlers "github.com/Mahaveer86619/ImaginAI/src/handlers"
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
 	middleware "github.com/Mahaveer86619/ImaginAI/src/middleware"
+	router "github.com/Mahaveer86619/ImaginAI/src/router"
+	services "github.com/Mahaveer86619/ImaginAI/src/services"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
 
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
@@ -20,7 +29,7 @@ func main() {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.SetLevel(logrus.InfoLevel)
 
-	mux := http.NewServeMux()
+	rt := router.New()
 
 	// Load environment variables
 	err := godotenv.Load(".env")
@@ -49,10 +58,55 @@ func main() {
 
 	postgres.SetDBConnection(db)
 
-	handleFunctions(mux)
+	// Tokens are shared with the chat-bot, so they must never be signed with an empty key
+	if len(middleware.JWTKey()) == 0 {
+		logrus.Fatal("JWT_SECRET must be set")
+	}
+
+	// Fail fast on a malformed ENCRYPTION_KEYS rather than on the first request
+	if _, err := helpers.GetSecretKeyring(); err != nil {
+		logrus.WithError(err).Fatal("Error configuring encryption keys")
+	}
+
+	// Run an admin command instead of the server, e.g. "main admin users list"
+	// or "imaginai-admin users list" through the symlink in the Docker image
+	if args, ok := adminArgs(); ok {
+		code := cli.RunAdmin(args, os.Stdin, os.Stdout, os.Stderr)
+		postgres.CloseDBConnection(db)
+		os.Exit(code)
+	}
+
+	// Configure how emails are delivered
+	mailer, err := services.NewMailerFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error configuring mailer")
+	}
+	services.SetMailer(mailer)
+
+	// Sign outgoing emails when a DKIM key is configured
+	dkimSigner, err := services.NewDKIMSignerFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error configuring DKIM signing")
+	}
+	services.SetDKIMSigner(dkimSigner)
+
+	// Deliver queued emails in the background
+	outboxWorker := services.NewOutboxWorkerFromEnv(db, mailer)
+	go outboxWorker.Run(context.Background())
+
+	// Prune audit events once they are past their retention period
+	auditRetention := services.NewAuditRetentionFromEnv(db)
+	go auditRetention.Run(context.Background())
+
+	handleFunctions(rt)
 
 	// Wrap all routes with the CORS and logging middleware
-	handler := middleware.CORSMiddleware(middleware.LoggingMiddleware(mux))
+	corsPolicy := middleware.LoadCORSPolicy()
+	if err := corsPolicy.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Error configuring CORS")
+	}
+	cors := middleware.CORSMiddleware(corsPolicy)
+	handler := cors(middleware.LoggingMiddleware(rt))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -68,65 +122,56 @@ func main() {
 	}
 }
 
-func handleFunctions(mux *http.ServeMux) {
-	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet && r.Method != http.MethodOptions {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		if r.URL.Path == "/favicon.ico" {
-			return
-		}
-		fmt.Fprint(w, "ImaginAi API is running!")
-	})
-
-	//* Auth routes - POST methods
-	mux.HandleFunc("/api/v1/auth/register", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost && r.Method != http.MethodOptions {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		handlers.RegisterUserController(w, r)
-	})
-
-	mux.HandleFunc("/api/v1/auth/authenticate", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost && r.Method != http.MethodOptions {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		handlers.AuthenticateUserController(w, r)
-	})
-
-	mux.HandleFunc("/api/v1/auth/refresh", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost && r.Method != http.MethodOptions {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		handlers.RefreshTokenController(w, r)
-	})
+// adminArgs returns the arguments of an admin command when the binary was
+// invoked as imaginai-admin or with "admin" as its first argument.
+func adminArgs() ([]string, bool) {
+	if filepath.Base(os.Args[0]) == "imaginai-admin" {
+		return os.Args[1:], true
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		return os.Args[2:], true
+	}
+	return nil, false
+}
 
-	//* User routes - different methods for same path
-	mux.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			handlers.GetUserByIDController(w, r)
-		case http.MethodPut:
-			handlers.UpdateUserController(w, r)
-		case http.MethodDelete:
-			handlers.DeleteUserController(w, r)
-		case http.MethodOptions:
-			return
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
+func handleFunctions(rt *router.Router) {
+	rt.Get("/test", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ImaginAi API is running!")
 	})
 
-	//* Admin routes - GET only
-	mux.HandleFunc("/api/v1/users/all", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet && r.Method != http.MethodOptions {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		handlers.GetAllUsersController(w, r)
-	})
+	//* Docs routes
+	rt.Get("/openapi.json", docs.SpecHandler)
+	rt.Get("/docs", docs.UIHandler)
+
+	//* Auth routes - public, rate limited per client IP
+	auth := rt.Group("/api/v1/auth", middleware.RateLimit(
+		helpers.GetEnvInt("RATE_LIMIT_AUTH_REQUESTS", 20),
+		helpers.GetEnvDuration("RATE_LIMIT_AUTH_WINDOW", time.Minute),
+	))
+	auth.Post("/register", handlers.RegisterUserController)
+	auth.Post("/authenticate", handlers.AuthenticateUserController)
+	auth.Post("/refresh", handlers.RefreshTokenController)
+	auth.Post("/forgot-password", handlers.SendPassResetCodeController)
+	auth.Post("/verify-reset-code", handlers.CheckResetPassCodeController)
+
+	//* User routes - token required, users may only access their own account
+	users := rt.Group("/api/v1/users", middleware.AuthMiddleware)
+	users.Get("/all", handlers.GetAllUsersController, middleware.RequireRole(types.RoleAdmin))
+	users.Get("/me/activity", handlers.ListMyActivityController)
+	users.Get("/{id}", handlers.GetUserByIDController, middleware.RequireSelfOrRole(types.RoleAdmin))
+	users.Put("/{id}", handlers.UpdateUserController, middleware.RequireSelfOrRole(types.RoleAdmin))
+	users.Delete("/{id}", handlers.DeleteUserController, middleware.RequireSelfOrRole(types.RoleAdmin))
+
+	//* Admin routes - token with admin role required
+	admin := rt.Group("/api/v1/admin", middleware.AuthMiddleware, middleware.RequireRole(types.RoleAdmin))
+	admin.Get("/outbox", handlers.ListOutboxMessagesController)
+	admin.Get("/outbox/{id}", handlers.GetOutboxMessageController)
+	admin.Post("/outbox/{id}/retry", handlers.RetryOutboxMessageController)
+	admin.Get("/audit-events", handlers.ListAuditEventsController)
+
+	//* Dev routes - unauthenticated, so only exposed when explicitly enabled
+	if helpers.GetEnvBool("EMAIL_PREVIEW_ENABLED", false) {
+		dev := rt.Group("/api/v1/dev")
+		dev.Get("/emails/{template}", handlers.PreviewEmailController)
+	}
 }
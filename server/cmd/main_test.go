@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	docs "github.com/Mahaveer86619/ImaginAI/src/docs"
+	router "github.com/Mahaveer86619/ImaginAI/src/router"
+)
+
+func TestOpenAPISpecCoversRegisteredRoutes(t *testing.T) {
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(docs.Spec(), &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if spec.OpenAPI != "3.1.0" {
+		t.Errorf("openapi version = %q, want 3.1.0", spec.OpenAPI)
+	}
+
+	// Register the opt-in routes too, they must be documented all the same
+	t.Setenv("EMAIL_PREVIEW_ENABLED", "true")
+	rt := router.New()
+	handleFunctions(rt)
+
+	routes := rt.Routes()
+	if len(routes) == 0 {
+		t.Fatal("no routes were registered")
+	}
+	for _, route := range routes {
+		if _, ok := spec.Paths[route.Pattern][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("route %s %s is registered but missing from openapi.json", route.Method, route.Pattern)
+		}
+	}
+}
+
+func TestEmailPreviewIsOptIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		status int
+	}{
+		{"unset", "", http.StatusNotFound},
+		{"disabled", "false", http.StatusNotFound},
+		{"enabled", "true", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", "development")
+			t.Setenv("EMAIL_PREVIEW_ENABLED", tt.value)
+			handler := router.New()
+			handleFunctions(handler)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/dev/emails/welcome", nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestDocsAreServedWithoutToken(t *testing.T) {
+	handler := router.New()
+	handleFunctions(handler)
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/openapi.json", "application/json", `"openapi": "3.1.0"`},
+		{"/docs", "text/html; charset=utf-8", `url: "/openapi.json"`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: status = %d, want 200", tt.path, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("GET %s: Content-Type = %q, want %q", tt.path, got, tt.contentType)
+		}
+		if !strings.Contains(w.Body.String(), tt.contains) {
+			t.Errorf("GET %s: body does not contain %s", tt.path, tt.contains)
+		}
+	}
+}
+
+func TestOpenAPISpecReferencesResolve(t *testing.T) {
+	var spec interface{}
+	if err := json.Unmarshal(docs.Spec(), &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+
+	var walk func(path string, node interface{})
+	walk = func(path string, node interface{}) {
+		switch node := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := node["$ref"].(string); ok && !resolves(spec, ref) {
+				t.Errorf("%s: $ref %s does not resolve", path, ref)
+			}
+			for key, value := range node {
+				walk(path+"/"+key, value)
+			}
+		case []interface{}:
+			for i, value := range node {
+				walk(fmt.Sprintf("%s/%d", path, i), value)
+			}
+		}
+	}
+	walk("#", spec)
+}
+
+// resolves reports whether ref, a local JSON pointer such as
+// #/components/schemas/User, names a node of spec
+func resolves(spec interface{}, ref string) bool {
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return false
+	}
+	node := spec
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if node, ok = object[token]; !ok {
+			return false
+		}
+	}
+	return true
+}
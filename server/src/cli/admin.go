@@ -0,0 +1,400 @@
+// Package cli implements the imaginai-admin commands used to operate the
+// server from a shell. Every command goes through the same implementations as
+// the HTTP API, against the database configured in the environment.
+package cli
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+
+	impl "github.com/Mahaveer86619/ImaginAI/src/implementations"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+const usage = `Usage: imaginai-admin <command> [flags] [arguments]
+
+Users are identified by id or email address. Passwords are read from the
+first line of -password-file, or from standard input when it is "-", and are
+generated when the flag is not given.
+
+Commands:
+  users list
+  users create -name <name> -email <email> [-password-file <file>] [-role <role>] [-language <language>]
+  users disable <user>
+  users enable <user>
+  users reset-password [-password-file <file>] <user>
+  users grant-role <user> <role>
+  users set-plan <user> <plan>
+  tokens revoke <user>
+  outbox retry <message id>
+  outbox retry -dead
+  keys rotate
+`
+
+// errUsage is returned when a command is called with the wrong arguments
+var errUsage = errors.New("invalid usage")
+
+type command struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	info   *types.RequestInfo
+}
+
+// RunAdmin runs the admin command in args and returns the process exit code
+func RunAdmin(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	c := &command{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		info:   cliRequestInfo(),
+	}
+
+	err := c.run(args)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		fmt.Fprint(stderr, usage)
+		return 2
+	default:
+		printError(stderr, err)
+		return 1
+	}
+}
+
+func (c *command) run(args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	group, name, rest := args[0], args[1], args[2:]
+	switch group + " " + name {
+	case "users list":
+		return c.listUsers(rest)
+	case "users create":
+		return c.createUser(rest)
+	case "users disable":
+		return c.setDisabled(rest, true)
+	case "users enable":
+		return c.setDisabled(rest, false)
+	case "users reset-password":
+		return c.resetPassword(rest)
+	case "users grant-role":
+		return c.grantRole(rest)
+	case "users set-plan":
+		return c.setPlan(rest)
+	case "tokens revoke":
+		return c.revokeTokens(rest)
+	case "outbox retry":
+		return c.retryOutbox(rest)
+	case "keys rotate":
+		return c.rotateKeys(rest)
+	default:
+		return errUsage
+	}
+}
+
+func (c *command) listUsers(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	users, _, err := impl.GetAllUsers()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tNAME\tROLE\tPLAN\tLANGUAGE\tDISABLED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%t\n", u.ID, u.Email, u.Name, u.Role, u.Plan, u.Language, u.Disabled)
+	}
+	return tw.Flush()
+}
+
+func (c *command) createUser(args []string) error {
+	fs := c.flagSet("users create")
+	name := fs.String("name", "", "display name")
+	email := fs.String("email", "", "email address")
+	passwordFile := fs.String("password-file", "", "file holding the password, - for standard input")
+	role := fs.String("role", types.RoleUser, "role to grant")
+	language := fs.String("language", "", "preferred language for emails")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	password, generated, err := c.password(*passwordFile)
+	if err != nil {
+		return err
+	}
+
+	creds := &types.RegisteringCredentials{
+		Name:     *name,
+		Email:    *email,
+		Password: password,
+		Language: *language,
+	}
+	creds.Normalize()
+	if err := creds.Validate(); err != nil {
+		return err
+	}
+
+	created, _, err := impl.RegisterUser(creds, c.info)
+	if err != nil {
+		return err
+	}
+
+	if *role != types.RoleUser {
+		if _, _, err := impl.SetUserRole(created.ID, *role, c.info); err != nil {
+			fmt.Fprintf(c.stderr, "User %s was created but granting the %s role failed\n", created.ID, *role)
+			return err
+		}
+	}
+
+	fmt.Fprintf(c.stdout, "Created user %s (%s) with role %s\n", created.ID, created.Email, *role)
+	if generated {
+		fmt.Fprintf(c.stdout, "Generated password: %s\n", password)
+	}
+	return nil
+}
+
+func (c *command) setDisabled(args []string, disabled bool) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	userID, _, err := impl.ResolveUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	updated, _, err := impl.SetUserDisabled(userID, disabled, c.info)
+	if err != nil {
+		return err
+	}
+
+	state := "enabled"
+	if updated.Disabled {
+		state = "disabled"
+	}
+	fmt.Fprintf(c.stdout, "User %s (%s) is %s\n", updated.ID, updated.Email, state)
+	return nil
+}
+
+func (c *command) resetPassword(args []string) error {
+	fs := c.flagSet("users reset-password")
+	passwordFile := fs.String("password-file", "", "file holding the new password, - for standard input")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	password, generated, err := c.password(*passwordFile)
+	if err != nil {
+		return err
+	}
+
+	userID, _, err := impl.ResolveUserID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if _, err := impl.SetUserPassword(userID, password, c.info); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "Password of user %s was reset and their tokens were revoked\n", userID)
+	if generated {
+		fmt.Fprintf(c.stdout, "Generated password: %s\n", password)
+	}
+	return nil
+}
+
+func (c *command) grantRole(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	userID, _, err := impl.ResolveUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	updated, _, err := impl.SetUserRole(userID, strings.ToLower(args[1]), c.info)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "User %s (%s) now has role %s\n", updated.ID, updated.Email, updated.Role)
+	return nil
+}
+
+func (c *command) setPlan(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	userID, _, err := impl.ResolveUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	updated, _, err := impl.SetUserPlan(userID, strings.ToLower(args[1]), c.info)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "User %s (%s) is now on the %s plan\n", updated.ID, updated.Email, updated.Plan)
+	return nil
+}
+
+func (c *command) revokeTokens(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	userID, _, err := impl.ResolveUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	if _, err := impl.RevokeUserTokens(userID, c.info); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "Revoked every token of user %s\n", userID)
+	return nil
+}
+
+func (c *command) retryOutbox(args []string) error {
+	fs := c.flagSet("outbox retry")
+	dead := fs.Bool("dead", false, "retry every dead-lettered message")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if *dead {
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+		requeued, _, err := impl.RetryDeadOutboxMessages()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Requeued %d dead messages\n", requeued)
+		return nil
+	}
+
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	if err := types.ValidateID(fs.Arg(0)); err != nil {
+		return err
+	}
+
+	message, _, err := impl.RetryOutboxMessage(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "Message %s queued for retry\n", message.ID)
+	return nil
+}
+
+func (c *command) rotateKeys(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	rotated, _, err := impl.RotateEncryptionKeys(c.info)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "Re-encrypted %d Gemini API keys with the primary encryption key\n", rotated)
+	return nil
+}
+
+func (c *command) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// cliRequestInfo identifies the operating system user in audit events written by the CLI
+func cliRequestInfo() *types.RequestInfo {
+	agent := "imaginai-admin"
+	if current, err := user.Current(); err == nil {
+		agent += " (" + current.Username + ")"
+	}
+	return &types.RequestInfo{
+		IP:        "local",
+		UserAgent: agent,
+	}
+}
+
+// password returns the password held in the first line of path, which is read
+// from stdin when it is "-", or a generated one when path is empty. Passwords
+// are never taken as arguments, which end up in shell history and process lists.
+func (c *command) password(path string) (string, bool, error) {
+	if path == "" {
+		password, err := generatePassword()
+		return password, true, err
+	}
+
+	var r io.Reader = c.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", false, fmt.Errorf("error reading password: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false, fmt.Errorf("error reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", false, errors.New("password file is empty")
+	}
+	return password, false, nil
+}
+
+func generatePassword() (string, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("error generating password: %w", err)
+	}
+	// The digit keeps generated passwords valid under the default password policy
+	return base64.RawURLEncoding.EncodeToString(random) + "7", nil
+}
+
+// printError prints the client-safe message of an AppError with its field
+// errors, and the underlying cause since the operator is trusted.
+func printError(w io.Writer, err error) {
+	var appErr *types.AppError
+	if !errors.As(err, &appErr) {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(w, "Error: %s (%s)\n", appErr.Message, appErr.Code)
+	for _, detail := range appErr.Details {
+		fmt.Fprintf(w, "  %s: %s\n", detail.Field, detail.Message)
+	}
+	if appErr.Err != nil {
+		fmt.Fprintf(w, "  cause: %v\n", appErr.Err)
+	}
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+func TestRunAdmin(t *testing.T) {
+	dir := t.TempDir()
+	shortPassword := filepath.Join(dir, "short")
+	if err := os.WriteFile(shortPassword, []byte("short\r\nignored\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyPassword := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyPassword, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every case fails before the database is reached
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		stderr string
+	}{
+		{"no command", nil, "", 2, "Usage: imaginai-admin"},
+		{"unknown command", []string{"users", "delete", "ada@example.com"}, "", 2, "Usage: imaginai-admin"},
+		{"extra argument", []string{"users", "list", "all"}, "", 2, "Usage: imaginai-admin"},
+		{"password as argument", []string{"users", "create", "-name", "Ada", "-email", "ada@example.com", "-password", "secret123"}, "", 2, "flag provided but not defined: -password"},
+		{"reset without user", []string{"users", "reset-password"}, "", 2, "Usage: imaginai-admin"},
+		{"dead retry with an id", []string{"outbox", "retry", "-dead", "42"}, "", 2, "Usage: imaginai-admin"},
+		{
+			"password from stdin",
+			[]string{"users", "create", "-name", "Ada", "-email", "ada@example.com", "-password-file", "-"},
+			"short\n", 1, "password: password must be at least 8 characters long",
+		},
+		{
+			"password from file",
+			[]string{"users", "create", "-name", "Ada", "-email", "ada@example.com", "-password-file", shortPassword},
+			"", 1, "password: password must be at least 8 characters long",
+		},
+		{
+			"missing password file",
+			[]string{"users", "create", "-name", "Ada", "-email", "ada@example.com", "-password-file", filepath.Join(dir, "missing")},
+			"", 1, "Error: error reading password",
+		},
+		{
+			"empty password file",
+			[]string{"users", "reset-password", "-password-file", emptyPassword, "ada@example.com"},
+			"", 1, "Error: password file is empty",
+		},
+		{
+			"empty stdin",
+			[]string{"users", "reset-password", "-password-file", "-", "ada@example.com"},
+			"", 1, "Error: password file is empty",
+		},
+		{
+			"generated password with invalid email",
+			[]string{"users", "create", "-name", "Ada", "-email", "ada"},
+			"", 1, "email: email must be a valid email address",
+		},
+		{"invalid message id", []string{"outbox", "retry", "42"}, "", 1, "id: id must be a valid UUID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := RunAdmin(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d (stderr: %s)", code, tt.code, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.stderr)
+			}
+			if strings.Contains(stderr.String(), "password:") && strings.Contains(tt.stderr, "email:") {
+				t.Errorf("generated password was rejected: %s", stderr.String())
+			}
+			if stdout.Len() != 0 {
+				t.Errorf("stdout = %q, want nothing", stdout.String())
+			}
+		})
+	}
+}
+
+func TestGeneratedPasswordsAreValid(t *testing.T) {
+	seen := map[string]bool{}
+	for range 20 {
+		password, err := generatePassword()
+		if err != nil {
+			t.Fatalf("generatePassword() error = %v", err)
+		}
+		if seen[password] {
+			t.Fatalf("generatePassword() returned %q twice", password)
+		}
+		seen[password] = true
+
+		v := &types.Validator{}
+		v.Password("password", password)
+		if err := v.Err(); err != nil {
+			t.Errorf("generated password %q is invalid: %v", password, err)
+		}
+	}
+}
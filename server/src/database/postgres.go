@@ -62,11 +62,80 @@ func CreateTables(conn *sql.DB) error {
 			created_at TIMESTAMP DEFAULT NOW(),
 			updated_at TIMESTAMP DEFAULT NOW()
 		);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT 'en';`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;`,
+		// The chat-bot checks the models a user may chat with against their plan
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS plan TEXT NOT NULL DEFAULT 'free';`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS default_model TEXT NOT NULL DEFAULT '';`,
 		`CREATE TABLE IF NOT EXISTS forgot_password (
   			id UUID PRIMARY KEY,
   			email TEXT UNIQUE NOT NULL,
   			code TEXT UNIQUE NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS email_outbox (
+			id UUID PRIMARY KEY,
+			recipients TEXT[] NOT NULL,
+			subject TEXT NOT NULL,
+			text_body TEXT NOT NULL DEFAULT '',
+			html_body TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			max_attempts INT NOT NULL DEFAULT 8,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			locked_until TIMESTAMP,
+			sent_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS email_outbox_due_idx ON email_outbox (status, next_attempt_at);`,
+		// Queued emails keep every field of services.Email, so they are sent as built
+		`ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS from_address TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS reply_to TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS attachments JSONB NOT NULL DEFAULT '[]';`,
+		`ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS template TEXT NOT NULL DEFAULT '';`,
+		// Audit events outlive the users they mention, so the ids are not foreign keys
+		`CREATE TABLE IF NOT EXISTS audit_events (
+			id UUID PRIMARY KEY,
+			actor_id UUID,
+			subject_id UUID,
+			action TEXT NOT NULL,
+			ip TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			metadata JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		// Audit times are filtered by instants given with any offset, so they carry a time zone.
+		// Events written before were stored in the session time zone, which the cast reads them in.
+		`DO $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'audit_events' AND column_name = 'created_at' AND data_type = 'timestamp without time zone'
+			) THEN
+				ALTER TABLE audit_events ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at::TIMESTAMPTZ;
+			END IF;
+		END;
+		$$;`,
+		`CREATE INDEX IF NOT EXISTS audit_events_subject_idx ON audit_events (subject_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS audit_events_created_idx ON audit_events (created_at);`,
+		// The audit log is append-only: events may be pruned by retention but never changed
+		`CREATE OR REPLACE FUNCTION audit_events_reject_update() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_events is append-only';
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_events_append_only') THEN
+				CREATE TRIGGER audit_events_append_only BEFORE UPDATE ON audit_events
+				FOR EACH ROW EXECUTE FUNCTION audit_events_reject_update();
+			END IF;
+		END;
+		$$;`,
 	}
 
 	for _, query := range queries {
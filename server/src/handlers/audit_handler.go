@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	impl "github.com/Mahaveer86619/ImaginAI/src/implementations"
+	middleware "github.com/Mahaveer86619/ImaginAI/src/middleware"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+// ListMyActivityController lists the audit events about the authenticated user
+func ListMyActivityController(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(http.StatusUnauthorized)
+		failureResponse.SetError(types.NewAppError(types.ErrCodeAuthMissingToken, "authorization token is required"))
+		failureResponse.Write(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	v := &types.Validator{}
+	filter := &types.AuditEventFilter{
+		SubjectID: claims.UserID,
+		Action:    query.Get("action"),
+	}
+	if filter.Action != "" {
+		v.OneOf("action", filter.Action, types.AuditActions)
+	}
+	filter.Since, filter.Until = timeRange(v, query)
+	filter.Limit, filter.Offset = pagination(v, query.Get("limit"), query.Get("offset"))
+	if err := v.Err(); err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(http.StatusBadRequest)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	events, statusCode, err := impl.ListAuditEvents(filter)
+	if err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	successResponse := &types.Success{}
+	successResponse.SetStatusCode(statusCode)
+	successResponse.SetData(events)
+	successResponse.SetMessage("Activity fetched successfully")
+	successResponse.JSON(w)
+}
+
+// ListAuditEventsController lets admins search the whole audit log
+func ListAuditEventsController(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	v := &types.Validator{}
+	filter := &types.AuditEventFilter{
+		ActorID:   query.Get("actor_id"),
+		SubjectID: query.Get("subject_id"),
+		Action:    query.Get("action"),
+		IP:        query.Get("ip"),
+	}
+	if filter.ActorID != "" {
+		v.UUID("actor_id", filter.ActorID)
+	}
+	if filter.SubjectID != "" {
+		v.UUID("subject_id", filter.SubjectID)
+	}
+	if filter.Action != "" {
+		v.OneOf("action", filter.Action, types.AuditActions)
+	}
+	filter.Since, filter.Until = timeRange(v, query)
+	filter.Limit, filter.Offset = pagination(v, query.Get("limit"), query.Get("offset"))
+	if err := v.Err(); err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(http.StatusBadRequest)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	events, statusCode, err := impl.ListAuditEvents(filter)
+	if err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	successResponse := &types.Success{}
+	successResponse.SetStatusCode(statusCode)
+	successResponse.SetData(events)
+	successResponse.SetMessage("Audit events fetched successfully")
+	successResponse.JSON(w)
+}
+
+// timeRange parses the RFC 3339 since and until query parameters, recording problems on v.
+func timeRange(v *types.Validator, query url.Values) (*time.Time, *time.Time) {
+	parse := func(field string) *time.Time {
+		raw := query.Get(field)
+		if raw == "" {
+			return nil
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		v.Check(err == nil, field, "invalid_time", field+" must be an RFC 3339 timestamp")
+		if err != nil {
+			return nil
+		}
+		return &parsed
+	}
+
+	since, until := parse("since"), parse("until")
+	if since != nil && until != nil {
+		v.Check(since.Before(*until), "until", "out_of_range", "until must be after since")
+	}
+	return since, until
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+func TestTimeRange(t *testing.T) {
+	instant := time.Date(2025, 1, 2, 1, 4, 5, 0, time.UTC)
+	earlier := time.Date(2025, 1, 2, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		since  *time.Time
+		until  *time.Time
+		errors []string
+	}{
+		{"unset", url.Values{}, nil, nil, nil},
+		{"utc", url.Values{"since": {"2025-01-02T01:04:05Z"}}, &instant, nil, nil},
+		// Times given with an offset name the same instant as their UTC equivalent
+		{"offset", url.Values{"until": {"2025-01-02T03:04:05+02:00"}}, nil, &instant, nil},
+		{"invalid", url.Values{"since": {"yesterday"}}, nil, nil, []string{"since invalid_time"}},
+		{"reversed", url.Values{"since": {"2025-01-02T03:04:05+02:00"}, "until": {"2025-01-02T01:00:00Z"}}, &instant, &earlier, []string{"until out_of_range"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &types.Validator{}
+			since, until := timeRange(v, tt.query)
+
+			if !sameInstant(since, tt.since) {
+				t.Errorf("since = %v, want %v", since, tt.since)
+			}
+			if !sameInstant(until, tt.until) {
+				t.Errorf("until = %v, want %v", until, tt.until)
+			}
+
+			var got []string
+			for _, e := range v.Errors() {
+				got = append(got, e.Field+" "+e.Code)
+			}
+			if !slices.Equal(got, tt.errors) {
+				t.Errorf("errors = %v, want %v", got, tt.errors)
+			}
+		})
+	}
+}
+
+func sameInstant(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func TestListAuditEventsControllerRejectsBadFilters(t *testing.T) {
+	tests := []struct {
+		query  string
+		fields []string
+	}{
+		{"actor_id=42", []string{"actor_id"}},
+		{"subject_id=ada", []string{"subject_id"}},
+		{"action=auth.logged_in", []string{"action"}},
+		{"since=yesterday&limit=0", []string{"since", "limit"}},
+		{"actor_id=0f8fad5b-d9cb-469f-a165-70867728950e&offset=-1", []string{"offset"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ListAuditEventsController(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit-events?"+tt.query, nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			var failure types.Failure
+			if err := json.NewDecoder(w.Body).Decode(&failure); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			var fields []string
+			for _, detail := range failure.Details {
+				fields = append(fields, detail.Field)
+			}
+			if failure.Code != types.ErrCodeValidationFailed || !slices.Equal(fields, tt.fields) {
+				t.Errorf("failure = %s on %v, want a validation failure on %v", failure.Code, fields, tt.fields)
+			}
+		})
+	}
+}
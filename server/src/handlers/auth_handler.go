@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 
 	impl "github.com/Mahaveer86619/ImaginAI/src/implementations"
@@ -10,21 +9,21 @@ import (
 
 func AuthenticateUserController(w http.ResponseWriter, r *http.Request) {
 	var creds types.AuthenticatingCredentials
-	err := json.NewDecoder(r.Body).Decode(&creds)
+	statusCode, err := decodeJSONBody(w, r, &creds)
 	if err != nil {
 		failureResponse := types.Failure{}
-		failureResponse.SetStatusCode(http.StatusBadRequest)
-		failureResponse.SetMessage("Invalid request body")
-		failureResponse.JSON(w)
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
-	returned_creds, statusCode, err := impl.AuthenticateUser(&creds)
+	returned_creds, statusCode, err := impl.AuthenticateUser(&creds, requestInfo(r))
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -37,23 +36,23 @@ func AuthenticateUserController(w http.ResponseWriter, r *http.Request) {
 
 func RegisterUserController(w http.ResponseWriter, r *http.Request) {
 	var creds types.RegisteringCredentials
-	err := json.NewDecoder(r.Body).Decode(&creds)
+	statusCode, err := decodeJSONBody(w, r, &creds)
 	if err != nil {
 		failureResponse := types.Failure{}
-		failureResponse.SetStatusCode(http.StatusBadRequest)
-		failureResponse.SetMessage("Invalid request body")
-		failureResponse.JSON(w)
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
 	// creds.GeminiAPIKey will be filled from the request body if provided
 
-	returned_user, statusCode, err := impl.RegisterUser(&creds)
+	returned_user, statusCode, err := impl.RegisterUser(&creds, requestInfo(r))
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -66,21 +65,21 @@ func RegisterUserController(w http.ResponseWriter, r *http.Request) {
 
 func SendPassResetCodeController(w http.ResponseWriter, r *http.Request) {
 	var reqBody types.SendPassResetCodeBody
-	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	statusCode, err := decodeJSONBody(w, r, &reqBody)
 	if err != nil {
 		failureResponse := types.Failure{}
-		failureResponse.SetStatusCode(http.StatusBadRequest)
-		failureResponse.SetMessage("Invalid request body")
-		failureResponse.JSON(w)
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
-	statusCode, err := impl.SendPassResetCode(reqBody.Email)
+	statusCode, err = impl.SendPassResetCode(reqBody.Email, requestInfo(r))
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -93,21 +92,21 @@ func SendPassResetCodeController(w http.ResponseWriter, r *http.Request) {
 
 func CheckResetPassCodeController(w http.ResponseWriter, r *http.Request) {
 	var reqBody types.CheckPassResetCodeBody
-	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	statusCode, err := decodeJSONBody(w, r, &reqBody)
 	if err != nil {
 		failureResponse := types.Failure{}
-		failureResponse.SetStatusCode(http.StatusBadRequest)
-		failureResponse.SetMessage("Invalid request body")
-		failureResponse.JSON(w)
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
-	code, statusCode, err := impl.CheckResetPassCode(reqBody.Code, reqBody.Email)
+	code, statusCode, err := impl.CheckResetPassCode(reqBody.Code, reqBody.Email, requestInfo(r))
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -120,12 +119,12 @@ func CheckResetPassCodeController(w http.ResponseWriter, r *http.Request) {
 
 func RefreshTokenController(w http.ResponseWriter, r *http.Request) {
 	var refreshingToken types.RefreshTokenBody
-	err := json.NewDecoder(r.Body).Decode(&refreshingToken)
+	statusCode, err := decodeJSONBody(w, r, &refreshingToken)
 	if err != nil {
 		failureResponse := types.Failure{}
-		failureResponse.SetStatusCode(http.StatusBadRequest)
-		failureResponse.SetMessage("Invalid request body")
-		failureResponse.JSON(w)
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -133,8 +132,8 @@ func RefreshTokenController(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
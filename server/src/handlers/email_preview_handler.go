@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	services "github.com/Mahaveer86619/ImaginAI/src/services"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+// PreviewEmailController renders an email template with sample data so designers
+// can check layouts and translations. Only routed in development.
+//
+// ?locale= picks the language, ?format=html (default), text or json picks the output.
+func PreviewEmailController(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("template")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+
+	v := &types.Validator{}
+	v.OneOf("template", name, services.EmailTemplateNames())
+	v.OneOf("format", format, []string{"html", "text", "json"})
+	if err := v.Err(); err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(http.StatusBadRequest)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	data := services.NewEmailData(r.URL.Query().Get("locale"))
+	data.Name = "Ada Lovelace"
+	data.Email = "ada@example.com"
+	data.Code = "123456"
+
+	rendered, err := services.RenderEmail(name, data)
+	if err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(http.StatusInternalServerError)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	switch format {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("Subject: " + rendered.Subject + "\n\n" + rendered.Text))
+	case "json":
+		successResponse := &types.Success{}
+		successResponse.SetStatusCode(http.StatusOK)
+		successResponse.SetData(map[string]interface{}{
+			"locale":            data.Locale,
+			"available_locales": services.EmailLocales(),
+			"subject":           rendered.Subject,
+			"html":              rendered.HTML,
+			"text":              rendered.Text,
+		})
+		successResponse.SetMessage("Email rendered successfully")
+		successResponse.JSON(w)
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(rendered.HTML))
+	}
+}
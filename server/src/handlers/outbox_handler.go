@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	impl "github.com/Mahaveer86619/ImaginAI/src/implementations"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+var outboxStatuses = []string{
+	types.OutboxStatusPending,
+	types.OutboxStatusSending,
+	types.OutboxStatusSent,
+	types.OutboxStatusDead,
+}
+
+func ListOutboxMessagesController(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+
+	v := &types.Validator{}
+	if status != "" {
+		v.OneOf("status", status, outboxStatuses)
+	}
+	limit, offset := pagination(v, query.Get("limit"), query.Get("offset"))
+	if err := v.Err(); err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(http.StatusBadRequest)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	messages, statusCode, err := impl.ListOutboxMessages(status, limit, offset)
+	if err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	successResponse := &types.Success{}
+	successResponse.SetStatusCode(statusCode)
+	successResponse.SetData(messages)
+	successResponse.SetMessage("Outbox messages fetched successfully")
+	successResponse.JSON(w)
+}
+
+func GetOutboxMessageController(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := types.ValidateID(id); err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(http.StatusBadRequest)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	message, statusCode, err := impl.GetOutboxMessage(id)
+	if err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	successResponse := &types.Success{}
+	successResponse.SetStatusCode(statusCode)
+	successResponse.SetData(message)
+	successResponse.SetMessage("Outbox message fetched successfully")
+	successResponse.JSON(w)
+}
+
+func RetryOutboxMessageController(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := types.ValidateID(id); err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(http.StatusBadRequest)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	message, statusCode, err := impl.RetryOutboxMessage(id)
+	if err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	successResponse := &types.Success{}
+	successResponse.SetStatusCode(statusCode)
+	successResponse.SetData(message)
+	successResponse.SetMessage("Outbox message queued for retry")
+	successResponse.JSON(w)
+}
+
+// pagination parses the limit and offset query parameters, recording problems on v.
+func pagination(v *types.Validator, rawLimit string, rawOffset string) (int, int) {
+	limit, offset := defaultPageLimit, 0
+
+	if rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		v.Check(err == nil && parsed > 0 && parsed <= maxPageLimit, "limit", "out_of_range", "limit must be between 1 and "+strconv.Itoa(maxPageLimit))
+		if err == nil {
+			limit = parsed
+		}
+	}
+
+	if rawOffset != "" {
+		parsed, err := strconv.Atoi(rawOffset)
+		v.Check(err == nil && parsed >= 0, "offset", "out_of_range", "offset must be zero or greater")
+		if err == nil {
+			offset = parsed
+		}
+	}
+
+	return limit, offset
+}
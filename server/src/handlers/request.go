@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+	middleware "github.com/Mahaveer86619/ImaginAI/src/middleware"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+const defaultMaxBodyBytes = 1 << 20 // 1 MiB
+
+// normalizer is implemented by request bodies that clean up their fields before validation.
+type normalizer interface {
+	Normalize()
+}
+
+// validator is implemented by request bodies that can check their own fields.
+type validator interface {
+	Validate() error
+}
+
+// decodeJSONBody strictly decodes a size-limited JSON body into dst, then normalizes
+// and validates it when dst supports that. It returns the status code to respond with.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) (int, error) {
+	maxBytes := helpers.GetEnvInt64("MAX_REQUEST_BODY_BYTES", defaultMaxBodyBytes)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err, maxBytes)
+	}
+
+	// The body must contain exactly one JSON value
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return http.StatusBadRequest, types.NewAppError(types.ErrCodeInvalidRequestBody, "Invalid request body: must contain a single JSON object")
+	}
+
+	if n, ok := dst.(normalizer); ok {
+		n.Normalize()
+	}
+
+	if v, ok := dst.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+func decodeError(err error, maxBytes int64) (int, error) {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		return http.StatusRequestEntityTooLarge, types.NewAppError(types.ErrCodeRequestTooLarge, fmt.Sprintf("Request body must not be larger than %d bytes", maxBytes))
+	case errors.As(err, &syntaxError), errors.Is(err, io.ErrUnexpectedEOF):
+		return http.StatusBadRequest, types.NewAppError(types.ErrCodeInvalidRequestBody, "Invalid request body: malformed JSON")
+	case errors.As(err, &typeError):
+		return http.StatusBadRequest, types.NewValidationError([]types.FieldError{
+			{Field: typeError.Field, Code: "invalid_type", Message: fmt.Sprintf("%s must be of type %s", typeError.Field, typeError.Type)},
+		})
+	case errors.Is(err, io.EOF):
+		return http.StatusBadRequest, types.NewAppError(types.ErrCodeInvalidRequestBody, "Invalid request body: body must not be empty")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return http.StatusBadRequest, types.NewValidationError([]types.FieldError{
+			{Field: field, Code: "unknown_field", Message: fmt.Sprintf("%s is not a recognised field", field)},
+		})
+	default:
+		return http.StatusBadRequest, types.NewAppError(types.ErrCodeInvalidRequestBody, "Invalid request body")
+	}
+}
+
+// requestInfo describes the caller of r for the audit log.
+func requestInfo(r *http.Request) *types.RequestInfo {
+	info := &types.RequestInfo{
+		IP:        helpers.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if claims, ok := middleware.ClaimsFromContext(r.Context()); ok {
+		info.ActorID = claims.UserID
+	}
+	return info
+}
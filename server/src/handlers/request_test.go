@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+// call sends body to handler and decodes the failure it answers with
+func call(t *testing.T, handler http.HandlerFunc, body string, pathID string) (int, types.Failure) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if pathID != "" {
+		r.SetPathValue("id", pathID)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	var failure types.Failure
+	if err := json.NewDecoder(w.Body).Decode(&failure); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return w.Code, failure
+}
+
+func TestDecodeRejectsMalformedBodies(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		code    types.ErrorCode
+		message string
+		field   string
+	}{
+		{"empty", "", types.ErrCodeInvalidRequestBody, "Invalid request body: body must not be empty", ""},
+		{"malformed", `{"email":`, types.ErrCodeInvalidRequestBody, "Invalid request body: malformed JSON", ""},
+		{"several values", `{"email":"a@example.com","password":"x"} {}`, types.ErrCodeInvalidRequestBody, "Invalid request body: must contain a single JSON object", ""},
+		{"unknown field", `{"email":"a@example.com","password":"x","admin":true}`, types.ErrCodeValidationFailed, "admin is not a recognised field", "admin"},
+		{"wrong type", `{"email":42,"password":"x"}`, types.ErrCodeValidationFailed, "email must be of type string", "email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, failure := call(t, AuthenticateUserController, tt.body, "")
+			if status != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", status)
+			}
+			if failure.Code != tt.code {
+				t.Errorf("code = %s, want %s", failure.Code, tt.code)
+			}
+			if tt.field == "" {
+				if failure.Message != tt.message {
+					t.Errorf("message = %q, want %q", failure.Message, tt.message)
+				}
+				return
+			}
+			if len(failure.Details) != 1 || failure.Details[0].Field != tt.field || failure.Details[0].Message != tt.message {
+				t.Errorf("details = %+v, want %s: %q", failure.Details, tt.field, tt.message)
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsOversizedBodies(t *testing.T) {
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "64")
+
+	body := `{"email":"a@example.com","password":"` + strings.Repeat("x", 64) + `"}`
+	status, failure := call(t, AuthenticateUserController, body, "")
+	if status != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", status)
+	}
+	if failure.Code != types.ErrCodeRequestTooLarge {
+		t.Errorf("code = %s, want %s", failure.Code, types.ErrCodeRequestTooLarge)
+	}
+	if want := "Request body must not be larger than 64 bytes"; failure.Message != want {
+		t.Errorf("message = %q, want %q", failure.Message, want)
+	}
+}
+
+func TestValidationMessages(t *testing.T) {
+	const userID = "6f1c3b52-0d7e-4f43-9a57-4d1a4c9f1e11"
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		pathID  string
+		body    string
+		// want lists the field errors as "field code: message"
+		want []string
+	}{
+		{
+			name:    "login without fields",
+			handler: AuthenticateUserController,
+			body:    `{}`,
+			want:    []string{"email required: email is required", "password required: password is required"},
+		},
+		{
+			name:    "login with invalid email",
+			handler: AuthenticateUserController,
+			body:    `{"email":"not-an-email","password":"secret"}`,
+			want:    []string{"email invalid_email: email must be a valid email address"},
+		},
+		{
+			name:    "register with every field invalid",
+			handler: RegisterUserController,
+			body:    `{"name":"` + strings.Repeat("n", 101) + `","email":"x","password":"short","gemini_api_key":"` + strings.Repeat("k", 257) + `","language":"english"}`,
+			want: []string{
+				"name too_long: name must be at most 100 characters long",
+				"email invalid_email: email must be a valid email address",
+				"password weak_password: password must be at least 8 characters long",
+				"password weak_password: password must contain a digit",
+				"gemini_api_key too_long: gemini_api_key must be at most 256 characters long",
+				"language invalid_language: language must be a language tag such as en or es-MX",
+			},
+		},
+		{
+			name:    "register with a blank name",
+			handler: RegisterUserController,
+			body:    `{"name":"   ","email":"a@example.com","password":"longenough1"}`,
+			want:    []string{"name required: name is required"},
+		},
+		{
+			name:    "reset code request without email",
+			handler: SendPassResetCodeController,
+			body:    `{"email":""}`,
+			want:    []string{"email required: email is required"},
+		},
+		{
+			name:    "reset code of the wrong length",
+			handler: CheckResetPassCodeController,
+			body:    `{"email":"a@example.com","code":"12345"}`,
+			want:    []string{"code invalid_code: code must be a 6 digit number"},
+		},
+		{
+			name:    "reset code with letters",
+			handler: CheckResetPassCodeController,
+			body:    `{"email":"a@example.com","code":"12a456"}`,
+			want:    []string{"code invalid_code: code must be a 6 digit number"},
+		},
+		{
+			name:    "refresh without token",
+			handler: RefreshTokenController,
+			body:    `{"refreshTokenKey":"  "}`,
+			want:    []string{"refreshTokenKey required: refreshTokenKey is required"},
+		},
+		{
+			name:    "update with an invalid model",
+			handler: UpdateUserController,
+			pathID:  userID,
+			body:    `{"name":"Ada","email":"ada@example.com","default_model":"Gemini 2.5!"}`,
+			want:    []string{"default_model invalid_model: default_model must be a model id such as gemini-2.5-flash"},
+		},
+		{
+			name:    "update of an invalid id",
+			handler: UpdateUserController,
+			pathID:  "42",
+			body:    `{"name":"Ada","email":"ada@example.com"}`,
+			want:    []string{"id invalid_uuid: id must be a valid UUID"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, failure := call(t, tt.handler, tt.body, tt.pathID)
+			if status != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", status)
+			}
+			if failure.Code != types.ErrCodeValidationFailed {
+				t.Errorf("code = %s, want %s", failure.Code, types.ErrCodeValidationFailed)
+			}
+
+			var got []string
+			for _, detail := range failure.Details {
+				got = append(got, detail.Field+" "+detail.Code+": "+detail.Message)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("details =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
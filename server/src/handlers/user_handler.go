@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 
 	impl "github.com/Mahaveer86619/ImaginAI/src/implementations"
@@ -13,8 +12,8 @@ func GetAllUsersController(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -26,12 +25,12 @@ func GetAllUsersController(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetUserByIDController(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("id")
-	if userID == "" {
+	userID := r.PathValue("id")
+	if err := types.ValidateID(userID); err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(http.StatusBadRequest)
-		failureResponse.SetMessage("ID is required")
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -39,8 +38,8 @@ func GetUserByIDController(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -52,24 +51,33 @@ func GetUserByIDController(w http.ResponseWriter, r *http.Request) {
 }
 
 func UpdateUserController(w http.ResponseWriter, r *http.Request) {
-	var user types.UserSafeResponse
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
+	userID := r.PathValue("id")
+	if err := types.ValidateID(userID); err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(http.StatusBadRequest)
-		failureResponse.SetMessage("Invalid request body")
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
+		return
+	}
+
+	var user types.UpdateUserBody
+	statusCode, err := decodeJSONBody(w, r, &user)
+	if err != nil {
+		failureResponse := types.Failure{}
+		failureResponse.SetStatusCode(statusCode)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
 	// user.GeminiAPIKey will be filled from the request body if provided
 
-	returned_user, statusCode, err := impl.UpdateUser(&user)
+	returned_user, statusCode, err := impl.UpdateUser(userID, &user, requestInfo(r))
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
@@ -81,21 +89,21 @@ func UpdateUserController(w http.ResponseWriter, r *http.Request) {
 }
 
 func DeleteUserController(w http.ResponseWriter, r *http.Request) {
-	user_id := r.URL.Query().Get("id")
-	if user_id == "" {
+	user_id := r.PathValue("id")
+	if err := types.ValidateID(user_id); err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(http.StatusBadRequest)
-		failureResponse.SetMessage("Invalid request body: id is required")
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
-	statusCode, err := impl.DeleteUser(user_id)
+	statusCode, err := impl.DeleteUser(user_id, requestInfo(r))
 	if err != nil {
 		failureResponse := types.Failure{}
 		failureResponse.SetStatusCode(statusCode)
-		failureResponse.SetMessage(err.Error())
-		failureResponse.JSON(w)
+		failureResponse.SetError(err)
+		failureResponse.Write(w, r)
 		return
 	}
 
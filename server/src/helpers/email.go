@@ -0,0 +1,27 @@
+package helpers
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// NormalizeEmail trims surrounding whitespace and case-folds an email address
+// so that lookups and uniqueness checks are case-insensitive.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// IsValidEmail reports whether email is a bare address such as "user@example.com".
+func IsValidEmail(email string) bool {
+	if len(email) > 254 {
+		return false
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+
+	at := strings.LastIndex(email, "@")
+	return at > 0 && strings.Contains(email[at+1:], ".")
+}
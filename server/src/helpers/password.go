@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"fmt"
+	"sync"
+	"unicode"
+)
+
+// PasswordPolicy describes the rules a new password has to satisfy.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+var (
+	passwordPolicy     PasswordPolicy
+	passwordPolicyOnce sync.Once
+)
+
+// GetPasswordPolicy returns the password policy configured through PASSWORD_* environment variables.
+// It is read lazily so that variables loaded from .env at startup are taken into account.
+func GetPasswordPolicy() PasswordPolicy {
+	passwordPolicyOnce.Do(func() {
+		passwordPolicy = PasswordPolicy{
+			MinLength:     GetEnvInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:     GetEnvInt("PASSWORD_MAX_LENGTH", 72),
+			RequireUpper:  GetEnvBool("PASSWORD_REQUIRE_UPPER", false),
+			RequireLower:  GetEnvBool("PASSWORD_REQUIRE_LOWER", false),
+			RequireDigit:  GetEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol: GetEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		}
+	})
+	return passwordPolicy
+}
+
+// Check returns a human readable description of every rule the password breaks.
+func (p PasswordPolicy) Check(password string) []string {
+	var problems []string
+
+	length := len([]rune(password))
+	if length < p.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		problems = append(problems, fmt.Sprintf("must be at most %d characters long", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		problems = append(problems, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		problems = append(problems, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		problems = append(problems, "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		problems = append(problems, "must contain a symbol")
+	}
+
+	return problems
+}
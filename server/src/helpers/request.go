@@ -0,0 +1,26 @@
+package helpers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the IP address of the caller. X-Forwarded-For and X-Real-IP
+// are only trusted when TRUST_PROXY_HEADERS is enabled, since clients can set them freely.
+func ClientIP(r *http.Request) string {
+	if GetEnvBool("TRUST_PROXY_HEADERS", false) {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
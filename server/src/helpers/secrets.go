@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// chat-bot/internal/secrets mirrors this file to read the stored keys, keep the two in sync.
+// TestSecretFormatIsShared decrypts the same stored value in both modules.
+
+// secretPrefix marks values encrypted by EncryptSecret. Values without it are
+// plaintext written before encryption was configured and are read as is.
+const secretPrefix = "enc:v1:"
+
+// SecretKeyring holds the AES-256 keys used to encrypt secrets at rest, such as
+// users' Gemini API keys. New values are encrypted with the primary key, older
+// keys are kept so that existing values can still be decrypted until rotated.
+type SecretKeyring struct {
+	PrimaryID string
+	Keys      map[string][]byte
+}
+
+var (
+	secretKeyring     *SecretKeyring
+	secretKeyringErr  error
+	secretKeyringOnce sync.Once
+)
+
+// GetSecretKeyring returns the keyring configured through ENCRYPTION_KEYS, a comma
+// separated list of id:base64-key pairs with the primary key first. It returns
+// nil when no keys are configured, in which case secrets are stored in plaintext.
+func GetSecretKeyring() (*SecretKeyring, error) {
+	secretKeyringOnce.Do(func() {
+		secretKeyring, secretKeyringErr = ParseSecretKeyring(GetEnvList("ENCRYPTION_KEYS", nil))
+	})
+	return secretKeyring, secretKeyringErr
+}
+
+// ParseSecretKeyring parses id:base64-key pairs, the first one being the primary key
+func ParseSecretKeyring(entries []string) (*SecretKeyring, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	keyring := &SecretKeyring{Keys: map[string][]byte{}}
+	for _, entry := range entries {
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("encryption key %q must have the form id:base64-key", entry)
+		}
+		if _, exists := keyring.Keys[id]; exists {
+			return nil, fmt.Errorf("encryption key id %q is used more than once", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, got %d", id, len(key))
+		}
+
+		keyring.Keys[id] = key
+		if keyring.PrimaryID == "" {
+			keyring.PrimaryID = id
+		}
+	}
+
+	return keyring, nil
+}
+
+// EncryptSecret encrypts plaintext with the primary key using AES-GCM.
+// Empty values stay empty so that "no key" remains distinguishable.
+func (k *SecretKeyring) EncryptSecret(plaintext string) (string, error) {
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	gcm, err := newGCM(k.Keys[k.PrimaryID])
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + k.PrimaryID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret reverses EncryptSecret. Plaintext values are returned unchanged.
+func (k *SecretKeyring) DecryptSecret(stored string) (string, error) {
+	if !strings.HasPrefix(stored, secretPrefix) {
+		return stored, nil
+	}
+
+	id, encoded, found := strings.Cut(strings.TrimPrefix(stored, secretPrefix), ":")
+	if !found {
+		return "", errors.New("malformed encrypted secret")
+	}
+	if k == nil {
+		return "", errors.New("encrypted secret found but ENCRYPTION_KEYS is not configured")
+	}
+	key, ok := k.Keys[id]
+	if !ok {
+		return "", fmt.Errorf("encrypted secret uses unknown key %q", id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted secret: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("malformed encrypted secret")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether stored is plaintext or encrypted with a key other than the primary one
+func (k *SecretKeyring) NeedsRotation(stored string) bool {
+	if k == nil || stored == "" {
+		return false
+	}
+	return !strings.HasPrefix(stored, secretPrefix+k.PrimaryID+":")
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
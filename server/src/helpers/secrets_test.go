@@ -0,0 +1,174 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// The chat-bot decrypts what the server stores, so both modules check this same value
+const (
+	vectorKey    = "2024:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	vectorStored = "enc:v1:2024:Zml4ZWQtbm9uY2UhBmlgjV+EAd1UmJHEhGGEcK+EHfJVP6DgXX5DyyKQNhHW8J/niY4="
+	vectorSecret = "AIzaSy-test-gemini-key"
+)
+
+func testKey(id string, fill byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), 32)))
+}
+
+func TestParseSecretKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		err     string
+	}{
+		{"missing id", []string{":" + base64.StdEncoding.EncodeToString(make([]byte, 32))}, "must have the form id:base64-key"},
+		{"missing key", []string{"k1"}, "must have the form id:base64-key"},
+		{"repeated id", []string{testKey("k1", 'a'), testKey("k1", 'b')}, `"k1" is used more than once`},
+		{"invalid base64", []string{"k1:not base64"}, "is not valid base64"},
+		{"short key", []string{"k1:" + base64.StdEncoding.EncodeToString(make([]byte, 16))}, "must be 32 bytes, got 16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSecretKeyring(tt.entries)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseSecretKeyring() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	keyring, err := ParseSecretKeyring(nil)
+	if keyring != nil || err != nil {
+		t.Errorf("ParseSecretKeyring(nil) = %v, %v, want no keyring", keyring, err)
+	}
+
+	keyring, err = ParseSecretKeyring([]string{testKey("new", 'a'), testKey("old", 'b')})
+	if err != nil {
+		t.Fatalf("ParseSecretKeyring() error = %v", err)
+	}
+	if keyring.PrimaryID != "new" || len(keyring.Keys) != 2 {
+		t.Errorf("keyring = %s with %d keys, want the first key as primary of 2", keyring.PrimaryID, len(keyring.Keys))
+	}
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	keyring, err := ParseSecretKeyring([]string{testKey("k1", 'a')})
+	if err != nil {
+		t.Fatalf("ParseSecretKeyring() error = %v", err)
+	}
+
+	for _, plaintext := range []string{"AIzaSyA-short", strings.Repeat("long secret ", 100), "ünïcødé 🔑"} {
+		stored, err := keyring.EncryptSecret(plaintext)
+		if err != nil {
+			t.Fatalf("EncryptSecret() error = %v", err)
+		}
+		if !strings.HasPrefix(stored, "enc:v1:k1:") || strings.Contains(stored, plaintext) {
+			t.Errorf("stored = %q, want it encrypted with k1", stored)
+		}
+
+		again, _ := keyring.EncryptSecret(plaintext)
+		if again == stored {
+			t.Error("encrypting twice gave the same value, the nonce is not random")
+		}
+
+		got, err := keyring.DecryptSecret(stored)
+		if err != nil {
+			t.Fatalf("DecryptSecret() error = %v", err)
+		}
+		if got != plaintext {
+			t.Errorf("DecryptSecret() = %q, want %q", got, plaintext)
+		}
+	}
+
+	if stored, _ := keyring.EncryptSecret(""); stored != "" {
+		t.Errorf("EncryptSecret(\"\") = %q, want it to stay empty", stored)
+	}
+}
+
+func TestSecretKeyRotation(t *testing.T) {
+	old, _ := ParseSecretKeyring([]string{testKey("old", 'a')})
+	rotated, _ := ParseSecretKeyring([]string{testKey("new", 'b'), testKey("old", 'a')})
+
+	stored, err := old.EncryptSecret("AIzaSy-rotate-me")
+	if err != nil {
+		t.Fatalf("EncryptSecret() error = %v", err)
+	}
+	if got, err := rotated.DecryptSecret(stored); err != nil || got != "AIzaSy-rotate-me" {
+		t.Errorf("DecryptSecret() with the old key kept = %q, %v", got, err)
+	}
+
+	current, _ := rotated.EncryptSecret("AIzaSy-rotate-me")
+	tests := []struct {
+		name   string
+		stored string
+		want   bool
+	}{
+		{"old key", stored, true},
+		{"plaintext", "AIzaSy-plain", true},
+		{"primary key", current, false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		if got := rotated.NeedsRotation(tt.stored); got != tt.want {
+			t.Errorf("NeedsRotation(%s) = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecryptSecretFailures(t *testing.T) {
+	keyring, _ := ParseSecretKeyring([]string{testKey("k1", 'a')})
+	other, _ := ParseSecretKeyring([]string{testKey("k1", 'b')})
+	stored, _ := keyring.EncryptSecret("AIzaSy-secret")
+
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, "enc:v1:k1:"))
+	sealed[len(sealed)-1] ^= 1
+	tampered := "enc:v1:k1:" + base64.StdEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name    string
+		keyring *SecretKeyring
+		stored  string
+		err     string
+	}{
+		{"tampered", keyring, tampered, "error decrypting secret"},
+		{"wrong key", other, stored, "error decrypting secret"},
+		{"unknown key", keyring, strings.Replace(stored, ":k1:", ":k2:", 1), `unknown key "k2"`},
+		{"no keyring", nil, stored, "ENCRYPTION_KEYS is not configured"},
+		{"missing key id", keyring, "enc:v1:nokey", "malformed encrypted secret"},
+		{"invalid base64", keyring, "enc:v1:k1:***", "malformed encrypted secret"},
+		{"too short", keyring, "enc:v1:k1:" + base64.StdEncoding.EncodeToString([]byte("short")), "malformed encrypted secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.keyring.DecryptSecret(tt.stored)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("DecryptSecret() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	// Values stored before encryption was configured are read as is
+	var none *SecretKeyring
+	if got, err := none.DecryptSecret("AIzaSy-plain"); err != nil || got != "AIzaSy-plain" {
+		t.Errorf("DecryptSecret() of plaintext = %q, %v", got, err)
+	}
+	if got, _ := none.EncryptSecret("AIzaSy-plain"); got != "AIzaSy-plain" {
+		t.Errorf("EncryptSecret() without keys = %q, want the plaintext", got)
+	}
+}
+
+func TestSecretFormatIsShared(t *testing.T) {
+	keyring, err := ParseSecretKeyring([]string{vectorKey})
+	if err != nil {
+		t.Fatalf("ParseSecretKeyring() error = %v", err)
+	}
+	got, err := keyring.DecryptSecret(vectorStored)
+	if err != nil {
+		t.Fatalf("DecryptSecret() error = %v", err)
+	}
+	if got != vectorSecret {
+		t.Errorf("DecryptSecret() = %q, want %q", got, vectorSecret)
+	}
+}
@@ -0,0 +1,227 @@
+package implementations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	db "github.com/Mahaveer86619/ImaginAI/src/database"
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+	services "github.com/Mahaveer86619/ImaginAI/src/services"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+
+	"github.com/google/uuid"
+)
+
+const userSafeColumns = `id, name, email, role, language, disabled_at IS NOT NULL, plan, default_model`
+
+// scanUserSafe scans a row of userSafeColumns
+func scanUserSafe(row interface{ Scan(...interface{}) error }, user *types.User) error {
+	return row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Language, &user.Disabled, &user.Plan, &user.DefaultModel)
+}
+
+// ResolveUserID accepts either a user id or an email address and returns the user's id.
+func ResolveUserID(idOrEmail string) (string, int, error) {
+	conn := db.GetDBConnection()
+
+	query := `SELECT id FROM users WHERE LOWER(email) = $1`
+	arg := helpers.NormalizeEmail(idOrEmail)
+	if _, err := uuid.Parse(idOrEmail); err == nil {
+		query = `SELECT id FROM users WHERE id = $1`
+		arg = idOrEmail
+	}
+
+	var userID string
+	if err := conn.QueryRow(query, arg).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", http.StatusNotFound, types.NewAppError(types.ErrCodeUserNotFound, "user not found")
+		}
+		return "", http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying user: %w", err))
+	}
+
+	return userID, http.StatusOK, nil
+}
+
+// SetUserDisabled disables or re-enables an account. Disabled users cannot log
+// in and their existing tokens are rejected.
+func SetUserDisabled(userID string, disabled bool, info *types.RequestInfo) (*types.UserSafeResponse, int, error) {
+	query := `UPDATE users
+		SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, NOW()) END, updated_at = NOW()
+		WHERE id = $2
+		RETURNING ` + userSafeColumns
+
+	action := types.AuditActionUserEnabled
+	if disabled {
+		action = types.AuditActionUserDisabled
+	}
+
+	return updateUserAsAdmin(userID, info, action, nil, query, disabled, userID)
+}
+
+// SetUserPassword replaces a user's password and revokes their tokens.
+func SetUserPassword(userID string, password string, info *types.RequestInfo) (int, error) {
+	v := &types.Validator{}
+	v.Password("password", password)
+	if err := v.Err(); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	query := `UPDATE users
+		SET password = $1, token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING ` + userSafeColumns
+
+	_, statusCode, err := updateUserAsAdmin(userID, info, types.AuditActionPasswordSet, nil, query, password, userID)
+	return statusCode, err
+}
+
+// SetUserRole grants role to a user. Their tokens are revoked so that the
+// new role takes effect on their next login.
+func SetUserRole(userID string, role string, info *types.RequestInfo) (*types.UserSafeResponse, int, error) {
+	v := &types.Validator{}
+	v.OneOf("role", role, types.Roles)
+	if err := v.Err(); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	query := `UPDATE users
+		SET role = $1, token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING ` + userSafeColumns
+
+	return updateUserAsAdmin(userID, info, types.AuditActionRoleGranted, map[string]interface{}{"role": role}, query, role, userID)
+}
+
+// SetUserPlan moves a user to plan, which decides the models they may chat with.
+func SetUserPlan(userID string, plan string, info *types.RequestInfo) (*types.UserSafeResponse, int, error) {
+	v := &types.Validator{}
+	v.OneOf("plan", plan, types.Plans)
+	if err := v.Err(); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	query := `UPDATE users
+		SET plan = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING ` + userSafeColumns
+
+	return updateUserAsAdmin(userID, info, types.AuditActionPlanChanged, map[string]interface{}{"plan": plan}, query, plan, userID)
+}
+
+// RevokeUserTokens invalidates every access and refresh token issued to a user.
+func RevokeUserTokens(userID string, info *types.RequestInfo) (int, error) {
+	query := `UPDATE users
+		SET token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + userSafeColumns
+
+	_, statusCode, err := updateUserAsAdmin(userID, info, types.AuditActionTokensRevoked, nil, query, userID)
+	return statusCode, err
+}
+
+// updateUserAsAdmin runs an update returning userSafeColumns and audits it in the same transaction.
+func updateUserAsAdmin(userID string, info *types.RequestInfo, action string, metadata map[string]interface{}, query string, args ...interface{}) (*types.UserSafeResponse, int, error) {
+	conn := db.GetDBConnection()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error starting transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	var user types.User
+	err = scanUserSafe(tx.QueryRow(query, args...), &user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, types.NewAppError(types.ErrCodeUserNotFound, "user not found")
+		}
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error updating user: %w", err))
+	}
+
+	err = services.RecordAuditEvent(context.Background(), tx, info, &types.AuditEvent{
+		SubjectID: userID,
+		Action:    action,
+		Metadata:  metadata,
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error committing user: %w", err))
+	}
+
+	return user.ToUserSafeResponse(), http.StatusOK, nil
+}
+
+// RotateEncryptionKeys re-encrypts every stored Gemini API key that is in
+// plaintext or encrypted with an older key, using the primary key of
+// ENCRYPTION_KEYS. It returns the number of keys that were rewritten.
+func RotateEncryptionKeys(info *types.RequestInfo) (int, int, error) {
+	keyring, err := helpers.GetSecretKeyring()
+	if err != nil {
+		return 0, http.StatusInternalServerError, types.InternalError(err)
+	}
+	if keyring == nil {
+		return 0, http.StatusBadRequest, types.NewAppError(types.ErrCodeEncryptionNotConfigured, "ENCRYPTION_KEYS is not configured")
+	}
+
+	conn := db.GetDBConnection()
+
+	select_query := `SELECT id, gemini_api_key FROM users WHERE gemini_api_key IS NOT NULL AND gemini_api_key <> ''`
+	// Only rewrite values that were not changed since they were read
+	update_query := `UPDATE users SET gemini_api_key = $1 WHERE id = $2 AND gemini_api_key = $3`
+
+	rows, err := conn.Query(select_query)
+	if err != nil {
+		return 0, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying users: %w", err))
+	}
+
+	type storedKey struct{ userID, value string }
+	var pending []storedKey
+	for rows.Next() {
+		var key storedKey
+		if err := rows.Scan(&key.userID, &key.value); err != nil {
+			rows.Close()
+			return 0, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error scanning row: %w", err))
+		}
+		if keyring.NeedsRotation(key.value) {
+			pending = append(pending, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying users: %w", err))
+	}
+
+	rotated := 0
+	for _, key := range pending {
+		plaintext, err := keyring.DecryptSecret(key.value)
+		if err != nil {
+			return rotated, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error decrypting key of user %s: %w", key.userID, err))
+		}
+		encrypted, err := keyring.EncryptSecret(plaintext)
+		if err != nil {
+			return rotated, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error encrypting key of user %s: %w", key.userID, err))
+		}
+
+		result, err := conn.Exec(update_query, encrypted, key.userID, key.value)
+		if err != nil {
+			return rotated, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error updating key of user %s: %w", key.userID, err))
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			rotated++
+		}
+	}
+
+	err = services.RecordAuditEvent(context.Background(), conn, info, &types.AuditEvent{
+		Action:   types.AuditActionKeysRotated,
+		Metadata: map[string]interface{}{"key_id": keyring.PrimaryID, "rotated": rotated},
+	})
+	if err != nil {
+		return rotated, http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	return rotated, http.StatusOK, nil
+}
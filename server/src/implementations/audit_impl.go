@@ -0,0 +1,81 @@
+package implementations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	db "github.com/Mahaveer86619/ImaginAI/src/database"
+	services "github.com/Mahaveer86619/ImaginAI/src/services"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// recordFailedAttempt audits a rejected request. Nothing is being changed, so
+// a failure to record is logged instead of replacing the original error.
+func recordFailedAttempt(info *types.RequestInfo, event *types.AuditEvent) {
+	if err := services.RecordAuditEvent(context.Background(), db.GetDBConnection(), info, event); err != nil {
+		logrus.WithError(err).WithField("action", event.Action).Error("Error recording audit event")
+	}
+}
+
+// ListAuditEvents returns a page of audit events matching filter, newest first.
+func ListAuditEvents(filter *types.AuditEventFilter) (*types.AuditEventListResponse, int, error) {
+	conn := db.GetDBConnection()
+
+	where := `WHERE ($1 = '' OR actor_id = NULLIF($1, '')::UUID)
+		AND ($2 = '' OR subject_id = NULLIF($2, '')::UUID)
+		AND ($3 = '' OR action = $3)
+		AND ($4 = '' OR ip = $4)
+		AND ($5::TIMESTAMPTZ IS NULL OR created_at >= $5)
+		AND ($6::TIMESTAMPTZ IS NULL OR created_at < $6)`
+
+	count_query := `SELECT COUNT(*) FROM audit_events ` + where
+	list_query := `SELECT id, COALESCE(actor_id::TEXT, ''), COALESCE(subject_id::TEXT, ''), action, ip, user_agent, metadata, created_at
+		FROM audit_events ` + where + `
+		ORDER BY created_at DESC
+		LIMIT $7 OFFSET $8`
+
+	var since, until sql.NullTime
+	if filter.Since != nil {
+		since = sql.NullTime{Time: *filter.Since, Valid: true}
+	}
+	if filter.Until != nil {
+		until = sql.NullTime{Time: *filter.Until, Valid: true}
+	}
+	args := []interface{}{filter.ActorID, filter.SubjectID, filter.Action, filter.IP, since, until}
+
+	resp := &types.AuditEventListResponse{
+		Events: []*types.AuditEvent{},
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+
+	if err := conn.QueryRow(count_query, args...).Scan(&resp.Total); err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error counting audit events: %w", err))
+	}
+
+	rows, err := conn.Query(list_query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying audit events: %w", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event types.AuditEvent
+		var metadata []byte
+		if err := rows.Scan(&event.ID, &event.ActorID, &event.SubjectID, &event.Action, &event.IP, &event.UserAgent, &metadata, &event.CreatedAt); err != nil {
+			return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error scanning row: %w", err))
+		}
+		if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error decoding audit metadata: %w", err))
+		}
+		event.CreatedAt = event.CreatedAt.UTC()
+		resp.Events = append(resp.Events, &event)
+	}
+
+	return resp, http.StatusOK, nil
+}
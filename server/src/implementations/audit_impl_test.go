@@ -0,0 +1,287 @@
+package implementations
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/Mahaveer86619/ImaginAI/src/database"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// scriptedConnector is a database whose queries are answered by query and whose
+// statements fail with execErr. Every query and statement is recorded.
+type scriptedConnector struct {
+	mu         sync.Mutex
+	query      func(query string) *scriptedRows
+	execErr    error
+	statements []recordedStatement
+}
+
+type recordedStatement struct {
+	query string
+	args  []interface{}
+}
+
+// matching returns the recorded statements whose query contains substr
+func (c *scriptedConnector) matching(substr string) []recordedStatement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matched []recordedStatement
+	for _, s := range c.statements {
+		if strings.Contains(s.query, substr) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+func (c *scriptedConnector) record(query string, args []driver.NamedValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := recordedStatement{query: query}
+	for _, arg := range args {
+		s.args = append(s.args, arg.Value)
+	}
+	c.statements = append(c.statements, s)
+}
+
+func (c *scriptedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &scriptedConn{c}, nil
+}
+
+func (c *scriptedConnector) Driver() driver.Driver {
+	return nil
+}
+
+type scriptedConn struct {
+	connector *scriptedConnector
+}
+
+func (c *scriptedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.connector.record(query, args)
+	rows := c.connector.query(query)
+	if rows == nil {
+		return nil, errors.New("unexpected query")
+	}
+	return rows, nil
+}
+
+func (c *scriptedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.connector.record(query, args)
+	if c.connector.execErr != nil {
+		return nil, c.connector.execErr
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *scriptedConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *scriptedConn) Close() error {
+	return nil
+}
+
+func (c *scriptedConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type scriptedRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *scriptedRows) Columns() []string {
+	return r.columns
+}
+
+func (r *scriptedRows) Close() error {
+	return nil
+}
+
+func (r *scriptedRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// useDB makes connector the database of the implementations for the rest of the test
+func useDB(t *testing.T, connector *scriptedConnector) {
+	t.Helper()
+
+	previous := db.GetDBConnection()
+	conn := sql.OpenDB(connector)
+	db.SetDBConnection(conn)
+	t.Cleanup(func() {
+		db.SetDBConnection(previous)
+		conn.Close()
+	})
+}
+
+const adaID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+// userRow answers the login query with Ada, whose password is "secret"
+func userRow(disabled bool) *scriptedRows {
+	return &scriptedRows{
+		columns: []string{"id", "name", "email", "password", "gemini_api_key", "role", "language", "disabled", "token_version"},
+		values:  [][]driver.Value{{adaID, "Ada", "ada@example.com", "secret", "", "user", "en", disabled, int64(2)}},
+	}
+}
+
+func TestAuthenticateUserAudit(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	info := &types.RequestInfo{IP: "203.0.113.7", UserAgent: "curl/8.5"}
+
+	tests := []struct {
+		name     string
+		user     *scriptedRows
+		password string
+		execErr  error
+		status   int
+		action   string
+		actor    string
+		subject  string
+		reason   string
+		logged   bool
+	}{
+		{name: "unknown email", user: &scriptedRows{columns: userRow(false).columns}, password: "secret", status: http.StatusUnauthorized, action: types.AuditActionLoginFailed, reason: "unknown_email"},
+		{name: "wrong password", user: userRow(false), password: "guess", status: http.StatusUnauthorized, action: types.AuditActionLoginFailed, subject: adaID, reason: "wrong_password"},
+		{name: "disabled account", user: userRow(true), password: "secret", status: http.StatusForbidden, action: types.AuditActionLoginFailed, subject: adaID, reason: "disabled"},
+		{name: "login", user: userRow(false), password: "secret", status: http.StatusOK, action: types.AuditActionLoginSucceeded, actor: adaID, subject: adaID},
+		{name: "login while the audit log fails", user: userRow(false), password: "secret", execErr: errors.New("disk full"), status: http.StatusOK, action: types.AuditActionLoginSucceeded, actor: adaID, subject: adaID, logged: true},
+		{name: "failed login while the audit log fails", user: userRow(false), password: "guess", execErr: errors.New("disk full"), status: http.StatusUnauthorized, action: types.AuditActionLoginFailed, subject: adaID, reason: "wrong_password", logged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := logtest.NewGlobal()
+			defer hook.Reset()
+
+			connector := &scriptedConnector{
+				query:   func(query string) *scriptedRows { return tt.user },
+				execErr: tt.execErr,
+			}
+			useDB(t, connector)
+
+			resp, status, err := AuthenticateUser(&types.AuthenticatingCredentials{Email: "ada@example.com", Password: tt.password}, info)
+			if status != tt.status {
+				t.Fatalf("status = %d (%v), want %d", status, err, tt.status)
+			}
+			if tt.status == http.StatusOK && (err != nil || resp.Token == "" || resp.RefreshToken == "") {
+				t.Errorf("AuthenticateUser = %+v, %v, want the user with tokens", resp, err)
+			}
+
+			inserts := connector.matching("INSERT INTO audit_events")
+			if len(inserts) != 1 {
+				t.Fatalf("recorded %d audit events, want 1", len(inserts))
+			}
+			// id, actor, subject, action, ip, user agent, metadata
+			args := inserts[0].args
+			if !reflect.DeepEqual(args[1:6], []interface{}{tt.actor, tt.subject, tt.action, info.IP, info.UserAgent}) {
+				t.Errorf("audit event = %v, want %s by %q on %q from %s", args[1:6], tt.action, tt.actor, tt.subject, info.IP)
+			}
+			metadata := args[6].(string)
+			if tt.reason != "" && !strings.Contains(metadata, `"reason":"`+tt.reason+`"`) {
+				t.Errorf("metadata = %s, want reason %s", metadata, tt.reason)
+			}
+			if tt.reason == "" && metadata != "{}" {
+				t.Errorf("metadata = %s, want none", metadata)
+			}
+
+			logged := false
+			for _, entry := range hook.AllEntries() {
+				logged = logged || entry.Message == "Error recording audit event"
+			}
+			if logged != tt.logged {
+				t.Errorf("logged an audit failure = %t, want %t", logged, tt.logged)
+			}
+		})
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2026, 1, 15, 11, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name   string
+		filter types.AuditEventFilter
+		args   []interface{}
+	}{
+		{
+			name:   "everything",
+			filter: types.AuditEventFilter{Limit: 50},
+			args:   []interface{}{"", "", "", "", nil, nil},
+		},
+		{
+			name:   "by actor and action",
+			filter: types.AuditEventFilter{ActorID: adaID, Action: types.AuditActionLoginFailed, Limit: 10, Offset: 20},
+			args:   []interface{}{adaID, "", types.AuditActionLoginFailed, "", nil, nil},
+		},
+		{
+			name:   "by subject, ip and time range",
+			filter: types.AuditEventFilter{SubjectID: adaID, IP: "203.0.113.7", Since: &since, Until: &until, Limit: 50},
+			args:   []interface{}{"", adaID, "", "203.0.113.7", since, until},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := &scriptedConnector{query: func(query string) *scriptedRows {
+				if strings.HasPrefix(query, "SELECT COUNT(*)") {
+					return &scriptedRows{columns: []string{"count"}, values: [][]driver.Value{{int64(21)}}}
+				}
+				return &scriptedRows{
+					columns: []string{"id", "actor_id", "subject_id", "action", "ip", "user_agent", "metadata", "created_at"},
+					values: [][]driver.Value{
+						{"a1", "", adaID, types.AuditActionLoginFailed, "203.0.113.7", "curl/8.5", []byte(`{"reason":"wrong_password"}`), created},
+					},
+				}
+			}}
+			useDB(t, connector)
+
+			resp, status, err := ListAuditEvents(&tt.filter)
+			if err != nil || status != http.StatusOK {
+				t.Fatalf("ListAuditEvents = %d, %v", status, err)
+			}
+
+			count := connector.matching("SELECT COUNT(*)")
+			list := connector.matching("ORDER BY created_at DESC")
+			if len(count) != 1 || len(list) != 1 {
+				t.Fatalf("ran %d count and %d list queries, want 1 of each", len(count), len(list))
+			}
+			if !reflect.DeepEqual(count[0].args, tt.args) {
+				t.Errorf("count arguments = %v, want %v", count[0].args, tt.args)
+			}
+			wantList := append(tt.args, int64(tt.filter.Limit), int64(tt.filter.Offset))
+			if !reflect.DeepEqual(list[0].args, wantList) {
+				t.Errorf("list arguments = %v, want %v", list[0].args, wantList)
+			}
+
+			if resp.Total != 21 || resp.Limit != tt.filter.Limit || resp.Offset != tt.filter.Offset || len(resp.Events) != 1 {
+				t.Fatalf("response = %+v, want 1 of 21 events", resp)
+			}
+			event := resp.Events[0]
+			if event.SubjectID != adaID || event.Metadata["reason"] != "wrong_password" || event.CreatedAt.Location() != time.UTC || !event.CreatedAt.Equal(created) {
+				t.Errorf("event = %+v, want the scanned row in UTC", event)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package implementations
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
@@ -11,100 +12,170 @@ import (
 	services "github.com/Mahaveer86619/ImaginAI/src/services"
 	types "github.com/Mahaveer86619/ImaginAI/src/types"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
-func AuthenticateUser(credentials *types.AuthenticatingCredentials) (*types.UserResponse, int, error) {
+func AuthenticateUser(credentials *types.AuthenticatingCredentials, info *types.RequestInfo) (*types.UserResponse, int, error) {
 	conn := db.GetDBConnection()
 
-	query := `SELECT id, name, email, password, gemini_api_key FROM users WHERE email = $1`
+	query := `SELECT id, name, email, password, gemini_api_key, role, language, disabled_at IS NOT NULL, token_version FROM users WHERE LOWER(email) = $1`
 	var user types.User
 
-	err := conn.QueryRow(query, credentials.Email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.GeminiAPIKey)
+	err := conn.QueryRow(query, credentials.Email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.GeminiAPIKey, &user.Role, &user.Language, &user.Disabled, &user.TokenVersion)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, http.StatusNotFound, fmt.Errorf("user not found")
+			recordFailedAttempt(info, &types.AuditEvent{
+				Action:   types.AuditActionLoginFailed,
+				Metadata: map[string]interface{}{"email": credentials.Email, "reason": "unknown_email"},
+			})
+			return nil, http.StatusUnauthorized, types.NewAppError(types.ErrCodeAuthInvalidCredentials, "invalid email or password")
 		}
-		return nil, http.StatusInternalServerError, fmt.Errorf("error querying user: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying user: %w", err))
 	}
 
 	if credentials.Password != user.Password {
-		return nil, http.StatusUnauthorized, fmt.Errorf("invalid password")
+		recordFailedAttempt(info, &types.AuditEvent{
+			SubjectID: user.ID,
+			Action:    types.AuditActionLoginFailed,
+			Metadata:  map[string]interface{}{"email": credentials.Email, "reason": "wrong_password"},
+		})
+		return nil, http.StatusUnauthorized, types.NewAppError(types.ErrCodeAuthInvalidCredentials, "invalid email or password")
 	}
 
-	token, err := middleware.GenerateToken(user.Email)
+	// Only reveal that the account is disabled to someone who knows the password
+	if user.Disabled {
+		recordFailedAttempt(info, &types.AuditEvent{
+			SubjectID: user.ID,
+			Action:    types.AuditActionLoginFailed,
+			Metadata:  map[string]interface{}{"email": credentials.Email, "reason": "disabled"},
+		})
+		return nil, http.StatusForbidden, types.NewAppError(types.ErrCodeAccountDisabled, "this account has been disabled")
+	}
+
+	if user.GeminiAPIKey, err = decryptGeminiKey(user.GeminiAPIKey); err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	// A login changes nothing, so an audit log that cannot be written is
+	// reported instead of locking every user out
+	err = services.RecordAuditEvent(context.Background(), conn, info, &types.AuditEvent{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    types.AuditActionLoginSucceeded,
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("action", types.AuditActionLoginSucceeded).Error("Error recording audit event")
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.Email, user.Role, user.TokenVersion)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error generating token: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error generating token: %w", err))
 	}
 
-	refreshToken, err := middleware.GenerateRefreshToken(user.Email)
+	refreshToken, err := middleware.GenerateRefreshToken(user.ID, user.Email, user.Role, user.TokenVersion)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error generating refresh token: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error generating refresh token: %w", err))
 	}
 
 	return user.ToUserResponseWithTokens(token, refreshToken), http.StatusOK, nil
 }
 
-func RegisterUser(credentials *types.RegisteringCredentials) (*types.UserResponse, int, error) {
+func RegisterUser(credentials *types.RegisteringCredentials, info *types.RequestInfo) (*types.UserResponse, int, error) {
 	conn := db.GetDBConnection()
 
-	checkQuery := `SELECT id FROM users WHERE email = $1`
+	checkQuery := `SELECT id FROM users WHERE LOWER(email) = $1`
 	var existingID string
 	err := conn.QueryRow(checkQuery, credentials.Email).Scan(&existingID)
 	if err == nil {
-		return nil, http.StatusConflict, fmt.Errorf("email already registered")
+		return nil, http.StatusConflict, types.NewAppError(types.ErrCodeEmailAlreadyRegistered, "email already registered")
 	} else if err != sql.ErrNoRows {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error checking user: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error checking user: %w", err))
 	}
 
 	insertQuery := `
-		INSERT INTO users (id, name, email, password, gemini_api_key, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW()) RETURNING name, email, password, gemini_api_key
+		INSERT INTO users (id, name, email, password, gemini_api_key, language, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'en'), NOW(), NOW())
+		RETURNING name, email, password, role, language, token_version
 	`
+
+	geminiAPIKey, err := encryptGeminiKey(credentials.GeminiAPIKey)
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	// The user and their welcome email are committed together, so a failing
+	// mail server can neither lose the email nor fail the registration
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error starting transaction: %w", err))
+	}
+	defer tx.Rollback()
+
 	var user types.User
 	user.ID = uuid.New().String()
-	err = conn.QueryRow(insertQuery, user.ID, credentials.Name, credentials.Email, credentials.Password, credentials.GeminiAPIKey).
-		Scan(&user.Name, &user.Email, &user.Password, &user.GeminiAPIKey)
+	user.GeminiAPIKey = credentials.GeminiAPIKey
+	err = tx.QueryRow(insertQuery, user.ID, credentials.Name, credentials.Email, credentials.Password, geminiAPIKey, credentials.Language).
+		Scan(&user.Name, &user.Email, &user.Password, &user.Role, &user.Language, &user.TokenVersion)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error creating user: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error creating user: %w", err))
 	}
 
-	token, err := middleware.GenerateToken(user.Email)
+	// Queue email to welcome user, in their preferred language
+	data := services.NewEmailData(user.Language)
+	data.Name = user.Name
+	data.Email = user.Email
+
+	welcomeEmail, err := services.RenderEmailTo([]string{user.Email}, services.TemplateWelcome, data)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error generating token: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(err)
 	}
 
-	refreshToken, err := middleware.GenerateRefreshToken(user.Email)
+	_, err = services.EnqueueEmail(context.Background(), tx, welcomeEmail)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error generating refresh token: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(err)
 	}
 
-	// Send email to welcome user
-	err = services.SendBasicHTMLEmail(
-		[]string{user.Email},
-		"Welcome to ImaginAI!",
-		services.GenerateWelcomeHTML(user.Email),
-	)
+	err = services.RecordAuditEvent(context.Background(), tx, info, &types.AuditEvent{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    types.AuditActionRegistered,
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(err)
+	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error committing user: %w", err))
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.Email, user.Role, user.TokenVersion)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error sending email: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error generating token: %w", err))
+	}
+
+	refreshToken, err := middleware.GenerateRefreshToken(user.ID, user.Email, user.Role, user.TokenVersion)
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error generating refresh token: %w", err))
 	}
 
 	return user.ToUserResponseWithTokens(token, refreshToken), http.StatusCreated, nil
 }
 
-func SendPassResetCode(email string) (int, error) {
+func SendPassResetCode(email string, info *types.RequestInfo) (int, error) {
 	conn := db.GetDBConnection()
 
+	// A new request replaces any code that was sent before
 	insert_query := `
 		INSERT INTO forgot_password (id, email, code)
-		VALUES ($1, $2, $3) RETURNING *
+		VALUES ($1, $2, $3)
+		ON CONFLICT (email) DO UPDATE SET code = EXCLUDED.code
+		RETURNING id, email, code
 	`
 	select_user_query := `
-	  SELECT *
+	  SELECT id, name, email, password, language
 	  FROM users
-	  WHERE email = $1
+	  WHERE LOWER(email) = $1
 	`
 
 	var authUser types.User
@@ -118,13 +189,14 @@ func SendPassResetCode(email string) (int, error) {
 		&authUser.Name,
 		&authUser.Email,
 		&authUser.Password,
+		&authUser.Language,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return http.StatusNotFound, fmt.Errorf("provided email is not registered: %s", email)
+			return http.StatusNotFound, types.NewAppError(types.ErrCodeEmailNotRegistered, "provided email is not registered")
 		}
-		return http.StatusInternalServerError, fmt.Errorf("error scanning row: %w", err)
+		return http.StatusInternalServerError, types.InternalError(fmt.Errorf("error scanning row: %w", err))
 	}
 
 	var forgotPassword types.ForgotPassword
@@ -132,7 +204,13 @@ func SendPassResetCode(email string) (int, error) {
 	forgotPassword.Email = email
 	forgotPassword.Code = helpers.Gen6DigitCode()
 
-	err = conn.QueryRow(
+	tx, err := conn.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, types.InternalError(fmt.Errorf("error starting transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
 		insert_query,
 		forgotPassword.ID,
 		forgotPassword.Email,
@@ -144,24 +222,41 @@ func SendPassResetCode(email string) (int, error) {
 	)
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("error generating forgot password code: %w", err)
+		return http.StatusInternalServerError, types.InternalError(fmt.Errorf("error generating forgot password code: %w", err))
 	}
 
-	// Send email with forgot password code
-	err = services.SendBasicHTMLEmail(
-		[]string{email},
-		"Reset your password",
-		services.GeneratePasswordResetHTML(forgotPassword.Code, email),
-	)
+	// Queue email with forgot password code, delivered in the background
+	data := services.NewEmailData(authUser.Language)
+	data.Name = authUser.Name
+	data.Email = email
+	data.Code = forgotPassword.Code
 
+	resetEmail, err := services.RenderEmailTo([]string{email}, services.TemplatePasswordReset, data)
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("error sending email: %w", err)
+		return http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	_, err = services.EnqueueEmail(context.Background(), tx, resetEmail)
+	if err != nil {
+		return http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	err = services.RecordAuditEvent(context.Background(), tx, info, &types.AuditEvent{
+		SubjectID: authUser.ID,
+		Action:    types.AuditActionPasswordResetRequested,
+	})
+	if err != nil {
+		return http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return http.StatusInternalServerError, types.InternalError(fmt.Errorf("error committing forgot password code: %w", err))
 	}
 
 	return http.StatusOK, nil
 }
 
-func CheckResetPassCode(code string, email string) (string, int, error) {
+func CheckResetPassCode(code string, email string, info *types.RequestInfo) (string, int, error) {
 	conn := db.GetDBConnection()
 
 	select_query := `
@@ -183,42 +278,85 @@ func CheckResetPassCode(code string, email string) (string, int, error) {
 		&forgotPassword.Code,
 	); err != nil {
 		if err == sql.ErrNoRows {
-			return "", http.StatusNotFound, fmt.Errorf("forgot password row not found with email: %s", email)
+			return "", http.StatusNotFound, types.NewAppError(types.ErrCodeResetCodeNotFound, "no password reset was requested for this email")
 		}
-		return "", http.StatusInternalServerError, fmt.Errorf("error scanning row: %w", err)
+		return "", http.StatusInternalServerError, types.InternalError(fmt.Errorf("error scanning row: %w", err))
+	}
+
+	var subjectID string
+	if err := conn.QueryRow(`SELECT id FROM users WHERE LOWER(email) = $1`, email).Scan(&subjectID); err != nil && err != sql.ErrNoRows {
+		return "", http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying user: %w", err))
 	}
 
 	// Delete forgot password row if code is correct
 	if forgotPassword.Code != code {
-		return "", http.StatusBadRequest, fmt.Errorf("invalid code")
+		recordFailedAttempt(info, &types.AuditEvent{
+			SubjectID: subjectID,
+			Action:    types.AuditActionPasswordResetFailed,
+			Metadata:  map[string]interface{}{"email": email},
+		})
+		return "", http.StatusBadRequest, types.NewAppError(types.ErrCodeResetCodeInvalid, "invalid code")
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return "", http.StatusInternalServerError, types.InternalError(fmt.Errorf("error starting transaction: %w", err))
 	}
+	defer tx.Rollback()
 
-	_, err := conn.Exec(del_query, forgotPassword.ID)
+	_, err = tx.Exec(del_query, forgotPassword.ID)
 	if err != nil {
-		return "", http.StatusInternalServerError, fmt.Errorf("error deleting row: %w", err)
+		return "", http.StatusInternalServerError, types.InternalError(fmt.Errorf("error deleting row: %w", err))
+	}
+
+	err = services.RecordAuditEvent(context.Background(), tx, info, &types.AuditEvent{
+		SubjectID: subjectID,
+		Action:    types.AuditActionPasswordResetVerified,
+	})
+	if err != nil {
+		return "", http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", http.StatusInternalServerError, types.InternalError(fmt.Errorf("error committing password reset: %w", err))
 	}
 
 	return forgotPassword.Code, http.StatusOK, nil
 }
 
 func RefreshToken(refreshingToken *types.RefreshTokenBody) (*types.RefreshTokenResp, int, error) {
-	claims := &middleware.Claims{}
-	token, err := jwt.ParseWithClaims(refreshingToken.RefreshTokenKey, claims, func(token *jwt.Token) (interface{}, error) {
-		return middleware.JwtKey, nil
-	})
+	claims, err := middleware.ParseToken(refreshingToken.RefreshTokenKey, middleware.TokenTypeRefresh)
+	if err != nil || uuid.Validate(claims.UserID) != nil {
+		return nil, http.StatusUnauthorized, types.NewAppError(types.ErrCodeAuthInvalidRefresh, "invalid refresh token")
+	}
+
+	// Re-read the user so that the new tokens carry the current email and role
+	conn := db.GetDBConnection()
+
+	query := `SELECT id, email, role, disabled_at IS NOT NULL, token_version FROM users WHERE id = $1`
+	var user types.User
+
+	err = conn.QueryRow(query, claims.UserID).Scan(&user.ID, &user.Email, &user.Role, &user.Disabled, &user.TokenVersion)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusUnauthorized, types.NewAppError(types.ErrCodeAuthInvalidRefresh, "invalid refresh token")
+		}
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying user: %w", err))
+	}
 
-	if err != nil || !token.Valid {
-		return nil, http.StatusUnauthorized, fmt.Errorf("invalid refresh token")
+	// Refresh tokens issued before the user's tokens were revoked are no longer accepted
+	if user.Disabled || user.TokenVersion != claims.TokenVersion {
+		return nil, http.StatusUnauthorized, types.NewAppError(types.ErrCodeAuthInvalidRefresh, "invalid refresh token")
 	}
 
-	newToken, err := middleware.GenerateToken(claims.Email)
+	newToken, err := middleware.GenerateToken(user.ID, user.Email, user.Role, user.TokenVersion)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error generating new token: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error generating new token: %w", err))
 	}
 
-	newRefreshToken, err := middleware.GenerateRefreshToken(claims.Email)
+	newRefreshToken, err := middleware.GenerateRefreshToken(user.ID, user.Email, user.Role, user.TokenVersion)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error generating new token: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error generating new token: %w", err))
 	}
 
 	return &types.RefreshTokenResp{
@@ -0,0 +1,151 @@
+package implementations
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	db "github.com/Mahaveer86619/ImaginAI/src/database"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+
+	"github.com/lib/pq"
+)
+
+const outboxColumns = `id, recipients, subject, status, attempts, max_attempts, last_error, next_attempt_at, sent_at, created_at, updated_at`
+
+func scanOutboxMessage(row interface{ Scan(...interface{}) error }) (*types.OutboxMessage, error) {
+	var message types.OutboxMessage
+	var sentAt sql.NullTime
+
+	err := row.Scan(
+		&message.ID,
+		pq.Array(&message.Recipients),
+		&message.Subject,
+		&message.Status,
+		&message.Attempts,
+		&message.MaxAttempts,
+		&message.LastError,
+		&message.NextAttemptAt,
+		&sentAt,
+		&message.CreatedAt,
+		&message.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if sentAt.Valid {
+		message.SentAt = &sentAt.Time
+	}
+	return &message, nil
+}
+
+// ListOutboxMessages returns a page of outbox messages, newest first, optionally filtered by status.
+func ListOutboxMessages(status string, limit int, offset int) (*types.OutboxListResponse, int, error) {
+	conn := db.GetDBConnection()
+
+	count_query := `SELECT COUNT(*) FROM email_outbox WHERE ($1 = '' OR status = $1)`
+	list_query := `SELECT ` + outboxColumns + ` FROM email_outbox
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	resp := &types.OutboxListResponse{
+		Messages: []*types.OutboxMessage{},
+		Limit:    limit,
+		Offset:   offset,
+	}
+
+	if err := conn.QueryRow(count_query, status).Scan(&resp.Total); err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error counting outbox messages: %w", err))
+	}
+
+	rows, err := conn.Query(list_query, status, limit, offset)
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying outbox messages: %w", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		message, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error scanning row: %w", err))
+		}
+		resp.Messages = append(resp.Messages, message)
+	}
+
+	return resp, http.StatusOK, nil
+}
+
+func GetOutboxMessage(id string) (*types.OutboxMessage, int, error) {
+	conn := db.GetDBConnection()
+
+	query := `SELECT ` + outboxColumns + ` FROM email_outbox WHERE id = $1`
+
+	message, err := scanOutboxMessage(conn.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, types.NewAppError(types.ErrCodeOutboxMessageNotFound, "outbox message not found")
+		}
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying outbox message: %w", err))
+	}
+
+	return message, http.StatusOK, nil
+}
+
+// RetryOutboxMessage puts a pending or dead message back in the queue with a
+// fresh set of attempts. Messages a worker is delivering are left alone, a
+// second worker could claim them otherwise and send them twice, unless their
+// lock expired because the worker crashed.
+func RetryOutboxMessage(id string) (*types.OutboxMessage, int, error) {
+	conn := db.GetDBConnection()
+
+	query := `UPDATE email_outbox
+		SET status = $1, attempts = 0, next_attempt_at = NOW(), locked_until = NULL, lock_token = NULL, updated_at = NOW()
+		WHERE id = $2 AND (status IN ($1, $3) OR (status = $4 AND locked_until < NOW()))
+		RETURNING ` + outboxColumns
+
+	message, err := scanOutboxMessage(conn.QueryRow(query, types.OutboxStatusPending, id, types.OutboxStatusDead, types.OutboxStatusSending))
+	if err == nil {
+		return message, http.StatusOK, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error retrying outbox message: %w", err))
+	}
+
+	// Nothing was updated: the message does not exist, was sent or is being delivered
+	message, statusCode, err := GetOutboxMessage(id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return nil, http.StatusConflict, retryConflict(message.Status)
+}
+
+// retryConflict explains why a message in status cannot be retried
+func retryConflict(status string) error {
+	if status == types.OutboxStatusSent {
+		return types.NewAppError(types.ErrCodeOutboxAlreadySent, "outbox message was already sent")
+	}
+	return types.NewAppError(types.ErrCodeOutboxInFlight, "outbox message is being delivered")
+}
+
+// RetryDeadOutboxMessages puts every dead-lettered message back in the queue and returns how many were requeued.
+func RetryDeadOutboxMessages() (int, int, error) {
+	conn := db.GetDBConnection()
+
+	query := `UPDATE email_outbox
+		SET status = $1, attempts = 0, next_attempt_at = NOW(), locked_until = NULL, lock_token = NULL, updated_at = NOW()
+		WHERE status = $2`
+
+	result, err := conn.Exec(query, types.OutboxStatusPending, types.OutboxStatusDead)
+	if err != nil {
+		return 0, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error retrying dead outbox messages: %w", err))
+	}
+
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return 0, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error retrying dead outbox messages: %w", err))
+	}
+
+	return int(requeued), http.StatusOK, nil
+}
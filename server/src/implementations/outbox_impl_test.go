@@ -0,0 +1,24 @@
+package implementations
+
+import (
+	"errors"
+	"testing"
+
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+func TestRetryConflict(t *testing.T) {
+	tests := []struct {
+		status string
+		want   types.ErrorCode
+	}{
+		{types.OutboxStatusSent, types.ErrCodeOutboxAlreadySent},
+		{types.OutboxStatusSending, types.ErrCodeOutboxInFlight},
+	}
+	for _, tt := range tests {
+		var appErr *types.AppError
+		if err := retryConflict(tt.status); !errors.As(err, &appErr) || appErr.Code != tt.want {
+			t.Errorf("retryConflict(%q) = %v, want %s", tt.status, err, tt.want)
+		}
+	}
+}
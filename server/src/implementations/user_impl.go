@@ -1,29 +1,32 @@
 package implementations
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
 
 	db "github.com/Mahaveer86619/ImaginAI/src/database"
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+	services "github.com/Mahaveer86619/ImaginAI/src/services"
 	types "github.com/Mahaveer86619/ImaginAI/src/types"
 )
 
 func GetAllUsers() ([]*types.UserSafeResponse, int, error) {
 	conn := db.GetDBConnection()
 
-	query := `SELECT id, name, email, password, gemini_api_key FROM users`
+	query := `SELECT ` + userSafeColumns + ` FROM users ORDER BY created_at`
 	rows, err := conn.Query(query)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error querying users: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying users: %w", err))
 	}
 	defer rows.Close()
 
 	var users []*types.UserSafeResponse
 	for rows.Next() {
 		var user types.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.GeminiAPIKey); err != nil {
-			return nil, http.StatusInternalServerError, fmt.Errorf("error scanning row: %w", err)
+		if err := scanUserSafe(rows, &user); err != nil {
+			return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error scanning row: %w", err))
 		}
 		users = append(users, user.ToUserSafeResponse())
 	}
@@ -34,86 +37,197 @@ func GetAllUsers() ([]*types.UserSafeResponse, int, error) {
 func GetUserByID(userID string) (*types.UserSafeResponse, int, error) {
 	conn := db.GetDBConnection()
 
-	query := `SELECT id, name, email, password, gemini_api_key FROM users WHERE id = $1`
+	query := `SELECT ` + userSafeColumns + ` FROM users WHERE id = $1`
 	var user types.User
 
-	err := conn.QueryRow(query, userID).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.GeminiAPIKey)
+	err := scanUserSafe(conn.QueryRow(query, userID), &user)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, http.StatusNotFound, fmt.Errorf("user not found")
+			return nil, http.StatusNotFound, types.NewAppError(types.ErrCodeUserNotFound, "user not found")
 		}
-		return nil, http.StatusInternalServerError, fmt.Errorf("error querying user: %w", err)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error querying user: %w", err))
 	}
 
 	return user.ToUserSafeResponse(), http.StatusOK, nil
 }
 
-func UpdateUser(user *types.UserSafeResponse) (*types.UserSafeResponse, int, error) {
+func UpdateUser(userID string, user *types.UpdateUserBody, info *types.RequestInfo) (*types.UserSafeResponse, int, error) {
 	conn := db.GetDBConnection()
 
-	search_query := `SELECT id FROM users WHERE id = $1`
+	search_query := `SELECT name, email, gemini_api_key, language, default_model FROM users WHERE id = $1`
 
 	update_query := `UPDATE users 
-	SET name = $1, email = $2, gemini_api_key = $3
-	WHERE id = $4
-	RETURNING id, name, email, gemini_api_key`
+	SET name = $1, email = $2, gemini_api_key = $3, language = COALESCE(NULLIF($4, ''), language),
+		default_model = COALESCE($5, default_model), updated_at = NOW()
+	WHERE id = $6
+	RETURNING ` + userSafeColumns
+
+	// Check if the user exists, keeping the current values to audit what changed
+	var existing types.User
+	var existingKey sql.NullString
+	err := conn.QueryRow(search_query, userID).Scan(&existing.Name, &existing.Email, &existingKey, &existing.Language, &existing.DefaultModel)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, types.NewAppError(types.ErrCodeUserNotFound, "user not found")
+		}
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error scanning row: %w", err))
+	}
 
-	// Check if the user exists
-	_, err := conn.Exec(search_query, user.ID)
+	previousKey, err := decryptGeminiKey(existingKey.String)
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(err)
+	}
+	geminiAPIKey, err := encryptGeminiKey(user.GeminiAPIKey)
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(err)
+	}
 
+	tx, err := conn.Begin()
 	if err != nil {
-		// Some error occurred
-		if err == sql.ErrNoRows {
-			return nil, http.StatusNotFound, fmt.Errorf("user not found with id: %s", user.ID)
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error starting transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	// Update the user
+	var userResp types.User
+	userResp.GeminiAPIKey = user.GeminiAPIKey
+
+	err = scanUserSafe(tx.QueryRow(
+		update_query,
+		user.Name,
+		user.Email,
+		geminiAPIKey,
+		user.Language,
+		user.DefaultModel,
+		userID,
+	), &userResp)
+
+	if err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error updating user profile: %w", err))
+	}
+
+	// Only field names are recorded, never the values
+	var changed []string
+	if existing.Name != userResp.Name {
+		changed = append(changed, "name")
+	}
+	if existing.Email != userResp.Email {
+		changed = append(changed, "email")
+	}
+	if existing.Language != userResp.Language {
+		changed = append(changed, "language")
+	}
+	if existing.DefaultModel != userResp.DefaultModel {
+		changed = append(changed, "default_model")
+	}
+	keyChanged := previousKey != userResp.GeminiAPIKey
+
+	if len(changed) > 0 {
+		err = services.RecordAuditEvent(context.Background(), tx, info, &types.AuditEvent{
+			SubjectID: userID,
+			Action:    types.AuditActionUserUpdated,
+			Metadata:  map[string]interface{}{"fields": changed},
+		})
+		if err != nil {
+			return nil, http.StatusInternalServerError, types.InternalError(err)
 		}
-		return nil, http.StatusInternalServerError, fmt.Errorf("error scanning row: %w", err)
-	} else {
-		// Update the user
-		var userResp types.User
-
-		err = conn.QueryRow(
-			update_query,
-			user.Name,
-			user.Email,
-			user.GeminiAPIKey,
-			user.ID,
-		).Scan(
-			&userResp.ID,
-			&userResp.Name,
-			&userResp.Email,
-			&userResp.GeminiAPIKey,
-		)
+	}
 
+	if keyChanged {
+		err = services.RecordAuditEvent(context.Background(), tx, info, &types.AuditEvent{
+			SubjectID: userID,
+			Action:    types.AuditActionGeminiKeyChanged,
+			Metadata:  map[string]interface{}{"removed": userResp.GeminiAPIKey == ""},
+		})
 		if err != nil {
-			return nil, http.StatusInternalServerError, fmt.Errorf("error updating user profile: %w", err)
+			return nil, http.StatusInternalServerError, types.InternalError(err)
+		}
+
+		if err := services.NotifyGeminiKeyChanged(context.Background(), tx, userID); err != nil {
+			return nil, http.StatusInternalServerError, types.InternalError(err)
 		}
+	}
 
-		// Generate user response
-		userResponse := userResp.ToUserSafeResponse()
-		return userResponse, http.StatusOK, nil
+	if err := tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, types.InternalError(fmt.Errorf("error committing user profile: %w", err))
 	}
+
+	// Generate user response
+	userResponse := userResp.ToUserSafeResponse()
+	return userResponse, http.StatusOK, nil
 }
 
-func DeleteUser(userId string) (int, error) {
+func DeleteUser(userId string, info *types.RequestInfo) (int, error) {
 	conn := db.GetDBConnection()
 
-	search_query := `SELECT id FROM users WHERE id = $1`
+	search_query := `SELECT email FROM users WHERE id = $1`
 	del_query := "DELETE FROM users WHERE id = $1"
 
 	// Check if the user exists
-	_, err := conn.Exec(search_query, userId)
+	var email string
+	err := conn.QueryRow(search_query, userId).Scan(&email)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return http.StatusNotFound, fmt.Errorf("user not found with id: %s", userId)
-		}
-		return http.StatusInternalServerError, fmt.Errorf("error scanning row: %w", err)
-	} else {
-		_, err = conn.Exec(del_query, userId)
-		if err != nil {
-			return http.StatusInternalServerError, fmt.Errorf("error deleting row: %w", err)
+			return http.StatusNotFound, types.NewAppError(types.ErrCodeUserNotFound, "user not found")
 		}
+		return http.StatusInternalServerError, types.InternalError(fmt.Errorf("error scanning row: %w", err))
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, types.InternalError(fmt.Errorf("error starting transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(del_query, userId)
+	if err != nil {
+		return http.StatusInternalServerError, types.InternalError(fmt.Errorf("error deleting row: %w", err))
+	}
+
+	// The email is kept so the event stays meaningful once the user is gone
+	err = services.RecordAuditEvent(context.Background(), tx, info, &types.AuditEvent{
+		SubjectID: userId,
+		Action:    types.AuditActionUserDeleted,
+		Metadata:  map[string]interface{}{"email": email},
+	})
+	if err != nil {
+		return http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	if err := services.NotifyGeminiKeyChanged(context.Background(), tx, userId); err != nil {
+		return http.StatusInternalServerError, types.InternalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return http.StatusInternalServerError, types.InternalError(fmt.Errorf("error committing user deletion: %w", err))
 	}
 
 	return http.StatusOK, nil
 }
+
+// encryptGeminiKey encrypts a Gemini API key for storage when ENCRYPTION_KEYS is configured
+func encryptGeminiKey(key string) (string, error) {
+	keyring, err := helpers.GetSecretKeyring()
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := keyring.EncryptSecret(key)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting gemini api key: %w", err)
+	}
+	return encrypted, nil
+}
+
+// decryptGeminiKey returns the plaintext of a stored Gemini API key
+func decryptGeminiKey(stored string) (string, error) {
+	keyring, err := helpers.GetSecretKeyring()
+	if err != nil {
+		return "", err
+	}
+	key, err := keyring.DecryptSecret(stored)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting gemini api key: %w", err)
+	}
+	return key, nil
+}
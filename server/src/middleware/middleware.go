@@ -3,15 +3,18 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/Mahaveer86619/ImaginAI/src/types"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey   = contextKey("user")
+	claimsContextKey = contextKey("claims")
+)
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,32 +22,97 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		if authHeader == "" {
 			failureResponse := types.Failure{}
 			failureResponse.SetStatusCode(http.StatusUnauthorized)
+			failureResponse.SetCode(types.ErrCodeAuthMissingToken)
 			failureResponse.SetMessage("Authorization header is required")
-			failureResponse.JSON(w)
+			failureResponse.Write(w, r)
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return JwtKey, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := ParseToken(tokenString, TokenTypeAccess)
+		if err != nil {
 			failureResponse := types.Failure{}
 			failureResponse.SetStatusCode(http.StatusUnauthorized)
+			failureResponse.SetCode(types.ErrCodeAuthInvalidToken)
 			failureResponse.SetMessage("Invalid token")
-			failureResponse.JSON(w)
+			failureResponse.Write(w, r)
+			return
+		}
+
+		// Tokens of disabled users and revoked tokens are rejected even before they expire
+		active, err := tokenIsActive(claims)
+		if err != nil {
+			failureResponse := types.Failure{}
+			failureResponse.SetStatusCode(http.StatusInternalServerError)
+			failureResponse.SetError(types.InternalError(err))
+			failureResponse.Write(w, r)
+			return
+		}
+		if !active {
+			failureResponse := types.Failure{}
+			failureResponse.SetStatusCode(http.StatusUnauthorized)
+			failureResponse.SetCode(types.ErrCodeAuthInvalidToken)
+			failureResponse.SetMessage("Token has been revoked")
+			failureResponse.Write(w, r)
 			return
 		}
 
 		// Token is valid, proceed to set the context
 		ctx := context.WithValue(r.Context(), userContextKey, claims.Email)
+		ctx = context.WithValue(ctx, claimsContextKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RequireRole only lets through requests whose token carries one of roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok || !slices.Contains(roles, claims.Role) {
+				forbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSelfOrRole only lets through requests for the caller's own {id} path
+// parameter, or from a caller holding one of roles. It must run after AuthMiddleware.
+func RequireSelfOrRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				forbidden(w, r)
+				return
+			}
+			if claims.UserID != r.PathValue("id") && !slices.Contains(roles, claims.Role) {
+				forbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request) {
+	failureResponse := types.Failure{}
+	failureResponse.SetStatusCode(http.StatusForbidden)
+	failureResponse.SetCode(types.ErrCodeForbidden)
+	failureResponse.SetMessage("You are not allowed to access this resource")
+	failureResponse.Write(w, r)
+}
+
 func UserFromContext(ctx context.Context) (string, bool) {
 	email, ok := ctx.Value(userContextKey).(string)
 	return email, ok
 }
+
+// ClaimsFromContext returns the token claims stored by AuthMiddleware.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok
+}
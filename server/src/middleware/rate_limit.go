@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+	"github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+// bucket is a token bucket for a single client.
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimit allows each client IP up to limit requests per window, refilling
+// continuously so that short bursts up to limit are accepted.
+func RateLimit(limit int, window time.Duration) func(http.Handler) http.Handler {
+	var (
+		mu        sync.Mutex
+		buckets   = map[string]*bucket{}
+		lastSweep = time.Now()
+	)
+	refillPerSecond := float64(limit) / window.Seconds()
+
+	// allow reports whether the client may proceed, and otherwise how long it should wait
+	allow := func(key string, now time.Time) (bool, time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Forget clients that have been idle long enough to have a full bucket again
+		if now.Sub(lastSweep) > window {
+			for k, b := range buckets {
+				if now.Sub(b.lastSeen) > window {
+					delete(buckets, k)
+				}
+			}
+			lastSweep = now
+		}
+
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{tokens: float64(limit), lastSeen: now}
+			buckets[key] = b
+		}
+
+		b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.lastSeen).Seconds()*refillPerSecond)
+		b.lastSeen = now
+
+		if b.tokens < 1 {
+			return false, time.Duration((1 - b.tokens) / refillPerSecond * float64(time.Second))
+		}
+		b.tokens--
+		return true, 0
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, retryAfter := allow(helpers.ClientIP(r), time.Now())
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+				failureResponse := types.Failure{}
+				failureResponse.SetStatusCode(http.StatusTooManyRequests)
+				failureResponse.SetCode(types.ErrCodeRateLimited)
+				failureResponse.SetMessage("Too many requests, please slow down")
+				failureResponse.Write(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
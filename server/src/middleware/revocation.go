@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+
+	db "github.com/Mahaveer86619/ImaginAI/src/database"
+
+	"github.com/google/uuid"
+)
+
+// tokenIsActive reports whether the user the token was issued to still exists,
+// is not disabled and has not revoked their tokens since it was issued.
+func tokenIsActive(claims *Claims) (bool, error) {
+	if _, err := uuid.Parse(claims.UserID); err != nil {
+		return false, nil
+	}
+
+	query := `SELECT token_version, disabled_at IS NOT NULL FROM users WHERE id = $1`
+
+	var tokenVersion int
+	var disabled bool
+	err := db.GetDBConnection().QueryRow(query, claims.UserID).Scan(&tokenVersion, &disabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking token: %w", err)
+	}
+
+	return !disabled && tokenVersion == claims.TokenVersion, nil
+}
@@ -1,41 +1,92 @@
 package middleware
 
 import (
-	"os"
+	"errors"
+	"fmt"
 	"time"
 
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Token types, both kinds of token are signed with the same key
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// Claims are also validated by the chat-bot, which shares JWT_SECRET with the server
 type Claims struct {
-	Email string `json:"email"`
-	jwt.MapClaims
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	// TokenVersion must match the user's token_version, bumping it revokes every issued token
+	TokenVersion int `json:"token_version"`
+	// TokenType keeps a long lived refresh token from being used as an access token
+	TokenType string `json:"token_type"`
+	jwt.RegisteredClaims
 }
 
-var JwtKey = []byte(os.Getenv("JWT_SECRET"))
+// JWTKey returns the key tokens are signed with. It is read lazily so that
+// JWT_SECRET may come from the .env file loaded at startup.
+func JWTKey() []byte {
+	return []byte(helpers.GetEnv("JWT_SECRET", ""))
+}
 
-func GenerateToken(email string) (string, error) {
+func GenerateToken(userID string, email string, role string, tokenVersion int) (string, error) {
 	expirationTime := time.Now().Add(25 * time.Hour) // 1 day + 1 hour
-	claims := &Claims{
-		Email: email,
-		MapClaims: jwt.MapClaims{
-			"email": email,
-			"exp":   expirationTime.Unix(),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(JwtKey)
+	return signToken(userID, email, role, tokenVersion, TokenTypeAccess, expirationTime)
 }
 
-func GenerateRefreshToken(email string) (string, error) {
+func GenerateRefreshToken(userID string, email string, role string, tokenVersion int) (string, error) {
 	expirationTime := time.Now().Add(721 * time.Hour) // 30 days + 1 hour
+	return signToken(userID, email, role, tokenVersion, TokenTypeRefresh, expirationTime)
+}
+
+func signToken(userID string, email string, role string, tokenVersion int, tokenType string, expirationTime time.Time) (string, error) {
+	key := JWTKey()
+	if len(key) == 0 {
+		return "", errors.New("JWT_SECRET is not configured")
+	}
+
 	claims := &Claims{
-		Email: email,
-		MapClaims: jwt.MapClaims{
-			"email": email,
-			"exp":   expirationTime.Unix(),
+		UserID:       userID,
+		Email:        email,
+		Role:         role,
+		TokenVersion: tokenVersion,
+		TokenType:    tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(JwtKey)
+	return token.SignedString(key)
+}
+
+// ParseToken verifies the signature, expiry and type of a token and returns its
+// claims. Tokens issued before types were added have none and are rejected.
+func ParseToken(tokenString string, tokenType string) (*Claims, error) {
+	key := JWTKey()
+	if len(key) == 0 {
+		return nil, errors.New("JWT_SECRET is not configured")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if claims.TokenType != tokenType {
+		return nil, fmt.Errorf("expected a %s token, got %q", tokenType, claims.TokenType)
+	}
+
+	return claims, nil
 }
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/ImaginAI/src/types"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testUserID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+func TestParseToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	access, err := GenerateToken(testUserID, "ada@example.com", "user", 3)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	refresh, err := GenerateRefreshToken(testUserID, "ada@example.com", "user", 3)
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	expired, _ := signToken(testUserID, "ada@example.com", "user", 3, TokenTypeAccess, time.Now().Add(-time.Minute))
+	untyped, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID:           testUserID,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString(JWTKey())
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID:           testUserID,
+		TokenType:        TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString([]byte("another-secret"))
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenType string
+		wantErr   bool
+	}{
+		{"access token", access, TokenTypeAccess, false},
+		{"refresh token", refresh, TokenTypeRefresh, false},
+		{"refresh token used for access", refresh, TokenTypeAccess, true},
+		{"access token used to refresh", access, TokenTypeRefresh, true},
+		{"token without a type", untyped, TokenTypeAccess, true},
+		{"expired token", expired, TokenTypeAccess, true},
+		{"token signed with another secret", forged, TokenTypeAccess, true},
+		{"malformed token", "not-a-token", TokenTypeAccess, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(tt.token, tt.tokenType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseToken() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.UserID != testUserID || claims.TokenVersion != 3 || claims.TokenType != tt.tokenType) {
+				t.Errorf("claims = %+v, want the claims of the issued token", claims)
+			}
+		})
+	}
+}
+
+// AuthMiddleware answers these requests before looking the user up
+func TestAuthMiddlewareRejectsBadTokens(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	refresh, err := GenerateRefreshToken(testUserID, "ada@example.com", "user", 0)
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		code          types.ErrorCode
+	}{
+		{"missing token", "", types.ErrCodeAuthMissingToken},
+		{"malformed token", "Bearer not-a-token", types.ErrCodeAuthInvalidToken},
+		{"refresh token", "Bearer " + refresh, types.ErrCodeAuthInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+testUserID, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("request reached the handler")
+			})).ServeHTTP(w, r)
+
+			var failure types.Failure
+			if err := json.NewDecoder(w.Body).Decode(&failure); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if w.Code != http.StatusUnauthorized || failure.Code != tt.code {
+				t.Errorf("response = %d %s, want 401 %s", w.Code, failure.Code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"net/http"
+
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+// router.go is the source of truth of the router of both services.
+// chat-bot/internal/router/router.go is an exact copy, the two modules sharing
+// no code: change this module's copy, then copy it there along with
+// router_test.go. Only writeRouteError, in this file, differs between them.
+
+// routeErrorCodes are the error codes of the failures the router answers itself
+var routeErrorCodes = map[int]types.ErrorCode{
+	http.StatusNotFound:         types.ErrCodeNotFound,
+	http.StatusMethodNotAllowed: types.ErrCodeMethodNotAllowed,
+}
+
+// writeRouteError answers a request no route matches with the failure envelope
+func writeRouteError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+	failureResponse := types.Failure{}
+	failureResponse.SetStatusCode(statusCode)
+	failureResponse.SetCode(routeErrorCodes[statusCode])
+	failureResponse.SetMessage(message)
+	failureResponse.Write(w, r)
+}
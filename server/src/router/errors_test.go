@@ -0,0 +1,37 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+)
+
+func TestRouteErrors(t *testing.T) {
+	tests := []struct {
+		method  string
+		path    string
+		status  int
+		code    types.ErrorCode
+		message string
+	}{
+		{method: http.MethodGet, path: "/missing", status: http.StatusNotFound, code: types.ErrCodeNotFound, message: "Route not found"},
+		{method: http.MethodPut, path: "/health", status: http.StatusMethodNotAllowed, code: types.ErrCodeMethodNotAllowed, message: "Method not allowed"},
+	}
+
+	rt := testRouter()
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		var failure types.Failure
+		if err := json.NewDecoder(w.Body).Decode(&failure); err != nil {
+			t.Fatalf("decoding %s %s: %v", tt.method, tt.path, err)
+		}
+		if w.Header().Get("Content-Type") != "application/json" || failure.StatusCode != tt.status || failure.Code != tt.code || failure.Message != tt.message {
+			t.Errorf("%s %s = %+v, want the %s envelope", tt.method, tt.path, failure, tt.code)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Middleware wraps a handler with extra behaviour (auth, rate limiting...).
+type Middleware func(http.Handler) http.Handler
+
+// Route is a registered method and path pattern, e.g. GET /api/v1/users/{id}.
+type Route struct {
+	Method  string
+	Pattern string
+}
+
+// Router is a thin layer over http.ServeMux that adds route groups with their
+// own middleware chains and consistent 404/405 responses.
+type Router struct {
+	mux         *http.ServeMux
+	prefix      string
+	middlewares []Middleware
+	table       *routeTable
+}
+
+// routeTable is shared by a router and all of its groups.
+type routeTable struct {
+	routes []Route
+}
+
+func New() *Router {
+	return &Router{
+		mux:   http.NewServeMux(),
+		table: &routeTable{},
+	}
+}
+
+// Group returns a sub-router whose routes are prefixed with prefix and wrapped
+// by the parent's middleware followed by middlewares.
+func (rt *Router) Group(prefix string, middlewares ...Middleware) *Router {
+	return &Router{
+		mux:         rt.mux,
+		prefix:      rt.prefix + prefix,
+		middlewares: append(slices.Clone(rt.middlewares), middlewares...),
+		table:       rt.table,
+	}
+}
+
+// Use appends middlewares to the chain of routes registered after the call.
+func (rt *Router) Use(middlewares ...Middleware) {
+	rt.middlewares = append(rt.middlewares, middlewares...)
+}
+
+// Handle registers handler for method and path. Path may contain wildcards such as {id},
+// available to the handler through r.PathValue.
+func (rt *Router) Handle(method string, path string, handler http.HandlerFunc, middlewares ...Middleware) {
+	pattern := rt.prefix + path
+
+	var h http.Handler = handler
+	chain := append(slices.Clone(rt.middlewares), middlewares...)
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i](h)
+	}
+
+	rt.mux.Handle(method+" "+pattern, h)
+	rt.table.routes = append(rt.table.routes, Route{Method: method, Pattern: pattern})
+}
+
+func (rt *Router) Get(path string, handler http.HandlerFunc, middlewares ...Middleware) {
+	rt.Handle(http.MethodGet, path, handler, middlewares...)
+}
+
+func (rt *Router) Post(path string, handler http.HandlerFunc, middlewares ...Middleware) {
+	rt.Handle(http.MethodPost, path, handler, middlewares...)
+}
+
+func (rt *Router) Put(path string, handler http.HandlerFunc, middlewares ...Middleware) {
+	rt.Handle(http.MethodPut, path, handler, middlewares...)
+}
+
+func (rt *Router) Patch(path string, handler http.HandlerFunc, middlewares ...Middleware) {
+	rt.Handle(http.MethodPatch, path, handler, middlewares...)
+}
+
+func (rt *Router) Delete(path string, handler http.HandlerFunc, middlewares ...Middleware) {
+	rt.Handle(http.MethodDelete, path, handler, middlewares...)
+}
+
+// Routes returns every registered route in registration order.
+func (rt *Router) Routes() []Route {
+	return slices.Clone(rt.table.routes)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern != "" {
+		rt.mux.ServeHTTP(w, r)
+		return
+	}
+
+	// No route matched. Let the mux decide between 404 and 405 (it computes the
+	// Allow header from the registered patterns), then answer in our own format.
+	rec := &statusRecorder{header: http.Header{}}
+	rt.mux.ServeHTTP(rec, r)
+
+	if rec.statusCode == http.StatusMethodNotAllowed {
+		w.Header().Set("Allow", rec.header.Get("Allow"))
+		writeRouteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeRouteError(w, r, http.StatusNotFound, "Route not found")
+}
+
+// statusRecorder captures the status code and headers of the mux's default error responses.
+type statusRecorder struct {
+	header     http.Header
+	statusCode int
+}
+
+func (rec *statusRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (rec *statusRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tag is a middleware recording its name in the X-Chain response header
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func testRouter() *Router {
+	rt := New()
+	rt.Use(tag("root"))
+	rt.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	api := rt.Group("/api/v1", tag("api"))
+	api.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + r.PathValue("id")))
+	})
+	api.Delete("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, tag("admin"))
+	api.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	return rt
+}
+
+func TestServeHTTP(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		chain  string
+		allow  []string
+	}{
+		{method: http.MethodGet, path: "/health", status: http.StatusOK, body: "ok", chain: "root"},
+		{method: http.MethodGet, path: "/api/v1/users/42", status: http.StatusOK, body: "user 42", chain: "root,api"},
+		{method: http.MethodDelete, path: "/api/v1/users/42", status: http.StatusNoContent, chain: "root,api,admin"},
+		{method: http.MethodPost, path: "/api/v1/users", status: http.StatusCreated, chain: "root,api"},
+		// GET routes answer HEAD requests too
+		{method: http.MethodHead, path: "/health", status: http.StatusOK, body: "ok", chain: "root"},
+		{method: http.MethodGet, path: "/missing", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/api/v1/users/42/posts", status: http.StatusNotFound},
+		{method: http.MethodPut, path: "/api/v1/users/42", status: http.StatusMethodNotAllowed, allow: []string{"DELETE", "GET", "HEAD"}},
+		{method: http.MethodGet, path: "/api/v1/users", status: http.StatusMethodNotAllowed, allow: []string{"POST"}},
+	}
+
+	rt := testRouter()
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status < http.StatusBadRequest && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := strings.Join(w.Header().Values("X-Chain"), ","); got != tt.chain {
+				t.Errorf("middleware chain = %q, want %q", got, tt.chain)
+			}
+
+			var allow []string
+			if header := w.Header().Get("Allow"); header != "" {
+				allow = strings.Split(header, ", ")
+				slices.Sort(allow)
+			}
+			if !slices.Equal(allow, tt.allow) {
+				t.Errorf("Allow = %v, want %v", allow, tt.allow)
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	var got []string
+	for _, route := range testRouter().Routes() {
+		got = append(got, route.Method+" "+route.Pattern)
+	}
+
+	want := []string{
+		"GET /health",
+		"GET /api/v1/users/{id}",
+		"DELETE /api/v1/users/{id}",
+		"POST /api/v1/users",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("routes = %v, want %v", got, want)
+	}
+}
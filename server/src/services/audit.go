@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// RecordAuditEvent appends event to the audit log. Passing the transaction of
+// the change being audited guarantees the event exists if and only if the change does.
+func RecordAuditEvent(ctx context.Context, exec Execer, info *types.RequestInfo, event *types.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (id, actor_id, subject_id, action, ip, user_agent, metadata, created_at)
+		VALUES ($1, NULLIF($2, '')::UUID, NULLIF($3, '')::UUID, $4, $5, $6, $7, NOW())
+	`
+
+	if info != nil {
+		if event.ActorID == "" {
+			event.ActorID = info.ActorID
+		}
+		event.IP = info.IP
+		event.UserAgent = info.UserAgent
+	}
+
+	metadata := []byte("{}")
+	if len(event.Metadata) > 0 {
+		var err error
+		if metadata, err = json.Marshal(event.Metadata); err != nil {
+			return fmt.Errorf("error encoding audit metadata: %w", err)
+		}
+	}
+
+	event.ID = uuid.New().String()
+	_, err := exec.ExecContext(ctx, query, event.ID, event.ActorID, event.SubjectID, event.Action, event.IP, event.UserAgent, string(metadata))
+	if err != nil {
+		return fmt.Errorf("error recording audit event: %w", err)
+	}
+
+	return nil
+}
+
+// AuditRetention periodically deletes audit events older than Retention.
+// Deleting expired events is the only way rows ever leave the audit log.
+type AuditRetention struct {
+	DB        *sql.DB
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+func NewAuditRetentionFromEnv(db *sql.DB) *AuditRetention {
+	return &AuditRetention{
+		DB:        db,
+		Retention: helpers.GetEnvDuration("AUDIT_RETENTION", 365*24*time.Hour),
+		Interval:  helpers.GetEnvDuration("AUDIT_RETENTION_INTERVAL", time.Hour),
+	}
+}
+
+// Run prunes expired events until ctx is cancelled. A zero Retention keeps events forever.
+func (a *AuditRetention) Run(ctx context.Context) {
+	if a.Retention <= 0 {
+		logrus.Info("Audit log retention disabled, events are kept forever")
+		return
+	}
+
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := a.Prune(ctx)
+		if err != nil {
+			logrus.WithError(err).Error("Error pruning audit events")
+		} else if deleted > 0 {
+			logrus.WithField("deleted", deleted).Info("Pruned expired audit events")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune deletes the events older than Retention and returns how many were removed
+func (a *AuditRetention) Prune(ctx context.Context) (int64, error) {
+	result, err := a.DB.ExecContext(ctx,
+		`DELETE FROM audit_events WHERE created_at < NOW() - ($1 * INTERVAL '1 second')`,
+		int64(a.Retention.Seconds()),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
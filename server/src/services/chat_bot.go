@@ -1,2 +1,20 @@
 package services
 
+import (
+	"context"
+	"fmt"
+)
+
+// GeminiKeyChangedChannel is the Postgres channel the chat-bot listens on to
+// drop the Gemini clients it cached for a user. The payload is the user id.
+const GeminiKeyChangedChannel = "gemini_api_key_changed"
+
+// NotifyGeminiKeyChanged tells the chat-bot that a user's Gemini API key changed
+// or was removed. Notifications sent inside a transaction are only delivered
+// once it commits, so the chat-bot never reloads a key that was rolled back.
+func NotifyGeminiKeyChanged(ctx context.Context, exec Execer, userID string) error {
+	if _, err := exec.ExecContext(ctx, `SELECT pg_notify($1, $2)`, GeminiKeyChangedChannel, userID); err != nil {
+		return fmt.Errorf("error notifying gemini api key change: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+)
+
+// defaultDKIMHeaders are signed when present in the message
+var defaultDKIMHeaders = []string{
+	"From", "To", "Reply-To", "Subject", "Date", "Message-ID",
+	"MIME-Version", "Content-Type", "Content-Transfer-Encoding",
+}
+
+// DKIMSigner signs messages with rsa-sha256 using relaxed/relaxed
+// canonicalization (RFC 6376).
+type DKIMSigner struct {
+	Domain     string
+	Selector   string
+	PrivateKey *rsa.PrivateKey
+	// Headers lists the header fields to sign, defaultDKIMHeaders when empty
+	Headers []string
+}
+
+var (
+	dkimSigner   *DKIMSigner
+	dkimSignerMu sync.RWMutex
+)
+
+// SetDKIMSigner sets the signer applied to every built message, nil disables signing
+func SetDKIMSigner(s *DKIMSigner) {
+	dkimSignerMu.Lock()
+	defer dkimSignerMu.Unlock()
+	dkimSigner = s
+}
+
+// GetDKIMSigner returns the configured signer, or nil when signing is disabled
+func GetDKIMSigner() *DKIMSigner {
+	dkimSignerMu.RLock()
+	defer dkimSignerMu.RUnlock()
+	return dkimSigner
+}
+
+// NewDKIMSignerFromEnv builds a signer from DKIM_DOMAIN, DKIM_SELECTOR and either
+// DKIM_PRIVATE_KEY (PEM) or DKIM_PRIVATE_KEY_PATH. It returns nil when DKIM is not configured.
+func NewDKIMSignerFromEnv() (*DKIMSigner, error) {
+	domain := helpers.GetEnv("DKIM_DOMAIN", "")
+	selector := helpers.GetEnv("DKIM_SELECTOR", "")
+	keyPEM := helpers.GetEnv("DKIM_PRIVATE_KEY", "")
+	keyPath := helpers.GetEnv("DKIM_PRIVATE_KEY_PATH", "")
+
+	if domain == "" && selector == "" && keyPEM == "" && keyPath == "" {
+		return nil, nil
+	}
+	if domain == "" || selector == "" {
+		return nil, errors.New("DKIM_DOMAIN and DKIM_SELECTOR are required for DKIM signing")
+	}
+
+	if keyPEM == "" {
+		if keyPath == "" {
+			return nil, errors.New("DKIM_PRIVATE_KEY or DKIM_PRIVATE_KEY_PATH is required for DKIM signing")
+		}
+		data, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading DKIM private key: %w", err)
+		}
+		keyPEM = string(data)
+	}
+
+	// Allow the PEM to be passed on a single line with escaped newlines
+	key, err := parseRSAPrivateKey([]byte(strings.ReplaceAll(keyPEM, `\n`, "\n")))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DKIMSigner{
+		Domain:     domain,
+		Selector:   selector,
+		PrivateKey: key,
+	}, nil
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("DKIM private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing DKIM private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("DKIM private key must be an RSA key")
+	}
+	return key, nil
+}
+
+// Sign returns message with a DKIM-Signature header prepended
+func (s *DKIMSigner) Sign(message []byte) ([]byte, error) {
+	headerBlock, body, found := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !found {
+		return nil, errors.New("message has no header/body separator")
+	}
+
+	bodyHash := sha256.Sum256(canonicalBodyRelaxed(body))
+
+	headers := parseHeaderFields(headerBlock)
+	wanted := s.Headers
+	if len(wanted) == 0 {
+		wanted = defaultDKIMHeaders
+	}
+
+	var signed []string
+	var data bytes.Buffer
+	for _, name := range wanted {
+		field, ok := headers[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		signed = append(signed, strings.ToLower(name))
+		data.WriteString(canonicalHeaderRelaxed(field) + "\r\n")
+	}
+
+	value := fmt.Sprintf(
+		"v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.Domain, s.Selector, time.Now().Unix(), strings.Join(signed, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+
+	// The signature header itself is signed with an empty b= and no trailing CRLF
+	data.WriteString(canonicalHeaderRelaxed("DKIM-Signature: " + value))
+	digest := sha256.Sum256(data.Bytes())
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing message: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("DKIM-Signature: " + value + foldBase64(base64.StdEncoding.EncodeToString(signature)) + "\r\n")
+	out.Write(message)
+	return out.Bytes(), nil
+}
+
+// parseHeaderFields maps lowercased header names to their raw, possibly folded,
+// field. When a header repeats, the last instance is the one signed.
+func parseHeaderFields(block []byte) map[string]string {
+	fields := map[string]string{}
+	var current string
+
+	flush := func() {
+		if name, _, ok := strings.Cut(current, ":"); ok {
+			fields[strings.ToLower(strings.TrimSpace(name))] = current
+		}
+	}
+
+	for _, line := range strings.Split(string(block), "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			current += "\r\n" + line
+			continue
+		}
+		flush()
+		current = line
+	}
+	flush()
+
+	return fields
+}
+
+// canonicalHeaderRelaxed implements the relaxed header canonicalization of RFC 6376 section 3.4.2
+func canonicalHeaderRelaxed(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.Join(strings.Fields(value), " ")
+}
+
+// canonicalBodyRelaxed implements the relaxed body canonicalization of RFC 6376 section 3.4.4
+func canonicalBodyRelaxed(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(collapseWhitespace(line), " ")
+	}
+
+	// Trailing empty lines are ignored
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func collapseWhitespace(line string) string {
+	var b strings.Builder
+	inSpace := false
+	for _, r := range line {
+		if r == ' ' || r == '\t' {
+			if !inSpace {
+				b.WriteByte(' ')
+			}
+			inSpace = true
+			continue
+		}
+		inSpace = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// foldBase64 folds a long signature over several lines, which is ignored by
+// verifiers since whitespace inside b= is not significant.
+func foldBase64(value string) string {
+	var b strings.Builder
+	for len(value) > 72 {
+		b.WriteString(value[:72] + "\r\n\t")
+		value = value[72:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
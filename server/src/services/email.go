@@ -1,8 +1,7 @@
 package services
 
 import (
-	"net/smtp"
-	"os"
+	"context"
 )
 
 // BasicEmailRequestBody is the request body for sending normal string emails
@@ -20,46 +19,20 @@ type TemplateEmailRequestBody struct {
 	Vars     map[string]string `json:"vars"`
 }
 
+// SendBasicEmail sends a plain text email through the configured mailer
 func SendBasicEmail(to []string, subject string, body string) error {
-	auth := smtp.PlainAuth(
-		"",
-		os.Getenv("FROM_EMAIL"),
-		os.Getenv("FROM_EMAIL_PASSWORD"),
-		os.Getenv("SMTP_SERVER"),
-	)
-
-	message := "Subject: " + subject + "\n" + body
-
-	err := smtp.SendMail(
-		os.Getenv("SMTP_ADDRESS"),
-		auth,
-		os.Getenv("FROM_EMAIL"),
-		to,
-		[]byte(message),
-	)
-
-	return err
+	return GetMailer().Send(context.Background(), &Email{
+		To:      to,
+		Subject: subject,
+		Text:    body,
+	})
 }
 
+// SendBasicHTMLEmail sends an HTML email through the configured mailer
 func SendBasicHTMLEmail(to []string, subject string, htmlBody string) error {
-	auth := smtp.PlainAuth(
-		"",
-		os.Getenv("FROM_EMAIL"),
-		os.Getenv("FROM_EMAIL_PASSWORD"),
-		os.Getenv("SMTP_SERVER"),
-	)
-
-	headers := "MIME-Version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";"
-
-	message := "Subject: " + subject + "\n" + headers + "\n\n" + htmlBody
-
-	err := smtp.SendMail(
-		os.Getenv("SMTP_ADDRESS"),
-		auth,
-		os.Getenv("FROM_EMAIL"),
-		to,
-		[]byte(message),
-	)
-
-	return err
+	return GetMailer().Send(context.Background(), &Email{
+		To:      to,
+		Subject: subject,
+		HTML:    htmlBody,
+	})
 }
@@ -1,195 +1,195 @@
 package services
 
 import (
+	"bytes"
+	"embed"
 	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
 	"time"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+)
+
+// Email template names
+const (
+	TemplateWelcome       = "welcome"
+	TemplatePasswordReset = "password_reset"
 )
 
-func GenerateWelcomeHTML(recipientEmail string) string {
-	return fmt.Sprintf(`
-        <!DOCTYPE html>
-        <html lang="en">
-        <head>
-            <meta charset="UTF-8">
-            <meta name="viewport" content="width=device-width, initial-scale=1.0">
-            <title>Welcome to ImaginAI!</title>
-            <style>
-                body {
-                    font-family: 'Helvetica Neue', Helvetica, Arial, sans-serif;
-                    line-height: 1.6;
-                    color: #333333;
-                    background-color: #f7f7f7;
-                    margin: 0;
-                    padding: 0;
-                }
-                .container {
-                    max-width: 500px;
-                    margin: 30px auto;
-                    background: #ffffff;
-                    border-radius: 8px;
-                    box-shadow: 0 4px 12px rgba(0, 0, 0, 0.05);
-                    padding: 30px;
-                    border: 1px solid #e0e0e0;
-                }
-                h2 {
-                    color: #1a1a1a;
-                    font-size: 28px;
-                    margin-bottom: 20px;
-                    text-align: center;
-                }
-                p {
-                    margin-bottom: 15px;
-                }
-                .button-container {
-                    text-align: center;
-                    margin: 30px 0;
-                }
-                .button {
-                    display: inline-block;
-                    background-color: #28a745; /* A pleasant green for action */
-                    color: #ffffff;
-                    padding: 12px 25px;
-                    border-radius: 5px;
-                    text-decoration: none;
-                    font-weight: bold;
-                    font-size: 16px;
-                    transition: background-color 0.3s ease;
-                }
-                .button:hover {
-                    background-color: #218838; /* Darker green on hover */
-                }
-                .footer {
-                    margin-top: 30px;
-                    font-size: 0.9em;
-                    color: #777777;
-                    text-align: center;
-                    border-top: 1px solid #eeeeee;
-                    padding-top: 20px;
-                }
-                a {
-                    color: #007bff;
-                    text-decoration: none;
-                }
-                a:hover {
-                    text-decoration: underline;
-                }
-            </style>
-        </head>
-        <body>
-            <div class="container">
-                <h2>Welcome to ImaginAI!</h2>
-                <p>Hello <strong>%s</strong>,</p>
-                <p>Thank you for joining the ImaginAI community! We're thrilled to have you on board.</p>
-                <p>At ImaginAI, we empower you to unleash your creativity with AI-powered ai chat agents.</p>
-                <br/><br/>
-                <p>If you have any questions or need assistance, our support team is always here to help. Feel free to reply to this email or visit our help center.</p>
-                <p>Happy creating!<br/>The ImaginAI Team</p>
-            </div>
-            <div class="footer">
-                <p>&copy; %d ImaginAI. All rights reserved.</p>
-                <p><a href="[Your Website Link]">Our Website</a> | <a href="[Your Privacy Policy Link]">Privacy Policy</a> | <a href="[Your Support Link]">Support</a></p>
-            </div>
-        </body>
-        </html>
-    `, recipientEmail, time.Now().Year())
+// DefaultLocale is used when a user has no language preference or their language has no templates.
+const DefaultLocale = "en"
+
+// Every locale directory under templates/ holds common.tmpl plus an .html and a
+// .txt file per template, each defining "subject" and "content" blocks that
+// are rendered inside the shared layout.
+//
+//go:embed templates
+var templateFS embed.FS
+
+// EmailData is the data available to every email template. Fields that only
+// make sense for some templates are left empty by the others.
+type EmailData struct {
+	AppName    string
+	WebsiteURL string
+	Year       int
+	Locale     string
+
+	Name  string
+	Email string
+	Code  string
+}
+
+// RenderedEmail is a template rendered for one recipient
+type RenderedEmail struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+type localeTemplates struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+var emailTemplates = mustParseEmailTemplates()
+
+func mustParseEmailTemplates() map[string]*localeTemplates {
+	parsed, err := parseEmailTemplates(templateFS)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseEmailTemplates(fsys fs.FS) (map[string]*localeTemplates, error) {
+	entries, err := fs.ReadDir(fsys, "templates")
+	if err != nil {
+		return nil, fmt.Errorf("error reading email templates: %w", err)
+	}
+
+	locales := map[string]*localeTemplates{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		locale := entry.Name()
+		dir := path.Join("templates", locale)
+		common := path.Join(dir, "common.tmpl")
+
+		lt := &localeTemplates{
+			html: map[string]*htmltemplate.Template{},
+			text: map[string]*texttemplate.Template{},
+		}
+
+		for _, name := range []string{TemplateWelcome, TemplatePasswordReset} {
+			htmlTmpl, err := htmltemplate.ParseFS(fsys, "templates/layout.html", common, path.Join(dir, name+".html"))
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s/%s.html: %w", locale, name, err)
+			}
+			textTmpl, err := texttemplate.ParseFS(fsys, "templates/layout.txt", common, path.Join(dir, name+".txt"))
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s/%s.txt: %w", locale, name, err)
+			}
+			lt.html[name] = htmlTmpl
+			lt.text[name] = textTmpl
+		}
+
+		locales[locale] = lt
+	}
+
+	if _, ok := locales[DefaultLocale]; !ok {
+		return nil, fmt.Errorf("email templates for default locale %q are missing", DefaultLocale)
+	}
+	return locales, nil
+}
+
+// EmailLocales returns every locale that has email templates
+func EmailLocales() []string {
+	locales := make([]string, 0, len(emailTemplates))
+	for locale := range emailTemplates {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// EmailTemplateNames returns the name of every email template
+func EmailTemplateNames() []string {
+	return []string{TemplateWelcome, TemplatePasswordReset}
+}
+
+// ResolveLocale picks the best available locale for a language preference such
+// as "es" or "es-MX", falling back to the base language and then DefaultLocale.
+func ResolveLocale(language string) string {
+	language = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(language), "_", "-"))
+	if _, ok := emailTemplates[language]; ok {
+		return language
+	}
+	if base, _, found := strings.Cut(language, "-"); found {
+		if _, ok := emailTemplates[base]; ok {
+			return base
+		}
+	}
+	return DefaultLocale
 }
 
-func GeneratePasswordResetHTML(code string, recipientEmail string) string {
-	return fmt.Sprintf(`
-        <!DOCTYPE html>
-        <html lang="en">
-        <head>
-            <meta charset="UTF-8">
-            <meta name="viewport" content="width=device-width, initial-scale=1.0">
-            <title>Password Reset Request</title>
-            <style>
-                body {
-                    font-family: 'Helvetica Neue', Helvetica, Arial, sans-serif;
-                    line-height: 1.6;
-                    color: #333333;
-                    background-color: #f7f7f7;
-                    margin: 0;
-                    padding: 0;
-                }
-                .container {
-                    max-width: 500px;
-                    margin: 30px auto;
-                    background: #ffffff;
-                    border-radius: 8px;
-                    box-shadow: 0 4px 12px rgba(0, 0, 0, 0.05);
-                    padding: 30px;
-                    border: 1px solid #e0e0e0;
-                }
-                h2 {
-                    color: #1a1a1a;
-                    font-size: 24px;
-                    margin-bottom: 20px;
-                    text-align: center;
-                }
-                p {
-                    margin-bottom: 15px;
-                }
-                .code-section {
-                    text-align: center;
-                    margin: 25px 0;
-                }
-                .code {
-                    font-size: 2.2em;
-                    font-weight: bold;
-                    letter-spacing: 4px;
-                    color: #007bff; /* A nice blue color */
-                    background: #e9f5ff; /* Lighter blue background for the code */
-                    padding: 15px 30px;
-                    border-radius: 8px;
-                    display: inline-block;
-                    border: 1px dashed #a0d8ff;
-                }
-                .footer {
-                    margin-top: 30px;
-                    font-size: 0.9em;
-                    color: #777777;
-                    text-align: center;
-                    border-top: 1px solid #eeeeee;
-                    padding-top: 20px;
-                }
-                .important-note {
-                    color: #dc3545; /* Red for important notes */
-                    font-weight: bold;
-                    margin-top: 20px;
-                    padding: 10px;
-                    background-color: #ffebeb;
-                    border-left: 5px solid #dc3545;
-                }
-                a {
-                    color: #007bff;
-                    text-decoration: none;
-                }
-                a:hover {
-                    text-decoration: underline;
-                }
-            </style>
-        </head>
-        <body>
-            <div class="container">
-                <h2>Password Reset Request</h2>
-                <p>Hello,</p>
-                <p>We received a request to reset the password for your account associated with the email address: <strong>%s</strong>.</p>
-                <p>To proceed with your password reset, please use the following verification code:</p>
-                <div class="code-section">
-                    <span class="code">%s</span>
-                </div>
-                <p>This code is valid for a limited time (e.g., 10 minutes). Please do not share this code with anyone.</p>
-                <div class="important-note">
-                    If you did not request a password reset, please ignore this email. Your password will remain unchanged.
-                </div>
-                <p>If you have any questions or encounter issues, please contact our support team.</p>
-                <p>Thanks,<br/>The ImaginAI Team</p>
-            </div>
-            <div class="footer">
-                <p>&copy; %d ImaginAI. All rights reserved.</p>
-                <p><a href="[Your Website Link]">Our Website</a> | <a href="[Your Privacy Policy Link]">Privacy Policy</a></p>
-            </div>
-        </body>
-        </html>
-    `, recipientEmail, code, time.Now().Year())
+// NewEmailData returns EmailData with the application wide fields filled in
+func NewEmailData(language string) EmailData {
+	return EmailData{
+		AppName:    helpers.GetEnv("APP_NAME", "ImaginAI"),
+		WebsiteURL: helpers.GetEnv("APP_URL", ""),
+		Year:       time.Now().Year(),
+		Locale:     ResolveLocale(language),
+	}
+}
+
+// RenderEmail renders the HTML and plaintext versions of a template in data.Locale.
+// User supplied values in data are escaped in the HTML version.
+func RenderEmail(name string, data EmailData) (*RenderedEmail, error) {
+	data.Locale = ResolveLocale(data.Locale)
+	lt := emailTemplates[data.Locale]
+
+	htmlTmpl, ok := lt.html[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+	textTmpl := lt.text[name]
+
+	var subject, htmlBody, textBody bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s subject: %w", name, err)
+	}
+	if err := htmlTmpl.ExecuteTemplate(&htmlBody, "layout", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s html: %w", name, err)
+	}
+	if err := textTmpl.ExecuteTemplate(&textBody, "layout", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s text: %w", name, err)
+	}
+
+	return &RenderedEmail{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    htmlBody.String(),
+		Text:    strings.TrimSpace(textBody.String()) + "\n",
+	}, nil
+}
+
+// RenderEmailTo renders a template and addresses it to the given recipients
+func RenderEmailTo(to []string, name string, data EmailData) (*Email, error) {
+	rendered, err := RenderEmail(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Email{
+		To:       to,
+		Subject:  rendered.Subject,
+		Text:     rendered.Text,
+		HTML:     rendered.HTML,
+		Template: name,
+	}, nil
 }
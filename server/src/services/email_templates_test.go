@@ -0,0 +1,254 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestResolveLocale(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{language: "es", want: "es"},
+		{language: "es-MX", want: "es"},
+		{language: "ES_mx", want: "es"},
+		{language: " en-GB ", want: "en"},
+		{language: "pt-BR", want: "en"},
+		{language: "pt", want: "en"},
+		{language: "", want: "en"},
+	}
+
+	for _, tt := range tests {
+		if got := ResolveLocale(tt.language); got != tt.want {
+			t.Errorf("ResolveLocale(%q) = %q, want %q", tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestNewEmailData(t *testing.T) {
+	t.Setenv("APP_NAME", "")
+	t.Setenv("APP_URL", "https://imaginai.dev")
+
+	data := NewEmailData("es-AR")
+	if data.AppName != "ImaginAI" || data.WebsiteURL != "https://imaginai.dev" || data.Locale != "es" || data.Year < 2025 {
+		t.Errorf("NewEmailData = %+v, want the default app name, the app url and locale es", data)
+	}
+}
+
+func TestRenderEmail(t *testing.T) {
+	data := EmailData{
+		AppName:    "ImaginAI",
+		WebsiteURL: "https://imaginai.dev",
+		Year:       2026,
+		Name:       `Ada <script>alert("hi")</script>`,
+		Email:      "ada@example.com",
+		Code:       "482913",
+	}
+
+	tests := []struct {
+		name     string
+		locale   string
+		subject  string
+		text     []string
+		html     []string
+		notInAny []string
+	}{
+		{
+			name:    TemplateWelcome,
+			locale:  "en",
+			subject: "Welcome to ImaginAI!",
+			text:    []string{`Hello Ada <script>alert("hi")</script>,`, "© 2026 ImaginAI. All rights reserved.", "https://imaginai.dev"},
+			html:    []string{`<html lang="en">`, "Ada &lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;", `<a href="https://imaginai.dev">`},
+		},
+		{
+			name:    TemplateWelcome,
+			locale:  "es-MX",
+			subject: "¡Bienvenido a ImaginAI!",
+			text:    []string{"Hola Ada", "© 2026 ImaginAI. Todos los derechos reservados."},
+			html:    []string{`<html lang="es">`, "Todos los derechos reservados."},
+		},
+		{
+			name:     TemplatePasswordReset,
+			locale:   "en",
+			subject:  "Reset your ImaginAI password",
+			text:     []string{"email address: ada@example.com.", "    482913\n"},
+			html:     []string{`<span class="code">482913</span>`, "<strong>ada@example.com</strong>"},
+			notInAny: []string{"Ada"},
+		},
+		{
+			name:     TemplatePasswordReset,
+			locale:   "pt",
+			subject:  "Reset your ImaginAI password",
+			text:     []string{"482913"},
+			html:     []string{`<html lang="en">`},
+			notInAny: []string{"Restablece"},
+		},
+		{
+			name:    TemplatePasswordReset,
+			locale:  "es",
+			subject: "Restablece tu contraseña de ImaginAI",
+			text:    []string{"correo: ada@example.com.", "    482913\n", "Todos los derechos reservados."},
+			html:    []string{`<span class="code">482913</span>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.locale, func(t *testing.T) {
+			data := data
+			data.Locale = tt.locale
+			rendered, err := RenderEmail(tt.name, data)
+			if err != nil {
+				t.Fatalf("RenderEmail: %v", err)
+			}
+
+			if rendered.Subject != tt.subject {
+				t.Errorf("subject = %q, want %q", rendered.Subject, tt.subject)
+			}
+			if !strings.HasSuffix(rendered.Text, "\n") || strings.HasSuffix(rendered.Text, "\n\n") {
+				t.Errorf("text does not end with a single newline: %q", rendered.Text)
+			}
+			for _, want := range tt.text {
+				if !strings.Contains(rendered.Text, want) {
+					t.Errorf("text does not contain %q:\n%s", want, rendered.Text)
+				}
+			}
+			for _, want := range tt.html {
+				if !strings.Contains(rendered.HTML, want) {
+					t.Errorf("html does not contain %q", want)
+				}
+			}
+			if strings.Contains(rendered.HTML, "<script>") {
+				t.Error("html contains the name unescaped")
+			}
+			for _, unwanted := range tt.notInAny {
+				if strings.Contains(rendered.Text, unwanted) || strings.Contains(rendered.HTML, unwanted) {
+					t.Errorf("email contains %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderEmailUnknownTemplate(t *testing.T) {
+	if _, err := RenderEmail("invoice", NewEmailData("en")); err == nil || !strings.Contains(err.Error(), `unknown email template "invoice"`) {
+		t.Errorf("RenderEmail(invoice) error = %v, want unknown email template", err)
+	}
+}
+
+func TestRenderEmailTo(t *testing.T) {
+	data := NewEmailData("en")
+	data.Email = "ada@example.com"
+	data.Code = "482913"
+
+	email, err := RenderEmailTo([]string{"ada@example.com"}, TemplatePasswordReset, data)
+	if err != nil {
+		t.Fatalf("RenderEmailTo: %v", err)
+	}
+	if len(email.To) != 1 || email.To[0] != "ada@example.com" || email.Template != TemplatePasswordReset || email.From != "" {
+		t.Errorf("email = %+v, want one recipient of the password reset template and the default sender", email)
+	}
+	if email.Subject == "" || !strings.Contains(email.Text, "482913") || !strings.Contains(email.HTML, "482913") {
+		t.Errorf("email = %+v, want the rendered template", email)
+	}
+}
+
+// templateFiles returns a template tree holding every template for each
+// locale, with files replacing or removing (when empty) the defaults
+func templateFiles(locales []string, files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{
+		"templates/layout.html": {Data: []byte(`{{define "layout"}}{{template "content" .}} {{template "rights" .}}{{end}}`)},
+		"templates/layout.txt":  {Data: []byte(`{{define "layout"}}{{template "content" .}} {{template "rights" .}}{{end}}`)},
+	}
+	for _, locale := range locales {
+		fsys["templates/"+locale+"/common.tmpl"] = &fstest.MapFile{Data: []byte(`{{define "rights"}}` + locale + `{{end}}`)}
+		for _, name := range EmailTemplateNames() {
+			body := fmt.Sprintf(`{{define "subject"}}%s{{end}}{{define "content"}}Hi {{.Name}}{{end}}`, name)
+			fsys["templates/"+locale+"/"+name+".html"] = &fstest.MapFile{Data: []byte(body)}
+			fsys["templates/"+locale+"/"+name+".txt"] = &fstest.MapFile{Data: []byte(body)}
+		}
+	}
+	for file, data := range files {
+		if data == "" {
+			delete(fsys, file)
+			continue
+		}
+		fsys[file] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return fsys
+}
+
+func TestParseEmailTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		locales []string
+		files   map[string]string
+		err     string
+	}{
+		{name: "every locale complete", locales: []string{"en", "es"}},
+		{name: "default locale missing", locales: []string{"es"}, err: `default locale "en" are missing`},
+		{name: "template missing in a locale", locales: []string{"en", "es"}, files: map[string]string{"templates/es/welcome.txt": ""}, err: "es/welcome.txt"},
+		{name: "template that does not parse", locales: []string{"en"}, files: map[string]string{"templates/en/password_reset.html": `{{define "content"}}{{.Code}{{end}}`}, err: "en/password_reset.html"},
+		{name: "layout missing", locales: []string{"en"}, files: map[string]string{"templates/layout.txt": ""}, err: "en/welcome.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locales, err := parseEmailTemplates(templateFiles(tt.locales, tt.files))
+			if tt.err == "" {
+				if err != nil || len(locales) != len(tt.locales) {
+					t.Fatalf("parseEmailTemplates = %d locales, %v, want %d locales", len(locales), err, len(tt.locales))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseEmailTemplates error = %v, want one mentioning %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRenderEmailMissingVariable(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name:  "field EmailData does not have",
+			files: map[string]string{"templates/en/welcome.txt": `{{define "subject"}}Hi{{end}}{{define "content"}}Hi {{.FirstName}}{{end}}`},
+			err:   "welcome text",
+		},
+		{
+			name:  "field missing in the subject",
+			files: map[string]string{"templates/en/welcome.txt": `{{define "subject"}}Hi {{.Nickname}}{{end}}{{define "content"}}Hi{{end}}`},
+			err:   "welcome subject",
+		},
+		{
+			name:  "block the locale does not define",
+			files: map[string]string{"templates/en/common.tmpl": `{{define "copyright"}}©{{end}}`},
+			err:   "welcome html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseEmailTemplates(templateFiles([]string{"en"}, tt.files))
+			if err != nil {
+				t.Fatalf("parseEmailTemplates: %v", err)
+			}
+			defer func(templates map[string]*localeTemplates) { emailTemplates = templates }(emailTemplates)
+			emailTemplates = parsed
+
+			rendered, err := RenderEmail(TemplateWelcome, EmailData{Name: "Ada"})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("RenderEmail error = %v, want one rendering the %s", err, tt.err)
+			}
+			if rendered != nil {
+				t.Errorf("RenderEmail returned a partly rendered email: %+v", rendered)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Email is a message ready to be handed to a Mailer.
+// At least one of Text and HTML should be set.
+type Email struct {
+	From        string
+	To          []string
+	ReplyTo     string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+	// Template is the name of the template the email was rendered from, if any
+	Template string
+}
+
+// Attachment is a file sent along with an Email
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// Mailer delivers emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, email *Email) error
+}
+
+var (
+	mailer   Mailer = &LogMailer{}
+	mailerMu sync.RWMutex
+)
+
+// SetMailer sets the mailer used by the package level send helpers
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	mailer = m
+}
+
+// GetMailer returns the mailer used by the package level send helpers
+func GetMailer() Mailer {
+	mailerMu.RLock()
+	defer mailerMu.RUnlock()
+	return mailer
+}
+
+// NewMailerFromEnv builds the mailer selected by MAILER: smtp, file, log or
+// memory. Without MAILER, emails are sent over SMTP when SMTP_HOST is set and
+// only logged otherwise, so a checkout without mail settings still starts.
+func NewMailerFromEnv() (Mailer, error) {
+	backend := strings.ToLower(helpers.GetEnv("MAILER", ""))
+	if backend == "" {
+		backend = "log"
+		if smtpConfigured() {
+			backend = "smtp"
+		} else {
+			logrus.Warn("MAILER and SMTP_HOST are not set, emails will only be logged")
+		}
+	}
+
+	switch backend {
+	case "smtp":
+		return NewSMTPMailerFromEnv()
+	case "file":
+		return NewFileMailer(helpers.GetEnv("MAILER_FILE_DIR", "./mail"))
+	case "log":
+		return &LogMailer{}, nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER backend %q", backend)
+	}
+}
+
+// defaultFrom returns the sender address used when an Email has no From set.
+func defaultFrom() string {
+	return helpers.GetEnv("MAIL_FROM", helpers.GetEnv("FROM_EMAIL", "no-reply@imaginai.local"))
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every email into a maildir (Dir/tmp, Dir/new, Dir/cur), so
+// local development works without SMTP credentials and mail can be opened in any client.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("error creating maildir: %w", err)
+		}
+	}
+
+	return &FileMailer{
+		Dir:  dir,
+		From: defaultFrom(),
+	}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, email *Email) error {
+	// The caller's email is left untouched, it may be stored or sent again
+	if email.From == "" {
+		withSender := *email
+		withSender.From = m.From
+		email = &withSender
+	}
+
+	message, err := email.Bytes()
+	if err != nil {
+		return fmt.Errorf("error building message: %w", err)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("error generating file name: %w", err)
+	}
+	name := fmt.Sprintf("%d.%s.imaginai.eml", time.Now().UnixNano(), hex.EncodeToString(suffix))
+
+	// Write to tmp first and rename, so readers never see a half-written message
+	tmpPath := filepath.Join(m.Dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, message, 0o644); err != nil {
+		return fmt.Errorf("error writing message: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(m.Dir, "new", name)); err != nil {
+		return fmt.Errorf("error delivering message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryMailer records emails in memory so tests can assert on what was sent.
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Email
+	// Err, when set, is returned by Send instead of recording the email
+	Err error
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, email *Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+
+	sent := *email
+	sent.To = append([]string(nil), email.To...)
+	m.sent = append(m.sent, sent)
+	return nil
+}
+
+// Sent returns a copy of every recorded email in the order they were sent
+func (m *MemoryMailer) Sent() []Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Email(nil), m.sent...)
+}
+
+// Last returns the most recently recorded email, if any
+func (m *MemoryMailer) Last() (Email, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		return Email{}, false
+	}
+	return m.sent[len(m.sent)-1], true
+}
+
+// Reset forgets every recorded email
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+)
+
+// SMTP connection security modes
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+// SMTPMailer delivers emails through an SMTP server.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// Security is one of starttls (upgrade a plain connection), tls (implicit TLS,
+	// usually port 465) or none (local relays and test servers only).
+	Security string
+	Timeout  time.Duration
+}
+
+// smtpConfigured reports whether an SMTP server is set, under any of the names
+// NewSMTPMailerFromEnv accepts
+func smtpConfigured() bool {
+	for _, key := range []string{"SMTP_HOST", "SMTP_SERVER", "SMTP_ADDRESS"} {
+		if helpers.GetEnv(key, "") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSMTPMailerFromEnv reads the SMTP settings. SMTP_ADDRESS, SMTP_SERVER, FROM_EMAIL
+// and FROM_EMAIL_PASSWORD are still honoured for existing deployments.
+func NewSMTPMailerFromEnv() (*SMTPMailer, error) {
+	host := helpers.GetEnv("SMTP_HOST", helpers.GetEnv("SMTP_SERVER", ""))
+	port := helpers.GetEnv("SMTP_PORT", "")
+
+	if address := helpers.GetEnv("SMTP_ADDRESS", ""); address != "" && (host == "" || port == "") {
+		addrHost, addrPort, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_ADDRESS %q: %w", address, err)
+		}
+		if host == "" {
+			host = addrHost
+		}
+		if port == "" {
+			port = addrPort
+		}
+	}
+
+	if host == "" {
+		return nil, fmt.Errorf("SMTP_HOST not set in environment variables")
+	}
+
+	security := strings.ToLower(helpers.GetEnv("SMTP_SECURITY", SMTPSecurityStartTLS))
+	if port == "" {
+		port = "587"
+		if security == SMTPSecurityTLS {
+			port = "465"
+		}
+	}
+
+	m := &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: helpers.GetEnv("SMTP_USERNAME", helpers.GetEnv("FROM_EMAIL", "")),
+		Password: helpers.GetEnv("SMTP_PASSWORD", helpers.GetEnv("FROM_EMAIL_PASSWORD", "")),
+		From:     defaultFrom(),
+		Security: security,
+		Timeout:  helpers.GetEnvDuration("SMTP_TIMEOUT", 15*time.Second),
+	}
+
+	switch m.Security {
+	case SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown SMTP_SECURITY %q", m.Security)
+	}
+
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, email *Email) error {
+	// The caller's email is left untouched, it may be stored or sent again
+	if email.From == "" {
+		withSender := *email
+		withSender.From = m.From
+		email = &withSender
+	}
+
+	message, err := email.Bytes()
+	if err != nil {
+		return fmt.Errorf("error building message: %w", err)
+	}
+
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Bound the whole SMTP conversation, not only the dial
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		return fmt.Errorf("error starting SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if m.Security == SMTPSecurityStartTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+			return fmt.Errorf("error starting TLS: %w", err)
+		}
+	}
+
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return fmt.Errorf("error authenticating: %w", err)
+		}
+	}
+
+	if err := client.Mail(envelopeAddress(email.From)); err != nil {
+		return fmt.Errorf("error setting sender: %w", err)
+	}
+	for _, to := range email.To {
+		if err := client.Rcpt(envelopeAddress(to)); err != nil {
+			return fmt.Errorf("error adding recipient %s: %w", to, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error starting message data: %w", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		return fmt.Errorf("error writing message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error finishing message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+func (m *SMTPMailer) dial(ctx context.Context) (net.Conn, error) {
+	address := net.JoinHostPort(m.Host, m.Port)
+
+	if m.Security == SMTPSecurityTLS {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: m.Host}}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to %s: %w", address, err)
+		}
+		return conn, nil
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+// envelopeAddress strips any display name, e.g. "ImaginAI <a@b.c>" becomes "a@b.c".
+func envelopeAddress(address string) string {
+	if start, end := strings.LastIndex(address, "<"), strings.LastIndex(address, ">"); start >= 0 && end > start {
+		return address[start+1 : end]
+	}
+	return address
+}
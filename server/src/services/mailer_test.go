@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestNewMailerFromEnvDefault(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"no mail settings", map[string]string{}, "*services.LogMailer"},
+		{"SMTP host", map[string]string{"SMTP_HOST": "smtp.example.com"}, "*services.SMTPMailer"},
+		{"legacy SMTP address", map[string]string{"SMTP_ADDRESS": "smtp.example.com:587"}, "*services.SMTPMailer"},
+		{"explicit backend", map[string]string{"MAILER": "memory", "SMTP_HOST": "smtp.example.com"}, "*services.MemoryMailer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"MAILER", "SMTP_HOST", "SMTP_SERVER", "SMTP_ADDRESS", "SMTP_PORT", "SMTP_SECURITY"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			m, err := NewMailerFromEnv()
+			if err != nil {
+				t.Fatalf("NewMailerFromEnv() error = %v", err)
+			}
+			if got := fmt.Sprintf("%T", m); got != tt.want {
+				t.Errorf("mailer = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMailerFromEnvRequiresHostForSMTP(t *testing.T) {
+	t.Setenv("MAILER", "smtp")
+	for _, key := range []string{"SMTP_HOST", "SMTP_SERVER", "SMTP_ADDRESS"} {
+		t.Setenv(key, "")
+	}
+
+	if _, err := NewMailerFromEnv(); err == nil {
+		t.Error("NewMailerFromEnv() with MAILER=smtp and no host succeeded, want an error")
+	}
+}
+
+func TestLogMailerLeavesOutBodies(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	email := &Email{
+		To:       []string{"ada@example.com"},
+		Subject:  "Reset your password",
+		Text:     "Your code is 123456",
+		HTML:     "<p>Your code is <b>123456</b></p>",
+		Template: TemplatePasswordReset,
+	}
+	if err := (&LogMailer{}).Send(context.Background(), email); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	entry := hook.LastEntry()
+	if entry == nil || entry.Level != logrus.InfoLevel {
+		t.Fatalf("no info entry was logged")
+	}
+	if entry.Data["template"] != TemplatePasswordReset || entry.Data["subject"] != email.Subject {
+		t.Errorf("fields = %v, want the subject and template", entry.Data)
+	}
+	for key, value := range entry.Data {
+		if strings.Contains(fmt.Sprint(value), "123456") {
+			t.Errorf("field %s holds the body of the email: %v", key, value)
+		}
+	}
+}
+
+// smtpServer accepts one SMTP session on a local port, returning its address
+// and a channel receiving the envelope sender and the message
+func smtpServer(t *testing.T) (string, <-chan [2]string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan [2]string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		var sender string
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				sender = strings.TrimPrefix(line, "MAIL FROM:")
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- [2]string{sender, string(data)}
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestMailersLeaveTheEmailUnchanged(t *testing.T) {
+	newEmail := func() *Email {
+		return &Email{To: []string{"ada@example.com"}, Subject: "Welcome", Text: "Hello Ada"}
+	}
+
+	t.Run("smtp", func(t *testing.T) {
+		address, received := smtpServer(t)
+		host, port, _ := net.SplitHostPort(address)
+		mailer := &SMTPMailer{Host: host, Port: port, From: "ImaginAI <noreply@imaginai.dev>", Security: SMTPSecurityNone, Timeout: 5 * time.Second}
+
+		email := newEmail()
+		if err := mailer.Send(context.Background(), email); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if email.From != "" {
+			t.Errorf("Send() set the sender of the email to %q", email.From)
+		}
+
+		got := <-received
+		if got[0] != "<noreply@imaginai.dev>" {
+			t.Errorf("envelope sender = %q, want the default sender", got[0])
+		}
+		if !strings.Contains(got[1], "From: \"ImaginAI\" <noreply@imaginai.dev>") {
+			t.Errorf("message has no From header of the default sender:\n%s", got[1])
+		}
+	})
+
+	t.Run("maildir", func(t *testing.T) {
+		mailer, err := NewFileMailer(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewFileMailer() error = %v", err)
+		}
+		mailer.From = "noreply@imaginai.dev"
+
+		email := newEmail()
+		if err := mailer.Send(context.Background(), email); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if email.From != "" {
+			t.Errorf("Send() set the sender of the email to %q", email.From)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrHeaderInjection is returned when a header value contains line breaks,
+// which would otherwise let callers smuggle extra headers into the message.
+var ErrHeaderInjection = errors.New("header value must not contain CR or LF")
+
+// Bytes renders the email as an RFC 5322 message with CRLF line endings,
+// RFC 2047 encoded headers, quoted-printable text parts and base64 attachments.
+// The message is DKIM signed when a signer is configured.
+func (e *Email) Bytes() ([]byte, error) {
+	message, err := e.build(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if signer := GetDKIMSigner(); signer != nil {
+		return signer.Sign(message)
+	}
+	return message, nil
+}
+
+func (e *Email) build(now time.Time) ([]byte, error) {
+	if len(e.To) == 0 {
+		return nil, errors.New("email has no recipients")
+	}
+	if e.Text == "" && e.HTML == "" {
+		return nil, errors.New("email has no body")
+	}
+
+	from, err := formatAddress(e.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid From address: %w", err)
+	}
+
+	to := make([]string, len(e.To))
+	for i, address := range e.To {
+		if to[i], err = formatAddress(address); err != nil {
+			return nil, fmt.Errorf("invalid To address: %w", err)
+		}
+	}
+
+	if strings.ContainsAny(e.Subject, "\r\n") {
+		return nil, ErrHeaderInjection
+	}
+
+	messageID, err := newMessageID(e.From)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", from)
+	writeHeader(&buf, "To", strings.Join(to, ", "))
+	if e.ReplyTo != "" {
+		replyTo, err := formatAddress(e.ReplyTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Reply-To address: %w", err)
+		}
+		writeHeader(&buf, "Reply-To", replyTo)
+	}
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", e.Subject))
+	writeHeader(&buf, "Date", now.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageID)
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	bodyHeader, body, err := e.body()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(e.Attachments) == 0 {
+		for _, name := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			if value := bodyHeader.Get(name); value != "" {
+				writeHeader(&buf, name, value)
+			}
+		}
+		buf.WriteString("\r\n")
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	// multipart/mixed: the body first, then one part per attachment
+	mixed := multipart.NewWriter(&buf)
+	writeHeader(&buf, "Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	buf.WriteString("\r\n")
+
+	part, err := mixed.CreatePart(bodyHeader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(body); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range e.Attachments {
+		if err := writeAttachment(mixed, attachment); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// body returns the headers and content of the message body. When both versions
+// are set they are wrapped in multipart/alternative with the HTML part last,
+// since clients show the last part they understand.
+func (e *Email) body() (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+
+	if e.Text == "" || e.HTML == "" {
+		contentType, body := "text/plain", e.Text
+		if e.HTML != "" {
+			contentType, body = "text/html", e.HTML
+		}
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, nil, err
+		}
+		return textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"})},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		}, buf.Bytes(), nil
+	}
+
+	alternative := multipart.NewWriter(&buf)
+	for _, p := range []struct{ contentType, body string }{
+		{"text/plain", e.Text},
+		{"text/html", e.HTML},
+	} {
+		part, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(p.contentType, map[string]string{"charset": "utf-8"})},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := writeQuotedPrintable(part, p.body); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": alternative.Boundary()})},
+	}, buf.Bytes(), nil
+}
+
+func writeAttachment(w *multipart.Writer, attachment Attachment) error {
+	filename := filepath.Base(attachment.Filename)
+	if strings.ContainsAny(filename, "\r\n") {
+		return ErrHeaderInjection
+	}
+
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return fmt.Errorf("invalid attachment content type %q: %w", contentType, err)
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Base64 lines are wrapped at 76 characters as required by RFC 2045
+	encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+	for len(encoded) > 76 {
+		if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeHeader(buf *bytes.Buffer, name string, value string) {
+	buf.WriteString(name + ": " + value + "\r\n")
+}
+
+// formatAddress validates an address such as "a@b.c" or "Name <a@b.c>" and
+// formats it with an RFC 2047 encoded display name when needed.
+func formatAddress(address string) (string, error) {
+	if strings.ContainsAny(address, "\r\n") {
+		return "", ErrHeaderInjection
+	}
+
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// newMessageID returns a unique Message-ID in the sender's domain
+func newMessageID(from string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("error generating message id: %w", err)
+	}
+
+	domain := "imaginai.local"
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(parsed.Address, "@"); at >= 0 {
+			domain = parsed.Address[at+1:]
+		}
+	}
+
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain), nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubjectEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		encoded string
+	}{
+		{"ascii is left alone", "Reset your password", "Reset your password"},
+		{"accented latin", "Réinitialisez votre mot de passe", "=?utf-8?q?R=C3=A9initialisez_votre_mot_de_passe?="},
+		{"non-latin script", "パスワードをリセット", ""},
+		{"emoji", "Welcome to ImaginAI 🎨", ""},
+		{"long", strings.Repeat("Überprüfen Sie Ihre E-Mail ", 5), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &Email{From: "noreply@example.com", To: []string{"ada@example.com"}, Subject: tt.subject, Text: "Hello"}
+			msg := readMessage(t, email)
+
+			raw := msg.Header.Get("Subject")
+			if tt.encoded != "" && raw != tt.encoded {
+				t.Errorf("Subject = %q, want %q", raw, tt.encoded)
+			}
+			for _, r := range raw {
+				if r > 127 {
+					t.Fatalf("Subject %q is not ASCII", raw)
+				}
+			}
+			for _, word := range strings.Fields(raw) {
+				if len(word) > 75 {
+					t.Errorf("encoded word %q is longer than 75 characters", word)
+				}
+			}
+
+			decoded, err := new(mime.WordDecoder).DecodeHeader(raw)
+			if err != nil {
+				t.Fatalf("decoding Subject: %v", err)
+			}
+			if decoded != tt.subject {
+				t.Errorf("decoded Subject = %q, want %q", decoded, tt.subject)
+			}
+		})
+	}
+}
+
+func TestQuotedPrintableBody(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"short ascii", "Your code is 123456"},
+		{"non-ascii", "Votre code est 123456, à bientôt ✨"},
+		{"long line", strings.Repeat("abcdefghij", 20)},
+		{"long non-ascii line", strings.Repeat("é", 120)},
+		{"long line ending in a space", strings.Repeat("word ", 40)},
+		{"several lines", "first line\nsecond line\n\n" + strings.Repeat("x", 100) + "\nlast"},
+		{"equals signs", strings.Repeat("a=b ", 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &Email{From: "noreply@example.com", To: []string{"ada@example.com"}, Subject: "Code", Text: tt.text}
+			msg := readMessage(t, email)
+
+			if got := msg.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+				t.Errorf("Content-Transfer-Encoding = %q, want quoted-printable", got)
+			}
+			if got := msg.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want text/plain; charset=utf-8", got)
+			}
+
+			raw, err := io.ReadAll(msg.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			for _, line := range strings.Split(string(raw), "\r\n") {
+				if len(line) > 76 {
+					t.Errorf("encoded line is %d characters long, want at most 76: %q", len(line), line)
+				}
+				for _, r := range line {
+					if r > 127 {
+						t.Fatalf("encoded line %q is not ASCII", line)
+					}
+				}
+			}
+
+			decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(raw)))
+			if err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			// Line breaks are sent as CRLF, whatever the template used
+			if want := strings.ReplaceAll(tt.text, "\n", "\r\n"); string(decoded) != want {
+				t.Errorf("decoded body = %q, want %q", decoded, want)
+			}
+		})
+	}
+}
+
+func TestHeaderInjectionIsRefused(t *testing.T) {
+	tests := []struct {
+		name  string
+		email Email
+	}{
+		{"subject", Email{From: "noreply@example.com", To: []string{"ada@example.com"}, Subject: "Hi\r\nBcc: eve@example.com", Text: "Hello"}},
+		{"recipient", Email{From: "noreply@example.com", To: []string{"ada@example.com\r\nBcc: eve@example.com"}, Subject: "Hi", Text: "Hello"}},
+		{"attachment name", Email{From: "noreply@example.com", To: []string{"ada@example.com"}, Subject: "Hi", Text: "Hello", Attachments: []Attachment{{Filename: "a.txt\r\nX-Evil: 1", Data: []byte("a")}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.email.build(time.Now()); !errors.Is(err, ErrHeaderInjection) {
+				t.Errorf("build() error = %v, want %v", err, ErrHeaderInjection)
+			}
+		})
+	}
+}
+
+// readMessage builds email and parses it back as a receiving client would
+func readMessage(t *testing.T, email *Email) *mail.Message {
+	t.Helper()
+
+	message, err := email.build(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("build() error = %v", err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+	return msg
+}
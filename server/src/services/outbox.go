@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// Execer is satisfied by both *sql.DB and *sql.Tx, so emails can be queued
+// inside the same transaction as the change that triggers them.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// EnqueueEmail stores email in the outbox. It is delivered by the OutboxWorker
+// once the surrounding transaction commits, exactly as it would be sent directly.
+func EnqueueEmail(ctx context.Context, exec Execer, email *Email) (string, error) {
+	query := `
+		INSERT INTO email_outbox (id, recipients, subject, text_body, html_body, from_address, reply_to, attachments, template, max_attempts, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW(), NOW())
+	`
+
+	attachments, err := encodeAttachments(email.Attachments)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	maxAttempts := helpers.GetEnvInt("OUTBOX_MAX_ATTEMPTS", 8)
+
+	_, err = exec.ExecContext(ctx, query, id, pq.Array(email.To), email.Subject, email.Text, email.HTML,
+		email.From, email.ReplyTo, attachments, email.Template, maxAttempts)
+	if err != nil {
+		return "", fmt.Errorf("error queueing email: %w", err)
+	}
+
+	return id, nil
+}
+
+// encodeAttachments returns the JSON stored in the attachments column
+func encodeAttachments(attachments []Attachment) (string, error) {
+	if attachments == nil {
+		attachments = []Attachment{}
+	}
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return "", fmt.Errorf("error encoding attachments: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeAttachments reads the attachments column, nil when there are none
+func decodeAttachments(data []byte) ([]Attachment, error) {
+	var attachments []Attachment
+	if err := json.Unmarshal(data, &attachments); err != nil {
+		return nil, fmt.Errorf("error decoding attachments: %w", err)
+	}
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	return attachments, nil
+}
+
+// OutboxWorker delivers queued emails with a pool of workers, retrying failures
+// with exponential backoff and dead-lettering messages that run out of attempts.
+type OutboxWorker struct {
+	DB           *sql.DB
+	Mailer       Mailer
+	Workers      int
+	BatchSize    int
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// LockTimeout is how long a claimed message stays reserved; messages of a
+	// crashed worker become available again afterwards.
+	LockTimeout time.Duration
+}
+
+// outboxJob is a claimed message waiting for delivery
+type outboxJob struct {
+	id string
+	// lockToken identifies the claim, which another worker takes over once it expires
+	lockToken   string
+	email       Email
+	attempts    int
+	maxAttempts int
+}
+
+func NewOutboxWorkerFromEnv(db *sql.DB, mailer Mailer) *OutboxWorker {
+	return &OutboxWorker{
+		DB:           db,
+		Mailer:       mailer,
+		Workers:      helpers.GetEnvInt("OUTBOX_WORKERS", 4),
+		BatchSize:    helpers.GetEnvInt("OUTBOX_BATCH_SIZE", 20),
+		PollInterval: helpers.GetEnvDuration("OUTBOX_POLL_INTERVAL", 2*time.Second),
+		BaseBackoff:  helpers.GetEnvDuration("OUTBOX_BASE_BACKOFF", 30*time.Second),
+		MaxBackoff:   helpers.GetEnvDuration("OUTBOX_MAX_BACKOFF", time.Hour),
+		LockTimeout:  helpers.GetEnvDuration("OUTBOX_LOCK_TIMEOUT", 5*time.Minute),
+	}
+}
+
+// Run polls the outbox until ctx is cancelled, then waits for in-flight deliveries.
+func (w *OutboxWorker) Run(ctx context.Context) {
+	jobs := make(chan outboxJob)
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				w.deliver(ctx, job)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	logrus.WithField("workers", w.Workers).Info("Email outbox worker started")
+
+	for {
+		claimed, err := w.claim(ctx)
+		if err != nil {
+			logrus.WithError(err).Error("Error claiming outbox messages")
+		}
+
+		for _, job := range claimed {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+			}
+		}
+
+		// Keep draining while there is a backlog, otherwise wait for the next tick
+		if len(claimed) == w.BatchSize {
+			if ctx.Err() == nil {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			logrus.Info("Email outbox worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim reserves a batch of due messages. SKIP LOCKED lets several server
+// instances share the outbox without delivering a message twice.
+func (w *OutboxWorker) claim(ctx context.Context) ([]outboxJob, error) {
+	query := `
+		UPDATE email_outbox
+		SET status = $1, attempts = attempts + 1, locked_until = NOW() + ($2 * INTERVAL '1 second'), lock_token = $5, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE (status = $3 AND next_attempt_at <= NOW())
+			   OR (status = $1 AND locked_until < NOW())
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipients, subject, text_body, html_body, from_address, reply_to, attachments, template, attempts, max_attempts
+	`
+
+	lockToken := uuid.New().String()
+	rows, err := w.DB.QueryContext(ctx, query,
+		types.OutboxStatusSending,
+		int(w.LockTimeout.Seconds()),
+		types.OutboxStatusPending,
+		w.BatchSize,
+		lockToken,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []outboxJob
+	for rows.Next() {
+		job := outboxJob{lockToken: lockToken}
+		var attachments []byte
+		if err := rows.Scan(
+			&job.id,
+			pq.Array(&job.email.To),
+			&job.email.Subject,
+			&job.email.Text,
+			&job.email.HTML,
+			&job.email.From,
+			&job.email.ReplyTo,
+			&attachments,
+			&job.email.Template,
+			&job.attempts,
+			&job.maxAttempts,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		if job.email.Attachments, err = decodeAttachments(attachments); err != nil {
+			return nil, fmt.Errorf("error reading outbox message %s: %w", job.id, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (w *OutboxWorker) deliver(ctx context.Context, job outboxJob) {
+	log := logrus.WithFields(logrus.Fields{
+		"outbox_id": job.id,
+		"attempt":   job.attempts,
+	})
+
+	sendErr := w.Mailer.Send(ctx, &job.email)
+	if sendErr == nil {
+		w.release(ctx, log, job, "marking outbox message as sent",
+			`status = $1, sent_at = NOW(), last_error = ''`, types.OutboxStatusSent)
+		return
+	}
+
+	if job.attempts >= job.maxAttempts {
+		log.WithError(sendErr).Error("Email delivery failed permanently, moving to dead letters")
+		w.release(ctx, log, job, "dead-lettering outbox message",
+			`status = $1, last_error = $2`, types.OutboxStatusDead, sendErr.Error())
+		return
+	}
+
+	backoff := w.backoff(job.attempts)
+	log.WithError(sendErr).WithField("retry_in", backoff.String()).Warn("Email delivery failed, will retry")
+
+	w.release(ctx, log, job, "rescheduling outbox message",
+		`status = $1, last_error = $2, next_attempt_at = NOW() + ($3 * INTERVAL '1 millisecond')`,
+		types.OutboxStatusPending, sendErr.Error(), backoff.Milliseconds())
+}
+
+// release ends the claim of job, setting the columns of set, whose arguments
+// are args. A claim that expired and was taken over by another worker, or
+// reset by an operator, is left alone: the message is theirs now.
+func (w *OutboxWorker) release(ctx context.Context, log *logrus.Entry, job outboxJob, action string, set string, args ...interface{}) {
+	n := len(args)
+	query := fmt.Sprintf(
+		`UPDATE email_outbox SET %s, locked_until = NULL, lock_token = NULL, updated_at = NOW() WHERE id = $%d AND status = $%d AND lock_token = $%d`,
+		set, n+1, n+2, n+3,
+	)
+	result, err := w.DB.ExecContext(ctx, query, append(args, job.id, types.OutboxStatusSending, job.lockToken)...)
+	if err != nil {
+		log.WithError(err).Error("Error " + action)
+		return
+	}
+
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		log.Warn("Outbox message was claimed again while it was being delivered, leaving it to the new claim")
+	}
+}
+
+// backoff returns BaseBackoff * 2^(attempt-1), capped at MaxBackoff, with up to 20% jitter
+// so that messages failing together do not retry together.
+func (w *OutboxWorker) backoff(attempt int) time.Duration {
+	delay := float64(w.BaseBackoff) * math.Pow(2, float64(attempt-1))
+	if delay > float64(w.MaxBackoff) {
+		delay = float64(w.MaxBackoff)
+	}
+	jitter := delay * 0.2 * rand.Float64()
+	return time.Duration(delay + jitter)
+}
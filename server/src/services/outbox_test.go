@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	types "github.com/Mahaveer86619/ImaginAI/src/types"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAttachmentsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		attachments []Attachment
+		stored      string
+	}{
+		{"none", nil, "[]"},
+		{
+			"binary data",
+			[]Attachment{{Filename: "invoice.pdf", ContentType: "application/pdf", Data: []byte{0x25, 0x50, 0x44, 0x46, 0x00, 0xff}}},
+			`[{"filename":"invoice.pdf","content_type":"application/pdf","data":"JVBERgD/"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored, err := encodeAttachments(tt.attachments)
+			if err != nil {
+				t.Fatalf("encodeAttachments() error = %v", err)
+			}
+			if stored != tt.stored {
+				t.Errorf("stored = %s, want %s", stored, tt.stored)
+			}
+
+			got, err := decodeAttachments([]byte(stored))
+			if err != nil {
+				t.Fatalf("decodeAttachments() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.attachments) {
+				t.Errorf("decoded = %+v, want %+v", got, tt.attachments)
+			}
+		})
+	}
+}
+
+// recordingConnector opens connections recording the statements they execute,
+// each affecting rowsAffected rows
+type recordingConnector struct {
+	mu           sync.Mutex
+	execs        []recordedExec
+	rowsAffected int64
+}
+
+type recordedExec struct {
+	query string
+	args  []interface{}
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &recordingConn{c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver {
+	return nil
+}
+
+type recordingConn struct {
+	connector *recordingConnector
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.connector.mu.Lock()
+	defer c.connector.mu.Unlock()
+
+	exec := recordedExec{query: query}
+	for _, arg := range args {
+		exec.args = append(exec.args, arg.Value)
+	}
+	c.connector.execs = append(c.connector.execs, exec)
+	return driver.RowsAffected(c.connector.rowsAffected), nil
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func TestDeliverRequiresTheClaim(t *testing.T) {
+	tests := []struct {
+		name     string
+		sendErr  error
+		attempts int
+		status   string
+	}{
+		{"sent", nil, 1, types.OutboxStatusSent},
+		{"retried", errors.New("connection refused"), 1, types.OutboxStatusPending},
+		{"dead-lettered", errors.New("connection refused"), 3, types.OutboxStatusDead},
+	}
+
+	for _, tt := range tests {
+		for _, rowsAffected := range []int64{1, 0} {
+			t.Run(fmt.Sprintf("%s with %d rows", tt.name, rowsAffected), func(t *testing.T) {
+				hook := logtest.NewGlobal()
+				defer hook.Reset()
+
+				connector := &recordingConnector{rowsAffected: rowsAffected}
+				db := sql.OpenDB(connector)
+				defer db.Close()
+
+				mailer := NewMemoryMailer()
+				mailer.Err = tt.sendErr
+				w := &OutboxWorker{DB: db, Mailer: mailer, BaseBackoff: time.Second, MaxBackoff: time.Minute}
+
+				job := outboxJob{
+					id:          "0b6f6a8e-6d55-4c1e-9b7a-2f6f0c3f8d21",
+					lockToken:   "5d0c1a8e-2a8c-4a55-8f5e-6a2b1e7c9d10",
+					email:       Email{To: []string{"ada@example.com"}, Subject: "Welcome"},
+					attempts:    tt.attempts,
+					maxAttempts: 3,
+				}
+				w.deliver(context.Background(), job)
+
+				if len(connector.execs) != 1 {
+					t.Fatalf("executed %d statements, want 1", len(connector.execs))
+				}
+				exec := connector.execs[0]
+				n := len(exec.args)
+				claim := fmt.Sprintf("WHERE id = $%d AND status = $%d AND lock_token = $%d", n-2, n-1, n)
+				if !strings.Contains(exec.query, claim) || !strings.Contains(exec.query, "lock_token = NULL") {
+					t.Errorf("query = %s, want it to release the claim %q", exec.query, claim)
+				}
+				want := []interface{}{job.id, types.OutboxStatusSending, job.lockToken}
+				if !reflect.DeepEqual(exec.args[n-3:], want) {
+					t.Errorf("claim arguments = %v, want %v", exec.args[n-3:], want)
+				}
+				if exec.args[0] != tt.status {
+					t.Errorf("status = %v, want %s", exec.args[0], tt.status)
+				}
+
+				warned := false
+				for _, entry := range hook.AllEntries() {
+					warned = warned || strings.Contains(entry.Message, "claimed again")
+				}
+				if warned != (rowsAffected == 0) {
+					t.Errorf("warned about a lost claim = %t with %d rows updated", warned, rowsAffected)
+				}
+			})
+		}
+	}
+}
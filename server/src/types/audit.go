@@ -0,0 +1,85 @@
+package types
+
+import "time"
+
+// Audit event actions
+const (
+	AuditActionLoginSucceeded         = "auth.login_succeeded"
+	AuditActionLoginFailed            = "auth.login_failed"
+	AuditActionRegistered             = "auth.registered"
+	AuditActionPasswordResetRequested = "auth.password_reset_requested"
+	AuditActionPasswordResetVerified  = "auth.password_reset_verified"
+	AuditActionPasswordResetFailed    = "auth.password_reset_failed"
+	AuditActionUserUpdated            = "user.updated"
+	AuditActionGeminiKeyChanged       = "user.gemini_key_changed"
+	AuditActionUserDeleted            = "user.deleted"
+	AuditActionUserDisabled           = "user.disabled"
+	AuditActionUserEnabled            = "user.enabled"
+	AuditActionPasswordSet            = "user.password_set"
+	AuditActionRoleGranted            = "user.role_granted"
+	AuditActionTokensRevoked          = "user.tokens_revoked"
+	AuditActionPlanChanged            = "user.plan_changed"
+	AuditActionKeysRotated            = "system.keys_rotated"
+)
+
+// AuditActions lists every action that can appear in the audit log
+var AuditActions = []string{
+	AuditActionLoginSucceeded,
+	AuditActionLoginFailed,
+	AuditActionRegistered,
+	AuditActionPasswordResetRequested,
+	AuditActionPasswordResetVerified,
+	AuditActionPasswordResetFailed,
+	AuditActionUserUpdated,
+	AuditActionGeminiKeyChanged,
+	AuditActionUserDeleted,
+	AuditActionUserDisabled,
+	AuditActionUserEnabled,
+	AuditActionPasswordSet,
+	AuditActionRoleGranted,
+	AuditActionTokensRevoked,
+	AuditActionPlanChanged,
+	AuditActionKeysRotated,
+}
+
+// RequestInfo describes who made a request and from where, for the audit log.
+// ActorID is empty for unauthenticated requests.
+type RequestInfo struct {
+	ActorID   string
+	IP        string
+	UserAgent string
+}
+
+// AuditEvent is an entry of the security audit log. ActorID is the user who
+// performed the action and SubjectID the user it was performed on; either is
+// empty when unknown, such as a failed login for an unregistered email.
+type AuditEvent struct {
+	ID        string                 `json:"id"`
+	ActorID   string                 `json:"actor_id,omitempty"`
+	SubjectID string                 `json:"subject_id,omitempty"`
+	Action    string                 `json:"action"`
+	IP        string                 `json:"ip,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditEventFilter selects audit events, empty fields match everything
+type AuditEventFilter struct {
+	ActorID   string
+	SubjectID string
+	Action    string
+	IP        string
+	Since     *time.Time
+	Until     *time.Time
+	Limit     int
+	Offset    int
+}
+
+// AuditEventListResponse is a page of audit events
+type AuditEventListResponse struct {
+	Events []*AuditEvent `json:"events"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
@@ -11,6 +11,8 @@ type RegisteringCredentials struct {
 	Email        string `json:"email"`
 	Password     string `json:"password"`
 	GeminiAPIKey string `json:"gemini_api_key"`
+	// Language is the preferred language for emails, e.g. "en" or "es-MX"
+	Language string `json:"language"`
 }
 
 // for forgot password
@@ -0,0 +1,97 @@
+package types
+
+import "fmt"
+
+// ErrorCode is a stable, machine-readable identifier for a failure.
+// Clients should branch on the code, never on the message text.
+type ErrorCode string
+
+const (
+	// Generic request errors
+	ErrCodeInvalidRequestBody ErrorCode = "INVALID_REQUEST_BODY"
+	ErrCodeRequestTooLarge    ErrorCode = "REQUEST_BODY_TOO_LARGE"
+	ErrCodeValidationFailed   ErrorCode = "VALIDATION_FAILED"
+	ErrCodeMethodNotAllowed   ErrorCode = "METHOD_NOT_ALLOWED"
+	ErrCodeNotFound           ErrorCode = "NOT_FOUND"
+	ErrCodeForbidden          ErrorCode = "FORBIDDEN"
+	ErrCodeRateLimited        ErrorCode = "RATE_LIMITED"
+	ErrCodeInternal           ErrorCode = "INTERNAL_ERROR"
+
+	// Auth errors
+	ErrCodeAuthInvalidCredentials ErrorCode = "AUTH_INVALID_CREDENTIALS"
+	ErrCodeAuthMissingToken       ErrorCode = "AUTH_MISSING_TOKEN"
+	ErrCodeAuthInvalidToken       ErrorCode = "AUTH_INVALID_TOKEN"
+	ErrCodeAuthInvalidRefresh     ErrorCode = "AUTH_INVALID_REFRESH_TOKEN"
+	ErrCodeEmailAlreadyRegistered ErrorCode = "EMAIL_ALREADY_REGISTERED"
+	ErrCodeEmailNotRegistered     ErrorCode = "EMAIL_NOT_REGISTERED"
+	ErrCodeResetCodeNotFound      ErrorCode = "RESET_CODE_NOT_FOUND"
+	ErrCodeResetCodeInvalid       ErrorCode = "RESET_CODE_INVALID"
+	ErrCodeAccountDisabled        ErrorCode = "ACCOUNT_DISABLED"
+
+	// User errors
+	ErrCodeUserNotFound ErrorCode = "USER_NOT_FOUND"
+
+	// Email outbox errors
+	ErrCodeOutboxMessageNotFound ErrorCode = "OUTBOX_MESSAGE_NOT_FOUND"
+	ErrCodeOutboxAlreadySent     ErrorCode = "OUTBOX_ALREADY_SENT"
+	ErrCodeOutboxInFlight        ErrorCode = "OUTBOX_IN_FLIGHT"
+
+	// Configuration errors
+	ErrCodeEncryptionNotConfigured ErrorCode = "ENCRYPTION_NOT_CONFIGURED"
+)
+
+// genericInternalMessage replaces the text of any error that is not safe to show to clients.
+const genericInternalMessage = "An internal error occurred. Please try again later."
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// AppError is an error that carries a client-safe code and message.
+// Err holds the underlying cause; it is logged but never sent to clients.
+type AppError struct {
+	Code    ErrorCode
+	Message string
+	Details []FieldError
+	Err     error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// NewAppError returns a client-safe error with the given code and message.
+func NewAppError(code ErrorCode, message string) *AppError {
+	return &AppError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// NewValidationError returns a VALIDATION_FAILED error listing every invalid field.
+func NewValidationError(details []FieldError) *AppError {
+	return &AppError{
+		Code:    ErrCodeValidationFailed,
+		Message: "Request validation failed",
+		Details: details,
+	}
+}
+
+// InternalError wraps err so that it is logged but replaced with a generic message in responses.
+func InternalError(err error) *AppError {
+	return &AppError{
+		Code:    ErrCodeInternal,
+		Message: genericInternalMessage,
+		Err:     err,
+	}
+}
@@ -2,9 +2,17 @@ package types
 
 import (
 	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
 )
 
+const problemContentType = "application/problem+json"
+
 type Response interface {
 	SetStatusCode(int)
 	SetMessage(string)
@@ -19,8 +27,24 @@ type Success struct {
 }
 
 type Failure struct {
-	StatusCode int    `json:"status_code"`
-	Message    string `json:"message"`
+	StatusCode int          `json:"status_code"`
+	Code       ErrorCode    `json:"code"`
+	Message    string       `json:"message"`
+	Details    []FieldError `json:"details,omitempty"`
+
+	// cause is the internal error behind a generic message; it is only logged
+	cause error
+}
+
+// Problem is the RFC 7807 representation of a Failure.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance,omitempty"`
+	Code     ErrorCode    `json:"code"`
+	Details  []FieldError `json:"details,omitempty"`
 }
 
 func (s *Success) SetStatusCode(statusCode int) {
@@ -49,8 +73,112 @@ func (f *Failure) SetMessage(message string) {
 	f.Message = message
 }
 
+func (f *Failure) SetCode(code ErrorCode) {
+	f.Code = code
+}
+
+func (f *Failure) SetDetails(details []FieldError) {
+	f.Details = details
+}
+
+// SetError fills the code, message and details from err.
+// Errors that are not an *AppError, and internal AppErrors, are replaced with
+// a generic message so that database and driver errors never reach clients.
+func (f *Failure) SetError(err error) {
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
+		appErr = InternalError(err)
+	}
+
+	f.Code = appErr.Code
+	f.Message = appErr.Message
+	f.Details = appErr.Details
+
+	if appErr.Code == ErrCodeInternal || f.StatusCode >= http.StatusInternalServerError {
+		f.cause = err
+		f.Code = ErrCodeInternal
+		f.Message = genericInternalMessage
+		f.Details = nil
+	}
+}
+
 func (f *Failure) JSON(w http.ResponseWriter) {
+	f.logCause(nil)
+	f.fillDefaults()
+
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(f.StatusCode)
 	json.NewEncoder(w).Encode(f)
 }
+
+// Write sends the failure as RFC 7807 problem details when the client asks for
+// application/problem+json, and as the regular JSON envelope otherwise.
+func (f *Failure) Write(w http.ResponseWriter, r *http.Request) {
+	f.logCause(r)
+
+	if !acceptsProblem(r.Header.Get("Accept")) {
+		f.JSON(w)
+		return
+	}
+
+	f.fillDefaults()
+
+	problem := Problem{
+		Type:     "urn:imaginai:error:" + strings.ToLower(string(f.Code)),
+		Title:    http.StatusText(f.StatusCode),
+		Status:   f.StatusCode,
+		Detail:   f.Message,
+		Instance: r.URL.Path,
+		Code:     f.Code,
+		Details:  f.Details,
+	}
+
+	w.Header().Add("Content-Type", problemContentType)
+	w.WriteHeader(f.StatusCode)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// acceptsProblem reports whether an Accept header lists application/problem+json
+// without refusing it with q=0
+func acceptsProblem(accept string) bool {
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(item)
+		if err != nil || mediaType != problemContentType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (f *Failure) fillDefaults() {
+	if f.StatusCode == 0 {
+		f.StatusCode = http.StatusInternalServerError
+	}
+	if f.Code == "" {
+		if f.StatusCode >= http.StatusInternalServerError {
+			f.Code = ErrCodeInternal
+		} else {
+			f.Code = ErrorCode(strings.ToUpper(strings.ReplaceAll(http.StatusText(f.StatusCode), " ", "_")))
+		}
+	}
+}
+
+func (f *Failure) logCause(r *http.Request) {
+	if f.cause == nil {
+		return
+	}
+
+	entry := logrus.WithError(f.cause).WithField("status_code", f.StatusCode)
+	if r != nil {
+		entry = entry.WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		})
+	}
+	entry.Error("Internal error")
+	f.cause = nil
+}
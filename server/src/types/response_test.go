@@ -0,0 +1,205 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFailureSetError(t *testing.T) {
+	driverErr := errors.New(`pq: relation "users" does not exist`)
+	details := []FieldError{{Field: "email", Code: "required", Message: "email is required"}}
+
+	tests := []struct {
+		name    string
+		status  int
+		err     error
+		code    ErrorCode
+		message string
+		details int
+	}{
+		{
+			name:    "client error",
+			status:  http.StatusNotFound,
+			err:     NewAppError(ErrCodeUserNotFound, "User not found"),
+			code:    ErrCodeUserNotFound,
+			message: "User not found",
+		},
+		{
+			name:    "validation error",
+			status:  http.StatusBadRequest,
+			err:     NewValidationError(details),
+			code:    ErrCodeValidationFailed,
+			message: "Request validation failed",
+			details: 1,
+		},
+		{
+			name:    "wrapped client error",
+			status:  http.StatusConflict,
+			err:     fmt.Errorf("registering: %w", NewAppError(ErrCodeEmailAlreadyRegistered, "Email already registered")),
+			code:    ErrCodeEmailAlreadyRegistered,
+			message: "Email already registered",
+		},
+		{
+			name:    "plain error",
+			status:  http.StatusBadRequest,
+			err:     driverErr,
+			code:    ErrCodeInternal,
+			message: genericInternalMessage,
+		},
+		{
+			name:    "internal error",
+			status:  http.StatusInternalServerError,
+			err:     InternalError(driverErr),
+			code:    ErrCodeInternal,
+			message: genericInternalMessage,
+		},
+		{
+			name:    "client error answered with a server status",
+			status:  http.StatusServiceUnavailable,
+			err:     &AppError{Code: ErrCodeEncryptionNotConfigured, Message: "Key " + driverErr.Error(), Details: details},
+			code:    ErrCodeInternal,
+			message: genericInternalMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Failure{}
+			f.SetStatusCode(tt.status)
+			f.SetError(tt.err)
+
+			w := httptest.NewRecorder()
+			f.JSON(w)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if strings.Contains(w.Body.String(), "pq:") {
+				t.Errorf("body leaks the internal error: %s", w.Body.String())
+			}
+
+			var got Failure
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if got.Code != tt.code || got.Message != tt.message || len(got.Details) != tt.details {
+				t.Errorf("failure = %s %q with %d details, want %s %q with %d", got.Code, got.Message, len(got.Details), tt.code, tt.message, tt.details)
+			}
+		})
+	}
+}
+
+func TestFailureWrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+	}{
+		{name: "no accept header", contentType: "application/json"},
+		{name: "json", accept: "application/json", contentType: "application/json"},
+		{name: "anything", accept: "*/*", contentType: "application/json"},
+		{name: "problem", accept: "application/problem+json", contentType: problemContentType},
+		{name: "problem among others", accept: "application/json;q=0.9, application/problem+json", contentType: problemContentType},
+		{name: "problem with a quality", accept: "Application/Problem+JSON; q=0.5", contentType: problemContentType},
+		{name: "problem refused", accept: "application/problem+json;q=0, application/json", contentType: "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/users/42", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			f := &Failure{}
+			f.SetStatusCode(http.StatusNotFound)
+			f.SetError(NewAppError(ErrCodeUserNotFound, "User not found"))
+
+			w := httptest.NewRecorder()
+			f.Write(w, r)
+
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if w.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want 404", w.Code)
+			}
+
+			if tt.contentType == "application/json" {
+				var got Failure
+				if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+					t.Fatalf("decoding body: %v", err)
+				}
+				if got.StatusCode != http.StatusNotFound || got.Code != ErrCodeUserNotFound || got.Message != "User not found" {
+					t.Errorf("failure = %+v, want the user not found envelope", got)
+				}
+				return
+			}
+
+			var got Problem
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			want := Problem{
+				Type:     "urn:imaginai:error:user_not_found",
+				Title:    "Not Found",
+				Status:   http.StatusNotFound,
+				Detail:   "User not found",
+				Instance: "/api/v1/users/42",
+				Code:     ErrCodeUserNotFound,
+			}
+			if got.Type != want.Type || got.Title != want.Title || got.Status != want.Status || got.Detail != want.Detail || got.Instance != want.Instance || got.Code != want.Code {
+				t.Errorf("problem = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFailureWriteMasksInternalErrors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	r.Header.Set("Accept", problemContentType)
+	f := &Failure{}
+	f.SetStatusCode(http.StatusInternalServerError)
+	f.SetError(errors.New("dial tcp 10.0.0.5:5432: connection refused"))
+
+	w := httptest.NewRecorder()
+	f.Write(w, r)
+
+	var got Problem
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if got.Detail != genericInternalMessage || got.Code != ErrCodeInternal || got.Type != "urn:imaginai:error:internal_error" {
+		t.Errorf("problem = %+v, want the generic internal error", got)
+	}
+}
+
+func TestFailureDefaults(t *testing.T) {
+	tests := []struct {
+		status int
+		want   int
+		code   ErrorCode
+	}{
+		{status: 0, want: http.StatusInternalServerError, code: ErrCodeInternal},
+		{status: http.StatusTooManyRequests, want: http.StatusTooManyRequests, code: "TOO_MANY_REQUESTS"},
+		{status: http.StatusBadGateway, want: http.StatusBadGateway, code: ErrCodeInternal},
+	}
+
+	for _, tt := range tests {
+		f := &Failure{StatusCode: tt.status, Message: "Failed"}
+		w := httptest.NewRecorder()
+		f.JSON(w)
+
+		var got Failure
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("decoding body: %v", err)
+		}
+		if w.Code != tt.want || got.Code != tt.code {
+			t.Errorf("failure of status %d = %d %s, want %d %s", tt.status, w.Code, got.Code, tt.want, tt.code)
+		}
+	}
+}
@@ -1,11 +1,34 @@
 package types
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Roles lists every role a user can be granted
+var Roles = []string{RoleUser, RoleAdmin}
+
+// A user's plan decides which models of the chat-bot's catalog a user may chat with
+const (
+	PlanFree = "free"
+	PlanPro  = "pro"
+)
+
+// Plans lists every plan a user can be on
+var Plans = []string{PlanFree, PlanPro}
+
 type User struct {
 	ID           string `json:"id"`
 	Name         string `json:"name"`
 	Email        string `json:"email"`
 	Password     string `json:"password"`
 	GeminiAPIKey string `json:"gemini_api_key"`
+	Role         string `json:"role"`
+	Language     string `json:"language"`
+	Disabled     bool   `json:"disabled"`
+	Plan         string `json:"plan"`
+	DefaultModel string `json:"default_model"`
+	TokenVersion int    `json:"-"`
 }
 
 type UserResponse struct {
@@ -15,6 +38,7 @@ type UserResponse struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
 	GeminiAPIKey string `json:"gemini_api_key"`
+	Language     string `json:"language"`
 }
 
 type UserSafeResponse struct {
@@ -22,6 +46,22 @@ type UserSafeResponse struct {
 	Name         string `json:"name"`
 	Email        string `json:"email"`
 	GeminiAPIKey string `json:"gemini_api_key"`
+	Role         string `json:"role"`
+	Language     string `json:"language"`
+	Disabled     bool   `json:"disabled"`
+	Plan         string `json:"plan"`
+	DefaultModel string `json:"default_model"`
+}
+
+// UpdateUserBody is the request body for updating a user's profile
+type UpdateUserBody struct {
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	GeminiAPIKey string `json:"gemini_api_key"`
+	Language     string `json:"language"`
+	// DefaultModel is left unchanged when omitted, an empty string resets it
+	// to the chat-bot's default
+	DefaultModel *string `json:"default_model"`
 }
 
 func (u *User) ToUserResponse() *UserResponse {
@@ -38,6 +78,11 @@ func (u *User) ToUserSafeResponse() *UserSafeResponse {
 		ID:           u.ID,
 		Name:         u.Name,
 		Email:        u.Email,
+		Role:         u.Role,
+		Language:     u.Language,
+		Disabled:     u.Disabled,
+		Plan:         u.Plan,
+		DefaultModel: u.DefaultModel,
 	}
 }
 
@@ -49,5 +94,6 @@ func (u *User) ToUserResponseWithTokens(token string, refreshToken string) *User
 		Token:        token,
 		RefreshToken: refreshToken,
 		GeminiAPIKey: u.GeminiAPIKey,
+		Language:     u.Language,
 	}
 }
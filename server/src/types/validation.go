@@ -0,0 +1,209 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	helpers "github.com/Mahaveer86619/ImaginAI/src/helpers"
+
+	"github.com/google/uuid"
+)
+
+var languageTag = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+// modelID matches the ids of the chat-bot's models, e.g. gemini-2.5-flash
+var modelID = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+const (
+	maxNameLength         = 100
+	maxGeminiAPIKeyLength = 256
+	maxModelIDLength      = 100
+)
+
+// Validator collects field errors so that every problem is reported at once.
+type Validator struct {
+	errors []FieldError
+}
+
+// Check records a field error when ok is false.
+func (v *Validator) Check(ok bool, field string, code string, message string) {
+	if !ok {
+		v.errors = append(v.errors, FieldError{Field: field, Code: code, Message: message})
+	}
+}
+
+// Required records an error when value is empty.
+func (v *Validator) Required(field string, value string) bool {
+	v.Check(value != "", field, "required", fmt.Sprintf("%s is required", field))
+	return value != ""
+}
+
+// MaxLength records an error when value is longer than max characters.
+func (v *Validator) MaxLength(field string, value string, max int) {
+	v.Check(len([]rune(value)) <= max, field, "too_long", fmt.Sprintf("%s must be at most %d characters long", field, max))
+}
+
+// Email records an error when value is missing or not a valid email address.
+func (v *Validator) Email(field string, value string) {
+	if v.Required(field, value) {
+		v.Check(helpers.IsValidEmail(value), field, "invalid_email", fmt.Sprintf("%s must be a valid email address", field))
+	}
+}
+
+// UUID records an error when value is missing or not a valid UUID.
+func (v *Validator) UUID(field string, value string) {
+	if v.Required(field, value) {
+		_, err := uuid.Parse(value)
+		v.Check(err == nil, field, "invalid_uuid", fmt.Sprintf("%s must be a valid UUID", field))
+	}
+}
+
+// Password records an error for every rule of the configured password policy that value breaks.
+func (v *Validator) Password(field string, value string) {
+	if !v.Required(field, value) {
+		return
+	}
+	for _, problem := range helpers.GetPasswordPolicy().Check(value) {
+		v.Check(false, field, "weak_password", fmt.Sprintf("%s %s", field, problem))
+	}
+}
+
+// OneOf records an error when value is not one of allowed.
+func (v *Validator) OneOf(field string, value string, allowed []string) {
+	v.Check(slices.Contains(allowed, value), field, "invalid_choice", fmt.Sprintf("%s must be one of %s", field, strings.Join(allowed, ", ")))
+}
+
+// Language records an error when value is set but is not a language tag such as "en" or "es-mx".
+func (v *Validator) Language(field string, value string) {
+	if value != "" {
+		v.Check(languageTag.MatchString(value), field, "invalid_language", fmt.Sprintf("%s must be a language tag such as en or es-MX", field))
+	}
+}
+
+// Errors returns the collected field errors.
+func (v *Validator) Errors() []FieldError {
+	return v.errors
+}
+
+// Err returns a VALIDATION_FAILED error when any check failed, nil otherwise.
+func (v *Validator) Err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return NewValidationError(v.errors)
+}
+
+// ValidateID checks an identifier taken from the URL.
+func ValidateID(id string) error {
+	v := &Validator{}
+	v.UUID("id", id)
+	return v.Err()
+}
+
+func (c *AuthenticatingCredentials) Normalize() {
+	c.Email = helpers.NormalizeEmail(c.Email)
+}
+
+func (c *AuthenticatingCredentials) Validate() error {
+	v := &Validator{}
+	v.Email("email", c.Email)
+	v.Required("password", c.Password)
+	return v.Err()
+}
+
+func (c *RegisteringCredentials) Normalize() {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Email = helpers.NormalizeEmail(c.Email)
+	c.GeminiAPIKey = strings.TrimSpace(c.GeminiAPIKey)
+	c.Language = normalizeLanguage(c.Language)
+}
+
+func (c *RegisteringCredentials) Validate() error {
+	v := &Validator{}
+	if v.Required("name", c.Name) {
+		v.MaxLength("name", c.Name, maxNameLength)
+	}
+	v.Email("email", c.Email)
+	v.Password("password", c.Password)
+	v.MaxLength("gemini_api_key", c.GeminiAPIKey, maxGeminiAPIKeyLength)
+	v.Language("language", c.Language)
+	return v.Err()
+}
+
+func (b *SendPassResetCodeBody) Normalize() {
+	b.Email = helpers.NormalizeEmail(b.Email)
+}
+
+func (b *SendPassResetCodeBody) Validate() error {
+	v := &Validator{}
+	v.Email("email", b.Email)
+	return v.Err()
+}
+
+func (b *CheckPassResetCodeBody) Normalize() {
+	b.Email = helpers.NormalizeEmail(b.Email)
+	b.Code = strings.TrimSpace(b.Code)
+}
+
+func (b *CheckPassResetCodeBody) Validate() error {
+	v := &Validator{}
+	v.Email("email", b.Email)
+	if v.Required("code", b.Code) {
+		v.Check(isDigits(b.Code, 6), "code", "invalid_code", "code must be a 6 digit number")
+	}
+	return v.Err()
+}
+
+func (b *RefreshTokenBody) Normalize() {
+	b.RefreshTokenKey = strings.TrimSpace(b.RefreshTokenKey)
+}
+
+func (b *RefreshTokenBody) Validate() error {
+	v := &Validator{}
+	v.Required("refreshTokenKey", b.RefreshTokenKey)
+	return v.Err()
+}
+
+func (u *UpdateUserBody) Normalize() {
+	u.Name = strings.TrimSpace(u.Name)
+	u.Email = helpers.NormalizeEmail(u.Email)
+	u.GeminiAPIKey = strings.TrimSpace(u.GeminiAPIKey)
+	u.Language = normalizeLanguage(u.Language)
+	if u.DefaultModel != nil {
+		model := strings.ToLower(strings.TrimSpace(*u.DefaultModel))
+		u.DefaultModel = &model
+	}
+}
+
+func (u *UpdateUserBody) Validate() error {
+	v := &Validator{}
+	if v.Required("name", u.Name) {
+		v.MaxLength("name", u.Name, maxNameLength)
+	}
+	v.Email("email", u.Email)
+	v.MaxLength("gemini_api_key", u.GeminiAPIKey, maxGeminiAPIKeyLength)
+	v.Language("language", u.Language)
+	if u.DefaultModel != nil && *u.DefaultModel != "" {
+		v.MaxLength("default_model", *u.DefaultModel, maxModelIDLength)
+		v.Check(modelID.MatchString(*u.DefaultModel), "default_model", "invalid_model", "default_model must be a model id such as gemini-2.5-flash")
+	}
+	return v.Err()
+}
+
+func normalizeLanguage(language string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(language), "_", "-"))
+}
+
+func isDigits(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}